@@ -247,7 +247,7 @@ func toTable(gd google_graph.GraphData2D) [][]string {
 	for i := 0; i < xlen; i++ {
 		result[i+1][0] = gd.XLabel(i)
 		for j := 0; j < ylen; j++ {
-			result[i+1][j+1] = fin.FormatUSD(gd.Value(i, j))
+			result[i+1][j+1] = fin.Money(gd.Value(i, j)).Grouped()
 		}
 	}
 	return result
@@ -280,12 +280,12 @@ func buildMessageHtml(
 		Data:         toTable(gd),
 		Link:         grapher.GraphURL2D(gd),
 		MonthStr:     currentMonthName,
-		MonthIncome:  fin.FormatUSD(monthlyBalance.Income),
-		MonthExpense: fin.FormatUSD(monthlyBalance.Expense),
-		MonthNet:     fin.FormatUSD(monthlyBalance.Net()),
-		YTDIncome:    fin.FormatUSD(yearlyBalance.Income),
-		YTDExpense:   fin.FormatUSD(yearlyBalance.Expense),
-		YTDNet:       fin.FormatUSD(yearlyBalance.Net())})
+		MonthIncome:  fin.Money(monthlyBalance.Income).Grouped(),
+		MonthExpense: fin.Money(monthlyBalance.Expense).Grouped(),
+		MonthNet:     fin.Money(monthlyBalance.Net()).Grouped(),
+		YTDIncome:    fin.Money(yearlyBalance.Income).Grouped(),
+		YTDExpense:   fin.Money(yearlyBalance.Expense).Grouped(),
+		YTDNet:       fin.Money(yearlyBalance.Net()).Grouped()})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -8,6 +8,7 @@ import (
 	"github.com/keep94/finance/fin/categories/categoriesdb"
 	"github.com/keep94/finance/fin/findb"
 	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/date_util"
 	"github.com/keep94/toolbox/db"
 	"github.com/keep94/toolbox/http_util"
 	"html/template"
@@ -33,7 +34,15 @@ var (
 <body>
 {{.LeftNav}}
 <div class="main">
-<h2>{{.Account.Name}}</h2>    
+<h2>{{.Account.Name}} ({{.Account.Currency}})</h2>    
+{{if .ShowConverted}}
+  {{with .Converted}}
+    Balance in {{$.Global.Currency}}: {{FormatUSD .Balance}}
+    (reconciled {{FormatUSD .RBalance}})<br><br>
+  {{else}}
+    <span class="error">Balance cannot be shown in {{.Global.Currency}} because exchange rates are missing.</span><br><br>
+  {{end}}
+{{end}}
 {{with $top := .}}
 <a href="{{.NewEntryLink .Account.Id}}">New Entry</a>&nbsp;
 <a href="{{.UploadLink .Account.Id}}">Import Entries</a>&nbsp;
@@ -58,7 +67,7 @@ Page: {{.DisplayPageNo}}
       <tr class="lineitem">
         <td>{{FormatDate .Date}}</td>
         <td>{{range $top.CatLink .CatPayment}}{{if .Link}}<a href="{{.Link}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}{{end}}</td>
-        <td><a href="{{$top.EntryLink .Id}}">{{.Name}}</td>
+        <td><a href="{{$top.EntryLink .Id}}">{{.Name}}</a>{{if .AttachmentCount}} <span title="{{.AttachmentCount}} attachment(s)">&#128206;</span>{{end}}</td>
         <td align=right>{{FormatUSD .Total}}</td>
         <td align=right>{{FormatUSD .Balance}}</td>
       </tr>
@@ -86,10 +95,16 @@ var (
 	kListEntriesUrl = http_util.NewUrl("/fin/list")
 )
 
+type Store interface {
+	findb.EntriesByAccountIdRunner
+	findb.CurrencyConverterRunner
+}
+
 type Handler struct {
 	Doer     db.Doer
-	Store    findb.EntriesByAccountIdRunner
+	Store    Store
 	Cdc      categoriesdb.Getter
+	Clock    date_util.Clock
 	PageSize int
 	Links    bool
 	LN       *common.LeftNav
@@ -110,6 +125,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var morePages bool
 	consumer := goconsume.Page(pageNo, h.PageSize, &entryBalances, &morePages)
 	account := fin.Account{}
+	var converter *fin.CurrencyConverter
 	err := h.Doer.Do(func(t db.Transaction) (err error) {
 		cds, err = h.Cdc.Get(t)
 		if err != nil {
@@ -120,6 +136,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		consumer.Finalize()
+		converter, err = findb.NewCurrencyConverter(
+			t, h.Store, h.Global.Currency)
 		return
 	})
 	if err == findb.NoSuchId {
@@ -130,6 +148,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http_util.ReportError(w, "Error reading database.", err)
 		return
 	}
+	converted, _ := converter.ConvertAccountDeltas(
+		fin.AccountDeltas{
+			account.Id: &fin.AccountDelta{
+				Balance:  account.Balance,
+				RBalance: account.RBalance}},
+		date_util.TimeToDate(h.Clock.Now()))
 	var listEntriesUrl *url.URL
 	if h.Links {
 		listEntriesUrl = kListEntriesUrl
@@ -148,8 +172,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			CatLinker:   common.CatLinker{Cds: cds, ListEntries: listEntriesUrl},
 			EntryLinker: common.EntryLinker{URL: r.URL, Sel: selecter},
 			Account:     accountWrapper{&account},
-			LeftNav:     leftnav,
-			Global:      h.Global})
+			ShowConverted: account.Currency.Normalize() !=
+				h.Global.Currency.Normalize(),
+			Converted: converted[account.Id],
+			LeftNav:   leftnav,
+			Global:    h.Global})
 }
 
 type view struct {
@@ -158,9 +185,14 @@ type view struct {
 	common.AccountLinker
 	common.EntryLinker
 	Account accountWrapper
-	Values  []fin.EntryBalance
-	LeftNav template.HTML
-	Global  *common.Global
+	// true if the account is not in the reporting currency
+	ShowConverted bool
+	// Balances in the reporting currency or nil if exchange rates are
+	// missing.
+	Converted *fin.AccountDelta
+	Values    []fin.EntryBalance
+	LeftNav   template.HTML
+	Global    *common.Global
 }
 
 type accountWrapper struct {
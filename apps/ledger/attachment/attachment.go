@@ -0,0 +1,184 @@
+package attachment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/toolbox/db"
+	"github.com/keep94/toolbox/http_util"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	kAttachment = "attachment"
+	// Attachments larger than this are rejected.
+	kMaxAttachmentSize = 10 * 1024 * 1024
+	// Multipart form data above this size goes to temporary files.
+	kMaxMemory = 1024 * 1024
+)
+
+var (
+	errTooLarge    = errors.New("Attachments must be 10 MB or less.")
+	errMissingFile = errors.New("Please choose a file to attach.")
+	errNoEntry     = errors.New("No entry found to attach to.")
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.EntryByIdRunner
+	findb.AddAttachmentRunner
+	findb.AttachmentByIdRunner
+	findb.RemoveAttachmentRunner
+}
+
+// Handler downloads, uploads, and deletes attachments on entries.
+// A GET downloads the attachment with the given id. A multipart POST
+// uploads the contents parameter as a new attachment of entry eid.
+// Any other POST deletes the attachment with the given id.
+// After a POST, Handler redirects to the prev parameter or to the
+// entry if prev is missing.
+type Handler struct {
+	Doer db.Doer
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	if r.Method == "GET" {
+		r.ParseForm()
+		h.download(w, r, store)
+		return
+	}
+	var err error
+	if isMultipart(r) {
+		r.Body = http.MaxBytesReader(
+			w, r.Body, kMaxAttachmentSize+kMaxMemory)
+		err = r.ParseMultipartForm(kMaxMemory)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		fmt.Fprintln(w, errTooLarge.Error())
+		return
+	}
+	if !common.VerifyXsrfToken(r, kAttachment) {
+		fmt.Fprintln(w, common.ErrXsrf.Error())
+		return
+	}
+	entryId, _ := strconv.ParseInt(r.Form.Get("eid"), 10, 64)
+	if r.MultipartForm != nil {
+		err = upload(r, h.Doer, store)
+	} else {
+		id, _ := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+		err = store.RemoveAttachment(nil, id)
+	}
+	if err == errTooLarge || err == errMissingFile || err == errNoEntry {
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	if err != nil {
+		http_util.ReportError(w, "Error updating database.", err)
+		return
+	}
+	prev := r.Form.Get("prev")
+	if prev == "" {
+		prev = http_util.NewUrl(
+			"/fin/single", "id", strconv.FormatInt(entryId, 10)).String()
+	}
+	http_util.Redirect(w, r, prev)
+}
+
+func (h *Handler) download(
+	w http.ResponseWriter, r *http.Request, store Store) {
+	id, _ := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+	var attachment fin.Attachment
+	err := store.AttachmentById(nil, id, &attachment)
+	if err == findb.NoSuchId {
+		fmt.Fprintln(w, "No attachment found.")
+		return
+	}
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(attachment.Data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set(
+		"Content-Disposition",
+		mime.FormatMediaType(
+			"attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(attachment.Data)))
+	w.Write(attachment.Data)
+}
+
+// NewXsrfToken returns the xsrf token that pages must include in forms
+// that post to Handler.
+func NewXsrfToken(r *http.Request) string {
+	return common.NewXsrfToken(r, kAttachment)
+}
+
+// DownloadLink returns the link to download the attachment with given id.
+func DownloadLink(id int64) *url.URL {
+	return http_util.NewUrl(
+		"/fin/attachment", "id", strconv.FormatInt(id, 10))
+}
+
+// upload adds the contents parameter as an attachment of the entry with
+// id eid. upload returns errNoEntry if there is no such entry or if it
+// is in the trash. upload checks for the entry and adds the attachment
+// in the same transaction so that no attachment is left without an entry.
+func upload(r *http.Request, doer db.Doer, store Store) error {
+	entryId, err := strconv.ParseInt(r.Form.Get("eid"), 10, 64)
+	if err != nil {
+		return errNoEntry
+	}
+	file, header, err := r.FormFile("contents")
+	if err != nil {
+		return errMissingFile
+	}
+	defer file.Close()
+	var contents bytes.Buffer
+	limitedReader := io.LimitedReader{R: file, N: kMaxAttachmentSize + 1}
+	if _, err := contents.ReadFrom(&limitedReader); err != nil {
+		return err
+	}
+	if limitedReader.N == 0 {
+		return errTooLarge
+	}
+	attachment := fin.Attachment{
+		EntryId:     entryId,
+		Name:        filepath.Base(header.Filename),
+		ContentType: header.Header.Get("Content-Type"),
+		Data:        contents.Bytes()}
+	if attachment.ContentType == "" {
+		attachment.ContentType = http.DetectContentType(attachment.Data)
+	}
+	return doer.Do(func(t db.Transaction) error {
+		var entry fin.Entry
+		err := store.EntryById(t, entryId, &entry)
+		if err == findb.NoSuchId {
+			return errNoEntry
+		}
+		if err != nil {
+			return err
+		}
+		return store.AddAttachment(t, &attachment)
+	})
+}
+
+func isMultipart(r *http.Request) bool {
+	return strings.HasPrefix(
+		r.Header.Get("Content-Type"), "multipart/form-data")
+}
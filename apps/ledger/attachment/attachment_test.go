@@ -0,0 +1,128 @@
+package attachment
+
+import (
+	"bytes"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/finance/fin/findb/for_memory"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestUpload(t *testing.T) {
+	doer, store := newStore(t)
+	entry := addEntry(t, store)
+	if err := upload(newRequest(t, strconv.FormatInt(entry.Id, 10)), doer, store); err != nil {
+		t.Fatalf("Got error uploading: %v", err)
+	}
+	verifyAttachmentCount(t, store, entry.Id, 1)
+}
+
+func TestUploadBadEntryId(t *testing.T) {
+	doer, store := newStore(t)
+	entry := addEntry(t, store)
+	if err := upload(newRequest(t, "abc"), doer, store); err != errNoEntry {
+		t.Errorf("Expected errNoEntry, got %v", err)
+	}
+	verifyAttachmentCount(t, store, entry.Id, 0)
+}
+
+func TestUploadMissingEntryId(t *testing.T) {
+	doer, store := newStore(t)
+	entry := addEntry(t, store)
+	if err := upload(newRequest(t, ""), doer, store); err != errNoEntry {
+		t.Errorf("Expected errNoEntry, got %v", err)
+	}
+	verifyAttachmentCount(t, store, entry.Id, 0)
+}
+
+func TestUploadNoSuchEntry(t *testing.T) {
+	doer, store := newStore(t)
+	if err := upload(newRequest(t, "9999"), doer, store); err != errNoEntry {
+		t.Errorf("Expected errNoEntry, got %v", err)
+	}
+	var attachments []fin.Attachment
+	store.AttachmentsByEntryId(nil, 9999, goconsume.AppendTo(&attachments))
+	if len(attachments) != 0 {
+		t.Errorf("Expected no attachments, got %v", attachments)
+	}
+}
+
+func TestUploadTrashedEntry(t *testing.T) {
+	doer, store := newStore(t)
+	entry := addEntry(t, store)
+	err := store.DoEntryChanges(
+		nil, &findb.EntryChanges{Trash: []int64{entry.Id}})
+	if err != nil {
+		t.Fatalf("Got error trashing entry: %v", err)
+	}
+	if err := upload(newRequest(t, strconv.FormatInt(entry.Id, 10)), doer, store); err != errNoEntry {
+		t.Errorf("Expected errNoEntry, got %v", err)
+	}
+	verifyAttachmentCount(t, store, entry.Id, 0)
+}
+
+func newStore(t *testing.T) (db.Doer, for_memory.Store) {
+	mdb := memory_db.New()
+	store := for_memory.New(mdb)
+	if err := store.AddAccount(nil, &fin.Account{Name: "checking", Active: true}); err != nil {
+		t.Fatalf("Got error adding account: %v", err)
+	}
+	return memory_db.NewDoer(mdb), store
+}
+
+func addEntry(t *testing.T, store for_memory.Store) *fin.Entry {
+	entry := &fin.Entry{
+		Date:       date_util.YMD(2015, 10, 1),
+		Name:       "Bookstore",
+		CatPayment: fin.NewCatPayment(fin.Expense, 2500, false, 1)}
+	err := store.DoEntryChanges(
+		nil, &findb.EntryChanges{Adds: []*fin.Entry{entry}})
+	if err != nil {
+		t.Fatalf("Got error adding entry: %v", err)
+	}
+	return entry
+}
+
+// newRequest returns a parsed multipart request that uploads receipt.txt
+// to the entry with id eid.
+func newRequest(t *testing.T, eid string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("eid", eid)
+	part, err := writer.CreateFormFile("contents", "receipt.txt")
+	if err != nil {
+		t.Fatalf("Got error creating form file: %v", err)
+	}
+	part.Write([]byte("Paid in full"))
+	writer.Close()
+	r, err := http.NewRequest("POST", "/fin/attachment", &body)
+	if err != nil {
+		t.Fatalf("Got error creating request: %v", err)
+	}
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := r.ParseMultipartForm(kMaxMemory); err != nil {
+		t.Fatalf("Got error parsing request: %v", err)
+	}
+	return r
+}
+
+func verifyAttachmentCount(
+	t *testing.T, store for_memory.Store, entryId int64, expected int) {
+	t.Helper()
+	var attachments []fin.Attachment
+	err := store.AttachmentsByEntryId(
+		nil, entryId, goconsume.AppendTo(&attachments))
+	if err != nil {
+		t.Fatalf("Got error reading attachments: %v", err)
+	}
+	if len(attachments) != expected {
+		t.Errorf("Expected %d attachments, got %v", expected, attachments)
+	}
+}
@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/finance/fin/findb/sqlite_backup"
+	"github.com/keep94/gosqlite/sqlite"
+	"github.com/keep94/toolbox/db/sqlite_db"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"path/filepath"
+)
+
+const (
+	kBackup = "backup"
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
+<h2>Backups</h2>
+{{if .Permitted}}
+{{if .Configured}}
+Snapshots are kept in {{.Dir}}, newest first.
+<form method="post">
+  <input type="hidden" name="xsrf" value="{{.Xsrf}}">
+  <input type="submit" value="Back up now">
+</form>
+<table>
+{{range .Names}}
+  <tr><td>{{.}}</td></tr>
+{{else}}
+  <tr><td>No snapshots yet.</td></tr>
+{{end}}
+</table>
+{{else}}
+Backups are not set up. Start ledger with the -backup_dir flag.
+{{end}}
+{{end}}
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+var (
+	errNotConfigured = errors.New("Backups are not set up.")
+)
+
+// Handler takes snapshots of the database on demand and lists the
+// snapshots already taken. Only users with fin.AllPermission may see or
+// take snapshots.
+type Handler struct {
+	Db *sqlite_db.Db
+
+	// Where snapshots go. Empty means backups are not set up.
+	Dir string
+
+	// How many snapshots to keep. 0 means keep all of them.
+	Keep int
+
+	Options *sqlite_backup.Options
+	LN      *common.LeftNav
+	Global  *common.Global
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	leftnav := h.LN.Generate(w, r, common.SelectBackup())
+	if leftnav == "" {
+		return
+	}
+	session := common.GetUserSession(r)
+	// The backup directory and snapshot names are for administrators only.
+	if session.User.Permission != fin.AllPermission {
+		http_util.WriteTemplate(
+			w,
+			kTemplate,
+			&view{
+				Error:   findb.NoPermission,
+				LeftNav: leftnav,
+				Global:  h.Global})
+		return
+	}
+	var postErr error
+	var message string
+	if r.Method == "POST" {
+		if !common.VerifyXsrfToken(r, kBackup) {
+			postErr = common.ErrXsrf
+		} else {
+			message, postErr = h.save()
+		}
+	}
+	var names []string
+	if h.Dir != "" {
+		paths, err := sqlite_backup.List(h.Dir)
+		if err != nil {
+			http_util.ReportError(w, "Error reading backup directory.", err)
+			return
+		}
+		for _, path := range paths {
+			names = append(names, filepath.Base(path))
+		}
+	}
+	http_util.WriteTemplate(
+		w,
+		kTemplate,
+		&view{
+			Permitted:  true,
+			Configured: h.Dir != "",
+			Dir:        h.Dir,
+			Names:      names,
+			Error:      postErr,
+			Message:    message,
+			Xsrf:       common.NewXsrfToken(r, kBackup),
+			LeftNav:    leftnav,
+			Global:     h.Global})
+}
+
+func (h *Handler) save() (message string, err error) {
+	if h.Dir == "" {
+		return "", errNotConfigured
+	}
+	// Compressing and encrypting take a while, so do them after giving up
+	// the connection.
+	var c *sqlite_backup.Copy
+	err = h.Db.Do(func(conn *sqlite.Conn) (err error) {
+		c, err = sqlite_backup.NewCopy(conn)
+		return
+	})
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	path, err := c.Save(h.Dir, h.Keep, h.Options)
+	if err != nil {
+		return
+	}
+	return fmt.Sprintf("Wrote %s.", filepath.Base(path)), nil
+}
+
+type view struct {
+	Permitted  bool
+	Configured bool
+	Dir        string
+	Names      []string
+	Error      error
+	Message    string
+	Xsrf       string
+	LeftNav    template.HTML
+	Global     *common.Global
+}
+
+func init() {
+	kTemplate = common.NewTemplate("backup", kTemplateSpec)
+}
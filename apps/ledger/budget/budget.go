@@ -0,0 +1,404 @@
+package budget
+
+import (
+	"errors"
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/aggregators"
+	"github.com/keep94/finance/fin/categories"
+	"github.com/keep94/finance/fin/categories/categoriesdb"
+	"github.com/keep94/finance/fin/consumers"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	kBudget      = "budget"
+	kMonthFormat = "200601"
+	kYearFormat  = "2006"
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
+<form method="get">
+  Month (yyyyMM) or year (yyyy):
+  <input type="text" name="date" value="{{.Get "date"}}" size="8">
+  <input type="submit" value="Show budget">
+</form>
+{{if .PeriodName}}
+<h2>
+  <a href="{{.PrevLink}}">&lt;</a>
+  Budget for {{.PeriodName}}
+  <a href="{{.NextLink}}">&gt;</a>
+</h2>
+Amounts in {{.Global.Currency}}
+<table>
+  <tr>
+    <td>Category</td>
+    <td>Target</td>
+    <td>Carried</td>
+    <td>Actual</td>
+    <td>Remaining</td>
+    <td>&nbsp;</td>
+  </tr>
+{{with $top := .}}
+{{range .Lines}}
+  <tr class="lineitem">
+    <td>{{($top.DetailById .Item.Cat).FullName}}</td>
+    <td align="right">{{FormatUSD .Target}}</td>
+    <td align="right">{{FormatUSD .Carried}}</td>
+    <td align="right">{{FormatUSD .Actual}}</td>
+    <td align="right">{{FormatUSD .Remaining}}</td>
+    <td>{{if .OverBudget}}<span class="negative">Over</span>{{else}}&nbsp;{{end}}</td>
+  </tr>
+{{end}}
+{{end}}
+</table>
+{{end}}
+<h2>Budget items</h2>
+<form method="post">
+  <input type="hidden" name="xsrf" value="{{.Xsrf}}">
+  <input type="hidden" name="date" value="{{.Get "date"}}">
+  <table>
+    <tr>
+      <td>Category</td>
+      <td>Amount</td>
+      <td>Period</td>
+      <td>Rollover</td>
+      <td>Start (yyyyMMdd)</td>
+      <td>&nbsp;</td>
+    </tr>
+    <tr>
+      <td>
+        <select name="cat">
+{{with .GetSelection .CatSelectModel "cat"}}
+          <option value="{{.Value}}">{{.Name}}</option>
+{{end}}
+{{range .CatDetails}}
+          <option value="{{.Id}}">{{.FullName}}</option>
+{{end}}
+        </select>
+      </td>
+      <td><input type="text" name="amount" value="{{.Get "amount"}}" size="12"></td>
+      <td>
+        <select name="period">
+          <option value="0">monthly</option>
+          <option value="1" {{if eq (.Get "period") "1"}}selected{{end}}>yearly</option>
+        </select>
+      </td>
+      <td><input type="checkbox" name="rollover" {{if .Get "rollover"}}checked{{end}}></td>
+      <td><input type="text" name="start" value="{{.Get "start"}}" size="10"></td>
+      <td><input type="submit" name="setitem" value="Set"></td>
+    </tr>
+  </table>
+</form>
+<table>
+  <tr>
+    <td>Category</td>
+    <td>Amount</td>
+    <td>Period</td>
+    <td>Rollover</td>
+    <td>Start</td>
+    <td>&nbsp;</td>
+  </tr>
+{{with $top := .}}
+{{range .Items}}
+  <tr class="lineitem">
+    <td>{{($top.DetailById .Cat).FullName}}</td>
+    <td align="right">{{FormatUSD .Amount}}</td>
+    <td>{{.Period}}</td>
+    <td>{{if .Rollover}}yes{{else}}no{{end}}</td>
+    <td>{{FormatDate .Start}}</td>
+    <td>
+      <form method="post">
+        <input type="hidden" name="xsrf" value="{{$top.Xsrf}}">
+        <input type="hidden" name="date" value="{{$top.Get "date"}}">
+        <input type="hidden" name="bid" value="{{.Id}}">
+        <input type="submit" name="removeitem" value="Remove">
+      </form>
+    </td>
+  </tr>
+{{end}}
+{{end}}
+</table>
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.EntriesRunner
+	findb.CurrencyConverterRunner
+	findb.AddBudgetItemRunner
+	findb.UpdateBudgetItemRunner
+	findb.BudgetItemsRunner
+	findb.RemoveBudgetItemRunner
+}
+
+// Handler shows the budget for a month or year. Budget amounts are in
+// the reporting currency.
+type Handler struct {
+	Doer   db.Doer
+	Cdc    categoriesdb.Getter
+	Clock  date_util.Clock
+	LN     *common.LeftNav
+	Global *common.Global
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	leftnav := h.LN.Generate(w, r, common.SelectBudget())
+	if leftnav == "" {
+		return
+	}
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	cds, _ := h.Cdc.Get(nil)
+	var postErr error
+	var message string
+	if r.Method == "POST" {
+		if !common.VerifyXsrfToken(r, kBudget) {
+			postErr = common.ErrXsrf
+		} else if http_util.HasParam(r.Form, "setitem") {
+			message, postErr = h.setItem(store, r)
+		} else if http_util.HasParam(r.Form, "removeitem") {
+			message, postErr = h.removeItem(store, r)
+		}
+	}
+	dateStr := r.Form.Get("date")
+	if dateStr == "" {
+		dateStr = date_util.TimeToDate(h.Clock.Now()).Format(kMonthFormat)
+	}
+	start, end, yearly, err := parsePeriod(dateStr)
+	if err != nil && postErr == nil {
+		postErr = err
+	}
+	var items []*fin.BudgetItem
+	var lines []*fin.BudgetLine
+	var missingRates error
+	err = h.Doer.Do(func(t db.Transaction) (err error) {
+		if err = store.BudgetItems(t, goconsume.AppendPtrsTo(&items)); err != nil {
+			return
+		}
+		if start.IsZero() {
+			return
+		}
+		var converter *fin.CurrencyConverter
+		converter, err = findb.NewCurrencyConverter(
+			t, store, h.Global.Currency)
+		if err != nil {
+			return
+		}
+		totaler := aggregators.NewBudgetTotaler(start, end)
+		erc := &consumers.ConvertCurrency{
+			Converter:     converter,
+			EntryConsumer: consumers.FromEntryAggregator(totaler)}
+		queryStart := aggregators.BudgetStart(items, start)
+		elo := findb.EntryListOptions{Start: &queryStart, End: &end}
+		if err = store.Entries(t, &elo, erc); err != nil {
+			return
+		}
+		if erc.Err != nil {
+			missingRates = common.ErrMissingExchangeRate
+		}
+		lines = totaler.Lines(items, cds)
+		return
+	})
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	if postErr == nil {
+		postErr = missingRates
+	}
+	sortItems(cds, items)
+	sortLines(cds, lines)
+	values := http_util.Values{Values: url.Values{"date": {dateStr}}}
+	if postErr != nil && r.Method == "POST" {
+		values = http_util.Values{Values: r.Form}
+	}
+	v := &view{
+		Values:       values,
+		CatDisplayer: common.CatDisplayer{CatDetailStore: cds},
+		CatDetails:   cds.ActiveCatDetails(false),
+		Items:        items,
+		Lines:        lines,
+		Error:        postErr,
+		Message:      message,
+		Xsrf:         common.NewXsrfToken(r, kBudget),
+		LeftNav:      leftnav,
+		Global:       h.Global}
+	if !start.IsZero() {
+		v.setPeriod(start, yearly)
+	}
+	http_util.WriteTemplate(w, kTemplate, v)
+}
+
+func (h *Handler) setItem(store Store, r *http.Request) (
+	message string, err error) {
+	var item fin.BudgetItem
+	if item.Cat, err = fin.CatFromString(r.Form.Get("cat")); err != nil {
+		err = errors.New("Category required.")
+		return
+	}
+	if item.Cat.Type == fin.AccountCat {
+		err = errors.New("Accounts cannot have a budget.")
+		return
+	}
+	item.Amount, err = fin.ParseUSD(r.Form.Get("amount"))
+	if err != nil || item.Amount <= 0 {
+		err = errors.New("Amount must be positive.")
+		return
+	}
+	period, _ := strconv.Atoi(r.Form.Get("period"))
+	var ok bool
+	if item.Period, ok = fin.ToBudgetPeriod(period); !ok {
+		err = errors.New("Invalid period.")
+		return
+	}
+	item.Rollover = r.Form.Get("rollover") != ""
+	if startStr := r.Form.Get("start"); startStr != "" {
+		item.Start, err = time.Parse(
+			date_util.YMDFormat, common.NormalizeYMDStr(startStr))
+		if err != nil {
+			err = errors.New("Start must be in yyyyMMdd format.")
+			return
+		}
+	}
+	err = h.Doer.Do(func(t db.Transaction) error {
+		var items []*fin.BudgetItem
+		if err := store.BudgetItems(t, goconsume.AppendPtrsTo(&items)); err != nil {
+			return err
+		}
+		for _, existing := range items {
+			if existing.Cat == item.Cat {
+				item.Id = existing.Id
+				if item.Start.IsZero() {
+					item.Start = existing.Start
+				}
+				break
+			}
+		}
+		// Without a start date, rollover would reach back through all
+		// history, so it starts with the current month.
+		if item.Rollover && item.Start.IsZero() {
+			item.Start = aggregators.Monthly().Normalize(
+				date_util.TimeToDate(h.Clock.Now()))
+		}
+		if item.Id != 0 {
+			return store.UpdateBudgetItem(t, &item)
+		}
+		return store.AddBudgetItem(t, &item)
+	})
+	if err != nil {
+		return
+	}
+	return "Budget item saved.", nil
+}
+
+func (h *Handler) removeItem(
+	store findb.RemoveBudgetItemRunner,
+	r *http.Request) (message string, err error) {
+	bid, _ := strconv.ParseInt(r.Form.Get("bid"), 10, 64)
+	if err = store.RemoveBudgetItem(nil, bid); err != nil {
+		return
+	}
+	return "Budget item removed.", nil
+}
+
+// parsePeriod parses yyyyMM as a month or yyyy as a year.
+func parsePeriod(s string) (
+	start, end time.Time, yearly bool, err error) {
+	switch len(s) {
+	case len(kMonthFormat):
+		if start, err = time.Parse(kMonthFormat, s); err == nil {
+			start = date_util.YMD(start.Year(), int(start.Month()), 1)
+			return start, start.AddDate(0, 1, 0), false, nil
+		}
+	case len(kYearFormat):
+		if start, err = time.Parse(kYearFormat, s); err == nil {
+			start = date_util.YMD(start.Year(), 1, 1)
+			return start, start.AddDate(1, 0, 0), true, nil
+		}
+	}
+	return time.Time{}, time.Time{}, false, errors.New(
+		"Date must be in yyyyMM or yyyy format.")
+}
+
+func sortItems(cds categories.CatDetailStore, items []*fin.BudgetItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return cds.DetailById(items[i].Cat).FullName() <
+			cds.DetailById(items[j].Cat).FullName()
+	})
+}
+
+func sortLines(cds categories.CatDetailStore, lines []*fin.BudgetLine) {
+	sort.SliceStable(lines, func(i, j int) bool {
+		return cds.DetailById(lines[i].Item.Cat).FullName() <
+			cds.DetailById(lines[j].Item.Cat).FullName()
+	})
+}
+
+type view struct {
+	http_util.Values
+	common.CatDisplayer
+	CatDetails []categories.CatDetail
+	Items      []*fin.BudgetItem
+	Lines      []*fin.BudgetLine
+	PeriodName string
+	PrevLink   *url.URL
+	NextLink   *url.URL
+	Error      error
+	Message    string
+	Xsrf       string
+	LeftNav    template.HTML
+	Global     *common.Global
+}
+
+func (v *view) setPeriod(start time.Time, yearly bool) {
+	format := kMonthFormat
+	prev, next := start.AddDate(0, -1, 0), start.AddDate(0, 1, 0)
+	v.PeriodName = start.Format("January 2006")
+	if yearly {
+		format = kYearFormat
+		prev, next = start.AddDate(-1, 0, 0), start.AddDate(1, 0, 0)
+		v.PeriodName = start.Format(kYearFormat)
+	}
+	v.PrevLink = http_util.NewUrl("/fin/budget", "date", prev.Format(format))
+	v.NextLink = http_util.NewUrl("/fin/budget", "date", next.Format(format))
+}
+
+func init() {
+	kTemplate = common.NewTemplate("budget", kTemplateSpec)
+}
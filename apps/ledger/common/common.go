@@ -35,8 +35,25 @@ var (
 
 var (
 	ErrXsrf = errors.New("Page had grown stale. Please resubmit.")
+
+	// Error for entries left out of a report because of missing exchange
+	// rates.
+	ErrMissingExchangeRate = errors.New(
+		"Some entries were left out because they are missing exchange rates.")
+
+	// Error for a tag in a search form that is not a valid tag.
+	ErrInvalidTag = errors.New(
+		"Tag must be a single word with at least one letter or digit.")
 )
 
+// CheckTag returns ErrInvalidTag if tag is neither empty nor a valid tag.
+func CheckTag(tag string) error {
+	if tag != "" && fin.NormalizeTag(tag) == "" {
+		return ErrInvalidTag
+	}
+	return nil
+}
+
 type RecurringUnitComboBoxType []fin.RecurringUnit
 
 func (r RecurringUnitComboBoxType) ToSelection(s string) *http_util.Selection {
@@ -55,7 +72,8 @@ var (
 	// Represents the combo box for recurring units.
 	// Implements http_util.SelectionModel
 	RecurringUnitComboBox = RecurringUnitComboBoxType{
-		fin.Months, fin.Years, fin.Days, fin.Weeks}
+		fin.Months, fin.Years, fin.Days, fin.Weeks,
+		fin.MonthEnds, fin.MonthWeekdays, fin.HalfMonths, fin.MonthBusinessEnds}
 )
 
 // NewGorillaSession creates a gorilla session for the finance app
@@ -356,6 +374,9 @@ type Global struct {
 
 	// True if website has an icon at /images/favicon.ico
 	Icon bool
+
+	// The currency in which reports show amounts
+	Currency fin.Currency
 }
 
 // CatDisplayer is used to display categories.
@@ -404,7 +425,7 @@ func NewTemplate(name, templateStr string) *template.Template {
 		template.FuncMap{
 			"FormatDate":   formatDate,
 			"FormatUSD":    formatUSD,
-			"FormatUSDRaw": fin.FormatUSD}).Parse(templateStr))
+			"FormatUSDRaw": formatUSDRaw}).Parse(templateStr))
 }
 
 func formatUSD(amt int64) template.HTML {
@@ -413,9 +434,13 @@ func formatUSD(amt int64) template.HTML {
 	positiveTemplate := `
       <span class="positive">%s</span>`
 	if amt < 0 {
-		return template.HTML(fmt.Sprintf(negTemplate, fin.FormatUSD(-amt)))
+		return template.HTML(fmt.Sprintf(negTemplate, fin.Money(-amt).Grouped()))
 	}
-	return template.HTML(fmt.Sprintf(positiveTemplate, fin.FormatUSD(amt)))
+	return template.HTML(fmt.Sprintf(positiveTemplate, fin.Money(amt).Grouped()))
+}
+
+func formatUSDRaw(amt int64) string {
+	return fin.Money(amt).Grouped()
 }
 
 func accountLink(id int64) *url.URL {
@@ -36,6 +36,19 @@ func TestSessionBatch(t *testing.T) {
 	}
 }
 
+func TestCheckTag(t *testing.T) {
+	for _, tag := range []string{"", "Vacation", " tax-deductible "} {
+		if err := CheckTag(tag); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", tag, err)
+		}
+	}
+	for _, tag := range []string{"summer vacation", "!!", "a,b"} {
+		if err := CheckTag(tag); err != ErrInvalidTag {
+			t.Errorf("Expected ErrInvalidTag for %q, got %v", tag, err)
+		}
+	}
+}
+
 type batchForTesting struct {
 	acctId int64
 }
@@ -43,11 +43,26 @@ Accounts:
 {{else}}
   <a href="{{.TrendUrl}}">Trends</a><br>
 {{end}}
+{{if .Budget}}
+  <span class="selected">Budget</span><br>
+{{else}}
+  <a href="/fin/budget">Budget</a><br>
+{{end}}
 {{if .Totals}}
   <span class="selected">Totals</span><br>
 {{else}}
   <a href="/fin/totals">Totals</a><br>
 {{end}}
+{{if .NetWorth}}
+  <span class="selected">Net Worth</span><br>
+{{else}}
+  <a href="/fin/networth">Net Worth</a><br>
+{{end}}
+{{if .Forecast}}
+  <span class="selected">Forecast</span><br>
+{{else}}
+  <a href="/fin/forecast">Forecast</a><br>
+{{end}}
 <br>
 {{if .Search}}
   <span class="selected">Search</span><br>
@@ -59,6 +74,11 @@ Accounts:
 {{else}}
   <a href="/fin/unreviewed">Review</a><br>
 {{end}}
+{{if .Trash}}
+  <span class="selected">Trash</span><br>
+{{else}}
+  <a href="/fin/trash">Trash</a><br>
+{{end}}
 {{if .Manage}}
   <span class="selected">Manage Categories</span><br>
 {{else}}
@@ -69,11 +89,31 @@ Accounts:
 {{else}}
   <a href="/fin/recurringlist">Recurring</a><br>
 {{end}}
+{{if .Templates}}
+  <span class="selected">Templates</span><br>
+{{else}}
+  <a href="/fin/templates">Templates</a><br>
+{{end}}
 {{if .Export}}
   <span class="selected">Export</span><br>
 {{else}}
   <a href="/fin/export">Export</a><br>
 {{end}}
+{{if .Currencies}}
+  <span class="selected">Currencies</span><br>
+{{else}}
+  <a href="/fin/currencies">Currencies</a><br>
+{{end}}
+{{if .LockDate}}
+  <span class="selected">Lock Dates</span><br>
+{{else}}
+  <a href="/fin/lockdate">Lock Dates</a><br>
+{{end}}
+{{if .Backup}}
+  <span class="selected">Backups</span><br>
+{{else}}
+  <a href="/fin/backup">Backups</a><br>
+{{end}}
 <br>
 {{if .Chpasswd}}
    <span class="selected">Change Password</span><br>
@@ -126,6 +166,14 @@ const (
 	recurring
 	export
 	chpasswd
+	currencies
+	budget
+	trash
+	networth
+	templates
+	forecast
+	lockdate
+	backup
 )
 
 func SelectAccount(id int64) Selecter { return Selecter{cat: accounts, id: id} }
@@ -138,6 +186,14 @@ func SelectManage() Selecter          { return Selecter{cat: manage} }
 func SelectRecurring() Selecter       { return Selecter{cat: recurring} }
 func SelectExport() Selecter          { return Selecter{cat: export} }
 func SelectChpasswd() Selecter        { return Selecter{cat: chpasswd} }
+func SelectCurrencies() Selecter      { return Selecter{cat: currencies} }
+func SelectBudget() Selecter          { return Selecter{cat: budget} }
+func SelectTrash() Selecter           { return Selecter{cat: trash} }
+func SelectNetWorth() Selecter        { return Selecter{cat: networth} }
+func SelectTemplates() Selecter       { return Selecter{cat: templates} }
+func SelectForecast() Selecter        { return Selecter{cat: forecast} }
+func SelectLockDate() Selecter        { return Selecter{cat: lockdate} }
+func SelectBackup() Selecter          { return Selecter{cat: backup} }
 func SelectNone() Selecter            { return Selecter{} }
 
 // LeftNav is for creating the left navigation bar.
@@ -205,6 +261,14 @@ func (v *view) Manage() bool          { return v.sel == SelectManage() }
 func (v *view) Recurring() bool       { return v.sel == SelectRecurring() }
 func (v *view) Export() bool          { return v.sel == SelectExport() }
 func (v *view) Chpasswd() bool        { return v.sel == SelectChpasswd() }
+func (v *view) Currencies() bool      { return v.sel == SelectCurrencies() }
+func (v *view) Budget() bool          { return v.sel == SelectBudget() }
+func (v *view) Trash() bool           { return v.sel == SelectTrash() }
+func (v *view) NetWorth() bool        { return v.sel == SelectNetWorth() }
+func (v *view) Templates() bool       { return v.sel == SelectTemplates() }
+func (v *view) Forecast() bool        { return v.sel == SelectForecast() }
+func (v *view) LockDate() bool        { return v.sel == SelectLockDate() }
+func (v *view) Backup() bool          { return v.sel == SelectBackup() }
 
 func init() {
 	kLeftNavTemplate = NewTemplate("leftnav", kLeftNavTemplateSpec)
@@ -53,6 +53,39 @@ func (v *SingleEntryView) DateMayBeWrong() bool {
 	return v.Error == ErrDateMayBeWrong
 }
 
+// SetEntry sets the name, description, check number, tags, payment and
+// splits of this view to those of entry leaving the other values such
+// as date alone. If entry has no payment, SetEntry leaves payment alone.
+func (v *SingleEntryView) SetEntry(entry *fin.Entry) {
+	v.Set("name", entry.Name)
+	v.Set("desc", entry.Desc)
+	v.Set("checkno", entry.CheckNo)
+	v.Set("tags", entry.Tags.String())
+	if entry.PaymentId() != 0 {
+		v.Set("payment", strconv.FormatInt(entry.PaymentId(), 10))
+	}
+	catrecs := v.SortedCatRecs(entry.CatRecs())
+	for idx, split := range v.Splits {
+		v.Del(split.CatParam())
+		v.Del(split.AmountParam())
+		v.Del(split.CurrencyAmountParam())
+		v.Del(split.ReconcileParam())
+		if idx < len(catrecs) {
+			v.Set(split.CatParam(), catrecs[idx].Cat.String())
+			v.Set(split.AmountParam(), fin.FormatUSD(catrecs[idx].Amount))
+			if catrecs[idx].Currency != "" {
+				v.Set(
+					split.CurrencyAmountParam(),
+					formatCurrencyAmount(
+						catrecs[idx].Currency, catrecs[idx].CurrencyAmount))
+			}
+			if catrecs[idx].Reconciled {
+				v.Set(split.ReconcileParam(), "on")
+			}
+		}
+	}
+}
+
 // EntrySplitType represents the display for a single split of an entry.
 type EntrySplitType int
 
@@ -66,6 +99,13 @@ func (s EntrySplitType) AmountParam() string {
 	return fmt.Sprintf("amount-%d", int(s))
 }
 
+// CurrencyAmountParam returns the name of the parameter for the amount
+// in the currency of the account receiving a cross-currency transfer
+// e.g "EUR 91.50".
+func (s EntrySplitType) CurrencyAmountParam() string {
+	return fmt.Sprintf("curamount-%d", int(s))
+}
+
 // ReconcileParam returns the name of the reconciled parameter for this split
 func (s EntrySplitType) ReconcileParam() string {
 	return fmt.Sprintf("reconciled-%d", int(s))
@@ -93,27 +133,14 @@ func ToSingleEntryView(
 		LeftNav:       leftnav,
 		catPopularity: catPopularity}
 	result.Set("etag", strconv.FormatUint(entry.Etag, 10))
-	result.Set("name", entry.Name)
-	result.Set("desc", entry.Desc)
-	result.Set("checkno", entry.CheckNo)
 	result.Set("date", entry.Date.Format(date_util.YMDFormat))
-	result.Set("payment", strconv.FormatInt(entry.PaymentId(), 10))
 	if entry.Reconciled() {
 		result.Set("reconciled", "on")
 	}
 	if entry.Status != fin.Reviewed {
 		result.Set("need_review", "on")
 	}
-	catrecs := cds.SortedCatRecs(entry.CatRecs())
-	for idx, split := range result.Splits {
-		if idx < len(catrecs) {
-			result.Set(split.CatParam(), catrecs[idx].Cat.String())
-			result.Set(split.AmountParam(), fin.FormatUSD(catrecs[idx].Amount))
-			if catrecs[idx].Reconciled {
-				result.Set(split.ReconcileParam(), "on")
-			}
-		}
-	}
+	result.SetEntry(entry)
 	return result
 }
 
@@ -165,6 +192,7 @@ func EntryMutation(values url.Values) (
 	}
 	desc := values.Get("desc")
 	checkno := values.Get("checkno")
+	tags := fin.ParseTags(values.Get("tags"))
 	paymentId, _ := strconv.ParseInt(values.Get("payment"), 10, 64)
 	if paymentId == 0 {
 		err = errors.New("Missing payment.")
@@ -172,24 +200,8 @@ func EntryMutation(values url.Values) (
 	}
 	cpb := fin.CatPaymentBuilder{}
 	cpb.SetPaymentId(paymentId).SetReconciled(values.Get("reconciled") != "")
-	catrec := fin.CatRec{}
-	for _, split := range entrySplits {
-		cat := fin.NewCat(values.Get(split.CatParam()))
-		amountStr := values.Get(split.AmountParam())
-		if amountStr == "" {
-			break
-		}
-		var amount int64
-		amount, err = fin.ParseUSD(amountStr)
-		if err != nil {
-			err = errors.New(fmt.Sprintf("Invalid amount: %s", amountStr))
-			return
-		}
-		catrec = fin.CatRec{
-			Cat:        cat,
-			Amount:     amount,
-			Reconciled: values.Get(split.ReconcileParam()) != ""}
-		cpb.AddCatRec(catrec)
+	if err = addSplits(values, &cpb, nil); err != nil {
+		return
 	}
 	cp := cpb.Build()
 	needReview := values.Get("need_review") != ""
@@ -198,6 +210,7 @@ func EntryMutation(values url.Values) (
 		p.Name = name
 		p.Desc = desc
 		p.CheckNo = checkno
+		p.Tags = tags
 		p.CatPayment = cp
 		if needReview {
 			if p.Status == fin.Reviewed {
@@ -213,6 +226,112 @@ func EntryMutation(values url.Values) (
 	return
 }
 
+// EntryTemplateFromForm converts the form values from a single entry page
+// into an entry template and returns that template or an error if the
+// form values were invalid. The title of the template is in the
+// template_title parameter. Split amounts ending in % such as 22.5% are
+// percentage based lines. Unlike entries, templates need no payment.
+func EntryTemplateFromForm(values url.Values) (
+	result *fin.EntryTemplate, err error) {
+	title := strings.TrimSpace(values.Get("template_title"))
+	if title == "" {
+		err = errors.New("Template title required.")
+		return
+	}
+	name := values.Get("name")
+	if strings.TrimSpace(name) == "" {
+		err = errors.New("Name required.")
+		return
+	}
+	paymentId, _ := strconv.ParseInt(values.Get("payment"), 10, 64)
+	cpb := fin.CatPaymentBuilder{}
+	cpb.SetPaymentId(paymentId)
+	percents := make(map[fin.Cat]int64)
+	if err = addSplits(values, &cpb, percents); err != nil {
+		return
+	}
+	if len(percents) == 0 {
+		percents = nil
+	}
+	result = &fin.EntryTemplate{
+		Entry: fin.Entry{
+			Name:       name,
+			Desc:       values.Get("desc"),
+			CheckNo:    values.Get("checkno"),
+			Tags:       fin.ParseTags(values.Get("tags")),
+			CatPayment: cpb.Build()},
+		Title:    title,
+		Percents: percents}
+	return
+}
+
+// addSplits adds the splits in the form values to cpb. If percents is
+// non-nil, split amounts ending in % are percentage based lines which
+// addSplits adds to cpb with zero amount and to percents.
+func addSplits(
+	values url.Values,
+	cpb *fin.CatPaymentBuilder,
+	percents map[fin.Cat]int64) error {
+	for _, split := range entrySplits {
+		cat := fin.NewCat(values.Get(split.CatParam()))
+		amountStr := values.Get(split.AmountParam())
+		if amountStr == "" {
+			break
+		}
+		if percents != nil && strings.HasSuffix(amountStr, "%") {
+			// Percents are in hundredths just like amounts are in cents
+			percent, err := fin.ParseUSD(strings.TrimSuffix(amountStr, "%"))
+			if err != nil {
+				return errors.New(fmt.Sprintf("Invalid percent: %s", amountStr))
+			}
+			percents[cat] += percent
+			cpb.AddCatRec(fin.CatRec{Cat: cat})
+			continue
+		}
+		amount, err := fin.ParseUSD(amountStr)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid amount: %s", amountStr))
+		}
+		catrec := fin.CatRec{
+			Cat:        cat,
+			Amount:     amount,
+			Reconciled: values.Get(split.ReconcileParam()) != ""}
+		currencyAmountStr := values.Get(split.CurrencyAmountParam())
+		if strings.TrimSpace(currencyAmountStr) != "" {
+			if cat.Type != fin.AccountCat {
+				return errors.New("Only accounts can have a currency amount.")
+			}
+			catrec.Currency, catrec.CurrencyAmount, err = parseCurrencyAmount(
+				currencyAmountStr)
+			if err != nil {
+				return errors.New(fmt.Sprintf(
+					"Invalid currency amount: %s", currencyAmountStr))
+			}
+		}
+		cpb.AddCatRec(catrec)
+	}
+	return nil
+}
+
+func formatCurrencyAmount(currency fin.Currency, amount int64) string {
+	return fmt.Sprintf("%s %s", currency, fin.FormatUSD(amount))
+}
+
+// parseCurrencyAmount parses strings like "EUR 91.50"
+func parseCurrencyAmount(s string) (
+	currency fin.Currency, amount int64, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		err = errors.New("Currency amount must be currency then amount.")
+		return
+	}
+	if currency, err = fin.ParseCurrency(fields[0]); err != nil {
+		return
+	}
+	amount, err = fin.ParseUSD(fields[1])
+	return
+}
+
 func init() {
 	entrySplits = make([]EntrySplitType, kMaxSplits)
 	for i := range entrySplits {
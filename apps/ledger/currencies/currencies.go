@@ -0,0 +1,266 @@
+package currencies
+
+import (
+	"errors"
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kCurrencies = "currencies"
+)
+
+var (
+	errAccountHasEntries = errors.New(
+		"Only accounts without entries can change currency.")
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
+<h2>Reports are in {{.Global.Currency}}</h2>
+<h2>Account currencies</h2>
+Only accounts without entries can change currency.<br><br>
+<table>
+  <tr>
+    <td>Account</td>
+    <td>Currency</td>
+    <td>&nbsp;</td>
+  </tr>
+{{with $top := .}}
+{{range .Accounts}}
+  <tr>
+    <form method="post">
+      <input type="hidden" name="xsrf" value="{{$top.Xsrf}}">
+      <input type="hidden" name="acctId" value="{{.Id}}">
+      <td>{{.Name}}</td>
+      <td><input type="text" name="currency" value="{{.Currency}}" size="4"></td>
+      <td><input type="submit" name="setcurrency" value="Change"></td>
+    </form>
+  </tr>
+{{end}}
+{{end}}
+</table>
+<h2>Exchange rates</h2>
+<form method="post">
+  <input type="hidden" name="xsrf" value="{{.Xsrf}}">
+  Date: <input type="text" name="date" value="{{.Get "date"}}" size="10">
+  1 <input type="text" name="from" value="{{.Get "from"}}" size="4">
+  = <input type="text" name="rate" value="{{.Get "rate"}}" size="10">
+  <input type="text" name="to" value="{{.Get "to"}}" size="4">
+  <input type="submit" name="addrate" value="Add">
+</form>
+<table>
+  <tr>
+    <td>Date</td>
+    <td>From</td>
+    <td>To</td>
+    <td>Rate</td>
+    <td>&nbsp;</td>
+  </tr>
+{{with $top := .}}
+{{range .Rates}}
+  <tr class="lineitem">
+    <td>{{FormatDate .Date}}</td>
+    <td>{{.From}}</td>
+    <td>{{.To}}</td>
+    <td align="right">{{.Rate}}</td>
+    <td>
+      <form method="post">
+        <input type="hidden" name="xsrf" value="{{$top.Xsrf}}">
+        <input type="hidden" name="rid" value="{{.Id}}">
+        <input type="submit" name="removerate" value="Remove">
+      </form>
+    </td>
+  </tr>
+{{end}}
+{{end}}
+</table>
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.AccountByIdRunner
+	findb.ActiveAccountsRunner
+	findb.UpdateAccountCurrencyRunner
+	findb.AddExchangeRateRunner
+	findb.ExchangeRatesRunner
+	findb.RemoveExchangeRateRunner
+}
+
+type Handler struct {
+	Doer   db.Doer
+	LN     *common.LeftNav
+	Global *common.Global
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	leftnav := h.LN.Generate(w, r, common.SelectCurrencies())
+	if leftnav == "" {
+		return
+	}
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	var postErr error
+	var message string
+	if r.Method == "POST" {
+		if !common.VerifyXsrfToken(r, kCurrencies) {
+			postErr = common.ErrXsrf
+		} else if http_util.HasParam(r.Form, "setcurrency") {
+			message, postErr = h.setCurrency(store, r)
+		} else if http_util.HasParam(r.Form, "addrate") {
+			message, postErr = h.addRate(store, r)
+		} else if http_util.HasParam(r.Form, "removerate") {
+			message, postErr = h.removeRate(store, r)
+		}
+	}
+	var accounts []*fin.Account
+	var rates []*fin.ExchangeRate
+	err := h.Doer.Do(func(t db.Transaction) (err error) {
+		if accounts, err = store.ActiveAccounts(t); err != nil {
+			return
+		}
+		return store.ExchangeRates(t, goconsume.AppendPtrsTo(&rates))
+	})
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	values := http_util.Values{}
+	if postErr != nil {
+		values = http_util.Values{Values: r.Form}
+	}
+	http_util.WriteTemplate(
+		w,
+		kTemplate,
+		&view{
+			Values:   values,
+			Accounts: accounts,
+			Rates:    rates,
+			Error:    postErr,
+			Message:  message,
+			Xsrf:     common.NewXsrfToken(r, kCurrencies),
+			LeftNav:  leftnav,
+			Global:   h.Global})
+}
+
+// setCurrency changes the currency of an account. Because changing the
+// currency does not convert existing amounts, setCurrency refuses to
+// change the currency of an account that has entries.
+func (h *Handler) setCurrency(
+	store Store,
+	r *http.Request) (message string, err error) {
+	acctId, _ := strconv.ParseInt(r.Form.Get("acctId"), 10, 64)
+	currency, err := fin.ParseCurrency(r.Form.Get("currency"))
+	if err != nil {
+		return
+	}
+	err = h.Doer.Do(func(t db.Transaction) error {
+		var account fin.Account
+		if err := store.AccountById(t, acctId, &account); err != nil {
+			return err
+		}
+		if account.Currency.Normalize() == currency {
+			return nil
+		}
+		if account.Count > 0 {
+			return errAccountHasEntries
+		}
+		return store.UpdateAccountCurrency(t, acctId, currency)
+	})
+	if err != nil {
+		return
+	}
+	return "Account currency changed.", nil
+}
+
+func (h *Handler) addRate(
+	store findb.AddExchangeRateRunner,
+	r *http.Request) (message string, err error) {
+	var rate fin.ExchangeRate
+	rate.Date, err = time.Parse(
+		date_util.YMDFormat, common.NormalizeYMDStr(r.Form.Get("date")))
+	if err != nil {
+		err = errors.New("Date must be in yyyyMMdd format.")
+		return
+	}
+	if rate.From, err = fin.ParseCurrency(r.Form.Get("from")); err != nil {
+		return
+	}
+	if rate.To, err = fin.ParseCurrency(r.Form.Get("to")); err != nil {
+		return
+	}
+	if rate.From == rate.To {
+		err = errors.New("From and to currencies must be different.")
+		return
+	}
+	rate.Rate, err = strconv.ParseFloat(
+		strings.TrimSpace(r.Form.Get("rate")), 64)
+	if err != nil || rate.Rate <= 0.0 {
+		err = errors.New("Rate must be a positive number.")
+		return
+	}
+	if err = store.AddExchangeRate(nil, &rate); err != nil {
+		return
+	}
+	return "Exchange rate added.", nil
+}
+
+func (h *Handler) removeRate(
+	store findb.RemoveExchangeRateRunner,
+	r *http.Request) (message string, err error) {
+	rid, _ := strconv.ParseInt(r.Form.Get("rid"), 10, 64)
+	if err = store.RemoveExchangeRate(nil, rid); err != nil {
+		return
+	}
+	return "Exchange rate removed.", nil
+}
+
+type view struct {
+	http_util.Values
+	Accounts []*fin.Account
+	Rates    []*fin.ExchangeRate
+	Error    error
+	Message  string
+	Xsrf     string
+	LeftNav  template.HTML
+	Global   *common.Global
+}
+
+func init() {
+	kTemplate = common.NewTemplate("currencies", kTemplateSpec)
+}
@@ -0,0 +1,249 @@
+package forecast
+
+import (
+	"errors"
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories/categoriesdb"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Default number of months to forecast
+	kDefaultMonths = 3
+	kMaxMonths     = 24
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{with $top := .}}
+<form method="get">
+  Months: <input type="text" name="months" value="{{.Get "months"}}" size="4">
+  Low balance: <input type="text" name="low" value="{{.Get "low"}}" size="10">
+  <input type="submit" value="Forecast">
+<h2>Forecast through {{FormatDate .End}}</h2>
+{{range .Forecast.Accounts}}
+  {{if .Low}}
+  <span class="error">{{.Account.Name}} falls below {{FormatUSD $top.LowBalance}} on {{FormatDate .LowBalanceDate}}.</span><br>
+  {{end}}
+{{end}}
+<table>
+  <tr>
+    <td>Date</td>
+  {{range .Forecast.Accounts}}
+    <td>{{.Account.Name}}</td>
+  {{end}}
+  </tr>
+  {{range .Rows}}
+  <tr class="lineitem">
+    <td>{{FormatDate .Date}}</td>
+    {{range .Balances}}
+    <td align=right>{{FormatUSD .}}</td>
+    {{end}}
+  </tr>
+  {{end}}
+</table>
+<h2>Recurring entries</h2>
+Check the entries to leave out of the forecast.
+<table>
+  <tr>
+    <td>Exclude</td>
+    <td>Next date</td>
+    <td>Name</td>
+    <td>Amount</td>
+    <td>Account</td>
+    <td>Period</td>
+  </tr>
+  {{range .RecurringEntries}}
+  <tr class="lineitem">
+    <td><input type="checkbox" name="x" value="{{.Id}}" {{if $top.Excluded .Id}}checked{{end}}></td>
+    <td>{{FormatDate ($top.NextDate .)}}</td>
+    <td>{{.Name}}</td>
+    <td align=right>{{FormatUSD .Total}}</td>
+    <td>{{$top.AcctName .CatPayment}}</td>
+    <td>{{.Period}}</td>
+  </tr>
+  {{end}}
+</table>
+<input type="submit" value="Forecast">
+</form>
+{{end}}
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.ActiveAccountsRunner
+	findb.RecurringEntriesRunner
+}
+
+// Handler projects the balances of the active accounts from the recurring
+// entries. The x parameters are the ids of recurring entries to leave out
+// of the forecast.
+type Handler struct {
+	Cdc   categoriesdb.Getter
+	Doer  db.Doer
+	Clock date_util.Clock
+	// Holidays for business day adjustment. May be nil.
+	Holidays fin.HolidayCalendar
+	LN       *common.LeftNav
+	Global   *common.Global
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	leftnav := h.LN.Generate(w, r, common.SelectForecast())
+	if leftnav == "" {
+		return
+	}
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	var formErr error
+	months := kDefaultMonths
+	if monthsStr := r.Form.Get("months"); monthsStr != "" {
+		var err error
+		months, err = strconv.Atoi(monthsStr)
+		if err != nil || months < 1 || months > kMaxMonths {
+			formErr = errors.New("Months must be between 1 and 24.")
+			months = kDefaultMonths
+		}
+	}
+	var lowBalance int64
+	if lowStr := r.Form.Get("low"); lowStr != "" {
+		var err error
+		if lowBalance, err = fin.ParseUSD(lowStr); err != nil {
+			formErr = errors.New("Low balance must be an amount.")
+		}
+	}
+	excluded := make(map[int64]bool)
+	for _, idStr := range r.Form["x"] {
+		id, _ := strconv.ParseInt(idStr, 10, 64)
+		excluded[id] = true
+	}
+	var accounts []*fin.Account
+	var recurringEntries []*fin.RecurringEntry
+	err := h.Doer.Do(func(t db.Transaction) (err error) {
+		if accounts, err = store.ActiveAccounts(t); err != nil {
+			return
+		}
+		return store.RecurringEntries(
+			t, goconsume.AppendPtrsTo(&recurringEntries))
+	})
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	var included []*fin.RecurringEntry
+	for _, entry := range recurringEntries {
+		if !excluded[entry.Id] {
+			included = append(included, entry)
+		}
+	}
+	start := date_util.TimeToDate(h.Clock.Now())
+	end := start.AddDate(0, months, 0)
+	forecast := fin.NewForecast(
+		accounts, included, start, end, h.Holidays, lowBalance)
+	cds, _ := h.Cdc.Get(nil)
+	http_util.WriteTemplate(
+		w,
+		kTemplate,
+		&view{
+			Values:           http_util.Values{Values: r.Form},
+			CatDisplayer:     common.CatDisplayer{CatDetailStore: cds},
+			Forecast:         forecast,
+			Rows:             changedRows(forecast),
+			End:              end,
+			LowBalance:       lowBalance,
+			RecurringEntries: recurringEntries,
+			excluded:         excluded,
+			holidays:         h.Holidays,
+			Error:            formErr,
+			LeftNav:          leftnav,
+			Global:           h.Global})
+}
+
+type row struct {
+	Date time.Time
+	// Balances in the same order as the accounts of the forecast
+	Balances []int64
+}
+
+// changedRows returns the first and last day of forecast along with
+// every day in between on which a balance changes.
+func changedRows(forecast *fin.Forecast) []row {
+	var result []row
+	days := forecast.Days()
+	for day := 0; day < days; day++ {
+		changed := day == 0 || day == days-1
+		balances := make([]int64, len(forecast.Accounts))
+		for i, account := range forecast.Accounts {
+			balances[i] = account.Balances[day]
+			if day > 0 && account.Balances[day] != account.Balances[day-1] {
+				changed = true
+			}
+		}
+		if changed {
+			result = append(
+				result, row{Date: forecast.Date(day), Balances: balances})
+		}
+	}
+	return result
+}
+
+type view struct {
+	http_util.Values
+	common.CatDisplayer
+	Forecast         *fin.Forecast
+	Rows             []row
+	End              time.Time
+	LowBalance       int64
+	RecurringEntries []*fin.RecurringEntry
+	excluded         map[int64]bool
+	holidays         fin.HolidayCalendar
+	Error            error
+	LeftNav          template.HTML
+	Global           *common.Global
+}
+
+// Excluded returns true if the recurring entry with given id is left out
+// of the forecast.
+func (v *view) Excluded(id int64) bool {
+	return v.excluded[id]
+}
+
+// NextDate returns the date of the next entry that entry generates.
+func (v *view) NextDate(entry *fin.RecurringEntry) time.Time {
+	return entry.NextDate(v.holidays)
+}
+
+func init() {
+	kTemplate = common.NewTemplate("forecast", kTemplateSpec)
+}
@@ -0,0 +1,287 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/db"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	kHistory = "history"
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
+<h2>History</h2>
+{{if .Current}}
+<a href="{{.EntryLink .Current.Id}}">{{.Current.Name}}</a>
+{{else}}
+This entry has been deleted.
+{{end}}
+<br><br>
+{{with $top := .}}
+<table>
+  <tr>
+    <td>When</td>
+    <td>User</td>
+    <td>Change</td>
+    <td>Date</td>
+    <td>Category</td>
+    <td>Name</td>
+    <td>Amount</td>
+    <td>Account</td>
+    <td>&nbsp;</td>
+  </tr>
+  {{range $index, $revision := .Revisions}}
+  <tr class="lineitem">
+    <td>{{.Time.Local.Format "01/02/2006 15:04"}}</td>
+    <td>{{$top.UserName .UserId}}</td>
+    <td>{{if .Added}}Added{{else if .Deleted}}Deleted{{else}}Updated{{end}}</td>
+    {{with .Version}}
+    <td>{{FormatDate .Date}}</td>
+    <td>{{$top.CatName .CatPayment}}</td>
+    <td>{{.Name}}</td>
+    <td align=right>{{FormatUSD .Total}}</td>
+    <td>{{$top.AcctName .CatPayment}}</td>
+    {{end}}
+    <td>
+    {{if and $top.Current $index (not .Deleted)}}
+      <form method="post">
+        <input type="hidden" name="xsrf" value="{{$top.Xsrf}}">
+        <input type="hidden" name="etag" value="{{$top.Current.Etag}}">
+        <input type="hidden" name="rid" value="{{.Id}}">
+        <input type="submit" value="Restore" onclick="return confirm('Are you sure you want to restore this version?');">
+      </form>
+    {{end}}
+    </td>
+  </tr>
+    {{with .Version}}
+  <tr>
+    <td colspan=3></td>
+    <td colspan=6>{{.Desc}}{{if .CheckNo}} #{{.CheckNo}}{{end}}{{if .Tags}} [{{.Tags}}]{{end}}</td>
+  </tr>
+    {{end}}
+  {{end}}
+</table>
+{{end}}
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+var (
+	errWrongEntry = errors.New("Version does not belong to this entry.")
+	errDeleted    = errors.New("Deleted entries cannot be restored.")
+	errTrashed    = errors.New(
+		"Entry is in the trash; restore it from the trash first.")
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.DoEntryChangesRunner
+	findb.EntryByIdRunner
+	findb.EntryRevisionByIdRunner
+	findb.EntryRevisionsByEntryIdRunner
+	findb.UsersRunner
+}
+
+// Handler shows the revisions of the entry with the given id. A POST
+// restores the entry to the version in revision rid.
+type Handler struct {
+	Doer   db.Doer
+	Global *common.Global
+	LN     *common.LeftNav
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	id, _ := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+	selecter, err := common.ParseSelecter(r.Form.Get("sel"))
+	if err != nil {
+		selecter = common.SelectSearch()
+	}
+	leftnav := h.LN.Generate(w, r, selecter)
+	if leftnav == "" {
+		return
+	}
+	var postErr error
+	var message string
+	if r.Method == "POST" {
+		if !common.VerifyXsrfToken(r, kHistory) {
+			postErr = common.ErrXsrf
+		} else {
+			message, postErr = h.restore(store, r, id)
+		}
+	}
+	cds, _ := session.Cache.Get(nil)
+	var current *fin.Entry
+	var revisions []*fin.EntryRevision
+	var users []*fin.User
+	err = h.Doer.Do(func(t db.Transaction) (err error) {
+		var entry fin.Entry
+		err = store.EntryById(t, id, &entry)
+		if err == nil {
+			current = &entry
+		} else if err != findb.NoSuchId {
+			return
+		}
+		err = store.EntryRevisionsByEntryId(
+			t, id, goconsume.AppendPtrsTo(&revisions))
+		if err != nil {
+			return
+		}
+		return store.Users(t, goconsume.AppendPtrsTo(&users))
+	})
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	if current == nil && len(revisions) == 0 {
+		fmt.Fprintln(w, "No entry found.")
+		return
+	}
+	userNames := make(map[int64]string, len(users))
+	for _, user := range users {
+		userNames[user.Id] = user.Name
+	}
+	http_util.WriteTemplate(
+		w,
+		kTemplate,
+		&view{
+			Current:      current,
+			Revisions:    toRevisionViews(revisions),
+			userNames:    userNames,
+			CatDisplayer: common.CatDisplayer{CatDetailStore: cds},
+			EntryLinker:  common.EntryLinker{URL: r.URL, Sel: selecter},
+			Xsrf:         common.NewXsrfToken(r, kHistory),
+			Message:      message,
+			Error:        postErr,
+			LeftNav:      leftnav,
+			Global:       h.Global})
+}
+
+// restore restores the entry with given id to the version in the
+// revision that the rid parameter names.
+func (h *Handler) restore(store Store, r *http.Request, id int64) (
+	message string, err error) {
+	rid, _ := strconv.ParseInt(r.Form.Get("rid"), 10, 64)
+	etag, _ := strconv.ParseUint(r.Form.Get("etag"), 10, 64)
+	var revision fin.EntryRevision
+	if err = store.EntryRevisionById(nil, rid, &revision); err != nil {
+		return
+	}
+	if revision.EntryId != id {
+		err = errWrongEntry
+		return
+	}
+	if revision.Deleted() {
+		err = errDeleted
+		return
+	}
+	version := revision.After
+	changes := findb.EntryChanges{
+		Updates: map[int64]fin.EntryUpdater{
+			id: func(p *fin.Entry) bool {
+				p.Date = version.Date
+				p.Name = version.Name
+				p.Desc = version.Desc
+				p.CheckNo = version.CheckNo
+				p.CatPayment = version.CatPayment
+				p.Status = version.Status
+				p.Tags = version.Tags
+				return true
+			}},
+		Etags: map[int64]uint64{id: etag}}
+	err = store.DoEntryChanges(nil, &changes)
+	if err == findb.ConcurrentUpdate {
+		err = common.ErrConcurrentModification
+	}
+	if err == findb.Trashed {
+		err = errTrashed
+	}
+	if err != nil {
+		return
+	}
+	return "Entry restored.", nil
+}
+
+// Link returns the link to the history of the entry with given id.
+func Link(id int64) *url.URL {
+	return http_util.NewUrl(
+		"/fin/history", "id", strconv.FormatInt(id, 10))
+}
+
+type revisionView struct {
+	*fin.EntryRevision
+	// The version of the entry this revision shows. For deletes, it is
+	// the version that was deleted.
+	Version *fin.Entry
+}
+
+func toRevisionViews(revisions []*fin.EntryRevision) []revisionView {
+	result := make([]revisionView, len(revisions))
+	for i, revision := range revisions {
+		result[i].EntryRevision = revision
+		result[i].Version = revision.After
+		if revision.Deleted() {
+			result[i].Version = revision.Before
+		}
+	}
+	return result
+}
+
+type view struct {
+	Current   *fin.Entry
+	Revisions []revisionView
+	userNames map[int64]string
+	common.CatDisplayer
+	common.EntryLinker
+	Xsrf    string
+	Message string
+	Error   error
+	LeftNav template.HTML
+	Global  *common.Global
+}
+
+func (v *view) UserName(id int64) string {
+	if name, ok := v.userNames[id]; ok {
+		return name
+	}
+	return "--Unknown--"
+}
+
+func init() {
+	kTemplate = common.NewTemplate("history", kTemplateSpec)
+}
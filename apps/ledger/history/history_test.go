@@ -0,0 +1,116 @@
+package history
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/finance/fin/findb/for_memory"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/date_util"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	store, entry := newStoreWithEntry(t)
+	rid := addedRevisionId(t, store, entry.Id)
+	var current fin.Entry
+	if err := store.EntryById(nil, entry.Id, &current); err != nil {
+		t.Fatalf("Got error reading entry: %v", err)
+	}
+	h := &Handler{}
+	message, err := h.restore(
+		store, newRequest(rid, current.Etag), entry.Id)
+	if err != nil {
+		t.Fatalf("Got error restoring: %v", err)
+	}
+	if message != "Entry restored." {
+		t.Errorf("Expected Entry restored., got %s", message)
+	}
+	if err := store.EntryById(nil, entry.Id, &current); err != nil {
+		t.Fatalf("Got error reading entry: %v", err)
+	}
+	if current.Name != "Bookstore" {
+		t.Errorf("Expected Bookstore, got %s", current.Name)
+	}
+}
+
+func TestRestoreTrashed(t *testing.T) {
+	store, entry := newStoreWithEntry(t)
+	rid := addedRevisionId(t, store, entry.Id)
+	err := store.DoEntryChanges(
+		nil, &findb.EntryChanges{Trash: []int64{entry.Id}})
+	if err != nil {
+		t.Fatalf("Got error trashing entry: %v", err)
+	}
+	h := &Handler{}
+	message, err := h.restore(store, newRequest(rid, entry.Etag), entry.Id)
+	if err != errTrashed {
+		t.Errorf("Expected errTrashed, got %v", err)
+	}
+	if message != "" {
+		t.Errorf("Expected no message, got %s", message)
+	}
+	var trashed []fin.TrashedEntry
+	if err := store.TrashedEntries(nil, goconsume.AppendTo(&trashed)); err != nil {
+		t.Fatalf("Got error reading trash: %v", err)
+	}
+	if len(trashed) != 1 || trashed[0].Name != "Library" {
+		t.Errorf("Expected Library in the trash, got %v", trashed)
+	}
+}
+
+// newStoreWithEntry returns a store with an entry named Bookstore that
+// was later renamed to Library.
+func newStoreWithEntry(t *testing.T) (for_memory.Store, *fin.Entry) {
+	store := for_memory.New(memory_db.New())
+	if err := store.AddAccount(nil, &fin.Account{Name: "checking", Active: true}); err != nil {
+		t.Fatalf("Got error adding account: %v", err)
+	}
+	entry := &fin.Entry{
+		Date:       date_util.YMD(2015, 10, 1),
+		Name:       "Bookstore",
+		CatPayment: fin.NewCatPayment(fin.Expense, 2500, false, 1)}
+	err := store.DoEntryChanges(
+		nil, &findb.EntryChanges{Adds: []*fin.Entry{entry}})
+	if err != nil {
+		t.Fatalf("Got error adding entry: %v", err)
+	}
+	err = store.DoEntryChanges(nil, &findb.EntryChanges{
+		Updates: map[int64]fin.EntryUpdater{
+			entry.Id: func(p *fin.Entry) bool {
+				p.Name = "Library"
+				return true
+			}}})
+	if err != nil {
+		t.Fatalf("Got error updating entry: %v", err)
+	}
+	return store, entry
+}
+
+// addedRevisionId returns the id of the revision that added the entry
+// with given id.
+func addedRevisionId(
+	t *testing.T, store for_memory.Store, entryId int64) int64 {
+	var revisions []*fin.EntryRevision
+	err := store.EntryRevisionsByEntryId(
+		nil, entryId, goconsume.AppendPtrsTo(&revisions))
+	if err != nil {
+		t.Fatalf("Got error reading revisions: %v", err)
+	}
+	for _, revision := range revisions {
+		if revision.Added() {
+			return revision.Id
+		}
+	}
+	t.Fatal("Expected a revision that adds the entry")
+	return 0
+}
+
+func newRequest(rid int64, etag uint64) *http.Request {
+	return &http.Request{Form: url.Values{
+		"rid":  {strconv.FormatInt(rid, 10)},
+		"etag": {strconv.FormatUint(etag, 10)}}}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"flag"
@@ -8,31 +9,46 @@ import (
 	"github.com/gorilla/context"
 	"github.com/keep94/finance/apps/ledger/ac"
 	"github.com/keep94/finance/apps/ledger/account"
+	"github.com/keep94/finance/apps/ledger/attachment"
+	"github.com/keep94/finance/apps/ledger/backup"
+	"github.com/keep94/finance/apps/ledger/budget"
 	"github.com/keep94/finance/apps/ledger/catedit"
 	"github.com/keep94/finance/apps/ledger/chpasswd"
 	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/apps/ledger/currencies"
 	"github.com/keep94/finance/apps/ledger/export"
+	"github.com/keep94/finance/apps/ledger/forecast"
+	"github.com/keep94/finance/apps/ledger/history"
 	"github.com/keep94/finance/apps/ledger/list"
+	"github.com/keep94/finance/apps/ledger/lockdate"
 	"github.com/keep94/finance/apps/ledger/login"
 	"github.com/keep94/finance/apps/ledger/logout"
+	"github.com/keep94/finance/apps/ledger/networth"
 	"github.com/keep94/finance/apps/ledger/recurringlist"
 	"github.com/keep94/finance/apps/ledger/recurringsingle"
 	"github.com/keep94/finance/apps/ledger/report"
 	"github.com/keep94/finance/apps/ledger/single"
 	"github.com/keep94/finance/apps/ledger/static"
+	"github.com/keep94/finance/apps/ledger/templates"
 	"github.com/keep94/finance/apps/ledger/totals"
+	"github.com/keep94/finance/apps/ledger/trash"
 	"github.com/keep94/finance/apps/ledger/trends"
 	"github.com/keep94/finance/apps/ledger/unreconciled"
 	"github.com/keep94/finance/apps/ledger/unreviewed"
 	"github.com/keep94/finance/apps/ledger/upload"
 	"github.com/keep94/finance/fin"
 	"github.com/keep94/finance/fin/autoimport"
+	"github.com/keep94/finance/fin/autoimport/camt"
 	"github.com/keep94/finance/fin/autoimport/csv"
+	"github.com/keep94/finance/fin/autoimport/mt940"
 	"github.com/keep94/finance/fin/autoimport/qfx"
 	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
 	qfxsqlite "github.com/keep94/finance/fin/autoimport/qfx/qfxdb/for_sqlite"
+	"github.com/keep94/finance/fin/autoimport/qif"
 	csqlite "github.com/keep94/finance/fin/categories/categoriesdb/for_sqlite"
 	"github.com/keep94/finance/fin/findb/for_sqlite"
+	"github.com/keep94/finance/fin/findb/sqlite_backup"
+	"github.com/keep94/finance/fin/findb/sqlite_setup"
 	"github.com/keep94/gosqlite/sqlite"
 	"github.com/keep94/ramstore"
 	"github.com/keep94/toolbox/date_util"
@@ -44,9 +60,12 @@ import (
 	"github.com/keep94/toolbox/mailer"
 	"github.com/keep94/weblogs"
 	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
 const (
@@ -65,9 +84,18 @@ var (
 	fGmailConfig        string
 	fLinks              bool
 	fPopularityLookback int
+	fCurrency           string
+	fTrashRetention     int
+	fHolidays           string
+	fCsvProfiles        string
+	fBackupDir          string
+	fBackupKeep         int
+	fBackupCompress     bool
+	fBackupPassphrase   string
 )
 
 var (
+	kDb                     *sqlite_db.Db
 	kDoer                   db.Doer
 	kCatDetailCache         *csqlite.Cache
 	kStore                  for_sqlite.Store
@@ -77,6 +105,7 @@ var (
 	kReadOnlyUploaders      map[string]autoimport.Loader
 	kSessionStore           = ramstore.NewRAMStore(kSessionTimeout)
 	kClock                  date_util.SystemClock
+	kHolidays               fin.Holidays
 )
 
 var (
@@ -92,7 +121,25 @@ func main() {
 		flag.Usage()
 		return
 	}
-	setupDb(fDb)
+	currency, err := fin.ParseCurrency(fCurrency)
+	if err != nil {
+		fmt.Println(err)
+		flag.Usage()
+		return
+	}
+	csvProfiles, err := readCsvProfiles(fCsvProfiles)
+	if err != nil {
+		log.Fatalf("Error reading csv profiles file: %v", err)
+	}
+	setupDb(fDb, csvProfiles)
+	backupOptions, err := readBackupOptions()
+	if err != nil {
+		log.Fatalf("Error reading backup passphrase file: %v", err)
+	}
+	if fHolidays != "" {
+		setupHolidays(fHolidays)
+	}
+	go purgeTrash(fTrashRetention)
 	if fGmailConfig != "" {
 		setupGmail(fGmailConfig)
 	}
@@ -109,7 +156,7 @@ func main() {
 			hasIcon = true
 		}
 	}
-	global := &common.Global{Title: fTitle, Icon: hasIcon}
+	global := &common.Global{Title: fTitle, Icon: hasIcon, Currency: currency}
 	if fGmailConfig != "" {
 		http.Handle(
 			"/auth/login",
@@ -147,17 +194,19 @@ func main() {
 	mux.Handle(
 		"/fin/recurringlist",
 		&recurringlist.Handler{
-			Doer:   kDoer,
-			Cdc:    kReadOnlyCatDetailCache,
-			Clock:  kClock,
-			LN:     ln,
-			Global: global})
+			Doer:     kDoer,
+			Cdc:      kReadOnlyCatDetailCache,
+			Clock:    kClock,
+			Holidays: kHolidays,
+			LN:       ln,
+			Global:   global})
 	mux.Handle(
 		"/fin/account",
 		&account.Handler{
 			Store:    kReadOnlyStore,
 			Cdc:      kReadOnlyCatDetailCache,
 			Doer:     kDoer,
+			Clock:    kClock,
 			PageSize: kPageSize,
 			Links:    fLinks,
 			LN:       ln,
@@ -165,6 +214,10 @@ func main() {
 	mux.Handle(
 		"/fin/single",
 		&single.Handler{Doer: kDoer, Clock: kClock, Global: global, LN: ln})
+	mux.Handle("/fin/attachment", &attachment.Handler{Doer: kDoer})
+	mux.Handle(
+		"/fin/history",
+		&history.Handler{Doer: kDoer, Global: global, LN: ln})
 	mux.Handle(
 		"/fin/recurringsingle",
 		&recurringsingle.Handler{
@@ -193,9 +246,47 @@ func main() {
 			Cdc:    kReadOnlyCatDetailCache,
 			LN:     ln,
 			Global: global})
+	mux.Handle(
+		"/fin/budget",
+		&budget.Handler{
+			Doer:   kDoer,
+			Cdc:    kReadOnlyCatDetailCache,
+			Clock:  kClock,
+			LN:     ln,
+			Global: global})
+	mux.Handle(
+		"/fin/trash",
+		&trash.Handler{
+			Cdc:           kReadOnlyCatDetailCache,
+			RetentionDays: fTrashRetention,
+			LN:            ln,
+			Global:        global})
+	mux.Handle(
+		"/fin/networth",
+		&networth.Handler{
+			Doer:   kDoer,
+			Clock:  kClock,
+			LN:     ln,
+			Global: global})
+	mux.Handle(
+		"/fin/forecast",
+		&forecast.Handler{
+			Cdc:      kReadOnlyCatDetailCache,
+			Doer:     kDoer,
+			Clock:    kClock,
+			Holidays: kHolidays,
+			LN:       ln,
+			Global:   global})
+	mux.Handle(
+		"/fin/templates",
+		&templates.Handler{
+			Cdc:    kReadOnlyCatDetailCache,
+			LN:     ln,
+			Global: global})
 	mux.Handle(
 		"/fin/totals",
-		&totals.Handler{Store: kReadOnlyStore, LN: ln, Global: global})
+		&totals.Handler{
+			Store: kReadOnlyStore, Clock: kClock, LN: ln, Global: global})
 	mux.Handle(
 		"/fin/export",
 		&export.Handler{
@@ -204,10 +295,26 @@ func main() {
 			Clock:  kClock,
 			LN:     ln,
 			Global: global})
+	mux.Handle(
+		"/fin/currencies",
+		&currencies.Handler{Doer: kDoer, LN: ln, Global: global})
+	mux.Handle(
+		"/fin/lockdate",
+		&lockdate.Handler{Doer: kDoer, LN: ln, Global: global})
+	mux.Handle(
+		"/fin/backup",
+		&backup.Handler{
+			Db:      kDb,
+			Dir:     fBackupDir,
+			Keep:    fBackupKeep,
+			Options: backupOptions,
+			LN:      ln,
+			Global:  global})
 	mux.Handle(
 		"/fin/unreconciled",
 		&unreconciled.Handler{
 			Doer:     kDoer,
+			Clock:    kClock,
 			PageSize: kPageSize,
 			LN:       ln,
 			Global:   global})
@@ -295,38 +402,151 @@ func init() {
 		"popularity_lookback",
 		200,
 		"Number of entries to look back to find most popular categories")
+	flag.StringVar(
+		&fCurrency,
+		"currency",
+		string(fin.DefaultCurrency),
+		"Currency for reports")
+	flag.IntVar(
+		&fTrashRetention,
+		"trash_retention",
+		30,
+		"Days to keep deleted entries in the trash")
+	flag.StringVar(
+		&fHolidays,
+		"holidays",
+		"",
+		"Holidays file path, one yyyyMMdd date per line")
+	flag.StringVar(
+		&fCsvProfiles,
+		"csv_profiles",
+		"",
+		"YAML file of csv import profiles for banks")
+	flag.StringVar(
+		&fBackupDir,
+		"backup_dir",
+		"",
+		"Directory for snapshots taken from the backups page")
+	flag.IntVar(
+		&fBackupKeep,
+		"backup_keep",
+		7,
+		"Number of snapshots to keep in backup_dir; 0 keeps all of them")
+	flag.BoolVar(
+		&fBackupCompress, "backup_compress", false, "Compress snapshots")
+	flag.StringVar(
+		&fBackupPassphrase,
+		"backup_passphrase_file",
+		"",
+		"File holding passphrase to encrypt snapshots")
 }
 
-func setupDb(filepath string) {
+func setupDb(filepath string, csvProfiles []*csv.Profile) {
 	conn, err := sqlite.Open(filepath)
 	if err != nil {
 		panic(err.Error())
 	}
 	dbase := sqlite_db.New(conn)
+	checkSchemaVersion(dbase)
+	kDb = dbase
 	qfxdata := qfxsqlite.New(dbase)
 	kDoer = sqlite_db.NewDoer(dbase)
 	kCatDetailCache = csqlite.New(dbase)
 	kStore = for_sqlite.New(dbase)
 	qfxLoader := qfx.QFXLoader{qfxdata}
-	csvLoader := csv.CsvLoader{qfxdata}
+	csvLoader := csv.CsvLoader{Store: qfxdata, Profiles: csvProfiles}
+	qifLoader := qif.QIFLoader{Store: qfxdata}
+	camtLoader := camt.CamtLoader{Store: qfxdata}
+	mt940Loader := mt940.MT940Loader{Store: qfxdata}
 	kUploaders = map[string]autoimport.Loader{
-		".qfx": qfxLoader,
-		".ofx": qfxLoader,
-		".csv": csvLoader}
+		".qfx":   qfxLoader,
+		".ofx":   qfxLoader,
+		".csv":   csvLoader,
+		".qif":   qifLoader,
+		".xml":   camtLoader,
+		".sta":   mt940Loader,
+		".mt940": mt940Loader}
 	kReadOnlyCatDetailCache = csqlite.ReadOnlyWrapper(kCatDetailCache)
 	kReadOnlyStore = for_sqlite.ReadOnlyWrapper(kStore)
 	readOnlyQFXLoader := qfx.QFXLoader{qfxdb.ReadOnlyWrapper(qfxdata)}
-	readOnlyCsvLoader := csv.CsvLoader{qfxdb.ReadOnlyWrapper(qfxdata)}
+	readOnlyCsvLoader := csv.CsvLoader{
+		Store: qfxdb.ReadOnlyWrapper(qfxdata), Profiles: csvProfiles}
+	readOnlyQIFLoader := qif.QIFLoader{Store: qfxdb.ReadOnlyWrapper(qfxdata)}
+	readOnlyCamtLoader := camt.CamtLoader{
+		Store: qfxdb.ReadOnlyWrapper(qfxdata)}
+	readOnlyMT940Loader := mt940.MT940Loader{
+		Store: qfxdb.ReadOnlyWrapper(qfxdata)}
 	kReadOnlyUploaders = map[string]autoimport.Loader{
-		".qfx": readOnlyQFXLoader,
-		".ofx": readOnlyQFXLoader,
-		".csv": readOnlyCsvLoader}
+		".qfx":   readOnlyQFXLoader,
+		".ofx":   readOnlyQFXLoader,
+		".csv":   readOnlyCsvLoader,
+		".qif":   readOnlyQIFLoader,
+		".xml":   readOnlyCamtLoader,
+		".sta":   readOnlyMT940Loader,
+		".mt940": readOnlyMT940Loader}
+}
+
+// checkSchemaVersion exits if the database needs migrations. ledger never
+// migrates the database itself; ledgermigrate does.
+func checkSchemaVersion(dbase *sqlite_db.Db) {
+	var version int
+	var pending []*sqlite_setup.Migration
+	err := dbase.Do(func(conn *sqlite.Conn) (err error) {
+		if version, err = sqlite_setup.Version(conn); err != nil {
+			return
+		}
+		pending, err = sqlite_setup.PendingMigrations(conn)
+		return
+	})
+	if err != nil {
+		log.Fatalf("Error reading schema version: %v", err)
+	}
+	if len(pending) > 0 {
+		log.Fatalf(
+			"Database is at schema version %d but ledger needs version %d. Run ledgermigrate first.",
+			version,
+			sqlite_setup.LatestVersion())
+	}
+	if version > sqlite_setup.LatestVersion() {
+		log.Fatalf(
+			"Database is at schema version %d which is newer than version %d that ledger supports. Upgrade ledger first.",
+			version,
+			sqlite_setup.LatestVersion())
+	}
+}
+
+// readBackupOptions returns the options for snapshots taken from the
+// backups page. The passphrase is the first line of the passphrase file.
+func readBackupOptions() (*sqlite_backup.Options, error) {
+	result := &sqlite_backup.Options{Compress: fBackupCompress}
+	if fBackupPassphrase == "" {
+		return result, nil
+	}
+	content, err := ioutil.ReadFile(fBackupPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	line := strings.SplitN(string(content), "\n", 2)[0]
+	result.Passphrase = strings.TrimSuffix(line, "\r")
+	return result, nil
+}
+
+// purgeTrash permanently deletes entries that have been in the trash
+// longer than retentionDays. It checks once a day and never returns.
+func purgeTrash(retentionDays int) {
+	retention := time.Duration(retentionDays) * 24 * time.Hour
+	for {
+		if err := kStore.PurgeTrash(nil, kClock.Now().Add(-retention)); err != nil {
+			log.Printf("Error purging trash: %v", err)
+		}
+		time.Sleep(24 * time.Hour)
+	}
 }
 
 func setupStores(session *common.UserSession) bool {
 	switch session.User.Permission {
 	case fin.AllPermission:
-		session.Store = kStore
+		session.Store = kStore.WithUserId(session.User.Id)
 		session.Cache = kCatDetailCache
 		session.Uploaders = kUploaders
 		return true
@@ -377,3 +597,53 @@ func setupGmail(configPath string) {
 	kMailer = mailer.New(kGmailConfig.Email, kGmailConfig.Password)
 	kLockout = lockout.New(kGmailConfig.Failures)
 }
+
+// readHolidays reads holidays from a file containing one yyyyMMdd date
+// per line. Blank lines and lines starting with # are ignored.
+func readHolidays(fileName string) (fin.Holidays, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var dates []time.Time
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		date, err := time.Parse(date_util.YMDFormat, line)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fin.NewHolidays(dates...), nil
+}
+
+// readCsvProfiles reads the csv import profiles in fileName. See
+// csv.ReadProfiles. readCsvProfiles returns no profiles if fileName is
+// empty.
+func readCsvProfiles(fileName string) ([]*csv.Profile, error) {
+	if fileName == "" {
+		return nil, nil
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return csv.ReadProfiles(f)
+}
+
+func setupHolidays(holidaysPath string) {
+	var err error
+	kHolidays, err = readHolidays(holidaysPath)
+	if err != nil {
+		log.Fatalf("Error reading holidays file: %v", err)
+	}
+}
@@ -120,6 +120,13 @@ body {
           <div id="descContainer"></div>
         </div>
       </td>
+      <td>Tag: </td>
+      <td><input type="text" name="tag" value="{{.Get "tag"}}"></td>
+    </tr>
+    <tr>
+      <td>Words: </td>
+      <td><input type="text" name="q" value="{{.Get "q"}}"></td>
+      <td colspan=2>Best matches of name, desc, and check number first</td>
     </tr>
   </table>
 <input type="submit" value="Search">
@@ -147,7 +154,7 @@ Page: {{.DisplayPageNo}}&nbsp;
       <tr class="lineitem">
         <td>{{FormatDate .Date}}</td>
         <td>{{range $top.CatLink .CatPayment}}{{if .Link}}<a href="{{.Link}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}{{end}}</td>
-        <td><a href="{{$top.EntryLink .Id}}">{{.Name}}</a></td>
+        <td><a href="{{$top.EntryLink .Id}}">{{.Name}}</a>{{if .AttachmentCount}} <span title="{{.AttachmentCount}} attachment(s)">&#128206;</span>{{end}}</td>
         <td align=right>{{FormatUSD .Total}}</td>
         <td>{{with $top.AccountNameLink .CatPayment}}{{if .Link}}<a href="{{.Link}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}{{end}}</td>
       </tr>
@@ -155,7 +162,7 @@ Page: {{.DisplayPageNo}}&nbsp;
         <td>
           {{if .CheckNo}}{{.CheckNo}}{{else}}&nbsp;{{end}}
         </td>
-        <td colspan=4>{{.Desc}}</td>
+        <td colspan=4>{{.Desc}}{{if .Tags}} [{{.Tags}}]{{end}}</td>
       </tr>
   {{end}}
   </table>
@@ -187,9 +194,14 @@ var (
 	kTemplate *template.Template
 )
 
+type Store interface {
+	findb.EntriesRunner
+	findb.SearchEntriesRunner
+}
+
 type Handler struct {
 	Cdc      categoriesdb.Getter
-	Store    findb.EntriesRunner
+	Store    Store
 	PageSize int
 	Links    bool
 	LN       *common.LeftNav
@@ -206,9 +218,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	pageNo, _ := strconv.Atoi(r.Form.Get(kPageParam))
 	cds, _ := h.Cdc.Get(nil)
 	var filt fin.CatFilter
+	var cats fin.CatSet
 	cat, caterr := fin.CatFromString(r.Form.Get("cat"))
 	if caterr == nil {
 		filt = cds.Filter(cat, r.Form.Get("top") == "")
+		cats = cds.Cats(cat, r.Form.Get("top") == "")
 	}
 	var amtFilter filters.AmountFilter
 	errorMessage := ""
@@ -219,13 +233,17 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			errorMessage = "Range must be of form 12.34 to 56.78."
 		}
 	}
+	if err := common.CheckTag(r.Form.Get("tag")); err != nil {
+		errorMessage = err.Error()
+	}
 	var filter goconsume.FilterFunc
-	if amtFilter != nil || filt != nil || r.Form.Get("name") != "" || r.Form.Get("desc") != "" {
+	if amtFilter != nil || filt != nil || r.Form.Get("name") != "" || r.Form.Get("desc") != "" || r.Form.Get("tag") != "" {
 		filter = filters.CompileAdvanceSearchSpec(&filters.AdvanceSearchSpec{
 			CF:   filt,
 			AF:   amtFilter,
 			Name: r.Form.Get("name"),
-			Desc: r.Form.Get("desc")})
+			Desc: r.Form.Get("desc"),
+			Tag:  r.Form.Get("tag")})
 	}
 	var totaler *aggregators.Totaler
 	var entries []fin.Entry
@@ -234,22 +252,28 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var cr goconsume.Consumer = epb
 	sdPtr, sderr := getDateRelaxed(r.Form, "sd")
 	edPtr, ederr := getDateRelaxed(r.Form, "ed")
+	query := r.Form.Get("q")
+	if (filter != nil || query != "") && sdPtr != nil {
+		totaler = &aggregators.Totaler{}
+		cr = goconsume.Compose(
+			consumers.FromCatPaymentAggregator(totaler),
+			cr)
+	}
 	if filter != nil {
-		if sdPtr != nil {
-			totaler = &aggregators.Totaler{}
-			cr = goconsume.Compose(
-				consumers.FromCatPaymentAggregator(totaler),
-				cr)
-		}
 		cr = goconsume.Filter(cr, filter)
 	}
 	var elo *findb.EntryListOptions
 	if sderr != nil || ederr != nil {
 		errorMessage = "Start and end date must be in yyyyMMdd format."
 	} else {
-		elo = &findb.EntryListOptions{Start: sdPtr, End: edPtr}
+		elo = &findb.EntryListOptions{Start: sdPtr, End: edPtr, Cats: cats}
+	}
+	var err error
+	if query != "" {
+		err = h.Store.SearchEntries(nil, query, elo, cr)
+	} else {
+		err = h.Store.Entries(nil, elo, cr)
 	}
-	err := h.Store.Entries(nil, elo, cr)
 	epb.Finalize()
 	if err != nil {
 		http_util.ReportError(w, "Error reading database.", err)
@@ -311,43 +335,43 @@ func compileRangeFilter(expr string) filters.AmountFilter {
 		parts[i] = strings.TrimSpace(parts[i])
 	}
 	if len(parts) == 1 {
-		neededAmount, err := fin.ParseUSD(parts[0])
+		neededAmount, err := fin.ParseMoney(parts[0])
 		if err != nil {
 			return nil
 		}
 		return func(amt int64) bool {
-			return amt == -neededAmount
+			return fin.Money(amt) == -neededAmount
 		}
 	}
 	if parts[0] != "" && parts[1] != "" {
-		lower, err := fin.ParseUSD(parts[0])
+		lower, err := fin.ParseMoney(parts[0])
 		if err != nil {
 			return nil
 		}
-		upper, err := fin.ParseUSD(parts[1])
+		upper, err := fin.ParseMoney(parts[1])
 		if err != nil {
 			return nil
 		}
 		return func(amt int64) bool {
-			return amt >= -upper && amt <= -lower
+			return fin.Money(amt) >= -upper && fin.Money(amt) <= -lower
 		}
 	}
 	if parts[0] != "" {
-		lower, err := fin.ParseUSD(parts[0])
+		lower, err := fin.ParseMoney(parts[0])
 		if err != nil {
 			return nil
 		}
 		return func(amt int64) bool {
-			return amt <= -lower
+			return fin.Money(amt) <= -lower
 		}
 	}
 	if parts[1] != "" {
-		upper, err := fin.ParseUSD(parts[1])
+		upper, err := fin.ParseMoney(parts[1])
 		if err != nil {
 			return nil
 		}
 		return func(amt int64) bool {
-			return amt >= -upper
+			return fin.Money(amt) >= -upper
 		}
 	}
 	return nil
@@ -0,0 +1,185 @@
+package lockdate
+
+import (
+	"errors"
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kLockDate = "lockdate"
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
+<h2>Lock dates</h2>
+Entries dated on or before the lock date of their account cannot be added,
+changed, or deleted. Leave the date blank to remove the lock.
+<table>
+  <tr>
+    <td>Account</td>
+    <td>Locked through</td>
+    <td>&nbsp;</td>
+  </tr>
+{{with $top := .}}
+{{range .Rows}}
+  <tr>
+    <form method="post">
+      <input type="hidden" name="xsrf" value="{{$top.Xsrf}}">
+      <input type="hidden" name="acctId" value="{{.Id}}">
+      <td>{{.Name}}</td>
+      <td><input type="text" name="date" value="{{.Date}}" size="10"></td>
+      <td><input type="submit" value="Change"></td>
+    </form>
+  </tr>
+{{end}}
+{{end}}
+</table>
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.ActiveAccountsRunner
+	findb.LockDatesRunner
+	findb.UpdateLockDateRunner
+}
+
+// Handler shows and changes the lock date for all accounts and for each
+// active account. Only users with fin.AllPermission may change lock dates.
+type Handler struct {
+	Doer   db.Doer
+	LN     *common.LeftNav
+	Global *common.Global
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	leftnav := h.LN.Generate(w, r, common.SelectLockDate())
+	if leftnav == "" {
+		return
+	}
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	var postErr error
+	var message string
+	if r.Method == "POST" {
+		if !common.VerifyXsrfToken(r, kLockDate) {
+			postErr = common.ErrXsrf
+		} else if session.User.Permission != fin.AllPermission {
+			postErr = findb.NoPermission
+		} else {
+			message, postErr = h.updateLockDate(store, r)
+		}
+	}
+	var accounts []*fin.Account
+	var locks fin.LockDates
+	err := h.Doer.Do(func(t db.Transaction) (err error) {
+		if accounts, err = store.ActiveAccounts(t); err != nil {
+			return
+		}
+		locks, err = store.LockDates(t)
+		return
+	})
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	rows := []row{newRow(0, "All accounts", locks)}
+	for _, account := range accounts {
+		rows = append(rows, newRow(account.Id, account.Name, locks))
+	}
+	http_util.WriteTemplate(
+		w,
+		kTemplate,
+		&view{
+			Rows:    rows,
+			Error:   postErr,
+			Message: message,
+			Xsrf:    common.NewXsrfToken(r, kLockDate),
+			LeftNav: leftnav,
+			Global:  h.Global})
+}
+
+func (h *Handler) updateLockDate(
+	store findb.UpdateLockDateRunner,
+	r *http.Request) (message string, err error) {
+	acctId, _ := strconv.ParseInt(r.Form.Get("acctId"), 10, 64)
+	var date time.Time
+	if dateStr := strings.TrimSpace(r.Form.Get("date")); dateStr != "" {
+		date, err = time.Parse(
+			date_util.YMDFormat, common.NormalizeYMDStr(dateStr))
+		if err != nil {
+			err = errors.New("Date must be in yyyyMMdd format.")
+			return
+		}
+	}
+	if err = store.UpdateLockDate(nil, acctId, date); err != nil {
+		return
+	}
+	if date.IsZero() {
+		return "Lock date removed.", nil
+	}
+	return "Lock date changed.", nil
+}
+
+type row struct {
+	// 0 means all accounts
+	Id   int64
+	Name string
+	// In yyyyMMdd format. Empty means not locked.
+	Date string
+}
+
+func newRow(acctId int64, name string, locks fin.LockDates) row {
+	result := row{Id: acctId, Name: name}
+	if date, ok := locks[acctId]; ok {
+		result.Date = date.Format(date_util.YMDFormat)
+	}
+	return result
+}
+
+type view struct {
+	Rows    []row
+	Error   error
+	Message string
+	Xsrf    string
+	LeftNav template.HTML
+	Global  *common.Global
+}
+
+func init() {
+	kTemplate = common.NewTemplate("lockdate", kTemplateSpec)
+}
@@ -0,0 +1,349 @@
+package networth
+
+import (
+	"errors"
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	kNetWorth = "networth"
+	// Default number of months in the trend
+	kDefaultMonths = 12
+	kMaxMonths     = 120
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
+<form method="get">
+  As of (yyyyMMdd): <input type="text" name="date" value="{{.Get "date"}}" size="10">
+  Months: <input type="text" name="months" value="{{.Get "months"}}" size="4">
+  <input type="submit" value="Show net worth">
+</form>
+<h2>Net worth as of {{FormatDate .AsOf}}</h2>
+Amounts in {{.Global.Currency}}
+{{with $top := .}}
+<table>
+  <tr>
+    <td>Account</td>
+    <td>Type</td>
+    <td>Balance</td>
+  </tr>
+  <tr><td colspan=3><b>Assets</b></td></tr>
+  {{range .Assets}}
+  <tr class="lineitem">
+    <td>{{.Account.Name}}</td>
+    <td>{{.Account.Type}}</td>
+    <td align=right>{{FormatUSD .Balance}}</td>
+  </tr>
+  {{end}}
+  <tr>
+    <td colspan=2><b>Total assets</b></td>
+    <td align=right><b>{{FormatUSD .NetWorth.Assets}}</b></td>
+  </tr>
+  <tr><td colspan=3><b>Liabilities</b></td></tr>
+  {{range .Liabilities}}
+  <tr class="lineitem">
+    <td>{{.Account.Name}}</td>
+    <td>{{.Account.Type}}</td>
+    <td align=right>{{FormatUSD .Balance}}</td>
+  </tr>
+  {{end}}
+  <tr>
+    <td colspan=2><b>Total liabilities</b></td>
+    <td align=right><b>{{FormatUSD .NetWorth.Liabilities}}</b></td>
+  </tr>
+  <tr>
+    <td colspan=2><b>Net worth</b></td>
+    <td align=right><b>{{FormatUSD .NetWorth.Total}}</b></td>
+  </tr>
+</table>
+<h2>Trend</h2>
+<table>
+  <tr>
+    <td>Date</td>
+    <td>Assets</td>
+    <td>Liabilities</td>
+    <td>Net worth</td>
+  </tr>
+  {{range .Trend}}
+  <tr class="lineitem">
+    <td>{{FormatDate .Date}}</td>
+    <td align=right>{{FormatUSD .NetWorth.Assets}}</td>
+    <td align=right>{{FormatUSD .NetWorth.Liabilities}}</td>
+    <td align=right>{{FormatUSD .NetWorth.Total}}</td>
+  </tr>
+  {{end}}
+</table>
+<h2>Account types</h2>
+<table>
+  {{range .Accounts}}
+  <tr>
+    <form method="post">
+      <input type="hidden" name="xsrf" value="{{$top.Xsrf}}">
+      <input type="hidden" name="acctId" value="{{.Id}}">
+      <td>{{.Name}}</td>
+      <td>
+        <select name="type" size=1>
+        {{with $account := .}}
+          {{range $top.AccountTypes}}
+          <option value="{{.ToInt}}" {{if eq . $account.Type}}selected{{end}}>{{.}}</option>
+          {{end}}
+        {{end}}
+        </select>
+      </td>
+      <td><input type="submit" name="settype" value="Change"></td>
+    </form>
+  </tr>
+  {{end}}
+</table>
+{{end}}
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.AccountsRunner
+	findb.EntriesByAccountIdRunner
+	findb.ExchangeRatesRunner
+	findb.UpdateAccountTypeRunner
+}
+
+// Handler shows assets, liabilities and net worth as of a date along
+// with the net worth at the end of each of the preceding months.
+type Handler struct {
+	Doer   db.Doer
+	Clock  date_util.Clock
+	LN     *common.LeftNav
+	Global *common.Global
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	leftnav := h.LN.Generate(w, r, common.SelectNetWorth())
+	if leftnav == "" {
+		return
+	}
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	var postErr error
+	var message string
+	if r.Method == "POST" {
+		if !common.VerifyXsrfToken(r, kNetWorth) {
+			postErr = common.ErrXsrf
+		} else if http_util.HasParam(r.Form, "settype") {
+			message, postErr = h.setType(store, r)
+		}
+	}
+	asOf := date_util.TimeToDate(h.Clock.Now())
+	if dateStr := r.Form.Get("date"); dateStr != "" {
+		var err error
+		asOf, err = time.Parse(
+			date_util.YMDFormat, common.NormalizeYMDStr(dateStr))
+		if err != nil && postErr == nil {
+			postErr = errors.New("Date must be in yyyyMMdd format.")
+		}
+	}
+	months := kDefaultMonths
+	if monthsStr := r.Form.Get("months"); monthsStr != "" {
+		var err error
+		months, err = strconv.Atoi(monthsStr)
+		if (err != nil || months < 1 || months > kMaxMonths) && postErr == nil {
+			postErr = errors.New("Months must be between 1 and 120.")
+		}
+		if err != nil || months < 1 || months > kMaxMonths {
+			months = kDefaultMonths
+		}
+	}
+	dates := trendDates(asOf, months)
+	var accounts []*fin.Account
+	var report *netWorthReport
+	err := h.Doer.Do(func(t db.Transaction) (err error) {
+		if err = store.Accounts(t, goconsume.AppendPtrsTo(&accounts)); err != nil {
+			return
+		}
+		var converter *fin.CurrencyConverter
+		converter, err = findb.NewCurrencyConverter(
+			t, store, h.Global.Currency)
+		if err != nil {
+			return
+		}
+		report, err = buildReport(t, store, converter, accounts, dates)
+		return
+	})
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	if report.missingRates && postErr == nil {
+		postErr = common.ErrMissingExchangeRate
+	}
+	var activeAccounts []*fin.Account
+	for _, account := range accounts {
+		if account.Active {
+			activeAccounts = append(activeAccounts, account)
+		}
+	}
+	http_util.WriteTemplate(
+		w,
+		kTemplate,
+		&view{
+			Values:         http_util.Values{Values: r.Form},
+			AsOf:           asOf,
+			netWorthReport: report,
+			Accounts:       activeAccounts,
+			Error:          postErr,
+			Message:        message,
+			Xsrf:           common.NewXsrfToken(r, kNetWorth),
+			LeftNav:        leftnav,
+			Global:         h.Global})
+}
+
+func (h *Handler) setType(
+	store findb.UpdateAccountTypeRunner,
+	r *http.Request) (message string, err error) {
+	acctId, _ := strconv.ParseInt(r.Form.Get("acctId"), 10, 64)
+	typeInt, _ := strconv.Atoi(r.Form.Get("type"))
+	accountType, ok := fin.ToAccountType(typeInt)
+	if !ok {
+		err = errors.New("Invalid account type.")
+		return
+	}
+	if err = store.UpdateAccountType(nil, acctId, accountType); err != nil {
+		return
+	}
+	return "Account type changed.", nil
+}
+
+// trendDates returns the end of each of the months before the month
+// containing asOf followed by asOf itself. The returned dates are
+// oldest first.
+func trendDates(asOf time.Time, months int) []time.Time {
+	result := make([]time.Time, months)
+	monthStart := date_util.YMD(asOf.Year(), int(asOf.Month()), 1)
+	for i := 0; i < months-1; i++ {
+		result[i] = monthStart.AddDate(0, i-months+2, -1)
+	}
+	result[months-1] = asOf
+	return result
+}
+
+type accountBalance struct {
+	Account *fin.Account
+	// Balance in the reporting currency
+	Balance int64
+}
+
+type trendPoint struct {
+	Date     time.Time
+	NetWorth fin.NetWorth
+}
+
+type netWorthReport struct {
+	// Balances of asset and liability accounts as of the last date
+	Assets      []accountBalance
+	Liabilities []accountBalance
+	NetWorth    fin.NetWorth
+	Trend       []trendPoint
+	// true if some balances could not be converted
+	missingRates bool
+}
+
+// buildReport builds the net worth report. dates are oldest first; the
+// account balances are as of the last date.
+func buildReport(
+	t db.Transaction,
+	store findb.EntriesByAccountIdRunner,
+	converter *fin.CurrencyConverter,
+	accounts []*fin.Account,
+	dates []time.Time) (*netWorthReport, error) {
+	result := &netWorthReport{Trend: make([]trendPoint, len(dates))}
+	for i := range dates {
+		result.Trend[i].Date = dates[i]
+	}
+	last := len(dates) - 1
+	for _, account := range accounts {
+		balances, err := findb.AccountBalancesAsOf(
+			t, store, account.Id, dates)
+		if err != nil {
+			return nil, err
+		}
+		for i := range balances {
+			balances[i], err = converter.ConvertBalance(
+				account.Id, balances[i], dates[i])
+			if err != nil {
+				result.missingRates = true
+				balances[i] = 0
+			}
+			result.Trend[i].NetWorth.Include(account.Type, balances[i])
+		}
+		if !account.Active && balances[last] == 0 {
+			continue
+		}
+		line := accountBalance{Account: account, Balance: balances[last]}
+		if account.Type.IsLiability() {
+			result.Liabilities = append(result.Liabilities, line)
+		} else {
+			result.Assets = append(result.Assets, line)
+		}
+	}
+	result.NetWorth = result.Trend[last].NetWorth
+	return result, nil
+}
+
+type view struct {
+	http_util.Values
+	AsOf time.Time
+	*netWorthReport
+	// Accounts whose type can be changed
+	Accounts []*fin.Account
+	Error    error
+	Message  string
+	Xsrf     string
+	LeftNav  template.HTML
+	Global   *common.Global
+}
+
+func (v *view) AccountTypes() []fin.AccountType {
+	result := make([]fin.AccountType, fin.AccountTypeCount)
+	for i := range result {
+		result[i] = fin.AccountType(i)
+	}
+	return result
+}
+
+func init() {
+	kTemplate = common.NewTemplate("networth", kTemplateSpec)
+}
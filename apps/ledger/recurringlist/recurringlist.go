@@ -65,7 +65,7 @@ var (
     </tr>
   {{range .Values}}
       <tr class="lineitem">
-        <td>{{FormatDate .Date}}</td>
+        <td>{{FormatDate ($top.NextDate .)}}</td>
         <td>{{$top.CatName .CatPayment}}</td>
         <td><a href="{{$top.EntryLink .Id}}">{{.Name}}</a></td>
         <td align=right>{{FormatUSD .Total}}</td>
@@ -83,8 +83,8 @@ var (
         <td>
           {{if .CheckNo}}{{.CheckNo}}{{else}}&nbsp;{{end}}
         </td>
-        <td>Every {{.Period.Count}} {{.Period.Unit}}</td>
-        <td>{{$top.NumLeft .NumLeft}}</td>
+        <td>{{.Period}}{{if .Adjustment}}, {{.Adjustment}}{{end}}{{if .Estimated}}, estimated{{end}}{{if .Escalation.Active}}, escalates {{.Escalation}}{{end}}</td>
+        <td>{{$top.NumLeft .NumLeft}}{{if not .EndDate.IsZero}}, ends {{FormatDate .EndDate}}{{end}}</td>
         <td colspan="2">{{.Desc}}</td>
       </tr>
   {{end}}
@@ -106,11 +106,13 @@ type Store interface {
 }
 
 type Handler struct {
-	Cdc    categoriesdb.Getter
-	Doer   db.Doer
-	Clock  date_util.Clock
-	LN     *common.LeftNav
-	Global *common.Global
+	Cdc   categoriesdb.Getter
+	Doer  db.Doer
+	Clock date_util.Clock
+	// Holidays for business day adjustment. May be nil.
+	Holidays fin.HolidayCalendar
+	LN       *common.LeftNav
+	Global   *common.Global
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -158,7 +160,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	currentDate := date_util.TimeToDate(h.Clock.Now())
 	count, err := findb.ApplyRecurringEntriesDryRun(
-		nil, store, acctId, currentDate)
+		nil, store, acctId, currentDate, h.Holidays)
 	if err != nil {
 		http_util.ReportError(
 			w, "Error doing apply recurring entries dry run.", err)
@@ -177,6 +179,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				URL: r.URL,
 				Sel: selecter},
 			Values:            entries,
+			Holidays:          h.Holidays,
 			AccountId:         acctId,
 			Today:             currentDate,
 			EntriesToAddCount: count,
@@ -195,7 +198,11 @@ func (h *Handler) applyRecurringEntries(
 	err = h.Doer.Do(func(t db.Transaction) error {
 		var err error
 		count, err = findb.ApplyRecurringEntries(
-			t, store, acctId, date_util.TimeToDate(h.Clock.Now()))
+			t,
+			store,
+			acctId,
+			date_util.TimeToDate(h.Clock.Now()),
+			h.Holidays)
 		return err
 	})
 	if err == nil {
@@ -227,7 +234,7 @@ func (h *Handler) applyEntry(
 	var applied bool
 	err = h.Doer.Do(func(t db.Transaction) error {
 		var err error
-		applied, err = findb.ApplyRecurringEntry(t, store, rid)
+		applied, err = findb.ApplyRecurringEntry(t, store, rid, h.Holidays)
 		return err
 	})
 	if applied {
@@ -243,6 +250,7 @@ type view struct {
 	common.RecurringEntryLinker
 	common.AccountLinker
 	Values            []*fin.RecurringEntry
+	Holidays          fin.HolidayCalendar
 	AccountId         int64
 	Today             time.Time
 	EntriesToAddCount int
@@ -261,6 +269,11 @@ func (v *view) NumLeft(numLeft int) string {
 	return fmt.Sprintf("%d left", numLeft)
 }
 
+// NextDate returns the date of the next entry that entry generates.
+func (v *view) NextDate(entry *fin.RecurringEntry) time.Time {
+	return entry.NextDate(v.Holidays)
+}
+
 func init() {
 	kTemplate = common.NewTemplate("recurringlist", kTemplateSpec)
 }
@@ -21,6 +21,11 @@ const (
 	kRecurringSingle = "recurringsingle"
 )
 
+const (
+	// kLastWeek is the weekOfMonth form value for the last week of the month.
+	kLastWeek = -1
+)
+
 var (
 	kTemplateSpec = `
 <html>
@@ -88,6 +93,7 @@ body {
 <input type="hidden" name="last_date" value="{{.Get "date"}}">
 {{end}}
 <input type="checkbox" name="need_review" {{if .Get "need_review"}}checked{{end}}>Under review
+<input type="checkbox" name="estimated" {{if .Get "estimated"}}checked{{end}}>Estimated amount (new entries need review)
 <table>
   <tr>
     <td align="right">Date: </td>
@@ -127,11 +133,57 @@ body {
         {{end}}
       </select>
     </td>
+  </tr>
+  <tr>
+    <td align="right">Second Day of Month: </td>
+    <td><input type="text" name="dayOfMonth2" size="2" value="{{.Get "dayOfMonth2"}}">&nbsp;(half months only, blank for 15th)</td>
+  </tr>
   <tr>
+    <td align="right">Weekday: </td>
+    <td>
+{{with $top := .}}
+      <select name="weekOfMonth">
+  {{range .WeeksOfMonth}}
+        <option value="{{.Value}}" {{if eq .Value ($top.Get "weekOfMonth")}}selected{{end}}>{{.Name}}</option>
+  {{end}}
+      </select>
+      <select name="weekday">
+  {{range .Weekdays}}
+        <option value="{{.Value}}" {{if eq .Value ($top.Get "weekday")}}selected{{end}}>{{.Name}}</option>
+  {{end}}
+      </select>
+{{end}}
+      &nbsp;(months on weekday only)
+    </td>
+  </tr>
   <tr>
     <td align="right">Remaining: </td>
     <td><input type="text" name="remaining" value="{{.Get "remaining"}}">&nbsp;(blank for unlimited)</td>
+  </tr>
+  <tr>
+    <td align="right">End Date: </td>
+    <td><input type="text" name="endDate" value="{{.Get "endDate"}}">&nbsp;(blank for none)</td>
+  </tr>
+  <tr>
+    <td align="right">Weekends/Holidays: </td>
+    <td>
+{{with $top := .}}
+      <select name="adjustment">
+  {{range .Adjustments}}
+        <option value="{{.Value}}" {{if eq .Value ($top.Get "adjustment")}}selected{{end}}>{{.Name}}</option>
+  {{end}}
+      </select>
+{{end}}
+    </td>
+  </tr>
   <tr>
+    <td align="right">Escalation: </td>
+    <td>
+      <input type="text" name="escPercent" size="5" value="{{.Get "escPercent"}}">%
+      plus <input type="text" name="escAmount" size="7" value="{{.Get "escAmount"}}" title="Added to the total and spread across the lines by their amounts">
+      every <input type="text" name="escEvery" size="3" value="{{.Get "escEvery"}}"> occurrences&nbsp;(blank for none)
+    </td>
+  </tr>
 </table>
 <table>
   <tr>
@@ -166,6 +218,9 @@ body {
     <td>
       <input type="text" name="{{.AmountParam}}" value="{{$top.Get .AmountParam}}" size="12">
     </td>
+    <td>
+      <input type="text" name="{{.CurrencyAmountParam}}" value="{{$top.Get .CurrencyAmountParam}}" size="12" title="Amount in account currency e.g EUR 91.50">
+    </td>
     <td>
      <input type="checkbox" name="{{.ReconcileParam}}" {{if $top.Get .ReconcileParam}} checked {{end}}>
     </td>
@@ -403,9 +458,31 @@ func (h *Handler) toView(
 	if entry.Period.DayOfMonth > 0 {
 		result.Set("dayOfMonth", strconv.Itoa(entry.Period.DayOfMonth))
 	}
+	if entry.Period.SecondDayOfMonth > 0 {
+		result.Set(
+			"dayOfMonth2", strconv.Itoa(entry.Period.SecondDayOfMonth))
+	}
+	result.Set("weekday", strconv.Itoa(int(entry.Period.Weekday)))
+	weekOfMonth := entry.Period.WeekOfMonth
+	if weekOfMonth < 1 || weekOfMonth > 4 {
+		weekOfMonth = kLastWeek
+	}
+	result.Set("weekOfMonth", strconv.Itoa(weekOfMonth))
 	if entry.NumLeft >= 0 {
 		result.Set("remaining", strconv.Itoa(entry.NumLeft))
 	}
+	if !entry.EndDate.IsZero() {
+		result.Set("endDate", entry.EndDate.Format(date_util.YMDFormat))
+	}
+	result.Set("adjustment", strconv.Itoa(entry.Adjustment.ToInt()))
+	if entry.Estimated {
+		result.Set("estimated", "on")
+	}
+	if entry.Escalation.Active() {
+		result.Set("escPercent", fin.FormatUSD(entry.Escalation.Percent))
+		result.Set("escAmount", fin.FormatUSD(entry.Escalation.Amount))
+		result.Set("escEvery", strconv.Itoa(entry.Escalation.Every))
+	}
 	return result
 }
 
@@ -429,6 +506,40 @@ type view struct {
 	RecurringUnitModel common.RecurringUnitComboBoxType
 }
 
+type option struct {
+	Value string
+	Name  string
+}
+
+func (v *view) Weekdays() []option {
+	result := make([]option, 7)
+	for i := range result {
+		result[i] = option{
+			Value: strconv.Itoa(i), Name: time.Weekday(i).String()}
+	}
+	return result
+}
+
+func (v *view) Adjustments() []option {
+	result := make([]option, fin.BusinessDayAdjustmentCount)
+	for i := range result {
+		adjustment := fin.BusinessDayAdjustment(i)
+		result[i] = option{
+			Value: strconv.Itoa(adjustment.ToInt()),
+			Name:  adjustment.String()}
+	}
+	return result
+}
+
+func (v *view) WeeksOfMonth() []option {
+	return []option{
+		{Value: "1", Name: "First"},
+		{Value: "2", Name: "Second"},
+		{Value: "3", Name: "Third"},
+		{Value: "4", Name: "Fourth"},
+		{Value: strconv.Itoa(kLastWeek), Name: "Last"}}
+}
+
 func isIdValid(id int64) bool {
 	return id > 0
 }
@@ -476,23 +587,42 @@ func entryMutation(values url.Values) (
 			return
 		}
 	}
-	dayOfMonthStr := values.Get("dayOfMonth")
-	var dayOfMonth int
-	if dayOfMonthStr == "" {
-		dayOfMonth = 0
-	} else {
-		if dayOfMonth, err = strconv.Atoi(dayOfMonthStr); err != nil {
-			return
-		}
-		if dayOfMonth <= 0 {
-			err = errors.New("Day of month must be greater than 0.")
-			return
-		}
-		if dayOfMonth > 31 {
-			err = errors.New("Day of month must not be greater than 31.")
+	var endDate time.Time
+	if endDateStr := values.Get("endDate"); endDateStr != "" {
+		if endDate, err = time.Parse(date_util.YMDFormat, endDateStr); err != nil {
+			err = errors.New("End date must be in yyyyMMdd format.")
 			return
 		}
 	}
+	iadjustment, _ := strconv.Atoi(values.Get("adjustment"))
+	adjustment, ok := fin.ToBusinessDayAdjustment(iadjustment)
+	if !ok {
+		err = errors.New("Invalid weekend/holiday adjustment.")
+		return
+	}
+	var escalation fin.Escalation
+	if escalation, err = escalationFromForm(values); err != nil {
+		return
+	}
+	estimated := values.Get("estimated") != ""
+	var dayOfMonth int
+	if dayOfMonth, err = parseDayOfMonth(values.Get("dayOfMonth")); err != nil {
+		return
+	}
+	var secondDayOfMonth int
+	if secondDayOfMonth, err = parseDayOfMonth(
+		values.Get("dayOfMonth2")); err != nil {
+		return
+	}
+	weekday, _ := strconv.Atoi(values.Get("weekday"))
+	if weekday < 0 || weekday > int(time.Saturday) {
+		err = errors.New("Invalid weekday.")
+		return
+	}
+	weekOfMonth, _ := strconv.Atoi(values.Get("weekOfMonth"))
+	if weekOfMonth < 1 || weekOfMonth > 4 {
+		weekOfMonth = kLastWeek
+	}
 	if unit == fin.Months && dayOfMonth == 0 {
 		var temp fin.Entry
 		entryFilterer(&temp)
@@ -504,12 +634,71 @@ func entryMutation(values url.Values) (
 		p.Period.Count = count
 		p.Period.Unit = unit
 		p.Period.DayOfMonth = dayOfMonth
+		p.Period.SecondDayOfMonth = secondDayOfMonth
+		p.Period.Weekday = time.Weekday(weekday)
+		p.Period.WeekOfMonth = weekOfMonth
 		p.NumLeft = numLeft
+		p.EndDate = endDate
+		p.Adjustment = adjustment
+		p.Estimated = estimated
+		if escalation.Active() {
+			// Keep counting occurrences since the last escalation
+			escalation.Count = p.Escalation.Count
+		}
+		p.Escalation = escalation
 		return true
 	}
 	return
 }
 
+// escalationFromForm returns the escalation from the escPercent, escAmount
+// and escEvery form values. A blank escEvery means no escalation.
+func escalationFromForm(values url.Values) (
+	escalation fin.Escalation, err error) {
+	everyStr := values.Get("escEvery")
+	if everyStr == "" {
+		return
+	}
+	if escalation.Every, err = strconv.Atoi(everyStr); err != nil {
+		return
+	}
+	if escalation.Every < 1 {
+		err = errors.New("Escalation must be every 1 or more occurrences.")
+		return
+	}
+	if percentStr := values.Get("escPercent"); percentStr != "" {
+		// Percents are in hundredths just like amounts are in cents
+		if escalation.Percent, err = fin.ParseUSD(percentStr); err != nil {
+			return
+		}
+	}
+	if amountStr := values.Get("escAmount"); amountStr != "" {
+		if escalation.Amount, err = fin.ParseUSD(amountStr); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// parseDayOfMonth parses a day of the month. Blank means 0.
+func parseDayOfMonth(dayOfMonthStr string) (dayOfMonth int, err error) {
+	if dayOfMonthStr == "" {
+		return
+	}
+	if dayOfMonth, err = strconv.Atoi(dayOfMonthStr); err != nil {
+		return
+	}
+	if dayOfMonth <= 0 {
+		err = errors.New("Day of month must be greater than 0.")
+		return
+	}
+	if dayOfMonth > 31 {
+		err = errors.New("Day of month must not be greater than 31.")
+		return
+	}
+	return
+}
+
 func deleteId(id int64, store findb.RemoveRecurringEntryByIdRunner) error {
 	return store.RemoveRecurringEntryById(nil, id)
 }
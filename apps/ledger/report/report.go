@@ -8,7 +8,9 @@ import (
 	"github.com/keep94/finance/fin/categories"
 	"github.com/keep94/finance/fin/categories/categoriesdb"
 	"github.com/keep94/finance/fin/consumers"
+	"github.com/keep94/finance/fin/filters"
 	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
 	"github.com/keep94/toolbox/date_util"
 	"github.com/keep94/toolbox/google_graph"
 	"github.com/keep94/toolbox/http_util"
@@ -113,15 +115,19 @@ var (
           <td><input type="text" name="sd" value="{{.Get "sd"}}"></td>
           <td>End date: </td>
           <td><input type="text" name="ed" value="{{.Get "ed"}}"></td>
+          <td>Tag: </td>
+          <td><input type="text" name="tag" value="{{.Get "tag"}}"></td>
         </tr>
         <tr>
-          <td colspan="6">
+          <td colspan="8">
             <input type="submit" value="Generate report">
           </td>
         </tr>
       </table>
     </form>
 {{if .Sets}}
+  Amounts in {{.Global.Currency}}
+  {{with .Get "tag"}}for entries tagged <b>{{.}}</b>{{end}}
   {{range .Sets}}
     {{if .Url}}
       <h2><a href="{{.Url}}">{{.Name}}</a></h2>
@@ -140,9 +146,15 @@ var (
 	kTemplate *template.Template
 )
 
+// Store methods are from fin.Store
+type Store interface {
+	findb.EntriesRunner
+	findb.CurrencyConverterRunner
+}
+
 type Handler struct {
 	Cdc    categoriesdb.Getter
-	Store  findb.EntriesRunner
+	Store  Store
 	LN     *common.LeftNav
 	Global *common.Global
 }
@@ -155,32 +167,57 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	cds, _ := h.Cdc.Get(nil)
 	start, end, err := getDateRange(r)
+	if err != nil {
+		err = errors.New("Dates must be in yyyyMMdd format.")
+	} else {
+		err = common.CheckTag(r.Form.Get("tag"))
+	}
 	if err != nil {
 		v := &view{
 			Values:       http_util.Values{r.Form},
 			CatDisplayer: common.CatDisplayer{cds},
 			CatDetails:   cds.DetailsByIds(fin.CatSet{fin.Expense: true, fin.Income: true}),
-			Error:        errors.New("Dates must be in yyyyMMdd format."),
+			Error:        err,
 			LeftNav:      leftnav,
 			Global:       h.Global}
 		http_util.WriteTemplate(w, kTemplate, v)
 		return
 	}
 	cat, caterr := fin.CatFromString(r.Form.Get("cat"))
+	converter, err := findb.NewCurrencyConverter(
+		nil, h.Store, h.Global.Currency)
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
 	ct := make(fin.CatTotals)
-	erc := consumers.FromCatPaymentAggregator(ct)
+	erc := &consumers.ConvertCurrency{
+		Converter:     converter,
+		EntryConsumer: consumers.FromCatPaymentAggregator(ct)}
+	var consumer goconsume.Consumer = erc
+	tag := r.Form.Get("tag")
+	if tag != "" {
+		consumer = goconsume.Filter(
+			consumer,
+			filters.CompileAdvanceSearchSpec(
+				&filters.AdvanceSearchSpec{Tag: tag}))
+	}
 	elo := findb.EntryListOptions{Start: &start, End: &end}
-	err = h.Store.Entries(nil, &elo, erc)
+	err = h.Store.Entries(nil, &elo, consumer)
 	if err != nil {
 		http_util.ReportError(w, "Error reading database.", err)
 		return
 	}
+	if erc.Err != nil {
+		erc.Err = common.ErrMissingExchangeRate
+	}
 	rolledCt, children := cds.RollUp(ct)
 	builder := dataSetBuilder{
 		ListUrl: http_util.NewUrl(
 			"/fin/list",
 			"sd", r.Form.Get("sd"),
-			"ed", r.Form.Get("ed")),
+			"ed", r.Form.Get("ed"),
+			"tag", tag),
 		ReportUrl: r.URL,
 		Cds:       cds,
 		Unrolled:  ct,
@@ -201,6 +238,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		CatDisplayer: common.CatDisplayer{cds},
 		Sets:         displaySets,
 		CatDetails:   cds.DetailsByIds(catsInDropDown),
+		Error:        erc.Err,
 		LeftNav:      leftnav,
 		Global:       h.Global}
 
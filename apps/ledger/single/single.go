@@ -1,11 +1,15 @@
 package single
 
 import (
+	"errors"
 	"fmt"
+	"github.com/keep94/finance/apps/ledger/attachment"
 	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/apps/ledger/history"
 	"github.com/keep94/finance/fin"
 	"github.com/keep94/finance/fin/categories"
 	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
 	"github.com/keep94/toolbox/date_util"
 	"github.com/keep94/toolbox/db"
 	"github.com/keep94/toolbox/http_util"
@@ -13,6 +17,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -75,12 +80,33 @@ body {
 {{if .Error}}
   <span class="error">{{.Error.Error}}</span>
 {{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
 <form method="post">
 <input type="hidden" name="xsrf" value="{{.Xsrf}}">
+{{with $top := .}}
+<table>
+  <tr>
+    <td align="right">Template: </td>
+    <td>
+      <select name="template" size=1>
+        <option value="">--Pick one--</option>
+  {{range .Templates}}
+        <option value="{{.Id}}" {{if eq ($top.TemplateParam .Id) ($top.Get "template")}}selected{{end}}>{{.Title}}</option>
+  {{end}}
+      </select>
+      Total: <input type="text" name="template_total" value="{{.Get "template_total"}}" size="12">
+      <input type="submit" name="fill" value="Fill in">
+      <a href="/fin/templates">Manage</a>
+    </td>
+  </tr>
+</table>
+{{end}}
 <input type="submit" name="save" value="Save">
 <input type="submit" name="cancel" value="Cancel">
 {{if .ExistingEntry}}
-<input type="submit" name="delete" value="Delete" onclick="return confirm('Are you sure you want to delete this entry?');">
+<input type="submit" name="delete" value="Delete" onclick="return confirm('Are you sure you want to move this entry to the trash?');">
 <input type="hidden" name="etag" value="{{.Get "etag"}}">
 {{end}}
 {{if .DateMayBeWrong}}
@@ -113,7 +139,11 @@ body {
   <tr>
     <td align="right">Check #: </td>
     <td><input type="text" name="checkno" value="{{.Get "checkno"}}"></td>
+  </tr>
   <tr>
+    <td align="right">Tags: </td>
+    <td><input type="text" name="tags" value="{{.Get "tags"}}"></td>
+  </tr>
 </table>
 <table>
   <tr>
@@ -148,6 +178,9 @@ body {
     <td>
       <input type="text" name="{{.AmountParam}}" value="{{$top.Get .AmountParam}}" size="12">
     </td>
+    <td>
+      <input type="text" name="{{.CurrencyAmountParam}}" value="{{$top.Get .CurrencyAmountParam}}" size="12" title="Amount in account currency e.g EUR 91.50">
+    </td>
     <td>
      <input type="checkbox" name="{{.ReconcileParam}}" {{if $top.Get .ReconcileParam}} checked {{end}}>
     </td>
@@ -155,12 +188,46 @@ body {
   {{end}}
 {{end}}
 </table>
+Template title: <input type="text" name="template_title" value="{{.Get "template_title"}}">
+<input type="submit" name="savetemplate" value="Save as template">
+(Amounts such as 22.5% are a share of the total)
+<br><br>
 <input type="submit" name="save" value="Save">
 <input type="submit" name="cancel" value="Cancel">
 {{if .ExistingEntry}}
-<input type="submit" name="delete" value="Delete" onclick="return confirm('Are you sure you want to delete this entry?');">
+<input type="submit" name="delete" value="Delete" onclick="return confirm('Are you sure you want to move this entry to the trash?');">
 {{end}}
 </form>
+{{if .ExistingEntry}}
+<a href="{{.HistoryLink}}">History</a>
+<h3>Attachments</h3>
+<table>
+{{with $top := .}}
+  {{range .Attachments}}
+  <tr class="lineitem">
+    <td><a href="{{$top.DownloadLink .Id}}">{{.Name}}</a></td>
+    <td align="right">{{.Size}} bytes</td>
+    <td>
+      <form method="post" action="/fin/attachment">
+        <input type="hidden" name="xsrf" value="{{$top.AttachmentXsrf}}">
+        <input type="hidden" name="eid" value="{{$top.EntryId}}">
+        <input type="hidden" name="id" value="{{.Id}}">
+        <input type="hidden" name="prev" value="{{$top.ThisUrl}}">
+        <input type="submit" value="Delete" onclick="return confirm('Are you sure you want to delete this attachment?');">
+      </form>
+    </td>
+  </tr>
+  {{end}}
+{{end}}
+</table>
+<form method="post" action="/fin/attachment" enctype="multipart/form-data">
+  <input type="hidden" name="xsrf" value="{{.AttachmentXsrf}}">
+  <input type="hidden" name="eid" value="{{.EntryId}}">
+  <input type="hidden" name="prev" value="{{.ThisUrl}}">
+  <input type="file" name="contents">
+  <input type="submit" value="Attach">
+</form>
+{{end}}
 </div>
 
 <script type="text/javascript">
@@ -191,6 +258,11 @@ type Store interface {
 	findb.DoEntryChangesRunner
 	findb.EntryByIdRunner
 	findb.EntriesRunner
+	findb.AttachmentsByEntryIdRunner
+	findb.AddEntryTemplateRunner
+	findb.UpdateEntryTemplateRunner
+	findb.EntryTemplateByIdRunner
+	findb.EntryTemplatesRunner
 }
 
 type Handler struct {
@@ -226,6 +298,9 @@ func (h *Handler) doPost(
 	cdc := session.Cache
 	catPopularity := session.CatPopularity()
 	var err error
+	var message string
+	// The entry a template filled in
+	var filled *fin.Entry
 	if !common.VerifyXsrfToken(r, kSingle) {
 		err = common.ErrXsrf
 	} else if http_util.HasParam(r.Form, "delete") {
@@ -234,6 +309,10 @@ func (h *Handler) doPost(
 		}
 	} else if http_util.HasParam(r.Form, "cancel") {
 		// Do nothing
+	} else if http_util.HasParam(r.Form, "fill") {
+		filled, err = fillFromTemplate(r.Form, store)
+	} else if http_util.HasParam(r.Form, "savetemplate") {
+		message, err = saveTemplate(r.Form, store)
 	} else {
 		// Save button
 		var mutation fin.EntryUpdater
@@ -259,7 +338,7 @@ func (h *Handler) doPost(
 			}
 		}
 	}
-	if err != nil {
+	if err != nil || filled != nil || message != "" {
 		if err == findb.ConcurrentUpdate {
 			err = common.ErrConcurrentModification
 		}
@@ -268,18 +347,29 @@ func (h *Handler) doPost(
 		if leftnav == "" {
 			return
 		}
-		http_util.WriteTemplate(
-			w,
-			kTemplate,
-			common.ToSingleEntryViewFromForm(
-				isIdValid(id),
-				r.Form,
-				common.NewXsrfToken(r, kSingle),
-				cds,
-				catPopularity,
-				h.Global,
-				leftnav,
-				err))
+		v := common.ToSingleEntryViewFromForm(
+			isIdValid(id),
+			r.Form,
+			common.NewXsrfToken(r, kSingle),
+			cds,
+			catPopularity,
+			h.Global,
+			leftnav,
+			err)
+		if filled != nil {
+			v.SetEntry(filled)
+		}
+		var attachments []fin.Attachment
+		if isIdValid(id) {
+			// Show the form even if we can't read the attachments.
+			store.AttachmentsByEntryId(
+				nil, id, goconsume.AppendTo(&attachments))
+		}
+		var templates []*fin.EntryTemplate
+		store.EntryTemplates(nil, goconsume.AppendPtrsTo(&templates))
+		result := newView(v, r, id, attachments, templates)
+		result.Message = message
+		http_util.WriteTemplate(w, kTemplate, result)
 	} else {
 		prev := r.Form.Get("prev")
 		if prev == "" {
@@ -303,6 +393,8 @@ func (h *Handler) doGet(
 		return
 	}
 	var v *common.SingleEntryView
+	var attachments []fin.Attachment
+	var templates []*fin.EntryTemplate
 	if isIdValid(id) {
 		var entryWithEtag fin.Entry
 		var cds categories.CatDetailStore
@@ -311,7 +403,15 @@ func (h *Handler) doGet(
 			if err != nil {
 				return
 			}
-			return store.EntryById(t, id, &entryWithEtag)
+			if err = store.EntryById(t, id, &entryWithEtag); err != nil {
+				return
+			}
+			err = store.AttachmentsByEntryId(
+				t, id, goconsume.AppendTo(&attachments))
+			if err != nil {
+				return
+			}
+			return store.EntryTemplates(t, goconsume.AppendPtrsTo(&templates))
 		})
 		if err == findb.NoSuchId {
 			fmt.Fprintln(w, "No entry found.")
@@ -334,6 +434,8 @@ func (h *Handler) doGet(
 		if paymentId > 0 {
 			values.Set("payment", strconv.FormatInt(paymentId, 10))
 		}
+		// Show the form even if we can't read the templates.
+		store.EntryTemplates(nil, goconsume.AppendPtrsTo(&templates))
 		v = common.ToSingleEntryViewFromForm(
 			false,
 			values,
@@ -344,7 +446,8 @@ func (h *Handler) doGet(
 			leftnav,
 			nil)
 	}
-	http_util.WriteTemplate(w, kTemplate, v)
+	http_util.WriteTemplate(
+		w, kTemplate, newView(v, r, id, attachments, templates))
 }
 
 func (h *Handler) isDateReasonable(date time.Time) bool {
@@ -358,7 +461,7 @@ func isIdValid(id int64) bool {
 }
 
 func deleteId(id int64, store findb.DoEntryChangesRunner) error {
-	changes := findb.EntryChanges{Deletes: []int64{id}}
+	changes := findb.EntryChanges{Trash: []int64{id}}
 	return store.DoEntryChanges(nil, &changes)
 }
 
@@ -373,11 +476,106 @@ func updateId(
 	return store.DoEntryChanges(nil, &changes)
 }
 
+// fillFromTemplate returns the entry that the template in the template
+// parameter fills in for the total in the template_total parameter.
+func fillFromTemplate(
+	values url.Values, store findb.EntryTemplateByIdRunner) (
+	*fin.Entry, error) {
+	id, _ := strconv.ParseInt(values.Get("template"), 10, 64)
+	if id == 0 {
+		return nil, errors.New("Pick a template.")
+	}
+	var entryTemplate fin.EntryTemplate
+	if err := store.EntryTemplateById(nil, id, &entryTemplate); err != nil {
+		return nil, err
+	}
+	var total int64
+	totalStr := strings.TrimSpace(values.Get("template_total"))
+	if totalStr != "" {
+		var err error
+		if total, err = fin.ParseUSD(totalStr); err != nil {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid total: %s", totalStr))
+		}
+	} else if len(entryTemplate.Percents) > 0 {
+		return nil, errors.New("This template needs a total.")
+	}
+	var entry fin.Entry
+	entryTemplate.Fill(total, &entry)
+	return &entry, nil
+}
+
+// saveTemplate saves the form values as a template. If a template with
+// the same title already exists, saveTemplate replaces it.
+func saveTemplate(values url.Values, store Store) (string, error) {
+	entryTemplate, err := common.EntryTemplateFromForm(values)
+	if err != nil {
+		return "", err
+	}
+	var templates []*fin.EntryTemplate
+	err = store.EntryTemplates(nil, goconsume.AppendPtrsTo(&templates))
+	if err != nil {
+		return "", err
+	}
+	for _, existing := range templates {
+		if existing.Title == entryTemplate.Title {
+			entryTemplate.Id = existing.Id
+			if err := store.UpdateEntryTemplate(nil, entryTemplate); err != nil {
+				return "", err
+			}
+			return "Template updated.", nil
+		}
+	}
+	if err := store.AddEntryTemplate(nil, entryTemplate); err != nil {
+		return "", err
+	}
+	return "Template saved.", nil
+}
+
 func add(entry *fin.Entry, store findb.DoEntryChangesRunner) error {
 	changes := findb.EntryChanges{Adds: []*fin.Entry{entry}}
 	return store.DoEntryChanges(nil, &changes)
 }
 
+type view struct {
+	*common.SingleEntryView
+	EntryId        int64
+	Attachments    []fin.Attachment
+	AttachmentXsrf string
+	ThisUrl        string
+	Templates      []*fin.EntryTemplate
+	Message        string
+}
+
+func newView(
+	v *common.SingleEntryView,
+	r *http.Request,
+	id int64,
+	attachments []fin.Attachment,
+	templates []*fin.EntryTemplate) *view {
+	return &view{
+		SingleEntryView: v,
+		EntryId:         id,
+		Attachments:     attachments,
+		AttachmentXsrf:  attachment.NewXsrfToken(r),
+		ThisUrl:         r.URL.String(),
+		Templates:       templates}
+}
+
+// TemplateParam returns the value of the template parameter that picks
+// the template with given id.
+func (v *view) TemplateParam(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func (v *view) DownloadLink(id int64) *url.URL {
+	return attachment.DownloadLink(id)
+}
+
+func (v *view) HistoryLink() *url.URL {
+	return history.Link(v.EntryId)
+}
+
 func init() {
 	kTemplate = common.NewTemplate("single", kTemplateSpec)
 }
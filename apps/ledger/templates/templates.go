@@ -0,0 +1,182 @@
+package templates
+
+import (
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories/categoriesdb"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+const (
+	kTemplates = "templates"
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
+<h2>Templates</h2>
+To add or change a template, fill in an entry then save it as a template.
+<br><br>
+{{with $top := .}}
+<table>
+  <tr>
+    <td>Title</td>
+    <td>Name</td>
+    <td>Account</td>
+    <td>Category</td>
+    <td>Amount</td>
+    <td>&nbsp;</td>
+  </tr>
+  {{range .Templates}}
+  <tr class="lineitem">
+    <td>{{.Title}}</td>
+    <td>{{.Name}}</td>
+    <td>{{$top.Payment .}}</td>
+    <td colspan=2>{{.Desc}}{{if .Tags}} [{{.Tags}}]{{end}}</td>
+    <td>
+      <form method="post">
+        <input type="hidden" name="xsrf" value="{{$top.Xsrf}}">
+        <input type="hidden" name="id" value="{{.Id}}">
+        <input type="submit" value="Delete" onclick="return confirm('Are you sure you want to delete this template?');">
+      </form>
+    </td>
+  </tr>
+    {{range $top.Lines .}}
+  <tr>
+    <td colspan=3></td>
+    <td>{{.Name}}</td>
+    <td align=right>{{.Amount}}</td>
+    <td></td>
+  </tr>
+    {{end}}
+  {{else}}
+  <tr><td colspan=6>No templates.</td></tr>
+  {{end}}
+</table>
+{{end}}
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.EntryTemplatesRunner
+	findb.RemoveEntryTemplateRunner
+}
+
+// Handler lists the entry templates. A POST deletes the template with
+// the given id.
+type Handler struct {
+	Cdc    categoriesdb.Getter
+	LN     *common.LeftNav
+	Global *common.Global
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	leftnav := h.LN.Generate(w, r, common.SelectTemplates())
+	if leftnav == "" {
+		return
+	}
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	var postErr error
+	var message string
+	if r.Method == "POST" {
+		if !common.VerifyXsrfToken(r, kTemplates) {
+			postErr = common.ErrXsrf
+		} else {
+			id, _ := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+			if postErr = store.RemoveEntryTemplate(nil, id); postErr == nil {
+				message = "Template deleted."
+			}
+		}
+	}
+	cds, _ := h.Cdc.Get(nil)
+	var templates []*fin.EntryTemplate
+	err := store.EntryTemplates(nil, goconsume.AppendPtrsTo(&templates))
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	http_util.WriteTemplate(
+		w,
+		kTemplate,
+		&view{
+			Templates:    templates,
+			CatDisplayer: common.CatDisplayer{CatDetailStore: cds},
+			Xsrf:         common.NewXsrfToken(r, kTemplates),
+			Message:      message,
+			Error:        postErr,
+			LeftNav:      leftnav,
+			Global:       h.Global})
+}
+
+type line struct {
+	Name string
+	// The amount or percent of the total e.g 22.50%
+	Amount string
+}
+
+type view struct {
+	Templates []*fin.EntryTemplate
+	common.CatDisplayer
+	Xsrf    string
+	Message string
+	Error   error
+	LeftNav template.HTML
+	Global  *common.Global
+}
+
+// Payment returns the name of the payment account of t.
+func (v *view) Payment(t *fin.EntryTemplate) string {
+	if t.PaymentId() == 0 {
+		return "--"
+	}
+	return v.AcctName(&t.CatPayment)
+}
+
+// Lines returns the lines of t.
+func (v *view) Lines(t *fin.EntryTemplate) []line {
+	catrecs := v.SortedCatRecs(t.CatRecs())
+	result := make([]line, len(catrecs))
+	for i, catrec := range catrecs {
+		result[i].Name = v.DetailById(catrec.Cat).FullName()
+		if percent, ok := t.Percents[catrec.Cat]; ok {
+			result[i].Amount = fin.FormatUSD(percent) + "%"
+		} else {
+			result[i].Amount = fin.Money(catrec.Amount).Grouped()
+		}
+	}
+	return result
+}
+
+func init() {
+	kTemplate = common.NewTemplate("templates", kTemplateSpec)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/keep94/finance/apps/ledger/common"
 	"github.com/keep94/finance/fin"
 	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/toolbox/date_util"
 	"github.com/keep94/toolbox/http_util"
 	"html/template"
 	"net/http"
@@ -23,17 +24,23 @@ var (
 {{.LeftNav}}
 <div class="main">
 <h2>Totals</h2>
-Total: {{FormatUSD .Total}}<br><br>
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span><br><br>
+{{else}}
+  Total: {{FormatUSD .Total}} {{.Global.Currency}}<br><br>
+{{end}}
 <table border=1>
   <tr>
     <td>Account</td>
     <td>Total</td>
+    <td>Total in {{.Global.Currency}}</td>
   </td>
 {{with $top := .}}
   {{range .Accounts}}
     <tr>
       <td><a href="{{$top.AccountLink .Id}}">{{.Name}}</a></td>
-      <td align="right">{{FormatUSD .Balance}}</td>
+      <td align="right">{{FormatUSD .Balance}} {{.Currency}}</td>
+      <td align="right">{{with $top.Converted .Id}}{{FormatUSD .Balance}}{{else}}&nbsp;{{end}}</td>
     </tr>
   {{end}}
 {{end}}
@@ -47,8 +54,14 @@ var (
 	kTemplate *template.Template
 )
 
+type Store interface {
+	findb.ActiveAccountsRunner
+	findb.CurrencyConverterRunner
+}
+
 type Handler struct {
-	Store  findb.ActiveAccountsRunner
+	Store  Store
+	Clock  date_util.Clock
 	LN     *common.LeftNav
 	Global *common.Global
 }
@@ -63,24 +76,56 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http_util.ReportError(w, "Database error", err)
 		return
 	}
-	var total int64
+	converter, err := findb.NewCurrencyConverter(
+		nil, h.Store, h.Global.Currency)
+	if err != nil {
+		http_util.ReportError(w, "Database error", err)
+		return
+	}
+	deltas := make(fin.AccountDeltas, len(accounts))
 	for _, account := range accounts {
-		total += account.Balance
+		deltas[account.Id] = &fin.AccountDelta{
+			Balance:  account.Balance,
+			RBalance: account.RBalance,
+			Count:    account.Count,
+			RCount:   account.RCount}
+	}
+	converted, err := converter.ConvertAccountDeltas(
+		deltas, date_util.TimeToDate(h.Clock.Now()))
+	if err == fin.NoExchangeRate {
+		err = common.ErrMissingExchangeRate
+	}
+	var total int64
+	for _, delta := range converted {
+		total += delta.Balance
 	}
 	http_util.WriteTemplate(w, kTemplate, &view{
-		Accounts: accounts,
-		Total:    total,
-		LeftNav:  leftnav,
-		Global:   h.Global,
+		Accounts:  accounts,
+		converted: converted,
+		Total:     total,
+		Error:     err,
+		LeftNav:   leftnav,
+		Global:    h.Global,
 	})
 }
 
 type view struct {
 	common.AccountLinker
 	Accounts []*fin.Account
-	Total    int64
-	LeftNav  template.HTML
-	Global   *common.Global
+	// Balances in the reporting currency. nil if some exchange rates
+	// are missing.
+	converted fin.AccountDeltas
+	// Total balance in the reporting currency
+	Total   int64
+	Error   error
+	LeftNav template.HTML
+	Global  *common.Global
+}
+
+// Converted returns the balances of the account with given id in the
+// reporting currency or nil if they could not be converted.
+func (v *view) Converted(acctId int64) *fin.AccountDelta {
+	return v.converted[acctId]
 }
 
 func init() {
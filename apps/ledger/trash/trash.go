@@ -0,0 +1,159 @@
+package trash
+
+import (
+	"github.com/keep94/finance/apps/ledger/common"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories/categoriesdb"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/http_util"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+const (
+	kTrash = "trash"
+)
+
+var (
+	kTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
+<h2>Trash</h2>
+{{if .RetentionDays}}
+Entries are deleted permanently {{.RetentionDays}} days after they are moved to the trash.
+<br><br>
+{{end}}
+{{with $top := .}}
+<table>
+  <tr>
+    <td>Deleted</td>
+    <td>Date</td>
+    <td>Category</td>
+    <td>Name</td>
+    <td>Amount</td>
+    <td>Account</td>
+    <td>&nbsp;</td>
+  </tr>
+  {{range .Entries}}
+  <tr class="lineitem">
+    <td>{{FormatDate .Trashed.Local}}</td>
+    <td>{{FormatDate .Date}}</td>
+    <td>{{$top.CatName .CatPayment}}</td>
+    <td>{{.Name}}</td>
+    <td align=right>{{FormatUSD .Total}}</td>
+    <td>{{$top.AcctName .CatPayment}}</td>
+    <td>
+      <form method="post">
+        <input type="hidden" name="xsrf" value="{{$top.Xsrf}}">
+        <input type="hidden" name="id" value="{{.Id}}">
+        <input type="submit" value="Restore">
+      </form>
+    </td>
+  </tr>
+  <tr>
+    <td colspan=3></td>
+    <td colspan=4>{{.Desc}}{{if .Tags}} [{{.Tags}}]{{end}}</td>
+  </tr>
+  {{else}}
+  <tr><td colspan=7>The trash is empty.</td></tr>
+  {{end}}
+</table>
+{{end}}
+</div>
+</body>
+</html>`
+)
+
+var (
+	kTemplate *template.Template
+)
+
+// Store methods are from fin.Store
+type Store interface {
+	findb.DoEntryChangesRunner
+	findb.TrashedEntriesRunner
+}
+
+// Handler lists the entries in the trash. A POST restores the entry
+// with the given id.
+type Handler struct {
+	Cdc categoriesdb.Getter
+	// The number of days entries stay in the trash. Shown on the page.
+	RetentionDays int
+	LN            *common.LeftNav
+	Global        *common.Global
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	leftnav := h.LN.Generate(w, r, common.SelectTrash())
+	if leftnav == "" {
+		return
+	}
+	session := common.GetUserSession(r)
+	store := session.Store.(Store)
+	var postErr error
+	var message string
+	if r.Method == "POST" {
+		if !common.VerifyXsrfToken(r, kTrash) {
+			postErr = common.ErrXsrf
+		} else {
+			id, _ := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+			changes := findb.EntryChanges{Restore: []int64{id}}
+			if postErr = store.DoEntryChanges(nil, &changes); postErr == nil {
+				message = "Entry restored."
+			}
+		}
+	}
+	cds, _ := h.Cdc.Get(nil)
+	var entries []fin.TrashedEntry
+	err := store.TrashedEntries(nil, goconsume.AppendTo(&entries))
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
+	http_util.WriteTemplate(
+		w,
+		kTemplate,
+		&view{
+			Entries:       entries,
+			CatDisplayer:  common.CatDisplayer{CatDetailStore: cds},
+			RetentionDays: h.RetentionDays,
+			Xsrf:          common.NewXsrfToken(r, kTrash),
+			Message:       message,
+			Error:         postErr,
+			LeftNav:       leftnav,
+			Global:        h.Global})
+}
+
+type view struct {
+	Entries []fin.TrashedEntry
+	common.CatDisplayer
+	RetentionDays int
+	Xsrf          string
+	Message       string
+	Error         error
+	LeftNav       template.HTML
+	Global        *common.Global
+}
+
+func init() {
+	kTemplate = common.NewTemplate("trash", kTemplateSpec)
+}
@@ -150,23 +150,27 @@ var (
           <td><input type="text" name="sd" value="{{.Get "sd"}}"></td>
           <td>End date: </td>
           <td><input type="text" name="ed" value="{{.Get "ed"}}"></td>
+          <td>Tag: </td>
+          <td><input type="text" name="tag" value="{{.Get "tag"}}"></td>
         </tr>
         <tr>
           <td>Top level: </td>
           <td><input type="checkbox" name="top" {{if .Get "top"}}checked{{end}}></td>
           <td>Frequency: </td>
-          <td colspan="3"><select name="freq">
+          <td colspan="5"><select name="freq">
             <option value="M" {{if .Equals "freq" "M"}}selected{{end}}>Monthly</option>
             <option value="Y" {{if .Equals "freq" "Y"}}selected{{end}}>Yearly</option>
           </select></td>
         </tr>
         <tr>
-          <td colspan="6">
+          <td colspan="8">
             <input type="submit" value="Generate report">
           </td>
         </tr>
       </table>
     </form>
+Amounts in {{.Global.Currency}}
+{{with .Get "tag"}}for entries tagged <b>{{.}}</b>{{end}}
 {{if .Items}}
   {{template "Graph" .}}
 {{else}}
@@ -181,9 +185,15 @@ var (
 	kTemplate *template.Template
 )
 
+// Store methods are from fin.Store
+type Store interface {
+	findb.EntriesRunner
+	findb.CurrencyConverterRunner
+}
+
 type Handler struct {
 	Cdc    categoriesdb.Getter
-	Store  findb.EntriesRunner
+	Store  Store
 	LN     *common.LeftNav
 	Global *common.Global
 }
@@ -197,11 +207,16 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	cds, _ := h.Cdc.Get(nil)
 	cat, caterr := fin.CatFromString(r.Form.Get("cat"))
 	start, end, err := getDateRange(r)
+	if err != nil {
+		err = errors.New("Dates must be in yyyyMMdd format.")
+	} else {
+		err = common.CheckTag(r.Form.Get("tag"))
+	}
 	if err != nil {
 		v := &view{
 			Values:       http_util.Values{r.Form},
 			CatDisplayer: common.CatDisplayer{cds},
-			Error:        errors.New("Dates must be in yyyyMMdd format."),
+			Error:        err,
 			CatDetails:   cds.DetailsByIds(fin.CatSet{fin.Expense: true, fin.Income: true}),
 			LeftNav:      leftnav,
 			Global:       h.Global,
@@ -209,8 +224,14 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http_util.WriteTemplate(w, kTemplate, v)
 		return
 	}
+	converter, err := findb.NewCurrencyConverter(
+		nil, h.Store, h.Global.Currency)
+	if err != nil {
+		http_util.ReportError(w, "Error reading database.", err)
+		return
+	}
 	if caterr == nil {
-		points, graphUrl, cats, err := h.singleCat(cds, r.URL, cat, r.Form.Get("top") != "", start, end, r.Form.Get("freq") == "Y")
+		points, graphUrl, cats, missingRates, err := h.singleCat(cds, converter, r.URL, cat, r.Form.Get("top") != "", r.Form.Get("tag"), start, end, r.Form.Get("freq") == "Y")
 		if err != nil {
 			http_util.ReportError(w, "Error reading database.", err)
 			return
@@ -220,6 +241,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			CatDisplayer: common.CatDisplayer{cds},
 			Items:        points,
 			CatDetails:   cds.DetailsByIds(cats),
+			Error:        missingRates,
 			GraphUrl:     graphUrl,
 			FormatStr:    formatStringLong(r.Form.Get("freq") == "Y"),
 			LeftNav:      leftnav,
@@ -227,7 +249,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		http_util.WriteTemplate(w, kTemplate, v)
 	} else {
-		points, graphUrl, cats, err := h.allCats(cds, r.URL, start, end, r.Form.Get("freq") == "Y")
+		points, graphUrl, cats, missingRates, err := h.allCats(cds, converter, r.URL, r.Form.Get("tag"), start, end, r.Form.Get("freq") == "Y")
 		if err != nil {
 			http_util.ReportError(w, "Error reading database.", err)
 			return
@@ -237,6 +259,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			CatDisplayer: common.CatDisplayer{cds},
 			MultiItems:   points,
 			CatDetails:   cds.DetailsByIds(cats),
+			Error:        missingRates,
 			GraphUrl:     graphUrl,
 			FormatStr:    formatStringLong(r.Form.Get("freq") == "Y"),
 			LeftNav:      leftnav,
@@ -248,11 +271,13 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) singleCat(
 	cds categories.CatDetailStore,
+	converter *fin.CurrencyConverter,
 	thisUrl *url.URL,
 	cat fin.Cat,
 	topOnly bool,
+	tag string,
 	start, end time.Time,
-	isYearly bool) (points []*dataPoint, graphUrl *url.URL, cats fin.CatSet, err error) {
+	isYearly bool) (points []*dataPoint, graphUrl *url.URL, cats fin.CatSet, missingRates, err error) {
 	// Only to see what the child categories are
 	ct := make(fin.CatTotals)
 	totals := createByPeriodTotaler(start, end, isYearly)
@@ -264,11 +289,16 @@ func (h *Handler) singleCat(
 			&filters.AdvanceSearchSpec{CF: cds.Filter(cat, !topOnly)}))
 	elo := findb.EntryListOptions{
 		Start: &start,
-		End:   &end}
-	err = h.Store.Entries(nil, &elo, cr)
+		End:   &end,
+		Cats:  cds.Cats(cat, !topOnly)}
+	cc := &consumers.ConvertCurrency{Converter: converter, EntryConsumer: cr}
+	err = h.Store.Entries(nil, &elo, byTag(cc, tag))
 	if err != nil {
 		return
 	}
+	if cc.Err != nil {
+		missingRates = common.ErrMissingExchangeRate
+	}
 	isIncome := cat.Type == fin.IncomeCat
 	var listUrl *url.URL
 	if topOnly {
@@ -281,6 +311,7 @@ func (h *Handler) singleCat(
 			"/fin/list",
 			"cat", cat.String())
 	}
+	listUrl = withTag(listUrl, tag)
 	var reportUrl *url.URL
 	if isYearly {
 		reportUrl = http_util.WithParams(thisUrl, "freq", "M")
@@ -309,9 +340,11 @@ func (h *Handler) singleCat(
 
 func (h *Handler) allCats(
 	cds categories.CatDetailStore,
+	converter *fin.CurrencyConverter,
 	thisUrl *url.URL,
+	tag string,
 	start, end time.Time,
-	isYearly bool) (points []*multiDataPoint, graphUrl *url.URL, cats fin.CatSet, err error) {
+	isYearly bool) (points []*multiDataPoint, graphUrl *url.URL, cats fin.CatSet, missingRates, err error) {
 	// Only to see what the child categories are
 	ct := make(fin.CatTotals)
 	expenseTotals := createByPeriodTotaler(start, end, isYearly)
@@ -334,11 +367,16 @@ func (h *Handler) allCats(
 	elo := findb.EntryListOptions{
 		Start: &start,
 		End:   &end}
-	err = h.Store.Entries(nil, &elo, cr)
+	cc := &consumers.ConvertCurrency{Converter: converter, EntryConsumer: cr}
+	err = h.Store.Entries(nil, &elo, byTag(cc, tag))
 	if err != nil {
 		return
 	}
+	if cc.Err != nil {
+		missingRates = common.ErrMissingExchangeRate
+	}
 	listUrl := http_util.NewUrl("/fin/list")
+	listUrl = withTag(listUrl, tag)
 	var reportUrl *url.URL
 	if isYearly {
 		reportUrl = http_util.WithParams(thisUrl, "freq", "M")
@@ -529,6 +567,26 @@ func (b *multiDataSetBuilder) Build() (result []*multiDataPoint) {
 	return result
 }
 
+// byTag restricts consumer to entries with given tag. If tag is empty,
+// byTag returns consumer unchanged.
+func byTag(consumer goconsume.Consumer, tag string) goconsume.Consumer {
+	if tag == "" {
+		return consumer
+	}
+	return goconsume.Filter(
+		consumer,
+		filters.CompileAdvanceSearchSpec(&filters.AdvanceSearchSpec{Tag: tag}))
+}
+
+// withTag adds tag to a url for the search page. If tag is empty, withTag
+// returns u unchanged.
+func withTag(u *url.URL, tag string) *url.URL {
+	if tag == "" {
+		return u
+	}
+	return http_util.WithParams(u, "tag", tag)
+}
+
 func init() {
 	kTemplate = common.NewTemplate("trends", kTemplateSpec)
 }
@@ -1,17 +1,20 @@
 package unreconciled
 
 import (
+	"errors"
 	"fmt"
 	"github.com/keep94/finance/apps/ledger/common"
 	"github.com/keep94/finance/fin"
 	"github.com/keep94/finance/fin/categories"
 	"github.com/keep94/finance/fin/findb"
 	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/date_util"
 	"github.com/keep94/toolbox/db"
 	"github.com/keep94/toolbox/http_util"
 	"html/template"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 const (
@@ -38,12 +41,45 @@ var (
 <br><br>
 Balance: {{FormatUSD .Account.Balance}}&nbsp;&nbsp;&nbsp;&nbsp;Reconciled: {{FormatUSD .Account.RBalance}}
 <br><br>
+{{if .Error}}
+  <span class="error">{{.Error.Error}}</span>
+{{end}}
+{{if .Message}}
+  <font color="#006600"><b>{{.Message}}</b></font>
+{{end}}
 <form method="post">
 <input type="hidden" name="xsrf" value="{{.Xsrf}}">
 <input type="hidden" name="edit_id" value="">
+{{if .Statement}}
+<table>
+  <tr>
+    <td>Statement date:</td>
+    <td>{{FormatDate .Statement.Date}}</td>
+  </tr>
+  <tr>
+    <td>Ending balance:</td>
+    <td align=right>{{FormatUSD .Statement.Balance}}</td>
+  </tr>
+  <tr>
+    <td>Difference:</td>
+    <td align=right><span id="difference">{{FormatUSD .Difference}}</span></td>
+  </tr>
+</table>
+<input type="submit" name="save" value="Save">
+<input type="submit" name="finish" id="finish" value="Finish" {{if .Difference}}disabled{{end}}>
+<input type="submit" name="cancel" value="Cancel reconciliation" onclick="return confirm('Are you sure you want to cancel this reconciliation?');">
+<br><br>
+{{else}}
+Statement date (yyyyMMdd): <input type="text" name="sdate" value="{{.Get "sdate"}}" size="10">
+Ending balance: <input type="text" name="sbalance" value="{{.Get "sbalance"}}" size="12">
+<input type="submit" name="start" value="Start reconciliation">
+<br><br>
+{{end}}
 {{if .Values}}
+{{if not .Statement}}
 <input type="submit" value="Reconcile"><br>
-  <input type="checkbox" onchange="selectAll(this, 'selectable')">
+{{end}}
+  <input type="checkbox" onchange="selectAll(this, 'selectable'); updateDifference()">
   <table>
     <tr>
       <td>Reconciled</td>
@@ -55,7 +91,7 @@ Balance: {{FormatUSD .Account.Balance}}&nbsp;&nbsp;&nbsp;&nbsp;Reconciled: {{For
 {{with $top := .}}
   {{range .Values}}
     <tr class="lineitem">
-      <td><input type="checkbox" name="id" class="selectable" value="{{.Id}}"></td>
+      <td><input type="checkbox" name="id" class="selectable" value="{{.Id}}" data-amount="{{.Total}}" onchange="updateDifference()" {{if $top.Selected .Id}}checked{{end}}></td>
       <td>{{FormatDate .Date}}</td>
       <td>{{$top.CatName .CatPayment}}</td>
       <td><a href="#" onclick="document.forms[0].edit_id.value={{.Id}}; document.forms[0].submit()">{{.Name}}</td>
@@ -68,11 +104,52 @@ Balance: {{FormatUSD .Account.Balance}}&nbsp;&nbsp;&nbsp;&nbsp;Reconciled: {{For
   {{end}}
 {{end}}
   </table>
+{{if not .Statement}}
 <input type="submit" value="Reconcile">
+{{end}}
 {{else}}
 No unreconciled entries.
 {{end}}
 </form>
+{{if .Statement}}
+<script>
+function updateDifference() {
+  var checkboxes = document.forms[0].getElementsByClassName('selectable');
+  var difference = {{.Account.RBalance}} - {{.Statement.Balance}};
+  for (var idx = 0; checkboxes[idx]; idx++) {
+    if (checkboxes[idx].checked) {
+      difference += parseInt(checkboxes[idx].getAttribute('data-amount'));
+    }
+  }
+  document.getElementById('difference').innerHTML = (difference / 100).toFixed(2);
+  document.getElementById('finish').disabled = (difference != 0);
+}
+</script>
+{{else}}
+<script>
+function updateDifference() {
+}
+</script>
+{{end}}
+{{if .History}}
+<h2>Statements</h2>
+<table>
+  <tr>
+    <td>Statement date</td>
+    <td>Ending balance</td>
+    <td>Entries</td>
+    <td>Finished</td>
+  </tr>
+  {{range .History}}
+  <tr class="lineitem">
+    <td>{{FormatDate .Date}}</td>
+    <td align=right>{{FormatUSD .Balance}}</td>
+    <td align=right>{{len .EntryIds}}</td>
+    <td>{{.Finished.Local.Format "01/02/2006 15:04"}}</td>
+  </tr>
+  {{end}}
+</table>
+{{end}}
 </div>
 </body>
 </html>`
@@ -82,13 +159,26 @@ var (
 	kTemplate *template.Template
 )
 
+var (
+	errInProgress  = errors.New("A reconciliation is already in progress.")
+	errNotBalanced = errors.New(
+		"Difference must be zero to finish the reconciliation.")
+)
+
 type Store interface {
+	findb.AddStatementRunner
+	findb.RemoveStatementRunner
+	findb.StatementFinisher
+	findb.StatementsByAccountIdRunner
 	findb.UnreconciledEntriesRunner
-	findb.DoEntryChangesRunner
 }
 
+// Handler shows the unreconciled entries of an account. Entries can be
+// reconciled directly or within a session against a bank statement.
+// Only one session per account may be open at a time.
 type Handler struct {
 	Doer     db.Doer
+	Clock    date_util.Clock
 	PageSize int
 	LN       *common.LeftNav
 	Global   *common.Global
@@ -100,20 +190,15 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	store := session.Store.(Store)
 	cache := session.Cache
 	acctId, _ := strconv.ParseInt(r.Form.Get("acctId"), 10, 64)
+	var postErr error
+	var message string
 	if r.Method == "POST" {
 		editId, _ := strconv.ParseInt(r.Form.Get("edit_id"), 10, 64)
 		// Alter DB only if xsrf token is valid
 		if common.VerifyXsrfToken(r, kUnreconciled) {
-			reconciler := func(p *fin.Entry) bool {
-				return p.Reconcile(acctId)
-			}
-			ids := r.Form["id"]
-			updates := make(map[int64]fin.EntryUpdater, len(ids))
-			for _, idStr := range ids {
-				id, _ := strconv.ParseInt(idStr, 10, 64)
-				updates[id] = reconciler
-			}
-			store.DoEntryChanges(nil, &findb.EntryChanges{Updates: updates})
+			message, postErr = h.doPost(store, r, acctId)
+		} else {
+			postErr = common.ErrXsrf
 		}
 		if editId != 0 {
 			entryLinker := &common.EntryLinker{
@@ -137,9 +222,14 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	consumer := goconsume.AppendTo(&entries)
 	consumer = goconsume.Slice(consumer, 0, h.PageSize)
 	account := fin.Account{}
+	var statements []*fin.Statement
 	err := h.Doer.Do(func(t db.Transaction) (err error) {
 		cds, _ = cache.Get(t)
-		return store.UnreconciledEntries(t, acctId, &account, consumer)
+		if err = store.UnreconciledEntries(t, acctId, &account, consumer); err != nil {
+			return
+		}
+		return store.StatementsByAccountId(
+			t, acctId, goconsume.AppendPtrsTo(&statements))
 	})
 	if err == findb.NoSuchId {
 		fmt.Fprintln(w, "No such account.")
@@ -153,16 +243,128 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if leftnav == "" {
 		return
 	}
-	http_util.WriteTemplate(
-		w,
-		kTemplate,
-		&view{
-			entries,
-			common.CatDisplayer{cds},
-			common.NewXsrfToken(r, kUnreconciled),
-			&account,
-			leftnav,
-			h.Global})
+	v := &view{
+		Values:       entries,
+		CatDisplayer: common.CatDisplayer{CatDetailStore: cds},
+		Xsrf:         common.NewXsrfToken(r, kUnreconciled),
+		Account:      &account,
+		Form:         http_util.Values{Values: r.Form},
+		Message:      message,
+		Error:        postErr,
+		LeftNav:      leftnav,
+		Global:       h.Global}
+	v.Statement, v.History = splitStatements(statements)
+	if v.Statement != nil {
+		v.selected = make(map[int64]bool, len(v.Statement.EntryIds))
+		for _, id := range v.Statement.EntryIds {
+			v.selected[id] = true
+		}
+		var cleared int64
+		for i := range entries {
+			if v.selected[entries[i].Id] {
+				cleared += entries[i].Total()
+			}
+		}
+		v.Difference = v.Statement.Difference(account.RBalance, cleared)
+	}
+	http_util.WriteTemplate(w, kTemplate, v)
+}
+
+func (h *Handler) doPost(store Store, r *http.Request, acctId int64) (
+	message string, err error) {
+	ids := r.Form["id"]
+	entryIds := make([]int64, len(ids))
+	for i, idStr := range ids {
+		entryIds[i], _ = strconv.ParseInt(idStr, 10, 64)
+	}
+	var statements []*fin.Statement
+	err = store.StatementsByAccountId(
+		nil, acctId, goconsume.AppendPtrsTo(&statements))
+	if err != nil {
+		return
+	}
+	statement, _ := splitStatements(statements)
+	if http_util.HasParam(r.Form, "start") {
+		if statement != nil {
+			err = errInProgress
+			return
+		}
+		return startStatement(store, r, acctId)
+	}
+	if statement == nil {
+		reconciler := func(p *fin.Entry) bool {
+			return p.Reconcile(acctId)
+		}
+		updates := make(map[int64]fin.EntryUpdater, len(entryIds))
+		for _, id := range entryIds {
+			updates[id] = reconciler
+		}
+		err = store.DoEntryChanges(nil, &findb.EntryChanges{Updates: updates})
+		return
+	}
+	if http_util.HasParam(r.Form, "cancel") {
+		if err = store.RemoveStatement(nil, statement.Id); err != nil {
+			return
+		}
+		return "Reconciliation cancelled.", nil
+	}
+	if http_util.HasParam(r.Form, "finish") {
+		err = h.Doer.Do(func(t db.Transaction) error {
+			return findb.FinishStatement(
+				t, store, statement, entryIds, h.Clock.Now())
+		})
+		if err == findb.NotBalanced {
+			err = errNotBalanced
+		}
+		if err != nil {
+			return
+		}
+		return "Reconciliation finished.", nil
+	}
+	// Any other post including following a link saves the selection.
+	statement.EntryIds = entryIds
+	if err = store.UpdateStatement(nil, statement); err != nil {
+		return
+	}
+	if http_util.HasParam(r.Form, "save") {
+		message = "Reconciliation saved."
+	}
+	return
+}
+
+func startStatement(
+	store findb.AddStatementRunner, r *http.Request, acctId int64) (
+	message string, err error) {
+	statement := fin.Statement{AcctId: acctId}
+	statement.Date, err = time.Parse(
+		date_util.YMDFormat, common.NormalizeYMDStr(r.Form.Get("sdate")))
+	if err != nil {
+		err = errors.New("Statement date must be in yyyyMMdd format.")
+		return
+	}
+	statement.Balance, err = fin.ParseUSD(r.Form.Get("sbalance"))
+	if err != nil {
+		err = errors.New("Invalid ending balance.")
+		return
+	}
+	if err = store.AddStatement(nil, &statement); err != nil {
+		return
+	}
+	return "Reconciliation started.", nil
+}
+
+// splitStatements splits statements into the open session, if any, and
+// the finished ones.
+func splitStatements(statements []*fin.Statement) (
+	open *fin.Statement, finished []*fin.Statement) {
+	for _, statement := range statements {
+		if statement.Done() {
+			finished = append(finished, statement)
+		} else if open == nil {
+			open = statement
+		}
+	}
+	return
 }
 
 type view struct {
@@ -170,10 +372,29 @@ type view struct {
 	common.CatDisplayer
 	Xsrf    string
 	Account *fin.Account
+	Form    http_util.Values
+	// The open reconciliation session, nil if there is none.
+	Statement *fin.Statement
+	// How far the reconciled balance plus the selected entries is from
+	// the statement balance.
+	Difference int64
+	selected   map[int64]bool
+	// The finished reconciliation sessions, most recent first.
+	History []*fin.Statement
+	Message string
+	Error   error
 	LeftNav template.HTML
 	Global  *common.Global
 }
 
+func (v *view) Get(key string) string {
+	return v.Form.Get(key)
+}
+
+func (v *view) Selected(id int64) bool {
+	return v.selected[id]
+}
+
 func init() {
 	kTemplate = common.NewTemplate("unreconciled", kTemplateSpec)
 }
@@ -3,6 +3,7 @@ package upload
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"github.com/keep94/finance/apps/ledger/common"
 	"github.com/keep94/finance/fin"
 	"github.com/keep94/finance/fin/aggregators"
@@ -54,13 +55,26 @@ var (
   <input type="hidden" name="xsrf" value="{{.Xsrf}}">
   <table>
     <tr>
-      <td>QFX file: </td>
+      <td>File: </td>
       <td><input type="file" name="contents"></td>
     </tr>
     <tr>
       <td>Start Date (YYYYmmdd): </td>
       <td><input type="text" name="sd" value="{{.StartDate}}"></td>
     </tr>
+    <tr>
+      <td>Bank account id: </td>
+      <td><input type="text" name="bankAcctId" value="{{.BankAccountId}}"></td>
+    </tr>
+    <tr>
+      <td colspan=2>Only needed the first time a file has several bank accounts.</td>
+    </tr>
+{{if .Conflict}}
+    <tr>
+      <td colspan=2><input type="checkbox" name="remapBankAcct" value="on">
+        Use this account for the bank account from now on.</td>
+    </tr>
+{{end}}
   </table>
   <table>
     <tr>
@@ -119,12 +133,61 @@ var (
 </form>
 </div>
 </body>
+</html>`
+
+	kSummaryTemplateSpec = `
+<html>
+<head>
+  <title>{{.Global.Title}}</title>
+  {{if .Global.Icon}}
+    <link rel="shortcut icon" href="/images/favicon.ico" type="image/x-icon" />
+  {{end}}
+  <link rel="stylesheet" type="text/css" href="/static/theme.css" />
+</head>
+<body>
+{{.LeftNav}}
+<div class="main">
+<h2>{{.Account.Name}} Import Entries</h2>
+This file has more than one bank account. Confirm the entries of each
+account separately.
+<br><br>
+<table>
+  <tr>
+    <td>Bank account</td>
+    <td>Account</td>
+    <td>New entries</td>
+    <td>&nbsp;</td>
+  </tr>
+{{range .Summaries}}
+  <tr>
+    <td>{{.BankAccountId}}</td>
+    <td>{{.Account.Name}}</td>
+    <td>{{.Count}}</td>
+    <td>
+{{if .Count}}
+      <a href="{{$.UploadLink .Account.Id}}">Confirm</a>
+{{else}}
+      &nbsp;
+{{end}}
+    </td>
+  </tr>
+{{end}}
+{{range .Skipped}}
+  <tr>
+    <td>{{.}}</td>
+    <td colspan=3>Skipped. Import this file from the right account page and enter {{.}} as the bank account id.</td>
+  </tr>
+{{end}}
+</table>
+</div>
+</body>
 </html>`
 )
 
 var (
 	kUploadTemplate  *template.Template
 	kConfirmTemplate *template.Template
+	kSummaryTemplate *template.Template
 )
 
 type Store interface {
@@ -133,6 +196,8 @@ type Store interface {
 	findb.DoEntryChangesRunner
 	findb.UnreconciledEntriesRunner
 	findb.UpdateAccountImportSDRunner
+	findb.BankAccountsRunner
+	findb.UpdateBankAccountRunner
 }
 
 type Handler struct {
@@ -279,6 +344,8 @@ func (h *Handler) serveUploadPage(
 	} else {
 		xsrf := ""
 		sdStr := ""
+		bankAccountId := ""
+		remap := false
 		qfxFile := bytes.Buffer{}
 		var fileTooLarge bool
 		var loader autoimport.Loader
@@ -306,6 +373,16 @@ func (h *Handler) serveUploadPage(
 					return
 				}
 				sdStr = buffer.String()
+			} else if part.FormName() == "bankAcctId" {
+				buffer := bytes.Buffer{}
+				_, err = buffer.ReadFrom(part)
+				if err != nil {
+					http_util.ReportError(w, "Error reading bankAcctId", err)
+					return
+				}
+				bankAccountId = strings.TrimSpace(buffer.String())
+			} else if part.FormName() == "remapBankAcct" {
+				remap = true
 			} else if part.FormName() == "contents" {
 				loader = uploaders[fileExtension(part.FileName())]
 				limitedReader := io.LimitedReader{R: part, N: kMaxUploadSize}
@@ -322,11 +399,12 @@ func (h *Handler) serveUploadPage(
 			return
 		}
 		view := &view{
-			Account:   &account,
-			StartDate: sdStr,
-			Xsrf:      common.NewXsrfToken(r, kUpload),
-			LeftNav:   leftnav,
-			Global:    h.Global}
+			Account:       &account,
+			StartDate:     sdStr,
+			BankAccountId: bankAccountId,
+			Xsrf:          common.NewXsrfToken(r, kUpload),
+			LeftNav:       leftnav,
+			Global:        h.Global}
 		if !common.VerifyXsrfTokenExplicit(xsrf, r, kUpload) {
 			showView(w, view, common.ErrXsrf)
 			return
@@ -336,7 +414,6 @@ func (h *Handler) serveUploadPage(
 			showView(w, view, errors.New("Start date must be in yyyyMMdd format."))
 			return
 		}
-		store.UpdateAccountImportSD(nil, acctId, sd)
 		if fileTooLarge {
 			showView(w, view, errors.New("File too large."))
 			return
@@ -349,11 +426,67 @@ func (h *Handler) serveUploadPage(
 			showView(w, view, errors.New("File extension not recognized."))
 			return
 		}
+		institution, bankAccountIds, err := listBankAccounts(
+			loader, qfxFile.Bytes())
+		if err != nil {
+			showView(w, view, err)
+			return
+		}
+		if len(bankAccountIds) == 0 {
+			// Files without bank account ids are for just one account.
+			bankAccountId = ""
+		} else if bankAccountId != "" && !contains(bankAccountIds, bankAccountId) {
+			showView(w, view, fmt.Errorf(
+				"Bank account %s not in file. Choose one of: %s",
+				bankAccountId,
+				strings.Join(bankAccountIds, ", ")))
+			return
+		}
+		if bankAccountId == "" && len(bankAccountIds) == 1 {
+			bankAccountId = bankAccountIds[0]
+		}
+		if bankAccountId != "" {
+			// Remember that this bank account goes with this account.
+			other, err := saveBankAccount(
+				store,
+				fin.BankAccount{
+					Institution: institution, BankAccountId: bankAccountId},
+				&account,
+				remap)
+			if err != nil {
+				http_util.ReportError(w, "Error saving bank account.", err)
+				return
+			}
+			if other != nil {
+				view.Conflict = true
+				showView(w, view, fmt.Errorf(
+					"Bank account %s goes with account %s. To use this account for it instead, check the box below and upload again.",
+					bankAccountId,
+					other.Name))
+				return
+			}
+		}
+		if len(bankAccountIds) > 1 {
+			h.splitUpload(
+				w,
+				r,
+				&account,
+				store,
+				loader,
+				institution,
+				bankAccountIds,
+				bankAccountId,
+				qfxFile.Bytes(),
+				sd,
+				view)
+			return
+		}
 		batch, err := loader.Load(acctId, "", &qfxFile, sd)
 		if err != nil {
 			showView(w, view, err)
 			return
 		}
+		store.UpdateAccountImportSD(nil, acctId, sd)
 		batch, err = batch.SkipProcessed(nil)
 		if err != nil {
 			http_util.ReportError(w, "Error skipping already processed entries.", err)
@@ -370,6 +503,159 @@ func (h *Handler) serveUploadPage(
 	}
 }
 
+// saveBankAccount remembers that bankAccount goes with account. If
+// bankAccount already goes with another active account, saveBankAccount
+// changes that only when remap is true. Otherwise it leaves the mapping
+// alone and returns the other account. saveBankAccount quietly does
+// nothing if the user may not change the mapping.
+func saveBankAccount(
+	store Store,
+	bankAccount fin.BankAccount,
+	account *fin.Account,
+	remap bool) (other *fin.Account, err error) {
+	mapping, err := store.BankAccounts(nil)
+	if err != nil {
+		return
+	}
+	existing := mapping[bankAccount]
+	if existing == account.Id {
+		return
+	}
+	if existing != 0 && !remap {
+		var mapped fin.Account
+		err = store.AccountById(nil, existing, &mapped)
+		if err != nil && err != findb.NoSuchId {
+			return
+		}
+		// Mappings to deleted or inactive accounts can change freely.
+		if err == nil && mapped.Active {
+			return &mapped, nil
+		}
+	}
+	err = store.UpdateBankAccount(nil, bankAccount, account.Id)
+	if err == findb.NoPermission {
+		err = nil
+	}
+	return
+}
+
+// splitUpload loads one batch for each bank account in a file that is
+// mapped to an account and stores each batch under its account so that
+// each account's entries get confirmed separately. Then it shows how
+// many new entries each account has. bankAccountId, if non-empty, is the
+// bank account in the file that goes with account. sd is the import
+// start date for account.
+func (h *Handler) splitUpload(
+	w http.ResponseWriter,
+	r *http.Request,
+	account *fin.Account,
+	store Store,
+	loader autoimport.Loader,
+	institution string,
+	bankAccountIds []string,
+	bankAccountId string,
+	contents []byte,
+	sd time.Time,
+	v *view) {
+	mapping, err := store.BankAccounts(nil)
+	if err != nil {
+		http_util.ReportError(w, "Error reading bank accounts.", err)
+		return
+	}
+	if bankAccountId != "" {
+		mapping[fin.BankAccount{
+			Institution: institution, BankAccountId: bankAccountId}] = account.Id
+	}
+	sv := &summaryView{Account: account, LeftNav: v.LeftNav, Global: h.Global}
+	var batches map[int64]autoimport.Batch
+	batches, sv.Summaries, sv.Skipped, err = splitBatches(
+		store,
+		loader,
+		mapping,
+		institution,
+		bankAccountIds,
+		account,
+		contents,
+		sd)
+	if err != nil {
+		showView(w, v, err)
+		return
+	}
+	if len(sv.Summaries) == 0 {
+		showView(w, v, fmt.Errorf(
+			"File has more than one bank account. Enter the one for this account: %s",
+			strings.Join(bankAccountIds, ", ")))
+		return
+	}
+	if batches[account.Id] != nil {
+		store.UpdateAccountImportSD(nil, account.Id, sd)
+	}
+	userSession := common.GetUserSession(r)
+	for acctId, batch := range batches {
+		if batch.Len() > 0 {
+			userSession.SetBatch(acctId, batch)
+		}
+	}
+	userSession.Save(r, w)
+	http_util.WriteTemplate(w, kSummaryTemplate, sv)
+}
+
+// splitBatches loads the new entries of each bank account in contents
+// that mapping maps to an active account. The batch for account starts
+// at sd; batches for other accounts start at their own import start
+// date. splitBatches returns the batches by account id along with a
+// summary of each batch and the bank accounts it skipped.
+func splitBatches(
+	store Store,
+	loader autoimport.Loader,
+	mapping map[fin.BankAccount]int64,
+	institution string,
+	bankAccountIds []string,
+	account *fin.Account,
+	contents []byte,
+	sd time.Time) (
+	batches map[int64]autoimport.Batch,
+	summaries []*summary,
+	skipped []string,
+	err error) {
+	batches = make(map[int64]autoimport.Batch)
+	for _, id := range bankAccountIds {
+		acctId := mapping[fin.BankAccount{
+			Institution: institution, BankAccountId: id}]
+		if acctId == 0 || batches[acctId] != nil {
+			skipped = append(skipped, id)
+			continue
+		}
+		var mapped fin.Account
+		err = store.AccountById(nil, acctId, &mapped)
+		if err == findb.NoSuchId || (err == nil && !mapped.Active) {
+			skipped = append(skipped, id)
+			continue
+		}
+		if err != nil {
+			return
+		}
+		start := mapped.ImportSD
+		if acctId == account.Id {
+			start = sd
+		}
+		var batch autoimport.Batch
+		batch, err = loader.Load(acctId, id, bytes.NewReader(contents), start)
+		if err != nil {
+			return
+		}
+		if batch, err = batch.SkipProcessed(nil); err != nil {
+			return
+		}
+		batches[acctId] = batch
+		summaries = append(
+			summaries,
+			&summary{
+				BankAccountId: id, Account: &mapped, Count: batch.Len()})
+	}
+	return batches, summaries, skipped, nil
+}
+
 func (h *Handler) showConfirmView(
 	w http.ResponseWriter, v *confirmView, xsrf string, leftnav template.HTML) {
 	v.Xsrf = xsrf
@@ -385,12 +671,34 @@ func showView(
 }
 
 type view struct {
+	Account       *fin.Account
+	StartDate     string
+	BankAccountId string
+	// true if the bank account goes with a different account
+	Conflict bool
+	Xsrf     string
+	Error    error
+	LeftNav  template.HTML
+	Global   *common.Global
+}
+
+type summary struct {
+	BankAccountId string
+	Account       *fin.Account
+
+	// The number of new entries
+	Count int
+}
+
+type summaryView struct {
+	common.AccountLinker
 	Account   *fin.Account
-	StartDate string
-	Xsrf      string
-	Error     error
-	LeftNav   template.HTML
-	Global    *common.Global
+	Summaries []*summary
+
+	// The bank accounts in the file that are not mapped to an account.
+	Skipped []string
+	LeftNav template.HTML
+	Global  *common.Global
 }
 
 type confirmView struct {
@@ -423,6 +731,27 @@ func computeConfirmView(
 	return result
 }
 
+// listBankAccounts returns the institution and bank account ids in
+// contents. If loader does not implement autoimport.BankAccountLister,
+// listBankAccounts returns no bank accounts.
+func listBankAccounts(loader autoimport.Loader, contents []byte) (
+	institution string, bankAccountIds []string, err error) {
+	lister, ok := loader.(autoimport.BankAccountLister)
+	if !ok {
+		return
+	}
+	return lister.BankAccounts(bytes.NewReader(contents))
+}
+
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
 func fileExtension(filename string) string {
 	return strings.ToLower(path.Ext(filename))
 }
@@ -430,4 +759,5 @@ func fileExtension(filename string) string {
 func init() {
 	kUploadTemplate = common.NewTemplate("upload", kUploadTemplateSpec)
 	kConfirmTemplate = common.NewTemplate("upload_confirm", kConfirmTemplateSpec)
+	kSummaryTemplate = common.NewTemplate("upload_summary", kSummaryTemplateSpec)
 }
@@ -0,0 +1,161 @@
+package upload
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/autoimport"
+	"github.com/keep94/finance/fin/findb/for_memory"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveBankAccount(t *testing.T) {
+	store := for_memory.New(memory_db.New())
+	checking := addAccount(t, store, "checking")
+	savings := addAccount(t, store, "savings")
+	bankAccount := fin.BankAccount{
+		Institution: "COBADEFF", BankAccountId: "1234"}
+
+	// A new bank account gets mapped.
+	other, err := saveBankAccount(store, bankAccount, checking, false)
+	if err != nil || other != nil {
+		t.Fatalf("Expected no conflict, got %v, %v", other, err)
+	}
+	verifyMapping(t, store, bankAccount, checking.Id)
+
+	// Uploading into another account doesn't change the mapping.
+	other, err = saveBankAccount(store, bankAccount, savings, false)
+	if err != nil {
+		t.Fatalf("Got error saving bank account: %v", err)
+	}
+	if other == nil || other.Id != checking.Id {
+		t.Errorf("Expected conflict with checking, got %v", other)
+	}
+	verifyMapping(t, store, bankAccount, checking.Id)
+
+	// Unless the user confirms.
+	other, err = saveBankAccount(store, bankAccount, savings, true)
+	if err != nil || other != nil {
+		t.Fatalf("Expected no conflict, got %v, %v", other, err)
+	}
+	verifyMapping(t, store, bankAccount, savings.Id)
+
+	// Mappings to inactive accounts change freely.
+	savings.Active = false
+	if err := store.UpdateAccount(nil, savings); err != nil {
+		t.Fatalf("Got error updating account: %v", err)
+	}
+	other, err = saveBankAccount(store, bankAccount, checking, false)
+	if err != nil || other != nil {
+		t.Fatalf("Expected no conflict, got %v, %v", other, err)
+	}
+	verifyMapping(t, store, bankAccount, checking.Id)
+}
+
+func TestSplitBatches(t *testing.T) {
+	store := for_memory.New(memory_db.New())
+	checking := addAccount(t, store, "checking")
+	savings := addAccount(t, store, "savings")
+	savingsSD := date_util.YMD(2015, 6, 1)
+	if err := store.UpdateAccountImportSD(nil, savings.Id, savingsSD); err != nil {
+		t.Fatalf("Got error updating import start date: %v", err)
+	}
+	mapping := map[fin.BankAccount]int64{
+		{Institution: "COBADEFF", BankAccountId: "1234"}: checking.Id,
+		{Institution: "COBADEFF", BankAccountId: "5678"}: savings.Id}
+	loader := make(startDateLoader)
+	sd := date_util.YMD(2015, 9, 1)
+	batches, summaries, skipped, err := splitBatches(
+		store,
+		loader,
+		mapping,
+		"COBADEFF",
+		[]string{"1234", "5678", "9999"},
+		checking,
+		nil,
+		sd)
+	if err != nil {
+		t.Fatalf("Got error splitting batches: %v", err)
+	}
+	// Each account imports from its own start date.
+	expected := startDateLoader{"1234": sd, "5678": savingsSD}
+	if !reflect.DeepEqual(expected, loader) {
+		t.Errorf("Expected %v, got %v", expected, loader)
+	}
+	if len(batches) != 2 || batches[checking.Id] == nil || batches[savings.Id] == nil {
+		t.Errorf("Expected batches for checking and savings, got %v", batches)
+	}
+	if len(summaries) != 2 {
+		t.Errorf("Expected 2 summaries, got %v", summaries)
+	}
+	if !reflect.DeepEqual([]string{"9999"}, skipped) {
+		t.Errorf("Expected 9999 skipped, got %v", skipped)
+	}
+	// splitBatches leaves import start dates alone.
+	var account fin.Account
+	if err := store.AccountById(nil, savings.Id, &account); err != nil {
+		t.Fatalf("Got error reading account: %v", err)
+	}
+	if !account.ImportSD.Equal(savingsSD) {
+		t.Errorf("Expected %v, got %v", savingsSD, account.ImportSD)
+	}
+}
+
+func addAccount(
+	t *testing.T, store for_memory.Store, name string) *fin.Account {
+	account := &fin.Account{Name: name, Active: true}
+	if err := store.AddAccount(nil, account); err != nil {
+		t.Fatalf("Got error adding account: %v", err)
+	}
+	return account
+}
+
+func verifyMapping(
+	t *testing.T,
+	store for_memory.Store,
+	bankAccount fin.BankAccount,
+	acctId int64) {
+	t.Helper()
+	mapping, err := store.BankAccounts(nil)
+	if err != nil {
+		t.Fatalf("Got error reading bank accounts: %v", err)
+	}
+	if output := mapping[bankAccount]; output != acctId {
+		t.Errorf("Expected %d, got %d", acctId, output)
+	}
+}
+
+// startDateLoader records the start date used to load each bank account.
+type startDateLoader map[string]time.Time
+
+func (s startDateLoader) Load(
+	accountId int64,
+	bankAccountId string,
+	r io.Reader,
+	startDate time.Time) (autoimport.Batch, error) {
+	s[bankAccountId] = startDate
+	return emptyBatch{}, nil
+}
+
+type emptyBatch struct {
+}
+
+func (e emptyBatch) Entries() []*fin.Entry {
+	return nil
+}
+
+func (e emptyBatch) SkipProcessed(t db.Transaction) (autoimport.Batch, error) {
+	return e, nil
+}
+
+func (e emptyBatch) MarkProcessed(t db.Transaction) error {
+	return nil
+}
+
+func (e emptyBatch) Len() int {
+	return 0
+}
@@ -0,0 +1,136 @@
+// ledgerbackup takes snapshots of a ledger database while ledger is
+// running and restores them.
+//
+// To take a snapshot and keep only the newest 7 in a directory:
+//
+//	ledgerbackup -db ledger.db -dir backups -keep 7 -compress
+//
+// To restore a snapshot, first stop ledger, then run:
+//
+//	ledgerbackup -db ledger.db -restore backups/ledger-20200102-030405.db.gz
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/keep94/finance/fin/findb/sqlite_backup"
+	"github.com/keep94/finance/fin/findb/sqlite_setup"
+	"github.com/keep94/gosqlite/sqlite"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	// How long to wait for ledger to release its locks in milliseconds
+	kBusyTimeout = 10000
+)
+
+var (
+	fDb             string
+	fDir            string
+	fKeep           int
+	fCompress       bool
+	fPassphraseFile string
+	fRestore        string
+)
+
+func main() {
+	flag.Parse()
+	if fDb == "" || (fDir == "") == (fRestore == "") {
+		fmt.Println("Need to specify -db flag and exactly one of -dir or -restore flags.")
+		flag.Usage()
+		os.Exit(1)
+	}
+	passphrase, err := readPassphrase(fPassphraseFile)
+	if err != nil {
+		fmt.Printf("Unable to read passphrase - %v\n", err)
+		os.Exit(1)
+	}
+	if fRestore == "" {
+		if _, err := os.Stat(fDb); err != nil {
+			fmt.Printf("Unable to open database - %s\n", fDb)
+			os.Exit(1)
+		}
+	}
+	conn, err := sqlite.Open(fDb)
+	if err != nil {
+		fmt.Printf("Unable to open database - %s\n", fDb)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	if err := conn.BusyTimeout(kBusyTimeout); err != nil {
+		fmt.Printf("Got database error: %v\n", err)
+		os.Exit(1)
+	}
+	if fRestore != "" {
+		err = restore(conn, fRestore, passphrase)
+	} else {
+		err = save(conn, fDir, fKeep, passphrase)
+	}
+	if err != nil {
+		fmt.Printf("Got error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func save(conn *sqlite.Conn, dir string, keep int, passphrase string) error {
+	path, err := sqlite_backup.Save(
+		conn,
+		dir,
+		keep,
+		&sqlite_backup.Options{Compress: fCompress, Passphrase: passphrase})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
+
+func restore(conn *sqlite.Conn, path string, passphrase string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	version, err := sqlite_backup.Restore(conn, f, passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s at schema version %d.\n", path, version)
+	if version < sqlite_setup.LatestVersion() {
+		fmt.Println("Run ledgermigrate before starting ledger.")
+	}
+	return nil
+}
+
+// readPassphrase returns the first line of fileName or the empty string
+// if fileName is empty.
+func readPassphrase(fileName string) (string, error) {
+	if fileName == "" {
+		return "", nil
+	}
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", err
+	}
+	line := strings.SplitN(string(content), "\n", 2)[0]
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+func init() {
+	flag.StringVar(&fDb, "db", "", "Path to database file")
+	flag.StringVar(&fDir, "dir", "", "Directory for snapshots")
+	flag.IntVar(
+		&fKeep,
+		"keep",
+		7,
+		"Number of snapshots to keep in dir; 0 keeps all of them")
+	flag.BoolVar(&fCompress, "compress", false, "Compress snapshots")
+	flag.StringVar(
+		&fPassphraseFile,
+		"passphrase_file",
+		"",
+		"File holding passphrase to encrypt or decrypt snapshots")
+	flag.StringVar(&fRestore, "restore", "", "Snapshot to restore")
+}
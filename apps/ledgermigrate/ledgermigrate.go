@@ -0,0 +1,76 @@
+// ledgermigrate brings a ledger database up to the latest schema version.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/keep94/finance/fin/findb/sqlite_setup"
+	"github.com/keep94/gosqlite/sqlite"
+	"github.com/keep94/toolbox/db/sqlite_db"
+	"os"
+)
+
+var (
+	fDb     string
+	fDryRun bool
+)
+
+func main() {
+	flag.Parse()
+	if fDb == "" {
+		fmt.Println("Need to specify at least -db flag.")
+		flag.Usage()
+		os.Exit(1)
+	}
+	conn, err := sqlite.Open(fDb)
+	if err != nil {
+		fmt.Printf("Unable to open database - %s\n", fDb)
+		os.Exit(1)
+	}
+	dbase := sqlite_db.New(conn)
+	defer dbase.Close()
+	err = dbase.Do(func(conn *sqlite.Conn) error {
+		version, err := sqlite_setup.Version(conn)
+		if err != nil {
+			return err
+		}
+		pending, err := sqlite_setup.PendingMigrations(conn)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database is at schema version %d.\n", version)
+		if len(pending) == 0 {
+			fmt.Println("No pending migrations.")
+			return nil
+		}
+		if fDryRun {
+			fmt.Println("Would apply the following migrations:")
+		} else {
+			fmt.Println("Applying the following migrations:")
+		}
+		fmt.Println()
+		for _, migration := range pending {
+			fmt.Println(migration)
+		}
+		if fDryRun {
+			return nil
+		}
+		if err = sqlite_setup.Migrate(conn); err != nil {
+			return err
+		}
+		fmt.Println()
+		fmt.Printf(
+			"Database is now at schema version %d.\n",
+			sqlite_setup.LatestVersion())
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Got database error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	flag.StringVar(&fDb, "db", "", "Path to database file")
+	flag.BoolVar(&fDryRun, "dryrun", false, "Show pending migrations only")
+}
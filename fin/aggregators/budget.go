@@ -0,0 +1,121 @@
+package aggregators
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories"
+	"github.com/keep94/toolbox/date_util"
+	"time"
+)
+
+// BudgetTotaler totals entries by category so that they can be compared
+// against budget items. BudgetTotaler also keeps monthly totals for entries
+// before the report period so that it can compute rollover amounts.
+type BudgetTotaler struct {
+	start   time.Time
+	end     time.Time
+	current fin.CatTotals
+	byMonth map[time.Time]fin.CatTotals
+}
+
+// NewBudgetTotaler creates a new BudgetTotaler for a report period that
+// runs from start inclusive to end exclusive. start and end are normally
+// the start of a month or year.
+func NewBudgetTotaler(start, end time.Time) *BudgetTotaler {
+	return &BudgetTotaler{
+		start:   date_util.TimeToDate(start),
+		end:     date_util.TimeToDate(end),
+		current: make(fin.CatTotals),
+		byMonth: make(map[time.Time]fin.CatTotals)}
+}
+
+// BudgetStart returns how far back the caller must fetch entries so that
+// a BudgetTotaler can compute rollover amounts for items. start is the
+// start of the report period.
+func BudgetStart(items []*fin.BudgetItem, start time.Time) time.Time {
+	result := date_util.TimeToDate(start)
+	for _, item := range items {
+		if !item.Rollover || item.Start.IsZero() {
+			continue
+		}
+		itemStart := Monthly().Normalize(item.Start)
+		if itemStart.Before(result) {
+			result = itemStart
+		}
+	}
+	return result
+}
+
+func (b *BudgetTotaler) Include(entry *fin.Entry) {
+	if !b.end.After(entry.Date) {
+		return
+	}
+	if !entry.Date.Before(b.start) {
+		b.current.Include(&entry.CatPayment)
+		return
+	}
+	month := Monthly().Normalize(entry.Date)
+	totals := b.byMonth[month]
+	if totals == nil {
+		totals = make(fin.CatTotals)
+		b.byMonth[month] = totals
+	}
+	totals.Include(&entry.CatPayment)
+}
+
+// Lines compares each budget item against the totals collected so far.
+// cds rolls totals of child categories into their parents. Items that
+// take effect on or after the end of the report period are skipped.
+func (b *BudgetTotaler) Lines(
+	items []*fin.BudgetItem,
+	cds categories.CatDetailStore) []*fin.BudgetLine {
+	current, _ := cds.RollUp(b.current)
+	rolledByMonth := make(map[time.Time]fin.CatTotals, len(b.byMonth))
+	for month, totals := range b.byMonth {
+		rolledByMonth[month], _ = cds.RollUp(totals)
+	}
+	months := monthsBetween(b.start, b.end)
+	var result []*fin.BudgetLine
+	for _, item := range items {
+		if !b.end.After(item.Start) {
+			continue
+		}
+		line := &fin.BudgetLine{
+			Item:   item,
+			Target: item.TargetForMonths(months),
+			Actual: fin.BudgetActual(item.Cat, current[item.Cat])}
+		if item.Rollover && !item.Start.IsZero() {
+			line.Carried = carried(item, b.start, rolledByMonth)
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// carried returns the unspent amount for item carried into the period
+// starting at start. Overspending in a month uses up what was carried,
+// but the carried amount never goes negative.
+func carried(
+	item *fin.BudgetItem,
+	start time.Time,
+	rolledByMonth map[time.Time]fin.CatTotals) int64 {
+	var result int64
+	monthlyTarget := item.TargetForMonths(1)
+	for month := Monthly().Normalize(item.Start); month.Before(start); month = Monthly().Add(month, 1) {
+		result += monthlyTarget - fin.BudgetActual(
+			item.Cat, rolledByMonth[month][item.Cat])
+		if result < 0 {
+			result = 0
+		}
+	}
+	return result
+}
+
+// monthsBetween returns the number of months touched by the period from
+// start inclusive to end exclusive.
+func monthsBetween(start, end time.Time) int {
+	result := 0
+	for month := Monthly().Normalize(start); month.Before(end); month = Monthly().Add(month, 1) {
+		result++
+	}
+	return result
+}
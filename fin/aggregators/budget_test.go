@@ -0,0 +1,118 @@
+package aggregators
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories"
+	"github.com/keep94/toolbox/date_util"
+	"testing"
+	"time"
+)
+
+var (
+	kCar    = fin.Cat{Type: fin.ExpenseCat, Id: 1}
+	kGas    = fin.Cat{Type: fin.ExpenseCat, Id: 2}
+	kSalary = fin.Cat{Type: fin.IncomeCat, Id: 1}
+)
+
+func TestBudgetStart(t *testing.T) {
+	items := []*fin.BudgetItem{
+		{Cat: kCar, Start: date_util.YMD(2012, 9, 15)},
+		{Cat: kGas, Start: date_util.YMD(2012, 11, 15), Rollover: true},
+	}
+	start := BudgetStart(items, date_util.YMD(2013, 1, 1))
+	if start != date_util.YMD(2012, 11, 1) {
+		t.Errorf("Expected 2012-11-01, got %v", start)
+	}
+	start = BudgetStart(items, date_util.YMD(2012, 10, 1))
+	if start != date_util.YMD(2012, 10, 1) {
+		t.Errorf("Expected 2012-10-01, got %v", start)
+	}
+	// Rollover items without a start date don't reach back in time.
+	items = []*fin.BudgetItem{{Cat: kGas, Rollover: true}}
+	start = BudgetStart(items, date_util.YMD(2013, 1, 1))
+	if start != date_util.YMD(2013, 1, 1) {
+		t.Errorf("Expected 2013-01-01, got %v", start)
+	}
+}
+
+func TestBudgetTotalerRolloverNoStart(t *testing.T) {
+	items := []*fin.BudgetItem{{Cat: kGas, Amount: 5000, Rollover: true}}
+	bt := NewBudgetTotaler(date_util.YMD(2013, 1, 1), date_util.YMD(2013, 2, 1))
+	bt.Include(newEntry(date_util.YMD(2012, 12, 3), kGas, 1000))
+	bt.Include(newEntry(date_util.YMD(2013, 1, 3), kGas, 2000))
+	lines := bt.Lines(items, categories.CatDetailStore{})
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(lines))
+	}
+	expected := fin.BudgetLine{Item: items[0], Target: 5000, Actual: 2000}
+	if *lines[0] != expected {
+		t.Errorf("Expected %v, got %v", expected, *lines[0])
+	}
+}
+
+func TestBudgetTotaler(t *testing.T) {
+	cdsb := categories.CatDetailStoreBuilder{}
+	row := categories.CatDbRow{Id: 1, Name: "car", Active: true}
+	cdsb.AddCatDbRow(fin.ExpenseCat, &row)
+	row = categories.CatDbRow{Id: 2, ParentId: 1, Name: "gas", Active: true}
+	cdsb.AddCatDbRow(fin.ExpenseCat, &row)
+	row = categories.CatDbRow{Id: 1, Name: "salary", Active: true}
+	cdsb.AddCatDbRow(fin.IncomeCat, &row)
+	cds := cdsb.Build()
+
+	items := []*fin.BudgetItem{
+		{Cat: kCar, Amount: 10000, Start: date_util.YMD(2012, 11, 20), Rollover: true},
+		{Cat: kSalary, Amount: 1200000, Period: fin.YearlyBudget},
+		{Cat: kGas, Amount: 5000, Start: date_util.YMD(2013, 2, 1)},
+	}
+	bt := NewBudgetTotaler(date_util.YMD(2013, 1, 1), date_util.YMD(2013, 2, 1))
+	bt.Include(newEntry(date_util.YMD(2012, 10, 31), kGas, 99999))
+	// 3000 carried from November
+	bt.Include(newEntry(date_util.YMD(2012, 11, 3), kGas, 7000))
+	// Overspending in December uses up 2500 of the carried amount
+	bt.Include(newEntry(date_util.YMD(2012, 12, 3), kCar, 8000))
+	bt.Include(newEntry(date_util.YMD(2012, 12, 3), kGas, 4500))
+	bt.Include(newEntry(date_util.YMD(2013, 1, 3), kGas, 11000))
+	bt.Include(newEntry(date_util.YMD(2013, 1, 31), kSalary, -90000))
+	bt.Include(newEntry(date_util.YMD(2013, 2, 1), kCar, 99999))
+	lines := bt.Lines(items, cds)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	expected := fin.BudgetLine{
+		Item: items[0], Target: 10000, Carried: 500, Actual: 11000}
+	if *lines[0] != expected {
+		t.Errorf("Expected %v, got %v", expected, *lines[0])
+	}
+	if lines[0].Remaining() != -500 || !lines[0].OverBudget() {
+		t.Error("Expected car to be 500 over budget")
+	}
+	expected = fin.BudgetLine{Item: items[1], Target: 100000, Actual: 90000}
+	if *lines[1] != expected {
+		t.Errorf("Expected %v, got %v", expected, *lines[1])
+	}
+	if lines[1].Remaining() != 10000 || lines[1].OverBudget() {
+		t.Error("Expected 10000 salary remaining")
+	}
+}
+
+func TestBudgetTotalerYear(t *testing.T) {
+	items := []*fin.BudgetItem{
+		{Cat: kCar, Amount: 10000},
+		{Cat: kSalary, Amount: 1200000, Period: fin.YearlyBudget},
+	}
+	bt := NewBudgetTotaler(date_util.YMD(2013, 1, 1), date_util.YMD(2014, 1, 1))
+	lines := bt.Lines(items, categories.CatDetailStore{})
+	if lines[0].Target != 120000 {
+		t.Errorf("Expected 120000, got %d", lines[0].Target)
+	}
+	if lines[1].Target != 1200000 {
+		t.Errorf("Expected 1200000, got %d", lines[1].Target)
+	}
+}
+
+func newEntry(date time.Time, cat fin.Cat, amount int64) *fin.Entry {
+	return &fin.Entry{
+		Date:       date,
+		CatPayment: fin.NewCatPayment(cat, amount, false, 1)}
+}
@@ -0,0 +1,27 @@
+package fin
+
+import (
+	"fmt"
+)
+
+// Attachment is a document such as a scanned receipt or PDF invoice
+// attached to an entry.
+type Attachment struct {
+	Id int64
+	// The entry to which this attachment belongs.
+	EntryId int64
+	// The original file name.
+	Name string
+	// The MIME type e.g application/pdf
+	ContentType string
+	// The size in bytes.
+	Size int64
+	// The contents. nil if only the details of the attachment were read.
+	Data []byte
+}
+
+func (a *Attachment) String() string {
+	return fmt.Sprintf(
+		"{Id: %d, EntryId: %d, Name: %s, ContentType: %s, Size: %d}",
+		a.Id, a.EntryId, a.Name, a.ContentType, a.Size)
+}
@@ -2,6 +2,7 @@
 package autoimport
 
 import (
+	"fmt"
 	"github.com/keep94/finance/fin"
 	"github.com/keep94/toolbox/db"
 	"io"
@@ -30,6 +31,17 @@ type Loader interface {
 		startDate time.Time) (Batch, error)
 }
 
+// BankAccountLister is implemented by Loaders whose files may contain
+// transactions from more than one bank account.
+//
+// BankAccounts reads the file from r and returns the financial institution
+// and the ids of the bank accounts in the file in the order they appear.
+// The returned ids are suitable for the bankAccountId parameter of Load.
+type BankAccountLister interface {
+	BankAccounts(r io.Reader) (
+		institution string, bankAccountIds []string, err error)
+}
+
 // Batch represents a group of transactions read from a file using a Loader
 // instance. Batch instances are immutable.
 type Batch interface {
@@ -49,3 +61,27 @@ type Batch interface {
 	// Len returns the number of entries in this batch.
 	Len() int
 }
+
+// BalanceError is returned by Loaders for statements whose opening
+// balance plus transactions does not equal their closing balance. It
+// usually means the file is incomplete.
+type BalanceError struct {
+	// Identifies the statement
+	Statement string
+
+	Opening fin.Money
+
+	// The total of the transactions in the statement
+	Total fin.Money
+
+	Closing fin.Money
+}
+
+func (e *BalanceError) Error() string {
+	return fmt.Sprintf(
+		"Statement %s: opening balance %v plus transactions %v does not equal closing balance %v.",
+		e.Statement,
+		e.Opening,
+		e.Total,
+		e.Closing)
+}
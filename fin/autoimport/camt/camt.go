@@ -0,0 +1,357 @@
+// Package camt provides processing of ISO 20022 camt.053 bank statements
+package camt
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/autoimport"
+	"github.com/keep94/finance/fin/autoimport/qfx"
+	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	kCredit  = "CRDT"
+	kDebit   = "DBIT"
+	kBooked  = "BOOK"
+	kOpening = "OPBD"
+	// Some banks give the previous closing balance instead of an opening
+	// balance.
+	kPreviousClosing = "PRCD"
+	kClosing         = "CLBD"
+)
+
+// CamtLoader implements the autoimport.Loader interface for camt.053
+// files. Each Stmt element in a file is one statement for one account.
+// When the file covers several accounts, Load returns only the
+// transactions for bankAccountId, the IBAN or other id of the account.
+// Load reads only booked transactions and checks that the opening balance
+// plus the booked transactions of each statement equals its closing
+// balance.
+type CamtLoader struct {
+	// Store stores which transactions have already been processed.
+	Store qfxdb.Store
+}
+
+func (c CamtLoader) Load(
+	accountId int64,
+	bankAccountId string,
+	r io.Reader,
+	startDate time.Time) (autoimport.Batch, error) {
+	doc, err := readDocument(r)
+	if err != nil {
+		return nil, err
+	}
+	_, bankAccountIds := doc.bankAccounts()
+	if bankAccountId == "" && len(bankAccountIds) > 1 ||
+		bankAccountId != "" && !contains(bankAccountIds, bankAccountId) {
+		return nil, &qfx.BankAccountError{
+			BankAccountId: bankAccountId, BankAccountIds: bankAccountIds}
+	}
+	var result []*qfx.QfxEntry
+	for i := range doc.Statements {
+		stmt := &doc.Statements[i]
+		if bankAccountId != "" && stmt.Account.id() != bankAccountId {
+			continue
+		}
+		entries, err := stmt.qfxEntries(accountId)
+		if err != nil {
+			return nil, err
+		}
+		for _, qentry := range entries {
+			if qentry.Date.Before(startDate) {
+				continue
+			}
+			if err := qentry.Check(); err != nil {
+				return nil, err
+			}
+			result = append(result, qentry)
+		}
+	}
+	return &qfx.QfxBatch{Store: c.Store, AccountId: accountId, QfxEntries: result}, nil
+}
+
+// BankAccounts implements autoimport.BankAccountLister. The institution
+// is the BIC of the bank servicing the first account.
+func (c CamtLoader) BankAccounts(r io.Reader) (
+	institution string, bankAccountIds []string, err error) {
+	doc, err := readDocument(r)
+	if err != nil {
+		return
+	}
+	institution, bankAccountIds = doc.bankAccounts()
+	return
+}
+
+type document struct {
+	Statements []statement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+func (d *document) bankAccounts() (
+	institution string, bankAccountIds []string) {
+	for i := range d.Statements {
+		account := &d.Statements[i].Account
+		if institution == "" {
+			institution = account.bic()
+		}
+		if id := account.id(); !contains(bankAccountIds, id) {
+			bankAccountIds = append(bankAccountIds, id)
+		}
+	}
+	return
+}
+
+type account struct {
+	IBAN    string `xml:"Id>IBAN"`
+	OtherId string `xml:"Id>Othr>Id"`
+	BIC     string `xml:"Svcr>FinInstnId>BIC"`
+	BICFI   string `xml:"Svcr>FinInstnId>BICFI"`
+}
+
+func (a *account) id() string {
+	if a.IBAN != "" {
+		return strings.TrimSpace(a.IBAN)
+	}
+	return strings.TrimSpace(a.OtherId)
+}
+
+func (a *account) bic() string {
+	if a.BIC != "" {
+		return strings.TrimSpace(a.BIC)
+	}
+	return strings.TrimSpace(a.BICFI)
+}
+
+type statement struct {
+	Id       string    `xml:"Id"`
+	Account  account   `xml:"Acct"`
+	Balances []balance `xml:"Bal"`
+	Entries  []entry   `xml:"Ntry"`
+}
+
+// qfxEntries returns the booked entries of this statement after checking
+// the balances.
+func (s *statement) qfxEntries(accountId int64) ([]*qfx.QfxEntry, error) {
+	var opening, closing *balance
+	for i := range s.Balances {
+		switch s.Balances[i].Code {
+		case kOpening, kPreviousClosing:
+			if opening == nil {
+				opening = &s.Balances[i]
+			}
+		case kClosing:
+			closing = &s.Balances[i]
+		}
+	}
+	var result []*qfx.QfxEntry
+	var total fin.Money
+	for i := range s.Entries {
+		e := &s.Entries[i]
+		if e.Status.code() != kBooked {
+			continue
+		}
+		amt, err := signedAmount(e.Amount, e.CreditDebit)
+		if err != nil {
+			return nil, err
+		}
+		total += amt
+		qentry, err := e.qfxEntry(accountId, amt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, qentry)
+	}
+	if opening == nil || closing == nil {
+		return nil, fmt.Errorf(
+			"Statement %s missing opening or closing balance.", s.Id)
+	}
+	openingAmt, err := signedAmount(opening.Amount, opening.CreditDebit)
+	if err != nil {
+		return nil, err
+	}
+	closingAmt, err := signedAmount(closing.Amount, closing.CreditDebit)
+	if err != nil {
+		return nil, err
+	}
+	if openingAmt+total != closingAmt {
+		return nil, &autoimport.BalanceError{
+			Statement: s.Id,
+			Opening:   openingAmt,
+			Total:     total,
+			Closing:   closingAmt}
+	}
+	return result, nil
+}
+
+type balance struct {
+	Code        string `xml:"Tp>CdOrPrtry>Cd"`
+	Amount      string `xml:"Amt"`
+	CreditDebit string `xml:"CdtDbtInd"`
+}
+
+// status is a plain code in older versions of camt.053 and a Cd element
+// in newer ones.
+type status struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+func (s *status) code() string {
+	if s.Code != "" {
+		return strings.TrimSpace(s.Code)
+	}
+	return strings.TrimSpace(s.Text)
+}
+
+type entry struct {
+	Ref             string      `xml:"NtryRef"`
+	AcctSvcrRef     string      `xml:"AcctSvcrRef"`
+	Amount          string      `xml:"Amt"`
+	CreditDebit     string      `xml:"CdtDbtInd"`
+	Status          status      `xml:"Sts"`
+	BookingDate     string      `xml:"BookgDt>Dt"`
+	BookingDateTime string      `xml:"BookgDt>DtTm"`
+	Details         []txDetails `xml:"NtryDtls>TxDtls"`
+	AdditionalInfo  string      `xml:"AddtlNtryInf"`
+}
+
+func (e *entry) qfxEntry(
+	accountId int64, amt fin.Money) (*qfx.QfxEntry, error) {
+	var qentry qfx.QfxEntry
+	var err error
+	qentry.Date, err = parseDate(e.BookingDate, e.BookingDateTime)
+	if err != nil {
+		return nil, err
+	}
+	var names, remittances []string
+	for i := range e.Details {
+		if name := e.Details[i].counterparty(e.CreditDebit); name != "" {
+			names = appendUnique(names, name)
+		}
+		if remittance := e.Details[i].remittance(); remittance != "" {
+			remittances = append(remittances, remittance)
+		}
+	}
+	qentry.Name = strings.Join(names, ", ")
+	qentry.Desc = strings.Join(remittances, " ")
+	additionalInfo := collapseSpaces(e.AdditionalInfo)
+	if qentry.Desc == "" {
+		qentry.Desc = additionalInfo
+	}
+	// Prefer counterparty to additional info to remittance info
+	if qentry.Name == "" {
+		qentry.Name = additionalInfo
+	}
+	if qentry.Name == "" {
+		qentry.Name = qentry.Desc
+	}
+	qentry.FitId = strings.TrimSpace(e.Ref)
+	if qentry.FitId == "" {
+		qentry.FitId = strings.TrimSpace(e.AcctSvcrRef)
+	}
+	qentry.CatPayment = fin.NewCatPayment(
+		fin.Expense, -int64(amt), true, accountId)
+	return &qentry, nil
+}
+
+type txDetails struct {
+	DebtorName        string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPartyName   string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	CreditorName      string   `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPartyName string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Unstructured      []string `xml:"RmtInf>Ustrd"`
+	CreditorRef       string   `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+}
+
+// counterparty returns the name of the other party. For credits, that is
+// the debtor; for debits, the creditor.
+func (t *txDetails) counterparty(creditDebit string) string {
+	debtor := firstNonEmpty(t.DebtorName, t.DebtorPartyName)
+	creditor := firstNonEmpty(t.CreditorName, t.CreditorPartyName)
+	if strings.TrimSpace(creditDebit) == kCredit {
+		return collapseSpaces(debtor)
+	}
+	return collapseSpaces(creditor)
+}
+
+func (t *txDetails) remittance() string {
+	if len(t.Unstructured) > 0 {
+		return collapseSpaces(strings.Join(t.Unstructured, " "))
+	}
+	return collapseSpaces(t.CreditorRef)
+}
+
+func readDocument(r io.Reader) (*document, error) {
+	var result document
+	if err := xml.NewDecoder(r).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Statements) == 0 {
+		return nil, errors.New("No camt.053 statements found.")
+	}
+	return &result, nil
+}
+
+// signedAmount returns amount as a positive number for credits and a
+// negative number for debits.
+func signedAmount(amount, creditDebit string) (fin.Money, error) {
+	result, err := fin.ParseMoney(amount)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.TrimSpace(creditDebit) {
+	case kCredit:
+		return result, nil
+	case kDebit:
+		return -result, nil
+	}
+	return 0, fmt.Errorf("Invalid credit debit indicator: %s", creditDebit)
+}
+
+// parseDate parses a booking date given as an ISO date or date time.
+func parseDate(date, dateTime string) (time.Time, error) {
+	str := strings.TrimSpace(date)
+	if str == "" {
+		str = strings.TrimSpace(dateTime)
+		if len(str) > 10 {
+			str = str[:10]
+		}
+	}
+	if str == "" {
+		return time.Time{}, errors.New("Entry missing booking date.")
+	}
+	return time.Parse("2006-01-02", str)
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func firstNonEmpty(strs ...string) string {
+	for _, s := range strs {
+		if strings.TrimSpace(s) != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func appendUnique(strs []string, s string) []string {
+	if contains(strs, s) {
+		return strs
+	}
+	return append(strs, s)
+}
+
+func contains(strs []string, s string) bool {
+	for _, x := range strs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package camt_test
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/autoimport"
+	"github.com/keep94/finance/fin/autoimport/camt"
+	"github.com/keep94/finance/fin/autoimport/qfx"
+	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const kCamt = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+<BkToCstmrStmt>
+<GrpHdr><MsgId>MSG1</MsgId></GrpHdr>
+<Stmt>
+  <Id>STMT1</Id>
+  <Acct>
+    <Id><IBAN>DE89370400440532013000</IBAN></Id>
+    <Svcr><FinInstnId><BIC>COBADEFFXXX</BIC></FinInstnId></Svcr>
+  </Acct>
+  <Bal>
+    <Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp>
+    <Amt Ccy="EUR">1000.00</Amt>
+    <CdtDbtInd>CRDT</CdtDbtInd>
+    <Dt><Dt>2015-12-01</Dt></Dt>
+  </Bal>
+  <Bal>
+    <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+    <Amt Ccy="EUR">2137.66</Amt>
+    <CdtDbtInd>CRDT</CdtDbtInd>
+    <Dt><Dt>2015-12-31</Dt></Dt>
+  </Bal>
+  <Ntry>
+    <NtryRef>REF1</NtryRef>
+    <Amt Ccy="EUR">12.34</Amt>
+    <CdtDbtInd>DBIT</CdtDbtInd>
+    <Sts>BOOK</Sts>
+    <BookgDt><Dt>2015-12-02</Dt></BookgDt>
+    <NtryDtls><TxDtls>
+      <RltdPties>
+        <Dbtr><Nm>Me</Nm></Dbtr>
+        <Cdtr><Nm>Stadtwerke   Berlin</Nm></Cdtr>
+      </RltdPties>
+      <RmtInf><Ustrd>Invoice 123</Ustrd><Ustrd>December</Ustrd></RmtInf>
+    </TxDtls></NtryDtls>
+  </Ntry>
+  <Ntry>
+    <AcctSvcrRef>SVC2</AcctSvcrRef>
+    <Amt Ccy="EUR">1150.00</Amt>
+    <CdtDbtInd>CRDT</CdtDbtInd>
+    <Sts>BOOK</Sts>
+    <BookgDt><DtTm>2015-12-15T08:00:00</DtTm></BookgDt>
+    <NtryDtls><TxDtls>
+      <RltdPties>
+        <Dbtr><Nm>Employer GmbH</Nm></Dbtr>
+      </RltdPties>
+    </TxDtls></NtryDtls>
+    <AddtlNtryInf>SALARY</AddtlNtryInf>
+  </Ntry>
+  <Ntry>
+    <NtryRef>REF3</NtryRef>
+    <Amt Ccy="EUR">50.00</Amt>
+    <CdtDbtInd>DBIT</CdtDbtInd>
+    <Sts>PDNG</Sts>
+    <BookgDt><Dt>2015-12-30</Dt></BookgDt>
+    <AddtlNtryInf>Pending card payment</AddtlNtryInf>
+  </Ntry>
+</Stmt>
+<Stmt>
+  <Id>STMT2</Id>
+  <Acct><Id><Othr><Id>SAVINGS1</Id></Othr></Id></Acct>
+  <Bal>
+    <Tp><CdOrPrtry><Cd>PRCD</Cd></CdOrPrtry></Tp>
+    <Amt Ccy="EUR">10.00</Amt>
+    <CdtDbtInd>DBIT</CdtDbtInd>
+  </Bal>
+  <Bal>
+    <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+    <Amt Ccy="EUR">90.00</Amt>
+    <CdtDbtInd>CRDT</CdtDbtInd>
+  </Bal>
+  <Ntry>
+    <NtryRef>REF4</NtryRef>
+    <Amt Ccy="EUR">100.00</Amt>
+    <CdtDbtInd>CRDT</CdtDbtInd>
+    <Sts><Cd>BOOK</Cd></Sts>
+    <BookgDt><Dt>2015-12-20</Dt></BookgDt>
+    <AddtlNtryInf>Transfer from checking</AddtlNtryInf>
+  </Ntry>
+</Stmt>
+</BkToCstmrStmt>
+</Document>
+`
+
+func TestReadCamt(t *testing.T) {
+	var loader autoimport.Loader
+	loader = camt.CamtLoader{make(storeType)}
+	batch, err := loader.Load(
+		3,
+		"DE89370400440532013000",
+		strings.NewReader(kCamt),
+		date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries := []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 2),
+			Name:       "Stadtwerke Berlin",
+			Desc:       "Invoice 123 December",
+			CatPayment: fin.NewCatPayment(fin.Expense, 1234, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 15),
+			Name:       "Employer GmbH",
+			Desc:       "SALARY",
+			CatPayment: fin.NewCatPayment(fin.Expense, -115000, true, 3)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+	batch, err = loader.Load(
+		4, "SAVINGS1", strings.NewReader(kCamt), date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries = []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 20),
+			Name:       "Transfer from checking",
+			Desc:       "Transfer from checking",
+			CatPayment: fin.NewCatPayment(fin.Expense, -10000, true, 4)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+}
+
+func TestCamtFitIds(t *testing.T) {
+	store := make(storeType)
+	loader := camt.CamtLoader{store}
+	batch, err := loader.Load(
+		3,
+		"DE89370400440532013000",
+		strings.NewReader(kCamt),
+		date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	batch.MarkProcessed(nil)
+	expected := map[string]bool{"REF1": true, "SVC2": true}
+	if !reflect.DeepEqual(expected, store[3]) {
+		t.Errorf("Expected %v, got %v", expected, store[3])
+	}
+}
+
+func TestCamtBankAccounts(t *testing.T) {
+	loader := camt.CamtLoader{make(storeType)}
+	institution, bankAccountIds, err := loader.BankAccounts(
+		strings.NewReader(kCamt))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if institution != "COBADEFFXXX" {
+		t.Errorf("Expected COBADEFFXXX, got %s", institution)
+	}
+	expected := []string{"DE89370400440532013000", "SAVINGS1"}
+	if !reflect.DeepEqual(expected, bankAccountIds) {
+		t.Errorf("Expected %v, got %v", expected, bankAccountIds)
+	}
+	_, err = loader.Load(
+		3, "", strings.NewReader(kCamt), date_util.YMD(2015, 12, 1))
+	if _, ok := err.(*qfx.BankAccountError); !ok {
+		t.Errorf("Expected BankAccountError, got %v", err)
+	}
+}
+
+func TestCamtBadBalance(t *testing.T) {
+	loader := camt.CamtLoader{make(storeType)}
+	bad := strings.Replace(kCamt, "2137.66", "2137.67", 1)
+	_, err := loader.Load(
+		3,
+		"DE89370400440532013000",
+		strings.NewReader(bad),
+		date_util.YMD(2015, 12, 1))
+	balanceErr, ok := err.(*autoimport.BalanceError)
+	if !ok {
+		t.Fatalf("Expected BalanceError, got %v", err)
+	}
+	expected := &autoimport.BalanceError{
+		Statement: "STMT1", Opening: 100000, Total: 113766, Closing: 213767}
+	if !reflect.DeepEqual(expected, balanceErr) {
+		t.Errorf("Expected %v, got %v", expected, balanceErr)
+	}
+}
+
+func TestReadBadCamt(t *testing.T) {
+	loader := camt.CamtLoader{make(storeType)}
+	_, err := loader.Load(
+		3, "", strings.NewReader("<Document></Document>"),
+		date_util.YMD(2015, 12, 1))
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
+
+type storeType map[int64]map[string]bool
+
+func (s storeType) Add(t db.Transaction, accountId int64, fitIds qfxdb.FitIdSet) error {
+	if s[accountId] == nil {
+		s[accountId] = make(map[string]bool)
+	}
+	for fitId, ok := range fitIds {
+		if ok {
+			s[accountId][fitId] = true
+		}
+	}
+	return nil
+}
+
+func (s storeType) Find(t db.Transaction, accountId int64, fitIds qfxdb.FitIdSet) (qfxdb.FitIdSet, error) {
+	var result qfxdb.FitIdSet
+	for fitId, ok := range fitIds {
+		if ok {
+			if s[accountId][fitId] {
+				if result == nil {
+					result = make(qfxdb.FitIdSet)
+				}
+				result[fitId] = true
+			}
+		}
+	}
+	return result, nil
+}
@@ -32,10 +32,122 @@ Date, Time, Time Zone, Name, Type, Status, Amount, Receipt ID, Balance,
 "9/2/2015","09:27:09","PST","Bank Account","Add Funds from a Bank Account","Completed","18.43","","18.43",
 `
 
+const kFormattedAmountsCsv = `
+Date, Time, Time Zone, Name, Type, Status, Amount, Receipt ID, Balance,
+"12/6/2015","07:59:04","PST","Landlord","Payment Sent","Completed","($1,250.00)","","0.00",
+"12/5/2015","07:59:04","PST","Refund","Payment Received","Completed","$3.10","","0.00",
+`
+
+const kBankProfiles = `
+- title: mybank
+  skip_rows: 2
+  header: [Posted Date, Description, Check, Debit, Credit]
+  date: Posted Date
+  date_format: 2006-01-02
+  name: description
+  memo: 5
+  check_no: Check
+  debit: Debit
+  credit: Credit
+  exclude:
+    - column: Description
+      pattern: ^ONLINE TRANSFER
+- title: mycard
+  header: [Date, Payee, Amount]
+  date: 0
+  name: 1
+  amount: 2
+  positive: withdrawal
+`
+
+const kBankCsv = `
+Account,1234
+Exported,2015-12-31
+Posted Date,Description,Check,Debit,Credit,Notes
+2015-12-06,Landlord,1001,"1,250.00",,December rent
+2015-12-05,ONLINE TRANSFER TO SAVINGS,,100.00,,
+2015-12-04,Refund,,,3.10,
+`
+
+const kCardCsv = `
+Date,Payee,Amount
+12/6/2015,Grocery Store,45.67
+12/5/2015,Payment,-100.00
+`
+
+func TestReadCsvWithProfiles(t *testing.T) {
+	profiles, err := csv.ReadProfiles(strings.NewReader(kBankProfiles))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	loader := csv.CsvLoader{Store: make(storeType), Profiles: profiles}
+	batch, err := loader.Load(
+		3, "", strings.NewReader(kBankCsv), date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries := []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 6),
+			Name:       "Landlord",
+			Desc:       "December rent",
+			CheckNo:    "1001",
+			CatPayment: fin.NewCatPayment(fin.Expense, 125000, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 4),
+			Name:       "Refund",
+			CatPayment: fin.NewCatPayment(fin.Expense, -310, true, 3)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+	batch, err = loader.Load(
+		3, "", strings.NewReader(kCardCsv), date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries = []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 6),
+			Name:       "Grocery Store",
+			CatPayment: fin.NewCatPayment(fin.Expense, 4567, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 5),
+			Name:       "Payment",
+			CatPayment: fin.NewCatPayment(fin.Expense, -10000, true, 3)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+	// Built in profiles still work
+	_, err = loader.Load(
+		3, "", strings.NewReader(kPaypalCsv), date_util.YMD(2015, 9, 3))
+	if err != nil {
+		t.Errorf("Got error %v", err)
+	}
+}
+
+func TestReadBadProfiles(t *testing.T) {
+	badProfiles := []string{
+		"- title: noheader\n  date: 0\n  name: 1\n  amount: 2\n",
+		"- header: [a]\n  name: 1\n  amount: 2\n",
+		"- header: [a]\n  date: 0\n  name: 1\n",
+		"- header: [a]\n  date: 0\n  name: 1\n  amount: 2\n  debit: 3\n",
+		"- header: [a]\n  date: 0\n  amount: 2\n",
+		"- header: [a]\n  date: 0\n  name: 1\n  amount: 2\n  positive: up\n",
+		"- header: [a]\n  date: 0\n  name: 1\n  amount: -2\n",
+		"- header: [a]\n  date: 0\n  name: 1\n  amount: 2\n  exclude:\n    - column: 1\n      pattern: \"(\"\n",
+		"- header: [a]\n  date: 0\n  name: 1\n  amount: 2\n  unknown: 3\n",
+	}
+	for _, profile := range badProfiles {
+		if _, err := csv.ReadProfiles(strings.NewReader(profile)); err == nil {
+			t.Errorf("Expected error reading %q", profile)
+		}
+	}
+}
+
 func TestReadBadCsvFile(t *testing.T) {
 	r := strings.NewReader("A bad file\nNo CSV things in here\n")
 	var loader autoimport.Loader
-	loader = csv.CsvLoader{make(storeType)}
+	loader = csv.CsvLoader{Store: make(storeType)}
 	_, err := loader.Load(3, "", r, date_util.YMD(2012, 11, 14))
 	if err == nil {
 		t.Error("Expected error")
@@ -44,7 +156,7 @@ func TestReadBadCsvFile(t *testing.T) {
 
 func TestReadCsvWithEntryMissingName(t *testing.T) {
 	var loader autoimport.Loader
-	loader = csv.CsvLoader{make(storeType)}
+	loader = csv.CsvLoader{Store: make(storeType)}
 	r := strings.NewReader(kMissingNameCsv)
 	_, err := loader.Load(3, "", r, date_util.YMD(2015, 9, 3))
 	if err != nil {
@@ -60,7 +172,7 @@ func TestReadCsvWithEntryMissingName(t *testing.T) {
 func TestReadPaypalCsv(t *testing.T) {
 	r := strings.NewReader(kPaypalCsv)
 	var loader autoimport.Loader
-	loader = csv.CsvLoader{make(storeType)}
+	loader = csv.CsvLoader{Store: make(storeType)}
 	batch, err := loader.Load(3, "", r, date_util.YMD(2015, 9, 3))
 	if err != nil {
 		t.Errorf("Got error %v", err)
@@ -85,9 +197,31 @@ func TestReadPaypalCsv(t *testing.T) {
 	}
 }
 
+func TestReadCsvWithFormattedAmounts(t *testing.T) {
+	r := strings.NewReader(kFormattedAmountsCsv)
+	loader := csv.CsvLoader{Store: make(storeType)}
+	batch, err := loader.Load(3, "", r, date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	entries := batch.Entries()
+	expectedEntries := []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 6),
+			Name:       "Landlord",
+			CatPayment: fin.NewCatPayment(fin.Expense, 125000, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 5),
+			Name:       "Refund",
+			CatPayment: fin.NewCatPayment(fin.Expense, -310, true, 3)}}
+	if !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+}
+
 func TestMarkProcessed(t *testing.T) {
 	r := strings.NewReader(kPaypalCsv)
-	loader := csv.CsvLoader{make(storeType)}
+	loader := csv.CsvLoader{Store: make(storeType)}
 	batch, err := loader.Load(3, "", r, date_util.YMD(2015, 9, 3))
 	if err != nil {
 		t.Errorf("Got error %v", err)
@@ -0,0 +1,361 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/keep94/finance/fin"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	kDefaultDateFormat = "1/2/2006"
+)
+
+// The profiles built into CsvLoader.
+const kBuiltInProfilesYAML = `
+- title: native
+  header: [Date, CheckNo, Name, Desc, Amount]
+  date: Date
+  check_no: CheckNo
+  name: Name
+  memo: Desc
+  amount: Amount
+- title: paypal
+  header: [Date, Time, Time Zone, Name, Type, Status, Amount]
+  date: Date
+  name: Name
+  amount: Amount
+  exclude:
+    - column: Name
+      pattern: ^Bank Account$
+`
+
+// Column identifies a column in a csv file by its zero based index or by
+// its name in the header row. In YAML, a number is an index and anything
+// else is a name.
+type Column struct {
+	Index int
+	Name  string
+	set   bool
+}
+
+// IsSet returns true if this column was specified.
+func (c *Column) IsSet() bool {
+	return c.set
+}
+
+func (c *Column) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var index int
+	if err := unmarshal(&index); err == nil {
+		if index < 0 {
+			return fmt.Errorf("Column index %d is negative.", index)
+		}
+		*c = Column{Index: index, set: true}
+		return nil
+	}
+	var name string
+	if err := unmarshal(&name); err != nil {
+		return err
+	}
+	*c = Column{Name: name, set: true}
+	return nil
+}
+
+func (c Column) String() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%d", c.Index)
+}
+
+// Exclusion excludes the rows whose value in Column matches Pattern.
+type Exclusion struct {
+	Column  Column `yaml:"column"`
+	Pattern string `yaml:"pattern"`
+}
+
+// Profile describes the layout of the csv files from one bank. Instances
+// usually come from YAML files. See ReadProfiles.
+type Profile struct {
+	// Identifies this profile in error messages
+	Title string `yaml:"title"`
+
+	// The first columns of the header row. Matching ignores case and
+	// surrounding spaces. Required.
+	Header []string `yaml:"header"`
+
+	// The number of rows before the header row
+	SkipRows int `yaml:"skip_rows"`
+
+	// The transaction date. Required.
+	Date Column `yaml:"date"`
+
+	// The format of dates as a Go reference time layout. The default is
+	// 1/2/2006.
+	DateFormat string `yaml:"date_format"`
+
+	// The transaction amount. Required unless Debit or Credit is given.
+	Amount Column `yaml:"amount"`
+
+	// Withdrawals or charges for files that keep them in their own column
+	Debit Column `yaml:"debit"`
+
+	// Deposits or payments for files that keep them in their own column
+	Credit Column `yaml:"credit"`
+
+	// The payee. Either Name or Memo is required.
+	Name Column `yaml:"name"`
+
+	// The memo becomes the name of the entry if there is no payee;
+	// otherwise it becomes the description.
+	Memo Column `yaml:"memo"`
+
+	CheckNo Column `yaml:"check_no"`
+
+	// What a positive value in the Amount column means: "deposit", the
+	// default, or "withdrawal" as in some credit card files.
+	Positive string `yaml:"positive"`
+
+	// Rows to leave out such as transfers or totals
+	Exclude []Exclusion `yaml:"exclude"`
+
+	excludePatterns []*regexp.Regexp
+}
+
+// ReadProfiles reads a YAML list of profiles from r. Each profile is a
+// mapping with the lower case names of the Profile fields with
+// underscores between words. For example:
+//
+//	# Profiles for ledger -csv_profiles
+//	- title: mybank
+//	  skip_rows: 3
+//	  header: [Posted Date, Description, Debit, Credit]
+//	  date: Posted Date
+//	  date_format: 2006-01-02
+//	  name: Description
+//	  debit: Debit
+//	  credit: 3
+//	  exclude:
+//	    - column: Description
+//	      pattern: ^ONLINE TRANSFER
+func ReadProfiles(r io.Reader) ([]*Profile, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Profile
+	if err := yaml.UnmarshalStrict(content, &result); err != nil {
+		return nil, err
+	}
+	for i, profile := range result {
+		if err := profile.init(); err != nil {
+			title := profile.Title
+			if title == "" {
+				title = fmt.Sprintf("%d", i+1)
+			}
+			return nil, fmt.Errorf("csv profile %s: %v", title, err)
+		}
+	}
+	return result, nil
+}
+
+var (
+	kBuiltInProfiles = mustReadProfiles(kBuiltInProfilesYAML)
+)
+
+// BuiltInProfiles returns the profiles that CsvLoader always recognizes.
+func BuiltInProfiles() []*Profile {
+	return append([]*Profile(nil), kBuiltInProfiles...)
+}
+
+func mustReadProfiles(s string) []*Profile {
+	result, err := ReadProfiles(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func (p *Profile) init() error {
+	if len(p.Header) == 0 {
+		return errors.New("header required")
+	}
+	if p.SkipRows < 0 {
+		return errors.New("skip_rows must be non-negative")
+	}
+	if !p.Date.IsSet() {
+		return errors.New("date required")
+	}
+	if p.Amount.IsSet() == (p.Debit.IsSet() || p.Credit.IsSet()) {
+		return errors.New("need either amount or debit and credit")
+	}
+	if !p.Name.IsSet() && !p.Memo.IsSet() {
+		return errors.New("name or memo required")
+	}
+	if p.DateFormat == "" {
+		p.DateFormat = kDefaultDateFormat
+	}
+	if p.Positive == "" {
+		p.Positive = "deposit"
+	}
+	if p.Positive != "deposit" && p.Positive != "withdrawal" {
+		return errors.New("positive must be deposit or withdrawal")
+	}
+	p.excludePatterns = make([]*regexp.Regexp, len(p.Exclude))
+	for i := range p.Exclude {
+		if !p.Exclude[i].Column.IsSet() {
+			return errors.New("exclude needs a column")
+		}
+		pattern, err := regexp.Compile(p.Exclude[i].Pattern)
+		if err != nil {
+			return err
+		}
+		p.excludePatterns[i] = pattern
+	}
+	return nil
+}
+
+// match returns a rowParser if the header row of rows matches this
+// profile or nil otherwise.
+func (p *Profile) match(rows [][]string) *rowParser {
+	if len(rows) <= p.SkipRows {
+		return nil
+	}
+	header := rows[p.SkipRows]
+	if len(header) < len(p.Header) {
+		return nil
+	}
+	for i, name := range p.Header {
+		if !sameColumnName(header[i], name) {
+			return nil
+		}
+	}
+	result := &rowParser{profile: p, header: header}
+	for _, c := range []Column{
+		p.Date, p.Amount, p.Debit, p.Credit, p.Name, p.Memo, p.CheckNo} {
+		if _, ok := result.index(c); c.IsSet() && !ok {
+			return nil
+		}
+	}
+	for i := range p.Exclude {
+		if _, ok := result.index(p.Exclude[i].Column); !ok {
+			return nil
+		}
+	}
+	return result
+}
+
+// matchProfile returns a rowParser for the first profile that matches
+// rows or nil if none match.
+func matchProfile(profiles []*Profile, rows [][]string) *rowParser {
+	for _, profile := range profiles {
+		if result := profile.match(rows); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// rowParser parses the rows after the header row using a profile.
+type rowParser struct {
+	profile *Profile
+	header  []string
+}
+
+// index returns the index of c. index returns false if c has a name
+// that is not in the header row.
+func (r *rowParser) index(c Column) (int, bool) {
+	if c.Name == "" {
+		return c.Index, true
+	}
+	for i, name := range r.header {
+		if sameColumnName(name, c.Name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// value returns the trimmed value of c in row or the empty string if c
+// is not set or row is too short.
+func (r *rowParser) value(row []string, c Column) string {
+	if !c.IsSet() {
+		return ""
+	}
+	idx, _ := r.index(c)
+	if idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
+
+// parse stores row in entry. parse returns false if the profile excludes
+// row.
+func (r *rowParser) parse(
+	row []string, accountId int64, entry *fin.Entry) (ok bool, err error) {
+	p := r.profile
+	for i, pattern := range p.excludePatterns {
+		if pattern.MatchString(r.value(row, p.Exclude[i].Column)) {
+			return
+		}
+	}
+	entry.Date, err = time.Parse(p.DateFormat, r.value(row, p.Date))
+	if err != nil {
+		return
+	}
+	var amt fin.Money
+	if p.Amount.IsSet() {
+		amt, err = fin.ParseMoney(r.value(row, p.Amount))
+		if err != nil {
+			return
+		}
+		if p.Positive == "withdrawal" {
+			amt = -amt
+		}
+	} else {
+		var debit, credit fin.Money
+		if debit, err = parseOptionalMoney(r.value(row, p.Debit)); err != nil {
+			return
+		}
+		if credit, err = parseOptionalMoney(r.value(row, p.Credit)); err != nil {
+			return
+		}
+		amt = abs(credit) - abs(debit)
+	}
+	name := r.value(row, p.Name)
+	memo := r.value(row, p.Memo)
+	// Prefer name to memo
+	if name != "" {
+		entry.Name = name
+		entry.Desc = memo
+	} else {
+		entry.Name = memo
+	}
+	entry.CheckNo = r.value(row, p.CheckNo)
+	entry.CatPayment = fin.NewCatPayment(fin.Expense, -int64(amt), true, accountId)
+	ok = true
+	return
+}
+
+func parseOptionalMoney(s string) (fin.Money, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return fin.ParseMoney(s)
+}
+
+func abs(m fin.Money) fin.Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+func sameColumnName(x, y string) bool {
+	return strings.EqualFold(strings.TrimSpace(x), strings.TrimSpace(y))
+}
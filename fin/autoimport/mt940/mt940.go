@@ -0,0 +1,441 @@
+// Package mt940 provides processing of SWIFT MT940 bank statements
+package mt940
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/autoimport"
+	"github.com/keep94/finance/fin/autoimport/qfx"
+	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
+	"hash/fnv"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kNoRef = "NONREF"
+)
+
+var (
+	kTagPattern = regexp.MustCompile(`^:([0-9]{2}[A-Z]?):`)
+
+	// value date, booking date, mark, funds code, amount, type, references
+	kTransactionPattern = regexp.MustCompile(
+		`^([0-9]{6})([0-9]{4})?(RC|RD|C|D)([A-Z])?([0-9]+,[0-9]*)([NFS][A-Z0-9]{3})(.*)$`)
+
+	// mark, date, currency, amount
+	kBalancePattern = regexp.MustCompile(
+		`^([CD])([0-9]{6})([A-Z]{3})([0-9]+,[0-9]*)$`)
+
+	// ?xx subfields of structured :86: fields
+	kSubfieldPattern = regexp.MustCompile(`\?([0-9]{2})`)
+)
+
+// MT940Loader implements the autoimport.Loader interface for MT940 files.
+// A file may have several statements, each beginning with a :20: field.
+// When the file covers several accounts, Load returns only the
+// transactions for bankAccountId, the account in the :25: field.
+// Load checks that the opening balance plus the transactions of each
+// statement equals its closing balance. The fitId of a transaction is its
+// bank reference or its customer reference. Transactions with neither get
+// a fitId made up from their contents.
+type MT940Loader struct {
+	// Store stores which transactions have already been processed.
+	Store qfxdb.Store
+}
+
+func (m MT940Loader) Load(
+	accountId int64,
+	bankAccountId string,
+	r io.Reader,
+	startDate time.Time) (autoimport.Batch, error) {
+	statements, err := readStatements(r)
+	if err != nil {
+		return nil, err
+	}
+	bankAccountIds := accountIds(statements)
+	if bankAccountId == "" && len(bankAccountIds) > 1 ||
+		bankAccountId != "" && !contains(bankAccountIds, bankAccountId) {
+		return nil, &qfx.BankAccountError{
+			BankAccountId: bankAccountId, BankAccountIds: bankAccountIds}
+	}
+	var result []*qfx.QfxEntry
+	// Identical transactions without references, such as two coffees on
+	// the same day, get different fitIds by counting them.
+	fitIdKeyCounts := make(map[string]int)
+	for _, stmt := range statements {
+		if bankAccountId != "" && stmt.account != bankAccountId {
+			continue
+		}
+		entries, err := stmt.qfxEntries(accountId)
+		if err != nil {
+			return nil, err
+		}
+		for _, qentry := range entries {
+			if qentry.FitId == "" {
+				key := fitIdKey(qentry)
+				fitIdKeyCounts[key]++
+				qentry.FitId, err = generateFitId(key, fitIdKeyCounts[key])
+				if err != nil {
+					return nil, err
+				}
+			}
+			if qentry.Date.Before(startDate) {
+				continue
+			}
+			if err := qentry.Check(); err != nil {
+				return nil, err
+			}
+			result = append(result, qentry)
+		}
+	}
+	return &qfx.QfxBatch{Store: m.Store, AccountId: accountId, QfxEntries: result}, nil
+}
+
+// BankAccounts implements autoimport.BankAccountLister. The institution
+// is the BIC of the sender in the basic header block if the file has
+// one.
+func (m MT940Loader) BankAccounts(r io.Reader) (
+	institution string, bankAccountIds []string, err error) {
+	statements, err := readStatements(r)
+	if err != nil {
+		return
+	}
+	for _, stmt := range statements {
+		if institution == "" {
+			institution = stmt.institution
+		}
+	}
+	return institution, accountIds(statements), nil
+}
+
+type field struct {
+	tag   string
+	value string
+}
+
+type transaction struct {
+	// The :61: field
+	line string
+
+	// The :86: field
+	info string
+}
+
+type statement struct {
+	institution  string
+	reference    string
+	account      string
+	opening      string
+	closing      string
+	transactions []*transaction
+}
+
+// qfxEntries returns the transactions of this statement after checking
+// the balances.
+func (s *statement) qfxEntries(accountId int64) ([]*qfx.QfxEntry, error) {
+	if s.opening == "" || s.closing == "" {
+		return nil, fmt.Errorf(
+			"Statement %s missing opening or closing balance.", s.reference)
+	}
+	opening, err := parseBalance(s.opening)
+	if err != nil {
+		return nil, err
+	}
+	closing, err := parseBalance(s.closing)
+	if err != nil {
+		return nil, err
+	}
+	var result []*qfx.QfxEntry
+	var total fin.Money
+	for _, t := range s.transactions {
+		qentry, amt, err := t.qfxEntry(accountId)
+		if err != nil {
+			return nil, err
+		}
+		total += amt
+		result = append(result, qentry)
+	}
+	if opening+total != closing {
+		return nil, &autoimport.BalanceError{
+			Statement: s.reference,
+			Opening:   opening,
+			Total:     total,
+			Closing:   closing}
+	}
+	return result, nil
+}
+
+// qfxEntry returns this transaction as an entry along with its amount
+// which is positive for credits.
+func (t *transaction) qfxEntry(accountId int64) (
+	*qfx.QfxEntry, fin.Money, error) {
+	lines := strings.SplitN(t.line, "\n", 2)
+	matches := kTransactionPattern.FindStringSubmatch(lines[0])
+	if matches == nil {
+		return nil, 0, fmt.Errorf("Invalid :61: field: %s", lines[0])
+	}
+	var qentry qfx.QfxEntry
+	valueDate, err := parseDate(matches[1])
+	if err != nil {
+		return nil, 0, err
+	}
+	qentry.Date = valueDate
+	if matches[2] != "" {
+		qentry.Date, err = bookingDate(valueDate, matches[2])
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	amt, err := parseAmount(matches[5])
+	if err != nil {
+		return nil, 0, err
+	}
+	// RD, a reversal of a debit, is a credit.
+	if matches[3] == "D" || matches[3] == "RC" {
+		amt = -amt
+	}
+	customerRef, bankRef := matches[7], ""
+	if idx := strings.Index(customerRef, "//"); idx != -1 {
+		customerRef, bankRef = customerRef[:idx], customerRef[idx+2:]
+	}
+	qentry.FitId = reference(bankRef)
+	if qentry.FitId == "" {
+		qentry.FitId = reference(customerRef)
+	}
+	name, remittance := parseInfo(t.info)
+	if remittance == "" && len(lines) > 1 {
+		remittance = collapseSpaces(lines[1])
+	}
+	qentry.Desc = remittance
+	// Prefer counterparty to remittance info to references to the
+	// transaction type so that every entry has a name.
+	qentry.Name = firstNonEmpty(
+		name,
+		remittance,
+		reference(customerRef),
+		reference(bankRef),
+		matches[6])
+	qentry.CatPayment = fin.NewCatPayment(
+		fin.Expense, -int64(amt), true, accountId)
+	return &qentry, amt, nil
+}
+
+// parseInfo returns the counterparty name and the remittance information
+// in a :86: field. Structured fields have ?xx subfields where ?20 to ?29
+// and ?60 to ?63 are remittance information and ?32 and ?33 are the
+// counterparty name. For unstructured fields, the whole field is the
+// remittance information.
+func parseInfo(info string) (name, remittance string) {
+	if !kSubfieldPattern.MatchString(info) {
+		return "", collapseSpaces(info)
+	}
+	// Subfields may continue on the next line.
+	info = strings.Replace(info, "\n", "", -1)
+	idxs := kSubfieldPattern.FindAllStringSubmatchIndex(info, -1)
+	var names, remittances []string
+	for i, idx := range idxs {
+		end := len(info)
+		if i+1 < len(idxs) {
+			end = idxs[i+1][0]
+		}
+		code, _ := strconv.Atoi(info[idx[2]:idx[3]])
+		value := info[idx[1]:end]
+		switch {
+		case code >= 20 && code <= 29, code >= 60 && code <= 63:
+			remittances = append(remittances, value)
+		case code == 32 || code == 33:
+			names = append(names, value)
+		}
+	}
+	return collapseSpaces(strings.Join(names, "")),
+		collapseSpaces(strings.Join(remittances, ""))
+}
+
+// readStatements reads the statements in an MT940 file.
+func readStatements(r io.Reader) ([]*statement, error) {
+	fields, institution, err := readFields(r)
+	if err != nil {
+		return nil, err
+	}
+	var result []*statement
+	var current *statement
+	var last *transaction
+	for _, f := range fields {
+		if f.tag == "20" {
+			current = &statement{
+				institution: institution, reference: f.value}
+			result = append(result, current)
+			last = nil
+			continue
+		}
+		if current == nil {
+			return nil, errors.New("MT940 file must start with a :20: field.")
+		}
+		switch f.tag {
+		case "25":
+			current.account = f.value
+		case "60F", "60M":
+			current.opening = f.value
+		case "62F", "62M":
+			current.closing = f.value
+			last = nil
+		case "61":
+			last = &transaction{line: f.value}
+			current.transactions = append(current.transactions, last)
+		case "86":
+			// :86: after the closing balance is about the statement.
+			if last != nil {
+				last.info = f.value
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("No MT940 statements found.")
+	}
+	return result, nil
+}
+
+// readFields reads the fields of an MT940 file. A field goes from its tag
+// to the next tag. readFields also returns the BIC in the basic header
+// block if there is one.
+func readFields(r io.Reader) (
+	fields []field, institution string, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(line, "{") {
+			if institution == "" && strings.HasPrefix(line, "{1:F01") &&
+				len(line) >= 14 {
+				institution = line[6:14]
+			}
+			if idx := strings.Index(line, "{4:"); idx != -1 {
+				line = line[idx+3:]
+			} else {
+				continue
+			}
+		}
+		if line == "" || line == "-" || strings.HasPrefix(line, "-}") {
+			continue
+		}
+		if matches := kTagPattern.FindStringSubmatch(line); matches != nil {
+			fields = append(fields, field{
+				tag: matches[1], value: line[len(matches[0]):]})
+		} else if len(fields) > 0 {
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	return
+}
+
+// parseBalance parses a balance field such as C151201EUR1000,00.
+func parseBalance(s string) (fin.Money, error) {
+	matches := kBalancePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return 0, fmt.Errorf("Invalid balance: %s", s)
+	}
+	amt, err := parseAmount(matches[4])
+	if err != nil {
+		return 0, err
+	}
+	if matches[1] == "D" {
+		amt = -amt
+	}
+	return amt, nil
+}
+
+func parseAmount(s string) (fin.Money, error) {
+	return fin.ParseMoney(strings.Replace(s, ",", ".", 1))
+}
+
+func parseDate(s string) (time.Time, error) {
+	return time.Parse("060102", s)
+}
+
+// bookingDate returns the booking date given as MMDD. The booking date
+// is usually in the same year as the value date but may be in the year
+// before or after around new year.
+func bookingDate(valueDate time.Time, mmdd string) (time.Time, error) {
+	date, err := time.Parse("0102", mmdd)
+	if err != nil {
+		return time.Time{}, err
+	}
+	year := valueDate.Year()
+	if valueDate.Month() == time.December && date.Month() == time.January {
+		year++
+	} else if valueDate.Month() == time.January && date.Month() == time.December {
+		year--
+	}
+	return time.Date(
+		year, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// reference returns ref or the empty string if ref says there is no
+// reference.
+func reference(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == kNoRef {
+		return ""
+	}
+	return ref
+}
+
+func accountIds(statements []*statement) []string {
+	var result []string
+	for _, stmt := range statements {
+		if !contains(result, stmt.account) {
+			result = append(result, stmt.account)
+		}
+	}
+	return result
+}
+
+// fitIdKey returns what identifies qentry in its file.
+func fitIdKey(qentry *qfx.QfxEntry) string {
+	return fmt.Sprintf(
+		"%s|%d|%s|%s",
+		qentry.Date.Format("20060102"),
+		qentry.Total(),
+		qentry.Name,
+		qentry.Desc)
+}
+
+func generateFitId(key string, count int) (string, error) {
+	h := fnv.New64a()
+	s := fmt.Sprintf("%s|%d", key, count)
+	_, err := h.Write(([]byte)(s))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(h.Sum64(), 10), nil
+}
+
+func firstNonEmpty(strs ...string) string {
+	for _, s := range strs {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func contains(strs []string, s string) bool {
+	for _, x := range strs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
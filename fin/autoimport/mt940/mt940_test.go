@@ -0,0 +1,233 @@
+package mt940_test
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/autoimport"
+	"github.com/keep94/finance/fin/autoimport/mt940"
+	"github.com/keep94/finance/fin/autoimport/qfx"
+	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const kMT940 = `{1:F01COBADEFFAXXX0000000000}{2:O9401200151231COBADEFFXXXX00000000001512311200N}{4:
+:20:STMT1
+:25:37040044/0532013000
+:28C:00001/001
+:60F:C151201EUR1000,00
+:61:1512311231DR12,34NTRFNONREF//BANKREF1
+:86:177?00SEPA UEBERWEISUNG?20Invoice 123 ?21December?32Stadtwerke
+?33 Berlin
+:61:1601021231CR1150,NTRFPAYROLL
+:86:SALARY DECEMBER
+:61:151231D3,50NMSCNONREF
+:86:Coffee
+:61:151231D3,50NMSCNONREF
+:86:Coffee
+:62F:C151231EUR2130,66
+:86:Statement info
+-}
+:20:STMT2
+:25:SAVINGS1
+:60M:D151201EUR10,00
+:61:151220C100,00NTRFNONREF
+/Transfer from checking
+:62M:C151220EUR90,00
+-
+`
+
+func TestReadMT940(t *testing.T) {
+	var loader autoimport.Loader
+	loader = mt940.MT940Loader{make(storeType)}
+	batch, err := loader.Load(
+		3,
+		"37040044/0532013000",
+		strings.NewReader(kMT940),
+		date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries := []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 31),
+			Name:       "Stadtwerke Berlin",
+			Desc:       "Invoice 123 December",
+			CatPayment: fin.NewCatPayment(fin.Expense, 1234, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 31),
+			Name:       "SALARY DECEMBER",
+			Desc:       "SALARY DECEMBER",
+			CatPayment: fin.NewCatPayment(fin.Expense, -115000, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 31),
+			Name:       "Coffee",
+			Desc:       "Coffee",
+			CatPayment: fin.NewCatPayment(fin.Expense, 350, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 31),
+			Name:       "Coffee",
+			Desc:       "Coffee",
+			CatPayment: fin.NewCatPayment(fin.Expense, 350, true, 3)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+	batch, err = loader.Load(
+		4, "SAVINGS1", strings.NewReader(kMT940), date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries = []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 20),
+			Name:       "/Transfer from checking",
+			Desc:       "/Transfer from checking",
+			CatPayment: fin.NewCatPayment(fin.Expense, -10000, true, 4)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+}
+
+func TestMT940NoInfo(t *testing.T) {
+	const noInfo = `:20:STMT3
+:25:CHECKING
+:60F:C151201EUR100,00
+:61:151202D5,00NCHGINV42
+:61:151203D2,50NCHGNONREF//BANKREF9
+:61:151204D1,00NCHGNONREF
+:62F:C151204EUR91,50
+`
+	loader := mt940.MT940Loader{make(storeType)}
+	batch, err := loader.Load(
+		3, "", strings.NewReader(noInfo), date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries := []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 2),
+			Name:       "INV42",
+			CatPayment: fin.NewCatPayment(fin.Expense, 500, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 3),
+			Name:       "BANKREF9",
+			CatPayment: fin.NewCatPayment(fin.Expense, 250, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 4),
+			Name:       "NCHG",
+			CatPayment: fin.NewCatPayment(fin.Expense, 100, true, 3)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+}
+
+func TestMT940FitIds(t *testing.T) {
+	store := make(storeType)
+	loader := mt940.MT940Loader{store}
+	batch, err := loader.Load(
+		3,
+		"37040044/0532013000",
+		strings.NewReader(kMT940),
+		date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	batch.MarkProcessed(nil)
+	if !store[3]["BANKREF1"] || !store[3]["PAYROLL"] {
+		t.Errorf("Expected references as fit ids, got %v", store[3])
+	}
+	// Two identical coffees get different fit ids.
+	if output := len(store[3]); output != 4 {
+		t.Errorf("Expected 4 fit ids, got %v", store[3])
+	}
+	batch, err = loader.Load(
+		3,
+		"37040044/0532013000",
+		strings.NewReader(kMT940),
+		date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	batch, _ = batch.SkipProcessed(nil)
+	if output := batch.Len(); output != 0 {
+		t.Errorf("Expected 0, got %v", output)
+	}
+}
+
+func TestMT940BankAccounts(t *testing.T) {
+	loader := mt940.MT940Loader{make(storeType)}
+	institution, bankAccountIds, err := loader.BankAccounts(
+		strings.NewReader(kMT940))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if institution != "COBADEFF" {
+		t.Errorf("Expected COBADEFF, got %s", institution)
+	}
+	expected := []string{"37040044/0532013000", "SAVINGS1"}
+	if !reflect.DeepEqual(expected, bankAccountIds) {
+		t.Errorf("Expected %v, got %v", expected, bankAccountIds)
+	}
+	_, err = loader.Load(
+		3, "", strings.NewReader(kMT940), date_util.YMD(2015, 12, 1))
+	if _, ok := err.(*qfx.BankAccountError); !ok {
+		t.Errorf("Expected BankAccountError, got %v", err)
+	}
+}
+
+func TestMT940BadBalance(t *testing.T) {
+	loader := mt940.MT940Loader{make(storeType)}
+	bad := strings.Replace(kMT940, ":62M:C151220EUR90,00", ":62M:C151220EUR90,01", 1)
+	_, err := loader.Load(
+		4, "SAVINGS1", strings.NewReader(bad), date_util.YMD(2015, 12, 1))
+	balanceErr, ok := err.(*autoimport.BalanceError)
+	if !ok {
+		t.Fatalf("Expected BalanceError, got %v", err)
+	}
+	expected := &autoimport.BalanceError{
+		Statement: "STMT2", Opening: -1000, Total: 10000, Closing: 9001}
+	if !reflect.DeepEqual(expected, balanceErr) {
+		t.Errorf("Expected %v, got %v", expected, balanceErr)
+	}
+}
+
+func TestReadBadMT940(t *testing.T) {
+	loader := mt940.MT940Loader{make(storeType)}
+	_, err := loader.Load(
+		3, "", strings.NewReader("A bad file\nNo MT940 things in here\n"),
+		date_util.YMD(2015, 12, 1))
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
+
+type storeType map[int64]map[string]bool
+
+func (s storeType) Add(t db.Transaction, accountId int64, fitIds qfxdb.FitIdSet) error {
+	if s[accountId] == nil {
+		s[accountId] = make(map[string]bool)
+	}
+	for fitId, ok := range fitIds {
+		if ok {
+			s[accountId][fitId] = true
+		}
+	}
+	return nil
+}
+
+func (s storeType) Find(t db.Transaction, accountId int64, fitIds qfxdb.FitIdSet) (qfxdb.FitIdSet, error) {
+	var result qfxdb.FitIdSet
+	for fitId, ok := range fitIds {
+		if ok {
+			if s[accountId][fitId] {
+				if result == nil {
+					result = make(qfxdb.FitIdSet)
+				}
+				result[fitId] = true
+			}
+		}
+	}
+	return result, nil
+}
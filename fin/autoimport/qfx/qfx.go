@@ -4,6 +4,7 @@ package qfx
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"github.com/keep94/finance/fin"
 	"github.com/keep94/finance/fin/autoimport"
 	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
@@ -24,6 +25,18 @@ const (
 	kCheckNum     = "<CHECKNUM>"
 	kStmtTrnClose = "</STMTTRN>"
 	kFitId        = "<FITID>"
+
+	// Bank and credit card statements identify their account with
+	// these blocks.
+	kBankAcctFrom      = "<BANKACCTFROM>"
+	kBankAcctFromClose = "</BANKACCTFROM>"
+	kCCAcctFrom        = "<CCACCTFROM>"
+	kCCAcctFromClose   = "</CCACCTFROM>"
+	kAcctId            = "<ACCTID>"
+
+	// The FI block identifies the financial institution.
+	kOrg = "<ORG>"
+	kFid = "<FID>"
 )
 
 var (
@@ -64,7 +77,36 @@ func byXMLToken(contents []byte) functional.Stream {
 	})
 }
 
+// BankAccountError is returned by QFXLoader.Load when the bankAccountId
+// passed to it does not pick out exactly one bank account in the file.
+type BankAccountError struct {
+	// The bankAccountId passed to Load
+	BankAccountId string
+
+	// The bank account ids in the file in the order they appear.
+	BankAccountIds []string
+}
+
+func (e *BankAccountError) Error() string {
+	if len(e.BankAccountIds) == 0 {
+		return fmt.Sprintf(
+			"Bank account %s not found. File has no bank accounts.",
+			e.BankAccountId)
+	}
+	ids := strings.Join(e.BankAccountIds, ", ")
+	if e.BankAccountId == "" {
+		return fmt.Sprintf(
+			"File has more than one bank account. Choose one of: %s", ids)
+	}
+	return fmt.Sprintf(
+		"Bank account %s not found. Choose one of: %s",
+		e.BankAccountId,
+		ids)
+}
+
 // QFXLoader implements the autoimport.Loader interface for QFX files.
+// When the file covers several bank accounts, Load returns only the
+// transactions for bankAccountId.
 type QFXLoader struct {
 	// Store stores which fitIds, unique identifier in QFX files,
 	// have already been processed.
@@ -76,6 +118,59 @@ func (q QFXLoader) Load(
 	bankAccountId string,
 	r io.Reader,
 	startDate time.Time) (autoimport.Batch, error) {
+	contents, err := readQFX(r, accountId, startDate)
+	if err != nil {
+		return nil, err
+	}
+	var result []*QfxEntry
+	if bankAccountId == "" && len(contents.bankAccountIds) <= 1 {
+		// The file covers at most one bank account so take everything.
+		result = contents.entries
+	} else if bankAccountId != "" && contains(contents.bankAccountIds, bankAccountId) {
+		for i := range contents.entries {
+			if contents.entryBankAccountIds[i] == bankAccountId {
+				result = append(result, contents.entries[i])
+			}
+		}
+	} else {
+		return nil, &BankAccountError{
+			BankAccountId:  bankAccountId,
+			BankAccountIds: contents.bankAccountIds}
+	}
+	for _, qe := range result {
+		if err = qe.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return &QfxBatch{Store: q.Store, AccountId: accountId, QfxEntries: result}, nil
+}
+
+// BankAccounts implements autoimport.BankAccountLister. The institution
+// is the ORG of the FI block or the FID if there is no ORG.
+func (q QFXLoader) BankAccounts(r io.Reader) (
+	institution string, bankAccountIds []string, err error) {
+	contents, err := readQFX(r, 0, time.Time{})
+	if err != nil {
+		return
+	}
+	return contents.institution, contents.bankAccountIds, nil
+}
+
+// qfxFile is what readQFX reads from a QFX file.
+type qfxFile struct {
+	institution string
+	entries     []*QfxEntry
+	// entryBankAccountIds[i] is the bank account id of entries[i]
+	entryBankAccountIds []string
+	// The bank account ids in the order they appear
+	bankAccountIds []string
+}
+
+// readQFX reads the entries posted on or after startDate from a QFX file
+// as payments from the account with id accountId. readQFX does not
+// check the entries it reads.
+func readQFX(
+	r io.Reader, accountId int64, startDate time.Time) (*qfxFile, error) {
 	fileStream := functional.ReadLines(r)
 	var line string
 	var err error
@@ -104,14 +199,31 @@ func (q QFXLoader) Load(
 	tagStream := byXMLToken(qfxContents.Bytes())
 	defer tagStream.Close()
 
+	result := &qfxFile{}
 	qe := &QfxEntry{}
-	var result []*QfxEntry
+	var org, fid string
+	var currentBankAccountId string
+	var inAcctFrom bool
 	var tagAndContents [2]string
 	var readName, readMemo string
 	for err = tagStream.Next(tagAndContents[:]); err == nil; err = tagStream.Next(tagAndContents[:]) {
 		tag := tagAndContents[0]
 		contents := tagAndContents[1]
-		if tag == kDtPosted {
+		if tag == kOrg {
+			org = strings.TrimSpace(strings.Replace(contents, "&amp;", "&", -1))
+		} else if tag == kFid {
+			fid = strings.TrimSpace(contents)
+		} else if tag == kBankAcctFrom || tag == kCCAcctFrom {
+			inAcctFrom = true
+		} else if tag == kBankAcctFromClose || tag == kCCAcctFromClose {
+			inAcctFrom = false
+		} else if tag == kAcctId && inAcctFrom {
+			currentBankAccountId = strings.TrimSpace(contents)
+			if !contains(result.bankAccountIds, currentBankAccountId) {
+				result.bankAccountIds = append(
+					result.bankAccountIds, currentBankAccountId)
+			}
+		} else if tag == kDtPosted {
 			qe.Date, err = parseQFXDate(contents)
 			if err != nil {
 				return nil, err
@@ -123,12 +235,12 @@ func (q QFXLoader) Load(
 		} else if tag == kCheckNum {
 			qe.CheckNo = contents
 		} else if tag == kTrnAmt {
-			var amt int64
-			amt, err = fin.ParseUSD(contents)
+			var amt fin.Money
+			amt, err = fin.ParseMoney(contents)
 			if err != nil {
 				return nil, err
 			}
-			qe.CatPayment = fin.NewCatPayment(fin.Expense, -amt, true, accountId)
+			qe.CatPayment = fin.NewCatPayment(fin.Expense, -int64(amt), true, accountId)
 		} else if tag == kFitId {
 			qe.FitId = contents
 		} else if tag == kStmtTrnClose {
@@ -141,18 +253,21 @@ func (q QFXLoader) Load(
 				} else {
 					qe.Name = readMemo
 				}
-				err = qe.Check()
-				if err != nil {
-					return nil, err
-				}
-				result = append(result, qe)
+				result.entries = append(result.entries, qe)
+				result.entryBankAccountIds = append(
+					result.entryBankAccountIds, currentBankAccountId)
 			}
 			qe = &QfxEntry{}
 			readName = ""
 			readMemo = ""
 		}
 	}
-	return &QfxBatch{Store: q.Store, AccountId: accountId, QfxEntries: result}, nil
+	if org != "" {
+		result.institution = org
+	} else {
+		result.institution = fid
+	}
+	return result, nil
 }
 
 // QfxBatch implements the autoimport.Batch interface. Although it was
@@ -231,6 +346,15 @@ func (q *QfxEntry) Check() error {
 	return nil
 }
 
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
 func parseQFXDate(s string) (time.Time, error) {
 	if len(s) < 8 {
 		return time.Time{}, errors.New("Invalid date field in qfx file.")
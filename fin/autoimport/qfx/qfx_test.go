@@ -252,6 +252,101 @@ NEWFILEUID:NONE
 
 <OFX><SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20121115120000[0:GMT]<LANGUAGE>ENG<FI><ORG>ISC<FID>10898</FI><INTU.BID>10898</SONRS></SIGNONMSGSRSV1><CREDITCARDMSGSRSV1><CCSTMTTRNRS><TRNUID>1<STATUS><CODE>0<SEVERITY>INFO<MESSAGE>Success</STATUS><CCSTMTRS><CURDEF>USD<CCACCTFROM><ACCTID>4147202080404005</CCACCTFROM><BANKTRANLIST><DTSTART>20121115120000[0:GMT]<DTEND>20121115120000[0:GMT]<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20121113120000[0:GMT]<TRNAMT>-109.01<FITID>10200<NAME>WHOLEFDS LAT 10155</STMTTRN><STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20121114120000[0:GMT]<TRNAMT>-100.75<FITID>10201<NAME>WHOLEFDS LAT 10155</STMTTRN><STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20121114120000[0:GMT]<TRNAMT>-57.14<FITID>10202<NAME>Amazon.com</STMTTRN><STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20121115120000[0:GMT]<TRNAMT>-12.12<FITID>10203<NAME>safeway</STMTTRN></BANKTRANLIST><LEDGERBAL><BALAMT>-3392.62<DTASOF>20121115120000[0:GMT]</LEDGERBAL><AVAILBAL><BALAMT>21714.00<DTASOF>20121115120000[0:GMT]</AVAILBAL></CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>`
 
+const kMultiAccountQfx = `
+OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+<OFX>
+<SIGNONMSGSRSV1>
+<SONRS>
+<FI>
+<ORG>B1
+<FID>10898
+</FI>
+</SONRS>
+</SIGNONMSGSRSV1>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<TRNUID>1
+<STMTRS>
+<CURDEF>USD
+<BANKACCTFROM>
+<BANKID>121000358
+<ACCTID>1111
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>XFER
+<DTPOSTED>20121113120000[0:GMT]
+<TRNAMT>-200.00
+<FITID>20200
+<NAME>Transfer to savings
+<BANKACCTTO>
+<BANKID>121000358
+<ACCTID>2222
+<ACCTTYPE>SAVINGS
+</BANKACCTTO>
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20121114120000[0:GMT]
+<TRNAMT>-12.12
+<FITID>20201
+<NAME>safeway
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+<STMTTRNRS>
+<TRNUID>2
+<STMTRS>
+<CURDEF>USD
+<BANKACCTFROM>
+<BANKID>121000358
+<ACCTID>2222
+<ACCTTYPE>SAVINGS
+</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>XFER
+<DTPOSTED>20121113120000[0:GMT]
+<TRNAMT>200.00
+<FITID>20300
+<NAME>Transfer from checking
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+<CREDITCARDMSGSRSV1>
+<CCSTMTTRNRS>
+<TRNUID>3
+<CCSTMTRS>
+<CURDEF>USD
+<CCACCTFROM>
+<ACCTID>3333
+</CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20121115120000[0:GMT]
+<TRNAMT>-57.14
+<FITID>20400
+<NAME>Amazon.com
+</STMTTRN>
+</BANKTRANLIST>
+</CCSTMTRS>
+</CCSTMTTRNRS>
+</CREDITCARDMSGSRSV1>
+</OFX>`
+
 func TestReadQFXBadFile(t *testing.T) {
 	r := strings.NewReader("A bad file\nNo QFX things in here\n")
 	var loader autoimport.Loader
@@ -351,6 +446,89 @@ func TestReadQFX(t *testing.T) {
 	}
 }
 
+func TestReadQFXMultiAccount(t *testing.T) {
+	loader := QFXLoader{make(storeType)}
+	batch, err := loader.Load(
+		3, "2222", strings.NewReader(kMultiAccountQfx), date_util.YMD(2012, 11, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries := []*fin.Entry{
+		{
+			Date:       date_util.YMD(2012, 11, 13),
+			Name:       "Transfer from checking",
+			CatPayment: fin.NewCatPayment(fin.Expense, -20000, true, 3)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+	batch, err = loader.Load(
+		4, "1111", strings.NewReader(kMultiAccountQfx), date_util.YMD(2012, 11, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if output := batch.Len(); output != 2 {
+		t.Errorf("Expected 2, got %v", output)
+	}
+}
+
+func TestQFXBankAccounts(t *testing.T) {
+	loader := QFXLoader{make(storeType)}
+	institution, bankAccountIds, err := loader.BankAccounts(
+		strings.NewReader(kMultiAccountQfx))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if institution != "B1" {
+		t.Errorf("Expected B1, got %s", institution)
+	}
+	expected := []string{"1111", "2222", "3333"}
+	if !reflect.DeepEqual(expected, bankAccountIds) {
+		t.Errorf("Expected %v, got %v", expected, bankAccountIds)
+	}
+}
+
+func TestReadQFXMultiAccountNeedsBankAccountId(t *testing.T) {
+	loader := QFXLoader{make(storeType)}
+	for _, bankAccountId := range []string{"", "4444"} {
+		_, err := loader.Load(
+			3,
+			bankAccountId,
+			strings.NewReader(kMultiAccountQfx),
+			date_util.YMD(2012, 11, 1))
+		bankAccountErr, ok := err.(*BankAccountError)
+		if !ok {
+			t.Errorf("Expected BankAccountError, got %v", err)
+			continue
+		}
+		expected := &BankAccountError{
+			BankAccountId:  bankAccountId,
+			BankAccountIds: []string{"1111", "2222", "3333"}}
+		if !reflect.DeepEqual(expected, bankAccountErr) {
+			t.Errorf("Expected %v, got %v", expected, bankAccountErr)
+		}
+	}
+}
+
+func TestReadQFXSingleAccountBankAccountId(t *testing.T) {
+	loader := QFXLoader{make(storeType)}
+	batch, err := loader.Load(
+		3,
+		"4147202080404005",
+		strings.NewReader(kSampleQfx),
+		date_util.YMD(2012, 11, 14))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if output := batch.Len(); output != 3 {
+		t.Errorf("Expected 3, got %v", output)
+	}
+	_, err = loader.Load(
+		3, "1111", strings.NewReader(kSampleQfx), date_util.YMD(2012, 11, 14))
+	if _, ok := err.(*BankAccountError); !ok {
+		t.Errorf("Expected BankAccountError, got %v", err)
+	}
+}
+
 func TestSkipProcessed(t *testing.T) {
 	r := strings.NewReader(kSampleQfx)
 	store := make(storeType)
@@ -0,0 +1,56 @@
+// Package for_memory provides an in-memory implementation for storing
+// processed QFX file fitIds.
+package for_memory
+
+import (
+	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"github.com/keep94/toolbox/db"
+)
+
+// New creates in-memory implementation of qfxdb.Store interface
+func New(db *memory_db.Db) qfxdb.Store {
+	return memoryStore{db}
+}
+
+func add(data *memory_db.Data, accountId int64, fitIds qfxdb.FitIdSet) {
+	for fitId, ok := range fitIds {
+		if ok {
+			data.FitIds[memory_db.FitId{AcctId: accountId, FitId: fitId}] = true
+		}
+	}
+}
+
+func find(data *memory_db.Data, accountId int64, fitIds qfxdb.FitIdSet) qfxdb.FitIdSet {
+	var result qfxdb.FitIdSet
+	for fitId, ok := range fitIds {
+		if ok && data.FitIds[memory_db.FitId{AcctId: accountId, FitId: fitId}] {
+			if result == nil {
+				result = make(qfxdb.FitIdSet)
+			}
+			result[fitId] = true
+		}
+	}
+	return result
+}
+
+type memoryStore struct {
+	db memory_db.Doer
+}
+
+func (s memoryStore) Add(
+	t db.Transaction, accountId int64, fitIds qfxdb.FitIdSet) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		add(data, accountId, fitIds)
+		return nil
+	})
+}
+
+func (s memoryStore) Find(
+	t db.Transaction, accountId int64, fitIds qfxdb.FitIdSet) (found qfxdb.FitIdSet, err error) {
+	err = memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		found = find(data, accountId, fitIds)
+		return nil
+	})
+	return
+}
@@ -0,0 +1,16 @@
+package for_memory
+
+import (
+	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb/fixture"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	db := memory_db.New()
+	newFixture(db).Find(t)
+}
+
+func newFixture(db *memory_db.Db) *fixture.Fixture {
+	return &fixture.Fixture{Store: New(db), Doer: memory_db.NewDoer(db)}
+}
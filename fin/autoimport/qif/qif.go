@@ -0,0 +1,296 @@
+// Package qif provides processing of QIF files
+package qif
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/autoimport"
+	"github.com/keep94/finance/fin/autoimport/qfx"
+	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Day before month formats like 25.12.2015 use a dot.
+	kDateFormats = []string{
+		"1/2/2006",
+		"1/2/06",
+		"1-2-2006",
+		"1-2-06",
+		"2006-1-2",
+		"2.1.2006",
+		"2.1.06",
+	}
+)
+
+var (
+	errNoType = errors.New("Unrecognized QIF file")
+)
+
+// QIFLoader implements the autoimport.Loader interface for QIF files.
+// QIFLoader reads the transactions in !Type:Bank and !Type:CCard sections
+// and ignores the other sections. QIF files do not identify their
+// transactions, so QIFLoader makes up a fitId for each transaction from
+// its date, amount, payee, check number, and memo.
+type QIFLoader struct {
+	// Store stores which transactions have already been processed.
+	Store qfxdb.Store
+}
+
+func (q QIFLoader) Load(
+	accountId int64,
+	bankAccountId string,
+	r io.Reader,
+	startDate time.Time) (autoimport.Batch, error) {
+	scanner := bufio.NewScanner(r)
+	var foundType, inTransactions bool
+	var record qifRecord
+	var result []*qfx.QfxEntry
+
+	// Identical transactions in the same file, such as two coffees on
+	// the same day, get different fitIds by counting them.
+	fitIdKeyCounts := make(map[string]int)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if lineNo == 1 {
+			// Skip any byte order mark
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" {
+			continue
+		}
+		if line[0] == '!' {
+			header := strings.ToLower(strings.TrimSpace(line))
+			if strings.HasPrefix(header, "!type:") {
+				foundType = true
+				kind := strings.TrimSpace(header[len("!type:"):])
+				inTransactions = kind == "bank" || kind == "ccard"
+			} else if header == "!account" {
+				inTransactions = false
+			}
+			record = qifRecord{}
+			continue
+		}
+		if !inTransactions {
+			continue
+		}
+		if line[0] != '^' {
+			if err := record.add(line); err != nil {
+				return nil, fmt.Errorf("QIF line %d: %v", lineNo, err)
+			}
+			continue
+		}
+		qentry, err := record.toEntry(accountId)
+		record = qifRecord{}
+		if err != nil {
+			return nil, fmt.Errorf("QIF line %d: %v", lineNo, err)
+		}
+		key := fitIdKey(qentry)
+		fitIdKeyCounts[key]++
+		if qentry.Date.Before(startDate) {
+			continue
+		}
+		qentry.FitId, err = generateFitId(key, fitIdKeyCounts[key])
+		if err != nil {
+			return nil, err
+		}
+		if err = qentry.Check(); err != nil {
+			return nil, err
+		}
+		result = append(result, qentry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !foundType {
+		return nil, errNoType
+	}
+	return &qfx.QfxBatch{Store: q.Store, AccountId: accountId, QfxEntries: result}, nil
+}
+
+type qifSplit struct {
+	category string
+	memo     string
+	amount   string
+}
+
+// qifRecord holds the fields of one QIF transaction.
+type qifRecord struct {
+	date    string
+	amount  string
+	uAmount string
+	payee   string
+	memo    string
+	checkNo string
+	splits  []qifSplit
+}
+
+func (r *qifRecord) add(line string) error {
+	value := strings.TrimSpace(line[1:])
+	switch line[0] {
+	case 'D':
+		r.date = value
+	case 'T':
+		r.amount = value
+	case 'U':
+		r.uAmount = value
+	case 'P':
+		r.payee = value
+	case 'M':
+		r.memo = value
+	case 'N':
+		r.checkNo = value
+	case 'S':
+		r.splits = append(r.splits, qifSplit{category: value})
+	case 'E', '$':
+		if len(r.splits) == 0 {
+			return fmt.Errorf("%c field before S field", line[0])
+		}
+		split := &r.splits[len(r.splits)-1]
+		if line[0] == 'E' {
+			split.memo = value
+		} else {
+			split.amount = value
+		}
+	}
+	return nil
+}
+
+func (r *qifRecord) toEntry(accountId int64) (*qfx.QfxEntry, error) {
+	var qentry qfx.QfxEntry
+	var err error
+	if r.date == "" {
+		return nil, errors.New("Transaction missing date.")
+	}
+	qentry.Date, err = parseQIFDate(r.date)
+	if err != nil {
+		return nil, err
+	}
+	amountStr := r.amount
+	if amountStr == "" {
+		amountStr = r.uAmount
+	}
+	var splitTotal fin.Money
+	var splitDescs []string
+	for _, split := range r.splits {
+		amt, err := fin.ParseMoney(split.amount)
+		if err != nil {
+			return nil, err
+		}
+		splitTotal += amt
+		splitDescs = append(splitDescs, split.String(amt))
+	}
+	var amt fin.Money
+	if amountStr != "" {
+		amt, err = fin.ParseMoney(amountStr)
+		if err != nil {
+			return nil, err
+		}
+		if len(r.splits) > 0 && splitTotal != amt {
+			return nil, fmt.Errorf(
+				"Splits total %v, but transaction total is %v.",
+				splitTotal,
+				amt)
+		}
+	} else if len(r.splits) > 0 {
+		amt = splitTotal
+	} else {
+		return nil, errors.New("Transaction missing amount.")
+	}
+	// Prefer payee to memo
+	if r.payee != "" {
+		qentry.Name = r.payee
+		qentry.Desc = r.memo
+	} else {
+		qentry.Name = r.memo
+	}
+	if len(splitDescs) > 0 {
+		if qentry.Desc != "" {
+			splitDescs = append([]string{qentry.Desc}, splitDescs...)
+		}
+		qentry.Desc = strings.Join(splitDescs, "; ")
+	}
+	if isCheckNo(r.checkNo) {
+		qentry.CheckNo = r.checkNo
+	}
+	qentry.CatPayment = fin.NewCatPayment(
+		fin.Expense, -int64(amt), true, accountId)
+	return &qentry, nil
+}
+
+// String returns this split for the description of its entry.
+func (s *qifSplit) String(amt fin.Money) string {
+	parts := []string{amt.String()}
+	if s.category != "" {
+		parts = append([]string{s.category}, parts...)
+	}
+	if s.memo != "" {
+		parts = append(parts, s.memo)
+	}
+	return strings.Join(parts, " ")
+}
+
+// isCheckNo returns true if s is a check number. The N field of bank
+// transactions may hold things like ATM or DEP instead.
+func isCheckNo(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseQIFDate parses a QIF date. Quicken writes years after 1999 as
+// 1/2'06 and may pad days and months with spaces as in 1/ 2'06.
+func parseQIFDate(s string) (time.Time, error) {
+	str := strings.Replace(strings.TrimSpace(s), " ", "", -1)
+	if idx := strings.IndexByte(str, '\''); idx != -1 {
+		year, err := strconv.Atoi(str[idx+1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid date: %s", s)
+		}
+		if year < 100 {
+			year += 2000
+		}
+		str = fmt.Sprintf("%s/%d", str[:idx], year)
+	}
+	for _, format := range kDateFormats {
+		if result, err := time.Parse(format, str); err == nil {
+			return result, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid date: %s", s)
+}
+
+// fitIdKey returns what identifies qentry in its file.
+func fitIdKey(qentry *qfx.QfxEntry) string {
+	return fmt.Sprintf(
+		"%s|%d|%s|%s|%s",
+		qentry.Date.Format("20060102"),
+		qentry.Total(),
+		qentry.Name,
+		qentry.CheckNo,
+		qentry.Desc)
+}
+
+func generateFitId(key string, count int) (string, error) {
+	h := fnv.New64a()
+	s := fmt.Sprintf("%s|%d", key, count)
+	_, err := h.Write(([]byte)(s))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(h.Sum64(), 10), nil
+}
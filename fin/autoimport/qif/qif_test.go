@@ -0,0 +1,200 @@
+package qif_test
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/autoimport"
+	"github.com/keep94/finance/fin/autoimport/qfx/qfxdb"
+	"github.com/keep94/finance/fin/autoimport/qif"
+	"github.com/keep94/toolbox/date_util"
+	"github.com/keep94/toolbox/db"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const kBankQif = `!Type:Bank
+D12/ 5'15
+T-87.00
+CX
+N1001
+PTrackR, Inc
+MBluetooth tracker
+LElectronics
+^
+D12/06/2015
+T1,250.00
+NDEP
+PPayroll
+^
+D12/7/2015
+T-60.00
+PSafeway
+MWeekly shopping
+SGroceries
+EMilk and eggs
+$-40.00
+SHousehold
+$-20.00
+^
+D12/7/2015
+T-3.50
+PStarbucks
+^
+D12/7/2015
+T-3.50
+PStarbucks
+^
+`
+
+const kCCardQif = `!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D2015-12-08
+U-12.34
+MLate fee
+^
+!Type:Cat
+NGroceries
+E
+^
+`
+
+func TestReadQif(t *testing.T) {
+	var loader autoimport.Loader
+	loader = qif.QIFLoader{make(storeType)}
+	batch, err := loader.Load(
+		3, "", strings.NewReader(kBankQif), date_util.YMD(2015, 12, 6))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	entries := batch.Entries()
+	expectedEntries := []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 6),
+			Name:       "Payroll",
+			CatPayment: fin.NewCatPayment(fin.Expense, -125000, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 7),
+			Name:       "Safeway",
+			Desc:       "Weekly shopping; Groceries -40.00 Milk and eggs; Household -20.00",
+			CatPayment: fin.NewCatPayment(fin.Expense, 6000, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 7),
+			Name:       "Starbucks",
+			CatPayment: fin.NewCatPayment(fin.Expense, 350, true, 3)},
+		{
+			Date:       date_util.YMD(2015, 12, 7),
+			Name:       "Starbucks",
+			CatPayment: fin.NewCatPayment(fin.Expense, 350, true, 3)}}
+	if !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+	batch, err = loader.Load(
+		3, "", strings.NewReader(kBankQif), date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntry := &fin.Entry{
+		Date:       date_util.YMD(2015, 12, 5),
+		Name:       "TrackR, Inc",
+		Desc:       "Bluetooth tracker",
+		CheckNo:    "1001",
+		CatPayment: fin.NewCatPayment(fin.Expense, 8700, true, 3)}
+	if entry := batch.Entries()[0]; !reflect.DeepEqual(expectedEntry, entry) {
+		t.Errorf("Expected %v, got %v", expectedEntry, entry)
+	}
+}
+
+func TestReadCCardQif(t *testing.T) {
+	loader := qif.QIFLoader{make(storeType)}
+	batch, err := loader.Load(
+		3, "", strings.NewReader(kCCardQif), date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	expectedEntries := []*fin.Entry{
+		{
+			Date:       date_util.YMD(2015, 12, 8),
+			Name:       "Late fee",
+			CatPayment: fin.NewCatPayment(fin.Expense, 1234, true, 3)}}
+	if entries := batch.Entries(); !reflect.DeepEqual(expectedEntries, entries) {
+		t.Errorf("Expected %v, got %v", expectedEntries, entries)
+	}
+}
+
+func TestReadBadQif(t *testing.T) {
+	loader := qif.QIFLoader{make(storeType)}
+	_, err := loader.Load(
+		3, "", strings.NewReader("A bad file\nNo QIF things in here\n"),
+		date_util.YMD(2015, 12, 1))
+	if err == nil {
+		t.Error("Expected error")
+	}
+	badSplits := strings.Replace(kBankQif, "$-20.00", "$-25.00", 1)
+	_, err = loader.Load(
+		3, "", strings.NewReader(badSplits), date_util.YMD(2015, 12, 1))
+	if err == nil {
+		t.Error("Expected error when splits do not add up")
+	}
+	badDate := strings.Replace(kBankQif, "D12/06/2015", "D12/06", 1)
+	_, err = loader.Load(
+		3, "", strings.NewReader(badDate), date_util.YMD(2015, 12, 1))
+	if err == nil {
+		t.Error("Expected error for bad date")
+	}
+}
+
+func TestQifMarkProcessed(t *testing.T) {
+	loader := qif.QIFLoader{make(storeType)}
+	batch, err := loader.Load(
+		3, "", strings.NewReader(kBankQif), date_util.YMD(2015, 12, 7))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	if output := batch.Len(); output != 3 {
+		t.Errorf("Expected 3, got %v", output)
+	}
+	batch.MarkProcessed(nil)
+	// Fit ids stay the same when the file is read again even with an
+	// earlier start date.
+	newBatch, err := loader.Load(
+		3, "", strings.NewReader(kBankQif), date_util.YMD(2015, 12, 1))
+	if err != nil {
+		t.Fatalf("Got error %v", err)
+	}
+	newBatch, _ = newBatch.SkipProcessed(nil)
+	if output := newBatch.Len(); output != 2 {
+		t.Errorf("Expected 2, got %v", output)
+	}
+}
+
+type storeType map[int64]map[string]bool
+
+func (s storeType) Add(t db.Transaction, accountId int64, fitIds qfxdb.FitIdSet) error {
+	if s[accountId] == nil {
+		s[accountId] = make(map[string]bool)
+	}
+	for fitId, ok := range fitIds {
+		if ok {
+			s[accountId][fitId] = true
+		}
+	}
+	return nil
+}
+
+func (s storeType) Find(t db.Transaction, accountId int64, fitIds qfxdb.FitIdSet) (qfxdb.FitIdSet, error) {
+	var result qfxdb.FitIdSet
+	for fitId, ok := range fitIds {
+		if ok {
+			if s[accountId][fitId] {
+				if result == nil {
+					result = make(qfxdb.FitIdSet)
+				}
+				result[fitId] = true
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,14 @@
+package fin
+
+// BankAccount identifies an account at a financial institution.
+type BankAccount struct {
+	// The financial institution. For QFX files, the ORG of the FI block.
+	Institution string
+
+	// The account number at the institution. For QFX files, the ACCTID.
+	BankAccountId string
+}
+
+// BankAccounts maps accounts at financial institutions to the ids of the
+// accounts into which their transactions get imported.
+type BankAccounts map[BankAccount]int64
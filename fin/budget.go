@@ -0,0 +1,117 @@
+package fin
+
+import (
+	"fmt"
+	"time"
+)
+
+// BudgetPeriod is the period of a budget target.
+// The zero value is equivalent to 'monthly'.
+type BudgetPeriod int
+
+const (
+	MonthlyBudget BudgetPeriod = iota
+	YearlyBudget
+	// Placeholder for period count. Does not represent an actual period.
+	// New periods must be inserted right before this one.
+	BudgetPeriodCount
+)
+
+// ToBudgetPeriod takes an int that ToInt returned and converts it back to a
+// BudgetPeriod. On success, returns the BudgetPeriod and true. If x is out
+// of range, returns BudgetPeriodCount and false.
+func ToBudgetPeriod(x int) (BudgetPeriod, bool) {
+	if x < 0 || x >= int(BudgetPeriodCount) {
+		return BudgetPeriodCount, false
+	}
+	return BudgetPeriod(x), true
+}
+
+func (b BudgetPeriod) String() string {
+	switch b {
+	case MonthlyBudget:
+		return "monthly"
+	case YearlyBudget:
+		return "yearly"
+	default:
+		return "unknown"
+	}
+}
+
+// ToInt maps a BudgetPeriod to an int in a way that is suitable for
+// persistent storage.
+func (b BudgetPeriod) ToInt() int {
+	return int(b)
+}
+
+// BudgetItem is the budget target for a single category.
+type BudgetItem struct {
+	Id int64
+	// The category. Totals of child categories count against this target.
+	Cat Cat
+	// The target for each period in cents. Always positive for both
+	// expense and income categories.
+	Amount int64
+	// The period of Amount.
+	Period BudgetPeriod
+	// If true, amounts left unspent in earlier periods carry over.
+	Rollover bool
+	// The date this target takes effect. Rollover starts from the month
+	// containing this date. Rollover items with no start date carry
+	// nothing over.
+	Start time.Time
+}
+
+func (b *BudgetItem) String() string {
+	return fmt.Sprintf("%v", *b)
+}
+
+// TargetForMonths returns the target for this item over the given number
+// of months.
+func (b *BudgetItem) TargetForMonths(months int) int64 {
+	if b.Period == YearlyBudget {
+		return b.Amount * int64(months) / 12
+	}
+	return b.Amount * int64(months)
+}
+
+// BudgetLine compares a budget item to what was actually spent during a
+// report period.
+type BudgetLine struct {
+	Item *BudgetItem
+	// The target for the report period excluding any carry over.
+	Target int64
+	// The unspent amount carried over from earlier periods. Always zero
+	// unless Item.Rollover is true.
+	Carried int64
+	// The actual amount spent or for income categories, received.
+	Actual int64
+}
+
+// Available returns Target + Carried.
+func (b *BudgetLine) Available() int64 {
+	return b.Target + b.Carried
+}
+
+// Remaining returns how much is left to spend. A negative value means
+// over budget.
+func (b *BudgetLine) Remaining() int64 {
+	return b.Available() - b.Actual
+}
+
+// OverBudget returns true if more was spent than available. For income
+// categories, OverBudget returns true if more was received than targeted
+// which is good news.
+func (b *BudgetLine) OverBudget() bool {
+	return b.Remaining() < 0
+}
+
+// BudgetActual converts a category total as found in CatTotals to an
+// amount to compare against a budget target. For income categories, the
+// sign flips so that income is positive.
+func BudgetActual(cat Cat, total int64) int64 {
+	if cat.Type == IncomeCat {
+		return -total
+	}
+	return total
+}
@@ -338,6 +338,26 @@ func (cds CatDetailStore) Filter(cat fin.Cat, includeChildren bool) fin.CatFilte
 	}
 }
 
+// Cats returns the categories that Filter(cat, includeChildren) accepts.
+// Cats returns nil if cat is top level and includeChildren is true because
+// then every category of that type matches.
+func (cds CatDetailStore) Cats(cat fin.Cat, includeChildren bool) fin.CatSet {
+	if !includeChildren {
+		return fin.CatSet{cat: true}
+	}
+	if cat.Id == 0 {
+		return nil
+	}
+	data := cds.data()
+	result := make(fin.CatSet)
+	for c := range data.catIdToDetail {
+		if data.isChildOf(c, cat) {
+			result[c] = true
+		}
+	}
+	return result
+}
+
 // Add adds a new category in the database and returns the updated store.
 // Name is the full name of the new category; adder adds the category to
 // the database. On error, returns the receiver unchanged.
@@ -419,6 +419,25 @@ func TestFilterTopLevelNoChildren(t *testing.T) {
 	verifyFilterExcludes(t, cat_filter, toCat("0:9983"))
 }
 
+func TestCats(t *testing.T) {
+	cds := createCatDetailStore()
+	expected := fin.CatSet{
+		toCat("0:1"):  true,
+		toCat("0:4"):  true,
+		toCat("0:5"):  true,
+		toCat("0:98"): true}
+	if cats := cds.Cats(toCat("0:1"), true); !reflect.DeepEqual(expected, cats) {
+		t.Errorf("Expected %v, got %v", expected, cats)
+	}
+	expected = fin.CatSet{toCat("0:1"): true}
+	if cats := cds.Cats(toCat("0:1"), false); !reflect.DeepEqual(expected, cats) {
+		t.Errorf("Expected %v, got %v", expected, cats)
+	}
+	if cats := cds.Cats(toCat("0:0"), true); cats != nil {
+		t.Errorf("Expected nil, got %v", cats)
+	}
+}
+
 func toCat(s string) fin.Cat {
 	return fin.NewCat(s)
 }
@@ -0,0 +1,297 @@
+package for_memory
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories"
+	"github.com/keep94/finance/fin/categories/categoriesdb"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"github.com/keep94/toolbox/db"
+	"sync"
+)
+
+func New(db *memory_db.Db) *Cache {
+	return &Cache{db: db}
+}
+
+func ReadOnlyWrapper(c *Cache) ReadOnlyCache {
+	return ReadOnlyCache{cache: c}
+}
+
+type catDetailCache struct {
+	mutex sync.Mutex
+	data  categories.CatDetailStore
+	valid bool
+}
+
+func (c *catDetailCache) DbGet(db *memory_db.Db) (
+	cds categories.CatDetailStore, err error) {
+	cds, ok := c.getFromCache()
+	if ok {
+		return
+	}
+	err = db.Do(func(data *memory_db.Data) (err error) {
+		cds, err = c.load(data)
+		return
+	})
+	return
+}
+
+func (c *catDetailCache) Get(data *memory_db.Data) (
+	cds categories.CatDetailStore, err error) {
+	cds, ok := c.getFromCache()
+	if ok {
+		return
+	}
+	return c.load(data)
+}
+
+func (c *catDetailCache) Invalidate(data *memory_db.Data) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.valid = false
+	return nil
+}
+
+func (c *catDetailCache) AccountAdd(data *memory_db.Data, name string) (
+	cds categories.CatDetailStore, newId int64, err error) {
+	if cds, err = catDetails(data); err != nil {
+		cds, _ = c.getFromCache()
+		return
+	}
+	cds, newId, err = cds.AccountAdd(name, accountStoreUpdater{data})
+	c.save(cds)
+	return
+}
+
+func (c *catDetailCache) AccountRename(
+	data *memory_db.Data, id int64, name string) (
+	cds categories.CatDetailStore, err error) {
+	if cds, err = catDetails(data); err != nil {
+		cds, _ = c.getFromCache()
+		return
+	}
+	cds, err = cds.AccountRename(id, name, accountStoreUpdater{data})
+	c.save(cds)
+	return
+}
+
+func (c *catDetailCache) AccountRemove(
+	data *memory_db.Data, id int64) (
+	cds categories.CatDetailStore, err error) {
+	if cds, err = catDetails(data); err != nil {
+		cds, _ = c.getFromCache()
+		return
+	}
+	cds, err = cds.AccountRemove(id, accountStoreUpdater{data})
+	c.save(cds)
+	return
+}
+
+func (c *catDetailCache) Add(data *memory_db.Data, name string) (
+	cds categories.CatDetailStore, newId fin.Cat, err error) {
+	if cds, err = catDetails(data); err != nil {
+		cds, _ = c.getFromCache()
+		return
+	}
+	cds, newId, err = cds.Add(name, catDetailStoreUpdater{data})
+	c.save(cds)
+	return
+}
+
+func (c *catDetailCache) Remove(data *memory_db.Data, id fin.Cat) (
+	cds categories.CatDetailStore, err error) {
+	if cds, err = catDetails(data); err != nil {
+		cds, _ = c.getFromCache()
+		return
+	}
+	cds, err = cds.Remove(id, catDetailStoreUpdater{data})
+	c.save(cds)
+	return
+}
+
+func (c *catDetailCache) Purge(data *memory_db.Data, cats fin.CatSet) error {
+	for cat, ok := range cats {
+		if ok {
+			if cat.Type == fin.ExpenseCat {
+				delete(data.ExpenseCategories, cat.Id)
+			} else if cat.Type == fin.IncomeCat {
+				delete(data.IncomeCategories, cat.Id)
+			} else {
+				return categories.NeedExpenseIncomeCategory
+			}
+		}
+	}
+	return c.Invalidate(data)
+}
+
+func (c *catDetailCache) Rename(data *memory_db.Data, id fin.Cat, newName string) (
+	cds categories.CatDetailStore, err error) {
+	if cds, err = catDetails(data); err != nil {
+		cds, _ = c.getFromCache()
+		return
+	}
+	cds, err = cds.Rename(id, newName, catDetailStoreUpdater{data})
+	c.save(cds)
+	return
+}
+
+func (c *catDetailCache) save(cds categories.CatDetailStore) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.data = cds
+	c.valid = true
+}
+
+func (c *catDetailCache) load(data *memory_db.Data) (
+	cds categories.CatDetailStore, err error) {
+	if cds, err = catDetails(data); err != nil {
+		return
+	}
+	c.save(cds)
+	return
+}
+
+func (c *catDetailCache) getFromCache() (cds categories.CatDetailStore, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.valid {
+		return
+	}
+	return c.data, true
+}
+
+type Cache struct {
+	db *memory_db.Db
+	c  catDetailCache
+}
+
+func (c *Cache) AccountAdd(t db.Transaction, name string) (
+	cds categories.CatDetailStore, newId int64, err error) {
+	err = memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) (err error) {
+		cds, newId, err = c.c.AccountAdd(data, name)
+		return
+	})
+	return
+}
+
+func (c *Cache) AccountRename(t db.Transaction, id int64, name string) (
+	cds categories.CatDetailStore, err error) {
+	err = memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) (err error) {
+		cds, err = c.c.AccountRename(data, id, name)
+		return
+	})
+	return
+}
+
+func (c *Cache) AccountRemove(t db.Transaction, id int64) (
+	cds categories.CatDetailStore, err error) {
+	err = memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) (err error) {
+		cds, err = c.c.AccountRemove(data, id)
+		return
+	})
+	return
+}
+
+func (c *Cache) Add(t db.Transaction, name string) (
+	cds categories.CatDetailStore, newId fin.Cat, err error) {
+	err = memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) (err error) {
+		cds, newId, err = c.c.Add(data, name)
+		return
+	})
+	return
+}
+
+func (c *Cache) Get(t db.Transaction) (
+	cds categories.CatDetailStore, err error) {
+	if t != nil {
+		err = memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) (err error) {
+			cds, err = c.c.Get(data)
+			return
+		})
+		return
+	}
+	return c.c.DbGet(c.db)
+}
+
+func (c *Cache) Invalidate(t db.Transaction) error {
+	return memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) error {
+		return c.c.Invalidate(data)
+	})
+}
+
+func (c *Cache) Remove(t db.Transaction, id fin.Cat) (
+	cds categories.CatDetailStore, err error) {
+	err = memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) (err error) {
+		cds, err = c.c.Remove(data, id)
+		return
+	})
+	return
+}
+
+func (c *Cache) Purge(t db.Transaction, cats fin.CatSet) error {
+	return memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) error {
+		return c.c.Purge(data, cats)
+	})
+}
+
+func (c *Cache) Rename(t db.Transaction, id fin.Cat, newName string) (
+	cds categories.CatDetailStore, err error) {
+	err = memory_db.ToDoer(c.db, t).Do(func(data *memory_db.Data) (err error) {
+		cds, err = c.c.Rename(data, id, newName)
+		return
+	})
+	return
+}
+
+// The writing methods of ReadOnlyCache merely return
+// categoriesdb.NoPermission error along with the contents of the cache.
+// If nothing is in the cache, they read from the database.
+type ReadOnlyCache struct {
+	categoriesdb.NoPermissionCache
+	cache *Cache
+}
+
+func (c ReadOnlyCache) Get(t db.Transaction) (
+	cds categories.CatDetailStore, err error) {
+	return c.cache.Get(t)
+}
+
+func (c ReadOnlyCache) AccountAdd(t db.Transaction, name string) (
+	cds categories.CatDetailStore, newId int64, err error) {
+	cds, err = c.reportNoPermission(t)
+	return
+}
+
+func (c ReadOnlyCache) AccountRename(t db.Transaction, id int64, name string) (
+	cds categories.CatDetailStore, err error) {
+	return c.reportNoPermission(t)
+}
+
+func (c ReadOnlyCache) AccountRemove(t db.Transaction, id int64) (
+	cds categories.CatDetailStore, err error) {
+	return c.reportNoPermission(t)
+}
+
+func (c ReadOnlyCache) Add(t db.Transaction, name string) (
+	cds categories.CatDetailStore, newId fin.Cat, err error) {
+	cds, err = c.reportNoPermission(t)
+	return
+}
+
+func (c ReadOnlyCache) Remove(t db.Transaction, id fin.Cat) (
+	cds categories.CatDetailStore, err error) {
+	return c.reportNoPermission(t)
+}
+
+func (c ReadOnlyCache) Rename(
+	t db.Transaction, id fin.Cat, newName string) (
+	cds categories.CatDetailStore, err error) {
+	return c.reportNoPermission(t)
+}
+
+func (c ReadOnlyCache) reportNoPermission(t db.Transaction) (
+	cds categories.CatDetailStore, err error) {
+	cds, _ = c.cache.Get(t)
+	err = categoriesdb.NoPermission
+	return
+}
@@ -0,0 +1,138 @@
+// Package for_memory stores types in categories package in an in-memory
+// database.
+package for_memory
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories"
+	fmemory "github.com/keep94/finance/fin/findb/for_memory"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"github.com/keep94/goconsume"
+	"sort"
+)
+
+// CatDetails populates a CatDetailStore object from the database.
+func catDetails(data *memory_db.Data) (
+	cds categories.CatDetailStore, err error) {
+	cdsb := categories.CatDetailStoreBuilder{}
+	cdc := categories.CatDetailConsumer{Builder: &cdsb, Type: fin.ExpenseCat}
+	readCategories(data.ExpenseCategories, &cdc)
+	cdc.Type = fin.IncomeCat
+	readCategories(data.IncomeCategories, &cdc)
+	adc := categories.AccountDetailConsumer{Builder: &cdsb}
+	if err = fmemory.DataNew(data).Accounts(nil, &adc); err != nil {
+		return
+	}
+	cds = cdsb.Build()
+	return
+}
+
+// accountStoreUpdater updates an in-memory database on behalf of a
+// fin.CatDetailStore value.
+type accountStoreUpdater struct {
+	D *memory_db.Data
+}
+
+func (u accountStoreUpdater) Add(name string) (newId int64, err error) {
+	account := fin.Account{
+		Name:   name,
+		Active: true,
+	}
+	if err = fmemory.DataNew(u.D).AddAccount(nil, &account); err != nil {
+		return
+	}
+	newId = account.Id
+	return
+}
+
+func (u accountStoreUpdater) Update(id int64, newName string) error {
+	store := fmemory.DataNew(u.D)
+	var account fin.Account
+	err := store.AccountById(nil, id, &account)
+	if err != nil {
+		return err
+	}
+	account.Name = newName
+	account.Active = true
+	return store.UpdateAccount(nil, &account)
+}
+
+func (u accountStoreUpdater) Remove(id int64) error {
+	store := fmemory.DataNew(u.D)
+	var account fin.Account
+	err := store.AccountById(nil, id, &account)
+	if err != nil {
+		return err
+	}
+	account.Active = false
+	return store.UpdateAccount(nil, &account)
+}
+
+// catDetailStoreUpdater updates an in-memory database on behalf of a
+// fin.CatDetailStore value.
+type catDetailStoreUpdater struct {
+	D *memory_db.Data
+}
+
+func (u catDetailStoreUpdater) Add(t fin.CatType, row *categories.CatDbRow) error {
+	if t == fin.ExpenseCat {
+		row.Id = u.D.NewId(memory_db.ExpenseCategoriesTable)
+		u.D.ExpenseCategories[row.Id] = *row
+	} else if t == fin.IncomeCat {
+		row.Id = u.D.NewId(memory_db.IncomeCategoriesTable)
+		u.D.IncomeCategories[row.Id] = *row
+	} else {
+		panic("t must be either ExpenseCat or IncomeCat")
+	}
+	return nil
+}
+
+func (u catDetailStoreUpdater) Update(t fin.CatType, row *categories.CatDbRow) error {
+	table := u.table(t)
+	if table == nil {
+		panic("t must be either ExpenseCat or IncomeCat")
+	}
+	if _, ok := table[row.Id]; ok {
+		table[row.Id] = *row
+	}
+	return nil
+}
+
+func (u catDetailStoreUpdater) Remove(t fin.CatType, id int64) error {
+	table := u.table(t)
+	if table == nil {
+		return categories.NeedExpenseIncomeCategory
+	}
+	if row, ok := table[id]; ok {
+		row.Active = false
+		table[id] = row
+	}
+	return nil
+}
+
+func (u catDetailStoreUpdater) table(t fin.CatType) map[int64]categories.CatDbRow {
+	if t == fin.ExpenseCat {
+		return u.D.ExpenseCategories
+	} else if t == fin.IncomeCat {
+		return u.D.IncomeCategories
+	}
+	return nil
+}
+
+// readCategories sends the rows in table to consumer in id order.
+func readCategories(
+	table map[int64]categories.CatDbRow, consumer goconsume.Consumer) {
+	rows := make([]categories.CatDbRow, 0, len(table))
+	for _, row := range table {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Id < rows[j].Id
+	})
+	for i := range rows {
+		if !consumer.CanConsume() {
+			break
+		}
+		consumer.Consume(&rows[i])
+	}
+}
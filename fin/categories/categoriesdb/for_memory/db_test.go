@@ -0,0 +1,215 @@
+package for_memory
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories"
+	"github.com/keep94/finance/fin/categories/categoriesdb/fixture"
+	fmemory "github.com/keep94/finance/fin/findb/for_memory"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"github.com/keep94/toolbox/db"
+	"testing"
+)
+
+func TestCatDetails(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CatDetails(t)
+}
+
+func TestCatDetailGoodAdd(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CatDetailGoodAdd(t)
+}
+
+func TestCatDetailsBadAdds(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CatDetailsBadAdds(t)
+}
+
+func TestCatDetailsRename(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CatDetailsRename(t)
+}
+
+func TestCatDetailsRename2(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CatDetailsRename2(t)
+}
+
+func TestCatDetailsRenameSame(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CatDetailsRenameSame(t)
+}
+
+func TestCatDetailsRenameBad(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CatDetailsRenameBad(t)
+}
+
+func TestRemoveCatDetail(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).RemoveCatDetail(t)
+}
+
+func TestRemoveCatDetail2(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).RemoveCatDetail2(t)
+}
+
+func TestRemoveCatDetailMissing(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).RemoveCatDetailMissing(t)
+}
+
+func TestRemoveCatDetailError(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).RemoveCatDetailError(t)
+}
+
+func TestCacheGet(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheGet(t, New(db))
+}
+
+func TestCatDetailInvalidate(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CatDetailInvalidate(t, New(db))
+}
+
+func TestCacheAdd(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAdd(t, New(db))
+}
+
+func TestCacheAddError(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAddError(t, New(db))
+}
+
+func TestCacheRename(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheRename(t, New(db))
+}
+
+func TestCacheRenameError(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheRenameError(t, New(db))
+}
+
+func TestCacheRemove(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheRemove(t, New(db))
+}
+
+func TestCacheRemoveError(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheRemoveError(t, New(db))
+}
+
+func TestCacheAccountAdd(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountAdd(t, New(db))
+}
+
+func TestCacheAccountAddError(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountAddError(t, New(db))
+}
+
+func TestCacheAccountAddMalformed(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountAddMalformed(t, New(db))
+}
+
+func TestCacheAccountRename(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountRename(t, New(db))
+}
+
+func TestCacheAccountRenameSame(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountRenameSame(t, New(db))
+}
+
+func TestCacheAccountRenameError(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountRenameError(t, New(db))
+}
+
+func TestCacheAccountRenameError2(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountRenameError2(t, New(db))
+}
+
+func TestCacheAccountRenameMalformed(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountRenameMalformed(t, New(db))
+}
+
+func TestCacheAccountRemove(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountRemove(t, New(db))
+}
+
+func TestCacheAccountRemoveError(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CacheAccountRemoveError(t, New(db))
+}
+
+func TestCachePurge(t *testing.T) {
+	db := openDb(t)
+	newFixture(db).CachePurge(t, New(db))
+}
+
+func newFixture(db *memory_db.Db) *fixture.Fixture {
+	return &fixture.Fixture{
+		Store: fmemory.New(db),
+		Doer:  memory_db.NewDoer(db),
+		Db:    dbstubb{db}}
+}
+
+type dbstubb struct {
+	db *memory_db.Db
+}
+
+func (d dbstubb) Read(t db.Transaction) (
+	cds categories.CatDetailStore, err error) {
+	err = memory_db.ToDoer(d.db, t).Do(func(data *memory_db.Data) (err error) {
+		cds, err = catDetails(data)
+		return
+	})
+	return
+}
+
+func (d dbstubb) Add(
+	t db.Transaction, cds categories.CatDetailStore, name string) (
+	newStore categories.CatDetailStore, newId fin.Cat, err error) {
+	err = memory_db.ToDoer(d.db, t).Do(func(data *memory_db.Data) (err error) {
+		newStore, newId, err = cds.Add(name, catDetailStoreUpdater{D: data})
+		return
+	})
+	return
+}
+
+func (d dbstubb) Rename(
+	t db.Transaction, cds categories.CatDetailStore, id fin.Cat, name string) (
+	newStore categories.CatDetailStore, err error) {
+	err = memory_db.ToDoer(d.db, t).Do(func(data *memory_db.Data) (err error) {
+		newStore, err = cds.Rename(id, name, catDetailStoreUpdater{D: data})
+		return
+	})
+	return
+}
+
+func (d dbstubb) Remove(
+	t db.Transaction, cds categories.CatDetailStore, id fin.Cat) (
+	newStore categories.CatDetailStore, err error) {
+	err = memory_db.ToDoer(d.db, t).Do(func(data *memory_db.Data) (err error) {
+		newStore, err = cds.Remove(id, catDetailStoreUpdater{D: data})
+		return
+	})
+	return
+}
+
+func openDb(t *testing.T) *memory_db.Db {
+	return memory_db.New()
+}
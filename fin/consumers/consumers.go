@@ -53,6 +53,35 @@ func (c *AddBalance) Consume(ptr interface{}) {
 	c.EntryBalanceConsumer.Consume(&c.entryBalance)
 }
 
+// ConvertCurrency is a consumer of Entry values that passes on each Entry
+// value with its amounts converted to a reporting currency. Entry values
+// that cannot be converted are skipped.
+type ConvertCurrency struct {
+	// Does the conversion
+	Converter *fin.CurrencyConverter
+	// Converted Entry values passed on here
+	EntryConsumer goconsume.Consumer
+	// Err is the error from the first Entry value that could not be
+	// converted.
+	Err   error
+	entry fin.Entry
+}
+
+func (c *ConvertCurrency) CanConsume() bool {
+	return c.EntryConsumer.CanConsume()
+}
+
+func (c *ConvertCurrency) Consume(ptr interface{}) {
+	c.entry = *ptr.(*fin.Entry)
+	if err := c.Converter.ConvertEntry(&c.entry); err != nil {
+		if c.Err == nil {
+			c.Err = err
+		}
+		return
+	}
+	c.EntryConsumer.Consume(&c.entry)
+}
+
 type entryAggregatorConsumer struct {
 	aggregator EntryAggregator
 }
@@ -63,6 +63,45 @@ func TestFromEntryAggregator(t *testing.T) {
 	}
 }
 
+func TestConvertCurrency(t *testing.T) {
+	converter := fin.NewCurrencyConverter(
+		fin.USD,
+		[]*fin.Account{{Id: 1, Currency: "EUR"}, {Id: 2}},
+		[]fin.ExchangeRate{{From: "EUR", To: fin.USD, Rate: 1.5}})
+	entries := []fin.Entry{
+		{CatPayment: makeTotalWithPayment(400, 1)},
+		{CatPayment: makeTotalWithPayment(700, 2)},
+		{CatPayment: makeTotalWithPayment(900, 3)},
+	}
+	aggregator := entryTotaler{}
+	consumer := &ConvertCurrency{
+		Converter:     converter,
+		EntryConsumer: FromEntryAggregator(&aggregator),
+	}
+	for i := range entries {
+		entry := entries[i]
+		consumer.Consume(&entry)
+	}
+	if aggregator.total != 2200 {
+		t.Errorf("Expected 2200, got %v", aggregator.total)
+	}
+	if consumer.Err != nil {
+		t.Errorf("Expected no error, got %v", consumer.Err)
+	}
+	if entries[0].Total() != 400 {
+		t.Error("Expected original entry to be unchanged.")
+	}
+	converter.Reporting = "CAD"
+	consumer.Consume(&entries[0])
+	if consumer.Err != fin.NoExchangeRate {
+		t.Errorf("Expected NoExchangeRate, got %v", consumer.Err)
+	}
+}
+
+func makeTotalWithPayment(total, paymentId int64) fin.CatPayment {
+	return fin.NewCatPayment(fin.NewCat("0:7"), -total, false, paymentId)
+}
+
 func makeTotal(total int64) fin.CatPayment {
 	return fin.NewCatPayment(fin.NewCat("0.7"), -total, false, 0)
 }
@@ -0,0 +1,241 @@
+package fin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Currency is an ISO 4217 currency code such as USD or EUR. The zero
+// value means DefaultCurrency.
+type Currency string
+
+const (
+	USD Currency = "USD"
+	// DefaultCurrency is the currency of accounts and amounts that don't
+	// specify one.
+	DefaultCurrency = USD
+)
+
+var (
+	// NoExchangeRate is returned when an amount cannot be converted because
+	// no suitable exchange rate is known.
+	NoExchangeRate = errors.New("fin: No exchange rate.")
+)
+
+// ParseCurrency converts a string such as "eur" to a Currency. The empty
+// string parses to DefaultCurrency.
+func ParseCurrency(s string) (Currency, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return DefaultCurrency, nil
+	}
+	if len(s) != 3 {
+		return "", fmt.Errorf("fin: Invalid currency: %s", s)
+	}
+	for _, ch := range s {
+		if ch < 'A' || ch > 'Z' {
+			return "", fmt.Errorf("fin: Invalid currency: %s", s)
+		}
+	}
+	return Currency(s), nil
+}
+
+// Normalize returns DefaultCurrency if this value is empty; otherwise it
+// returns this value.
+func (c Currency) Normalize() Currency {
+	if c == "" {
+		return DefaultCurrency
+	}
+	return c
+}
+
+func (c Currency) String() string {
+	return string(c.Normalize())
+}
+
+// ExchangeRate gives the value of one unit of From in units of To as of
+// Date.
+type ExchangeRate struct {
+	Id   int64
+	Date time.Time
+	From Currency
+	To   Currency
+	Rate float64
+}
+
+func (e *ExchangeRate) String() string {
+	return fmt.Sprintf("%v", *e)
+}
+
+type currencyPair struct {
+	from Currency
+	to   Currency
+}
+
+// ExchangeRates looks up exchange rates by date. The zero value has no
+// exchange rates.
+type ExchangeRates struct {
+	byPair map[currencyPair][]ExchangeRate
+}
+
+// NewExchangeRates returns a new ExchangeRates containing rates.
+func NewExchangeRates(rates []ExchangeRate) *ExchangeRates {
+	result := &ExchangeRates{byPair: make(map[currencyPair][]ExchangeRate)}
+	for _, rate := range rates {
+		if rate.Rate <= 0.0 {
+			continue
+		}
+		pair := currencyPair{from: rate.From.Normalize(), to: rate.To.Normalize()}
+		result.byPair[pair] = append(result.byPair[pair], rate)
+	}
+	for _, rates := range result.byPair {
+		sort.SliceStable(rates, func(i, j int) bool {
+			return rates[i].Date.Before(rates[j].Date)
+		})
+	}
+	return result
+}
+
+// Rate returns the value of one unit of from in units of to as of date.
+// Rate uses the most recent rate on or before date, trying both the rates
+// from from to to and the inverse of the rates from to to from. Rate
+// returns false if no such rate exists.
+func (e *ExchangeRates) Rate(from, to Currency, date time.Time) (
+	rate float64, ok bool) {
+	from, to = from.Normalize(), to.Normalize()
+	if from == to {
+		return 1.0, true
+	}
+	var asOf time.Time
+	if direct, found := e.latest(from, to, date); found {
+		rate, asOf, ok = direct.Rate, direct.Date, true
+	}
+	if inverse, found := e.latest(to, from, date); found {
+		if !ok || inverse.Date.After(asOf) {
+			rate, ok = 1.0/inverse.Rate, true
+		}
+	}
+	return
+}
+
+// Convert converts amount in from currency to to currency as of date.
+// Convert returns NoExchangeRate if there is no suitable exchange rate.
+func (e *ExchangeRates) Convert(
+	amount int64, from, to Currency, date time.Time) (int64, error) {
+	rate, ok := e.Rate(from, to, date)
+	if !ok {
+		return 0, NoExchangeRate
+	}
+	return convert(amount, rate), nil
+}
+
+func (e *ExchangeRates) latest(from, to Currency, date time.Time) (
+	result ExchangeRate, ok bool) {
+	if e == nil {
+		return
+	}
+	rates := e.byPair[currencyPair{from: from, to: to}]
+	idx := sort.Search(len(rates), func(i int) bool {
+		return rates[i].Date.After(date)
+	})
+	if idx == 0 {
+		return
+	}
+	return rates[idx-1], true
+}
+
+// CurrencyConverter converts Entry values and account deltas to a
+// reporting currency.
+type CurrencyConverter struct {
+	// The reporting currency
+	Reporting Currency
+	// Maps account Id to the currency of that account. Accounts missing
+	// from this map have DefaultCurrency.
+	Accounts map[int64]Currency
+	// The exchange rates to use
+	Rates *ExchangeRates
+}
+
+// NewCurrencyConverter returns a CurrencyConverter that converts to
+// reporting using the currencies of accounts and the given exchange rates.
+func NewCurrencyConverter(
+	reporting Currency,
+	accounts []*Account,
+	rates []ExchangeRate) *CurrencyConverter {
+	result := &CurrencyConverter{
+		Reporting: reporting.Normalize(),
+		Accounts:  make(map[int64]Currency, len(accounts)),
+		Rates:     NewExchangeRates(rates)}
+	for _, account := range accounts {
+		result.Accounts[account.Id] = account.Currency.Normalize()
+	}
+	return result
+}
+
+// AccountCurrency returns the currency of the account with given id.
+func (c *CurrencyConverter) AccountCurrency(acctId int64) Currency {
+	return c.Accounts[acctId].Normalize()
+}
+
+// ConvertEntry converts the amounts in entry from the currency of its
+// payment account to the reporting currency as of the date of entry.
+// Because the amounts of a CatPayment are all in the currency of the
+// payment account, ConvertEntry leaves the CurrencyAmount fields alone.
+// ConvertEntry returns NoExchangeRate and leaves entry unchanged if there
+// is no suitable exchange rate.
+func (c *CurrencyConverter) ConvertEntry(entry *Entry) error {
+	rate, ok := c.Rates.Rate(
+		c.AccountCurrency(entry.PaymentId()), c.Reporting, entry.Date)
+	if !ok {
+		return NoExchangeRate
+	}
+	if rate == 1.0 {
+		return nil
+	}
+	ncr := make([]CatRec, len(entry.cr))
+	for i := range entry.cr {
+		ncr[i] = entry.cr[i]
+		ncr[i].Amount = convert(ncr[i].Amount, rate)
+	}
+	entry.cr = ncr
+	return nil
+}
+
+// ConvertAccountDeltas returns deltas with the balances of each account
+// converted from the currency of that account to the reporting currency
+// as of date.
+func (c *CurrencyConverter) ConvertAccountDeltas(
+	deltas AccountDeltas, date time.Time) (AccountDeltas, error) {
+	result := make(AccountDeltas, len(deltas))
+	for id, delta := range deltas {
+		rate, ok := c.Rates.Rate(c.AccountCurrency(id), c.Reporting, date)
+		if !ok {
+			return nil, NoExchangeRate
+		}
+		converted := *delta
+		converted.Balance = convert(delta.Balance, rate)
+		converted.RBalance = convert(delta.RBalance, rate)
+		result[id] = &converted
+	}
+	return result, nil
+}
+
+// ConvertBalance converts balance from the currency of the account with
+// given id to the reporting currency as of date.
+func (c *CurrencyConverter) ConvertBalance(
+	acctId int64, balance int64, date time.Time) (int64, error) {
+	return c.Rates.Convert(
+		balance, c.AccountCurrency(acctId), c.Reporting, date)
+}
+
+func convert(amount int64, rate float64) int64 {
+	f := float64(amount) * rate
+	if f < 0 {
+		return -int64(math.Floor(-f + 0.5))
+	}
+	return int64(math.Floor(f + 0.5))
+}
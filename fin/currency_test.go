@@ -0,0 +1,129 @@
+package fin
+
+import (
+	"github.com/keep94/toolbox/date_util"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCurrency(t *testing.T) {
+	verifyParseCurrency(t, "eur", "EUR")
+	verifyParseCurrency(t, " CAD ", "CAD")
+	verifyParseCurrency(t, "", USD)
+	verifyParseCurrencyError(t, "EURO")
+	verifyParseCurrencyError(t, "E1R")
+	if Currency("").String() != "USD" {
+		t.Error("Expected empty currency to be USD.")
+	}
+}
+
+func TestExchangeRates(t *testing.T) {
+	rates := NewExchangeRates([]ExchangeRate{
+		{Date: date_util.YMD(2015, 3, 1), From: "EUR", To: USD, Rate: 1.25},
+		{Date: date_util.YMD(2015, 1, 1), From: "EUR", To: USD, Rate: 1.5},
+		{Date: date_util.YMD(2015, 2, 1), From: USD, To: "EUR", Rate: 0.5},
+		{Date: date_util.YMD(2015, 2, 1), From: USD, To: "CAD", Rate: 0.0},
+	})
+	verifyRate(t, rates, "EUR", USD, date_util.YMD(2015, 1, 15), 1.5)
+	verifyRate(t, rates, "EUR", USD, date_util.YMD(2015, 2, 1), 2.0)
+	verifyRate(t, rates, USD, "EUR", date_util.YMD(2015, 2, 15), 0.5)
+	verifyRate(t, rates, "EUR", USD, date_util.YMD(2015, 3, 1), 1.25)
+	verifyRate(t, rates, "EUR", "", date_util.YMD(2015, 4, 1), 1.25)
+	verifyRate(t, rates, "CAD", "CAD", date_util.YMD(2015, 4, 1), 1.0)
+	if _, ok := rates.Rate("EUR", USD, date_util.YMD(2014, 12, 31)); ok {
+		t.Error("Expected no rate before first date.")
+	}
+	if _, ok := rates.Rate(USD, "CAD", date_util.YMD(2015, 4, 1)); ok {
+		t.Error("Expected non positive rates to be ignored.")
+	}
+	amount, err := rates.Convert(-333, "EUR", USD, date_util.YMD(2015, 1, 1))
+	if err != nil || amount != -500 {
+		t.Errorf("Expected -500, got %d, %v", amount, err)
+	}
+	if _, err := rates.Convert(100, "CAD", USD, date_util.YMD(2015, 4, 1)); err != NoExchangeRate {
+		t.Errorf("Expected NoExchangeRate, got %v", err)
+	}
+}
+
+func TestCurrencyConverter(t *testing.T) {
+	converter := NewCurrencyConverter(
+		"",
+		[]*Account{{Id: 1, Currency: "EUR"}, {Id: 2}, {Id: 3, Currency: "CAD"}},
+		[]ExchangeRate{
+			{Date: date_util.YMD(2015, 1, 1), From: "EUR", To: USD, Rate: 1.5}})
+	cpb := CatPaymentBuilder{}
+	entry := Entry{
+		Date: date_util.YMD(2015, 6, 1),
+		CatPayment: cpb.AddCatRec(
+			CatRec{Cat: NewCat("0:7"), Amount: 1000}).AddCatRec(
+			CatRec{Cat: NewCat("2:3"), Amount: 200, Currency: "CAD", CurrencyAmount: 250}).SetPaymentId(
+			1).Build()}
+	original := entry
+	if err := converter.ConvertEntry(&entry); err != nil {
+		t.Fatalf("Got error converting entry: %v", err)
+	}
+	if verifyCatPayment(t, &entry.CatPayment, -1800, 2, 1, false) {
+		verifyCatRec(t, &entry.CatPayment, 0, "0:7", 1500, false)
+		verifyCatRec(t, &entry.CatPayment, 1, "2:3", 300, false)
+		if cr := entry.CatRecByIndex(1); cr.CurrencyAmount != 250 {
+			t.Errorf("Expected currency amount to be unchanged, got %d", cr.CurrencyAmount)
+		}
+	}
+	if original.Total() != -1200 {
+		t.Error("Expected original entry to be unchanged.")
+	}
+	deltas := AccountDeltas{
+		1: {Balance: 100, RBalance: 50, Count: 1},
+		2: {Balance: 100, Count: 1}}
+	converted, err := converter.ConvertAccountDeltas(deltas, date_util.YMD(2015, 6, 1))
+	if err != nil {
+		t.Fatalf("Got error converting deltas: %v", err)
+	}
+	expected := AccountDeltas{
+		1: {Balance: 150, RBalance: 75, Count: 1},
+		2: {Balance: 100, Count: 1}}
+	if !reflect.DeepEqual(expected, converted) {
+		t.Errorf("Expected %v, got %v", expected, converted)
+	}
+	deltas[3] = &AccountDelta{Balance: 100, Count: 1}
+	if _, err := converter.ConvertAccountDeltas(deltas, date_util.YMD(2015, 6, 1)); err != NoExchangeRate {
+		t.Errorf("Expected NoExchangeRate, got %v", err)
+	}
+	balance, err := converter.ConvertBalance(1, -200, date_util.YMD(2015, 6, 1))
+	if err != nil || balance != -300 {
+		t.Errorf("Expected -300, got %d, %v", balance, err)
+	}
+	if _, err := converter.ConvertBalance(3, 100, date_util.YMD(2015, 6, 1)); err != NoExchangeRate {
+		t.Errorf("Expected NoExchangeRate, got %v", err)
+	}
+}
+
+func verifyParseCurrency(t *testing.T, s string, expected Currency) {
+	actual, err := ParseCurrency(s)
+	if err != nil {
+		t.Errorf("Got error parsing %s: %v", s, err)
+		return
+	}
+	if actual != expected {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+}
+
+func verifyParseCurrencyError(t *testing.T, s string) {
+	if _, err := ParseCurrency(s); err == nil {
+		t.Errorf("Expected error parsing %s", s)
+	}
+}
+
+func verifyRate(
+	t *testing.T,
+	rates *ExchangeRates,
+	from, to Currency,
+	asOf time.Time,
+	expected float64) {
+	actual, ok := rates.Rate(from, to, asOf)
+	if !ok || actual != expected {
+		t.Errorf("Expected %v for %s to %s, got %v", expected, from, to, actual)
+	}
+}
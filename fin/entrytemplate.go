@@ -0,0 +1,65 @@
+package fin
+
+import (
+	"fmt"
+)
+
+// EntryTemplate is a memorized transaction for filling in new entries
+// such as a paycheck split into gross pay, taxes, and deductions.
+type EntryTemplate struct {
+	// The entry this template fills in. Id is the id of the template.
+	// Date, Status and the reconciled flags are ignored.
+	Entry
+
+	// The name of the template.
+	Title string
+
+	// Percents maps the categories of percentage based lines to their
+	// share of the total in hundredths of a percent so that 2250 means
+	// 22.5%. Like CatRec.Amount, positive means expense and negative
+	// means income. The amounts in CatPayment for these categories are
+	// ignored.
+	Percents map[Cat]int64
+}
+
+func (t *EntryTemplate) String() string {
+	return fmt.Sprintf("%v", *t)
+}
+
+// Fill fills in the name, description, check number, tags and
+// categories of entry from this template leaving the other fields of
+// entry unchanged. total is the amount in one cent increments that
+// percentage based lines are a share of. Percentage based lines are
+// rounded to the nearest cent.
+func (t *EntryTemplate) Fill(total int64, entry *Entry) {
+	entry.Name = t.Name
+	entry.Desc = t.Desc
+	entry.CheckNo = t.CheckNo
+	entry.Tags = nil
+	if len(t.Tags) > 0 {
+		entry.Tags = make(TagSet, len(t.Tags))
+		for tag := range t.Tags {
+			entry.Tags[tag] = true
+		}
+	}
+	cpb := CatPaymentBuilder{}
+	cpb.SetPaymentId(t.PaymentId())
+	for _, cr := range t.CatRecs() {
+		if percent, ok := t.Percents[cr.Cat]; ok {
+			cr.Amount = percentOf(total, percent)
+		}
+		cr.Reconciled = false
+		cpb.AddCatRec(cr)
+	}
+	entry.CatPayment = cpb.Build()
+}
+
+// percentOf returns percent hundredths of a percent of total rounding
+// half away from zero.
+func percentOf(total, percent int64) int64 {
+	product := total * percent
+	if product < 0 {
+		return (product - 5000) / 10000
+	}
+	return (product + 5000) / 10000
+}
@@ -0,0 +1,51 @@
+package fin
+
+import (
+	"github.com/keep94/toolbox/date_util"
+	"reflect"
+	"testing"
+)
+
+func TestEntryTemplateFill(t *testing.T) {
+	cpb := CatPaymentBuilder{}
+	template := EntryTemplate{
+		Entry: Entry{
+			Id:      5,
+			Name:    "Paycheck",
+			Desc:    "Acme",
+			CheckNo: "1001",
+			Tags:    ParseTags("work"),
+			CatPayment: cpb.AddCatRec(
+				CatRec{Cat: NewCat("1:2")}).AddCatRec(
+				CatRec{Cat: NewCat("0:7")}).AddCatRec(
+				CatRec{Cat: NewCat("0:8"), Amount: 1500}).AddCatRec(
+				CatRec{Cat: NewCat("2:3"), Reconciled: true}).SetPaymentId(
+				1).SetReconciled(true).Build()},
+		Title: "Paycheck",
+		Percents: map[Cat]int64{
+			NewCat("1:2"): -10000,
+			NewCat("0:7"): 2250,
+			NewCat("2:3"): 333}}
+	entry := Entry{Id: 9, Date: date_util.YMD(2015, 10, 15), Name: "Old"}
+	template.Fill(400001, &entry)
+	expected := Entry{
+		Id:      9,
+		Date:    date_util.YMD(2015, 10, 15),
+		Name:    "Paycheck",
+		Desc:    "Acme",
+		CheckNo: "1001",
+		Tags:    ParseTags("work"),
+		CatPayment: cpb.AddCatRec(
+			CatRec{Cat: NewCat("1:2"), Amount: -400001}).AddCatRec(
+			CatRec{Cat: NewCat("0:7"), Amount: 90000}).AddCatRec(
+			CatRec{Cat: NewCat("0:8"), Amount: 1500}).AddCatRec(
+			CatRec{Cat: NewCat("2:3"), Amount: 13320}).SetPaymentId(
+			1).Build()}
+	if !reflect.DeepEqual(expected, entry) {
+		t.Errorf("Expected %v, got %v", expected, entry)
+	}
+	entry.Tags["home"] = true
+	if template.Tags.Contains("home") {
+		t.Error("Expected Fill to copy tags.")
+	}
+}
@@ -21,6 +21,9 @@ type AdvanceSearchSpec struct {
 	CF fin.CatFilter
 	// If present, include only entries whose total matches AF.
 	AF AmountFilter
+	// If present, include only entries with this tag. An invalid tag
+	// matches no entries.
+	Tag string
 }
 
 // CompileAdvanceSearchSpec compiles a search specification into a
@@ -33,6 +36,9 @@ func CompileAdvanceSearchSpec(spec *AdvanceSearchSpec) goconsume.FilterFunc {
 	if spec.AF != nil {
 		filters = append(filters, byAmountFilterer(spec.AF))
 	}
+	if spec.Tag != "" {
+		filters = append(filters, byTagFilterer(fin.NormalizeTag(spec.Tag)))
+	}
 	if spec.Name != "" {
 		filters = append(filters, byNameFilterer(str_util.Normalize(spec.Name)))
 	}
@@ -56,6 +62,13 @@ func byAmountFilterer(f AmountFilter) goconsume.FilterFunc {
 	}
 }
 
+func byTagFilterer(tag string) goconsume.FilterFunc {
+	return func(ptr interface{}) bool {
+		p := ptr.(*fin.Entry)
+		return tag != "" && p.Tags[tag]
+	}
+}
+
 func byNameFilterer(name string) goconsume.FilterFunc {
 	return func(ptr interface{}) bool {
 		p := ptr.(*fin.Entry)
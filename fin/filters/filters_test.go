@@ -35,6 +35,28 @@ func TestCompileAdvanceSearchSpec(t *testing.T) {
 			AF: func(amt int64) bool { return amt == -201 }})); output != 0 {
 		t.Errorf("Expected 0, got %v", output)
 	}
+	if output := runFilter(CompileAdvanceSearchSpec(
+		&AdvanceSearchSpec{
+			Tag: "Vacation"})); output != 2 {
+		t.Errorf("Expected 2, got %v", output)
+	}
+	if output := runFilter(CompileAdvanceSearchSpec(
+		&AdvanceSearchSpec{
+			Name: "Name",
+			Tag:  "vacation"})); output != 1 {
+		t.Errorf("Expected 1, got %v", output)
+	}
+	// An invalid tag must not turn the tag filter off.
+	if output := runFilter(CompileAdvanceSearchSpec(
+		&AdvanceSearchSpec{
+			Tag: "summer vacation"})); output != 0 {
+		t.Errorf("Expected 0, got %v", output)
+	}
+	if output := runFilter(CompileAdvanceSearchSpec(
+		&AdvanceSearchSpec{
+			Tag: "!!"})); output != 0 {
+		t.Errorf("Expected 0, got %v", output)
+	}
 }
 
 func runFilter(f goconsume.FilterFunc) int {
@@ -45,12 +67,16 @@ func runFilter(f goconsume.FilterFunc) int {
 	if f(&fin.Entry{Name: "Name 2", Desc: "Other"}) {
 		result++
 	}
-	if f(&fin.Entry{Name: "Other", Desc: "Other"}) {
+	if f(&fin.Entry{
+		Name: "Other",
+		Desc: "Other",
+		Tags: fin.TagSet{"vacation": true}}) {
 		result++
 	}
 	if f(&fin.Entry{
 		Name:       "Name 3",
 		Desc:       "Desc 3",
+		Tags:       fin.TagSet{"vacation": true, "reimbursable": true},
 		CatPayment: fin.NewCatPayment(fin.NewCat("0:7"), 200, false, 0)}) {
 		result++
 	}
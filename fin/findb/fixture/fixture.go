@@ -80,6 +80,25 @@ type UpdateAccountStore interface {
 	findb.UpdateAccountRunner
 }
 
+type UpdateAccountTypeStore interface {
+	MinimalStore
+	findb.AccountByIdRunner
+	findb.UpdateAccountTypeRunner
+}
+
+type AccountBalancesAsOfStore interface {
+	MinimalStore
+	findb.EntriesByAccountIdRunner
+}
+
+type UpdateAccountCurrencyStore interface {
+	MinimalStore
+	findb.AccountByIdRunner
+	findb.EntryByIdRunner
+	findb.EntriesByAccountIdRunner
+	findb.UpdateAccountCurrencyRunner
+}
+
 type RemoveAccountStore interface {
 	MinimalStore
 	findb.AccountByIdRunner
@@ -100,8 +119,8 @@ func (f EntryAccountFixture) AccountUpdates(t *testing.T, store AccountByIdStore
 	cpb := fin.CatPaymentBuilder{}
 	entry1 := fin.Entry{
 		CatPayment: cpb.AddCatRec(
-			fin.CatRec{fin.NewCat("0:7"), 6000, false}).AddCatRec(
-			fin.CatRec{fin.NewCat("2:2"), 2000, false}).SetPaymentId(
+			fin.CatRec{Cat: fin.NewCat("0:7"), Amount: 6000, Reconciled: false}).AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("2:2"), Amount: 2000, Reconciled: false}).SetPaymentId(
 			1).SetReconciled(true).Build()}
 	entry2 := fin.Entry{
 		CatPayment: fin.NewCatPayment(fin.NewCat("0:7"), 3000, false, 1)}
@@ -212,6 +231,67 @@ func (f EntryAccountFixture) DeleteEntries(t *testing.T, store EntriesStore) {
 	}
 }
 
+type SearchEntriesStore interface {
+	MinimalStore
+	findb.SearchEntriesRunner
+}
+
+// SearchEntries tests that full text search stays in sync with entry
+// changes and ranks the best matches first.
+func (f EntryAccountFixture) SearchEntries(
+	t *testing.T, store SearchEntriesStore) {
+	f.createAccounts(t, store)
+	rent := fin.Entry{
+		Date:       date_util.YMD(2015, 1, 1),
+		Name:       "Rent",
+		CatPayment: fin.NewCatPayment(fin.Expense, 150000, false, 1)}
+	lateFee := fin.Entry{
+		Date:       date_util.YMD(2015, 1, 20),
+		Name:       "Landlord",
+		Desc:       "Late fee because the rent for the apartment was late",
+		CatPayment: fin.NewCatPayment(fin.Expense, 5000, false, 1)}
+	groceries := fin.Entry{
+		Date:       date_util.YMD(2015, 1, 10),
+		Name:       "Safeway",
+		Desc:       "Weekly shopping",
+		CheckNo:    "1234",
+		CatPayment: fin.NewCatPayment(fin.Expense, 8000, false, 1)}
+	trashed := fin.Entry{
+		Date:       date_util.YMD(2015, 1, 15),
+		Name:       "Safeway",
+		CatPayment: fin.NewCatPayment(fin.Expense, 2000, false, 1)}
+	changeEntries(t, store, &findb.EntryChanges{
+		Adds: []*fin.Entry{&rent, &lateFee, &groceries, &trashed}})
+	changeEntries(t, store, &findb.EntryChanges{
+		Trash: []int64{trashed.Id}})
+
+	// The name match ranks first even though the late fee is more recent.
+	verifySearch(t, store, "RENT", nil, rent.Id, lateFee.Id)
+	verifySearch(t, store, "saf", nil, groceries.Id)
+	verifySearch(t, store, "week shop", nil, groceries.Id)
+	verifySearch(t, store, "1234", nil, groceries.Id)
+	verifySearch(t, store, "late rent", nil, lateFee.Id)
+	verifySearch(t, store, "rent groceries", nil)
+	verifySearch(t, store, "  ", nil)
+	verifySearch(
+		t,
+		store,
+		"rent",
+		&findb.EntryListOptions{Start: ymdPtr(2015, 1, 5)},
+		lateFee.Id)
+
+	changeEntries(t, store, &findb.EntryChanges{
+		Updates: map[int64]fin.EntryUpdater{
+			groceries.Id: func(p *fin.Entry) bool {
+				p.Name = "Trader Joe's"
+				return true
+			}},
+		Deletes: []int64{rent.Id}})
+	verifySearch(t, store, "safeway", nil)
+	verifySearch(t, store, "joe", nil, groceries.Id)
+	verifySearch(t, store, "rent", nil, lateFee.Id)
+}
+
 func (f EntryAccountFixture) ListEntriesEmptyOptions(
 	t *testing.T, store EntriesStore) {
 	f.createAccounts(t, store)
@@ -252,6 +332,34 @@ func (f EntryAccountFixture) ListEntriesDateRangeAndUnreviewed(
 	}
 }
 
+func (f EntryAccountFixture) ListEntriesCats(
+	t *testing.T, store EntriesStore) {
+	f.createAccounts(t, store)
+	createListEntries(t, store)
+	elo := findb.EntryListOptions{
+		Cats: fin.CatSet{fin.NewCat("0:7"): true}}
+	fetched_entries := fetchEntries(t, store, &elo)
+	if output := len(fetched_entries); output != 1 {
+		t.Errorf("Expected to fetch 1 entries, but fetched %v", output)
+	}
+	elo = findb.EntryListOptions{
+		Start: ymdPtr(2012, 10, 16),
+		Cats: fin.CatSet{
+			fin.NewCat("0:7"): true, fin.NewCat("2:1"): true}}
+	fetched_entries = fetchEntries(t, store, &elo)
+	verifyEntriesSorted(t, fetched_entries)
+	if output := len(fetched_entries); output != 2 {
+		t.Errorf("Expected to fetch 2 entries, but fetched %v", output)
+	}
+	// Payments count too
+	elo = findb.EntryListOptions{
+		Cats: fin.CatSet{fin.NewCat("2:2"): true}}
+	fetched_entries = fetchEntries(t, store, &elo)
+	if output := len(fetched_entries); output != 3 {
+		t.Errorf("Expected to fetch 3 entries, but fetched %v", output)
+	}
+}
+
 func (f EntryAccountFixture) ListEntriesJustStartDate(
 	t *testing.T, store EntriesStore) {
 	f.createAccounts(t, store)
@@ -458,6 +566,59 @@ func (f EntryAccountFixture) UpdateAccountImportSD(
 	}
 }
 
+func (f EntryAccountFixture) UpdateAccountType(
+	t *testing.T, store UpdateAccountTypeStore) {
+	f.createAccounts(t, store)
+	if output := store.UpdateAccountType(nil, 2, fin.LoanAccount); output != nil {
+		t.Errorf("Got error updating database, %v", output)
+	}
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, ImportSD: kCheckingSD},
+		&fin.Account{Id: 2, Name: "savings", Active: true, Type: fin.LoanAccount})
+}
+
+func (f EntryAccountFixture) AccountBalancesAsOf(
+	t *testing.T, store AccountBalancesAsOfStore) {
+	f.createAccounts(t, store)
+	dates := []time.Time{
+		date_util.YMD(2015, 2, 10),
+		date_util.YMD(2015, 1, 1),
+		date_util.YMD(2015, 12, 31),
+		date_util.YMD(2015, 1, 10),
+		date_util.YMD(2015, 2, 9)}
+	balances, err := findb.AccountBalancesAsOf(nil, store, 1, dates)
+	if err != nil {
+		t.Fatalf("Got error reading balances: %v", err)
+	}
+	if expected := []int64{0, 0, 0, 0, 0}; !reflect.DeepEqual(expected, balances) {
+		t.Errorf("Expected %v, got %v", expected, balances)
+	}
+	changes := findb.EntryChanges{
+		Adds: []*fin.Entry{
+			{
+				Date:       date_util.YMD(2015, 1, 10),
+				CatPayment: fin.NewCatPayment(fin.NewCat("0:7"), 1000, false, 1)},
+			{
+				Date:       date_util.YMD(2015, 2, 10),
+				CatPayment: fin.NewCatPayment(fin.NewCat("0:7"), 2000, false, 1)},
+			{
+				Date:       date_util.YMD(2015, 2, 10),
+				CatPayment: fin.NewCatPayment(fin.NewCat("0:8"), 500, false, 1)},
+			{
+				Date:       date_util.YMD(2015, 2, 1),
+				CatPayment: fin.NewCatPayment(fin.NewCat("0:8"), 700, false, 2)}}}
+	changeEntries(t, store, &changes)
+	balances, err = findb.AccountBalancesAsOf(nil, store, 1, dates)
+	if err != nil {
+		t.Fatalf("Got error reading balances: %v", err)
+	}
+	if expected := []int64{-3500, 0, -3500, -1000, -1000}; !reflect.DeepEqual(expected, balances) {
+		t.Errorf("Expected %v, got %v", expected, balances)
+	}
+}
+
 func (f EntryAccountFixture) UpdateAccount(
 	t *testing.T, store UpdateAccountStore) {
 	f.createAccounts(t, store)
@@ -469,7 +630,9 @@ func (f EntryAccountFixture) UpdateAccount(
 		RBalance: 75024,
 		Count:    4,
 		RCount:   3,
-		ImportSD: date_util.YMD(2014, 5, 26)}
+		ImportSD: date_util.YMD(2014, 5, 26),
+		Currency: "EUR",
+		Type:     fin.CreditCardAccount}
 	if output := store.UpdateAccount(nil, &account); output != nil {
 		t.Errorf("Got error updating database, %v", output)
 	}
@@ -480,6 +643,578 @@ func (f EntryAccountFixture) UpdateAccount(
 	}
 }
 
+func (f EntryAccountFixture) CrossCurrencyEntries(
+	t *testing.T, store UpdateAccountCurrencyStore) {
+	f.createAccounts(t, store)
+	if output := store.UpdateAccountCurrency(nil, 2, "EUR"); output != nil {
+		t.Errorf("Got error updating database, %v", output)
+	}
+	cpb := fin.CatPaymentBuilder{}
+	entry := fin.Entry{
+		Date: date_util.YMD(2015, 4, 10),
+		Name: "Transfer to Europe",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:7"), Amount: 300}).AddCatRec(
+			fin.CatRec{
+				Cat:            fin.NewCat("2:2"),
+				Amount:         10000,
+				Reconciled:     true,
+				Currency:       "EUR",
+				CurrencyAmount: 9150}).SetPaymentId(1).Build()}
+	changes := findb.EntryChanges{Adds: []*fin.Entry{&entry}}
+	changeEntries(t, store, &changes)
+	verifyEntries(t, store, &entry)
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, Balance: -10300, Count: 1, ImportSD: kCheckingSD},
+		&fin.Account{Id: 2, Name: "savings", Active: true, Balance: 9150, RBalance: 9150, Count: 1, RCount: 1, Currency: "EUR"})
+
+	// From savings, the checking line keeps its amount in USD.
+	var entries []fin.EntryBalance
+	err := store.EntriesByAccountId(nil, 2, nil, goconsume.AppendTo(&entries))
+	if err != nil {
+		t.Fatalf("Got error reading entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %v", entries)
+	}
+	expectedCatRec := fin.CatRec{
+		Cat:            fin.NewCat("2:1"),
+		Amount:         -9150,
+		Currency:       fin.USD,
+		CurrencyAmount: -10000}
+	if output := entries[0].CatRecByIndex(0); output != expectedCatRec {
+		t.Errorf("Expected %v, got %v", expectedCatRec, output)
+	}
+	if output := entries[0].Balance; output != 9150 {
+		t.Errorf("Expected 9150, got %d", output)
+	}
+	changes = findb.EntryChanges{Deletes: []int64{entry.Id}}
+	changeEntries(t, store, &changes)
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, ImportSD: kCheckingSD},
+		&fin.Account{Id: 2, Name: "savings", Active: true, Currency: "EUR"})
+}
+
+type TagsStore interface {
+	MinimalStore
+	findb.EntryByIdRunner
+	findb.EntriesRunner
+}
+
+func (f EntryAccountFixture) Tags(t *testing.T, store TagsStore) {
+	f.createAccounts(t, store)
+	cpb := fin.CatPaymentBuilder{}
+	trip := fin.Entry{
+		Date: date_util.YMD(2015, 7, 1),
+		Name: "Hotel",
+		Tags: fin.TagSet{"vacation-2015": true, "reimbursable": true},
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:7"), Amount: 20000}).SetPaymentId(
+			1).Build()}
+	untagged := fin.Entry{
+		Date: date_util.YMD(2015, 7, 2),
+		Name: "Groceries",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:8"), Amount: 5000}).SetPaymentId(
+			1).Build()}
+	flight := fin.Entry{
+		Date: date_util.YMD(2015, 7, 3),
+		Name: "Flight",
+		Tags: fin.TagSet{"vacation-2015": true},
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:9"), Amount: 40000}).SetPaymentId(
+			2).Build()}
+	changes := findb.EntryChanges{
+		Adds: []*fin.Entry{&trip, &untagged, &flight}}
+	changeEntries(t, store, &changes)
+	verifyEntries(t, store, &trip, &untagged, &flight)
+	var tagged []fin.Entry
+	consumer := goconsume.Filter(
+		goconsume.AppendTo(&tagged),
+		func(ptr interface{}) bool {
+			return ptr.(*fin.Entry).Tags.Contains("vacation-2015")
+		})
+	if err := store.Entries(nil, nil, consumer); err != nil {
+		t.Fatalf("Got error reading entries: %v", err)
+	}
+	if len(tagged) != 2 || tagged[0].Id != flight.Id || tagged[1].Id != trip.Id {
+		t.Errorf("Expected flight and hotel, got %v", tagged)
+	}
+	var before fin.Entry
+	if err := store.EntryById(nil, trip.Id, &before); err != nil {
+		t.Fatalf("Got error reading entry: %v", err)
+	}
+	changes = findb.EntryChanges{
+		Updates: map[int64]fin.EntryUpdater{
+			trip.Id: func(p *fin.Entry) bool {
+				p.Tags = fin.TagSet{"vacation-2015": true}
+				return true
+			},
+			flight.Id: func(p *fin.Entry) bool {
+				p.Tags = nil
+				return true
+			}}}
+	changeEntries(t, store, &changes)
+	trip.Tags = fin.TagSet{"vacation-2015": true}
+	flight.Tags = nil
+	verifyEntries(t, store, &trip, &flight)
+	var after fin.Entry
+	if err := store.EntryById(nil, trip.Id, &after); err != nil {
+		t.Fatalf("Got error reading entry: %v", err)
+	}
+	if before.Etag == after.Etag {
+		t.Error("Expected etag to change when tags change.")
+	}
+	changes = findb.EntryChanges{Deletes: []int64{trip.Id}}
+	changeEntries(t, store, &changes)
+	verifyNoEntry(t, store, trip.Id)
+}
+
+type AttachmentsStore interface {
+	MinimalStore
+	findb.EntryByIdRunner
+	findb.AddAttachmentRunner
+	findb.AttachmentByIdRunner
+	findb.AttachmentsByEntryIdRunner
+	findb.RemoveAttachmentRunner
+}
+
+func (f EntryAccountFixture) Attachments(
+	t *testing.T, store AttachmentsStore) {
+	f.createAccounts(t, store)
+	cpb := fin.CatPaymentBuilder{}
+	entry := fin.Entry{
+		Date: date_util.YMD(2015, 8, 1),
+		Name: "Hardware store",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:7"), Amount: 4599}).SetPaymentId(
+			1).Build()}
+	changes := findb.EntryChanges{Adds: []*fin.Entry{&entry}}
+	changeEntries(t, store, &changes)
+	receipt := fin.Attachment{
+		EntryId:     entry.Id,
+		Name:        "receipt.jpg",
+		ContentType: "image/jpeg",
+		Data:        []byte{0xff, 0xd8, 0x00, 0x01, 0xff, 0xd9}}
+	invoice := fin.Attachment{
+		EntryId:     entry.Id,
+		Name:        "invoice.pdf",
+		ContentType: "application/pdf",
+		Data:        []byte("%PDF-1.4")}
+	for _, attachment := range []*fin.Attachment{&receipt, &invoice} {
+		if err := store.AddAttachment(nil, attachment); err != nil {
+			t.Fatalf("Got error adding attachment: %v", err)
+		}
+	}
+	if receipt.Size != 6 {
+		t.Errorf("Expected size 6, got %d", receipt.Size)
+	}
+	entry.AttachmentCount = 2
+	verifyEntries(t, store, &entry)
+	var actual fin.Attachment
+	if err := store.AttachmentById(nil, invoice.Id, &actual); err != nil {
+		t.Fatalf("Got error reading attachment: %v", err)
+	}
+	if !reflect.DeepEqual(invoice, actual) {
+		t.Errorf("Expected %v, got %v", &invoice, &actual)
+	}
+	var details []fin.Attachment
+	err := store.AttachmentsByEntryId(
+		nil, entry.Id, goconsume.AppendTo(&details))
+	if err != nil {
+		t.Fatalf("Got error reading attachments: %v", err)
+	}
+	receiptDetails, invoiceDetails := receipt, invoice
+	receiptDetails.Data = nil
+	invoiceDetails.Data = nil
+	expected := []fin.Attachment{receiptDetails, invoiceDetails}
+	if !reflect.DeepEqual(expected, details) {
+		t.Errorf("Expected %v, got %v", expected, details)
+	}
+	if err := store.RemoveAttachment(nil, receipt.Id); err != nil {
+		t.Fatalf("Got error removing attachment: %v", err)
+	}
+	entry.AttachmentCount = 1
+	verifyEntries(t, store, &entry)
+	changes = findb.EntryChanges{Deletes: []int64{entry.Id}}
+	changeEntries(t, store, &changes)
+	if err := store.AttachmentById(nil, invoice.Id, &actual); err != findb.NoSuchId {
+		t.Errorf("Expected NoSuchId, got %v", err)
+	}
+}
+
+type TrashStore interface {
+	MinimalStore
+	findb.AccountByIdRunner
+	findb.EntryByIdRunner
+	findb.EntriesByAccountIdRunner
+	findb.TrashedEntriesRunner
+	findb.PurgeTrashRunner
+}
+
+func (f EntryAccountFixture) Trash(t *testing.T, store TrashStore) {
+	f.createAccounts(t, store)
+	cpb := fin.CatPaymentBuilder{}
+	entry1 := fin.Entry{
+		Date: date_util.YMD(2015, 10, 1),
+		Name: "Bookstore",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:7"), Amount: 2500}).SetPaymentId(
+			1).Build()}
+	entry2 := fin.Entry{
+		Date: date_util.YMD(2015, 10, 2),
+		Name: "Cafe",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:8"), Amount: 700}).SetPaymentId(
+			1).Build()}
+	changes := findb.EntryChanges{Adds: []*fin.Entry{&entry1, &entry2}}
+	changeEntries(t, store, &changes)
+	changes = findb.EntryChanges{Trash: []int64{entry1.Id, 9999}}
+	changeEntries(t, store, &changes)
+	verifyNoEntry(t, store, entry1.Id)
+	verifyEntriesByAccountId(t, store, 1, 1)
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, Balance: -700, Count: 1, ImportSD: kCheckingSD})
+
+	// Entries in the trash can't be updated
+	changes = findb.EntryChanges{
+		Updates: map[int64]fin.EntryUpdater{
+			entry1.Id: func(p *fin.Entry) bool {
+				p.Name = "Library"
+				return true
+			}}}
+	if err := store.DoEntryChanges(nil, &changes); err != findb.Trashed {
+		t.Errorf("Expected Trashed, got %v", err)
+	}
+	var trashed []fin.TrashedEntry
+	err := store.TrashedEntries(nil, goconsume.AppendTo(&trashed))
+	if err != nil {
+		t.Fatalf("Got error reading trash: %v", err)
+	}
+	if len(trashed) != 1 {
+		t.Fatalf("Expected 1 trashed entry, got %d", len(trashed))
+	}
+	if trashed[0].Trashed.IsZero() {
+		t.Error("Expected trashed time to be set.")
+	}
+	expected := entry1
+	expected.Etag = trashed[0].Etag
+	if !reflect.DeepEqual(expected, trashed[0].Entry) {
+		t.Errorf("Expected %v, got %v", expected, trashed[0].Entry)
+	}
+
+	changes = findb.EntryChanges{Restore: []int64{entry1.Id, entry2.Id}}
+	changeEntries(t, store, &changes)
+	verifyEntries(t, store, &entry1, &entry2)
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, Balance: -3200, Count: 2, ImportSD: kCheckingSD})
+
+	changes = findb.EntryChanges{Trash: []int64{entry2.Id}}
+	changeEntries(t, store, &changes)
+	now := time.Now()
+	if err := store.PurgeTrash(nil, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Got error purging trash: %v", err)
+	}
+	trashed = nil
+	store.TrashedEntries(nil, goconsume.AppendTo(&trashed))
+	if len(trashed) != 1 {
+		t.Errorf("Expected 1 trashed entry, got %d", len(trashed))
+	}
+	if err := store.PurgeTrash(nil, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Got error purging trash: %v", err)
+	}
+	trashed = nil
+	store.TrashedEntries(nil, goconsume.AppendTo(&trashed))
+	if len(trashed) != 0 {
+		t.Errorf("Expected empty trash, got %v", trashed)
+	}
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, Balance: -2500, Count: 1, ImportSD: kCheckingSD})
+}
+
+type PurgeLockedTrashStore interface {
+	TrashStore
+	findb.UpdateLockDateRunner
+}
+
+// PurgeLockedTrash tests that purging the trash deletes trashed entries
+// even when they are dated on or before the lock date.
+func (f EntryAccountFixture) PurgeLockedTrash(
+	t *testing.T, store PurgeLockedTrashStore) {
+	f.createAccounts(t, store)
+	old := fin.Entry{
+		Date:       date_util.YMD(2015, 3, 10),
+		Name:       "Bookstore",
+		CatPayment: fin.NewCatPayment(fin.Expense, 1000, false, 1)}
+	recent := fin.Entry{
+		Date:       date_util.YMD(2015, 5, 10),
+		Name:       "Cafe",
+		CatPayment: fin.NewCatPayment(fin.Expense, 2000, false, 1)}
+	changeEntries(t, store, &findb.EntryChanges{
+		Adds: []*fin.Entry{&old, &recent}})
+	changeEntries(t, store, &findb.EntryChanges{
+		Trash: []int64{old.Id, recent.Id}})
+	updateLockDate(t, store, 0, date_util.YMD(2015, 3, 31))
+	if err := store.PurgeTrash(nil, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Got error purging trash: %v", err)
+	}
+	var trashed []fin.TrashedEntry
+	err := store.TrashedEntries(nil, goconsume.AppendTo(&trashed))
+	if err != nil {
+		t.Fatalf("Got error reading trash: %v", err)
+	}
+	if len(trashed) != 0 {
+		t.Errorf("Expected empty trash, got %v", trashed)
+	}
+	verifyNoEntry(t, store, old.Id)
+	verifyNoEntry(t, store, recent.Id)
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, ImportSD: kCheckingSD})
+}
+
+type StatementsStore interface {
+	MinimalStore
+	findb.AccountByIdRunner
+	findb.EntryByIdRunner
+	findb.AddStatementRunner
+	findb.UpdateStatementRunner
+	findb.StatementsByAccountIdRunner
+	findb.RemoveStatementRunner
+}
+
+func (f EntryAccountFixture) Statements(
+	t *testing.T, store StatementsStore) {
+	f.createAccounts(t, store)
+	cpb := fin.CatPaymentBuilder{}
+	entry1 := fin.Entry{
+		Date: date_util.YMD(2015, 10, 1),
+		Name: "Bookstore",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:7"), Amount: 2500}).SetPaymentId(
+			1).Build()}
+	entry2 := fin.Entry{
+		Date: date_util.YMD(2015, 10, 2),
+		Name: "Cafe",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:8"), Amount: 700}).SetPaymentId(
+			1).Build()}
+	entry3 := fin.Entry{
+		Date: date_util.YMD(2015, 10, 3),
+		Name: "Paycheck",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("1:2"), Amount: -1000}).SetPaymentId(
+			1).Build()}
+	changes := findb.EntryChanges{
+		Adds: []*fin.Entry{&entry1, &entry2, &entry3}}
+	changeEntries(t, store, &changes)
+	october := fin.Statement{
+		AcctId:  1,
+		Date:    date_util.YMD(2015, 10, 31),
+		Balance: -1500}
+	if err := store.AddStatement(nil, &october); err != nil {
+		t.Fatalf("Got error adding statement: %v", err)
+	}
+	october.EntryIds = []int64{entry1.Id}
+	if err := store.UpdateStatement(nil, &october); err != nil {
+		t.Fatalf("Got error updating statement: %v", err)
+	}
+	verifyStatements(t, store, 1, &october)
+
+	finish := func(entryIds ...int64) error {
+		return f.Doer.Do(func(t db.Transaction) error {
+			return findb.FinishStatement(
+				t, store, &october, entryIds, time.Unix(1500000000, 0).UTC())
+		})
+	}
+	if err := finish(entry1.Id); err != findb.NotBalanced {
+		t.Errorf("Expected NotBalanced, got %v", err)
+	}
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, Balance: -2200, Count: 3, ImportSD: kCheckingSD})
+	if err := finish(entry1.Id, entry3.Id, entry3.Id, 9999); err != nil {
+		t.Fatalf("Got error finishing statement: %v", err)
+	}
+	verifyAccounts(
+		t,
+		store,
+		&fin.Account{Id: 1, Name: "checking", Active: true, Balance: -2200, RBalance: -1500, Count: 3, RCount: 2, ImportSD: kCheckingSD})
+	if !reflect.DeepEqual([]int64{entry1.Id, entry3.Id}, october.EntryIds) {
+		t.Errorf("Expected cleared entries %v, got %v", []int64{entry1.Id, entry3.Id}, october.EntryIds)
+	}
+	if !october.Done() {
+		t.Error("Expected statement to be finished.")
+	}
+	verifyStatements(t, store, 1, &october)
+	if err := finish(entry2.Id); err != findb.AlreadyFinished {
+		t.Errorf("Expected AlreadyFinished, got %v", err)
+	}
+
+	november := fin.Statement{
+		AcctId:  1,
+		Date:    date_util.YMD(2015, 11, 30),
+		Balance: -2200}
+	if err := store.AddStatement(nil, &november); err != nil {
+		t.Fatalf("Got error adding statement: %v", err)
+	}
+	verifyStatements(t, store, 1, &november, &october)
+	verifyStatements(t, store, 2)
+	if err := store.RemoveStatement(nil, november.Id); err != nil {
+		t.Fatalf("Got error removing statement: %v", err)
+	}
+	verifyStatements(t, store, 1, &october)
+}
+
+type EntryTemplatesStore interface {
+	findb.AddEntryTemplateRunner
+	findb.UpdateEntryTemplateRunner
+	findb.EntryTemplateByIdRunner
+	findb.EntryTemplatesRunner
+	findb.RemoveEntryTemplateRunner
+}
+
+func EntryTemplates(t *testing.T, store EntryTemplatesStore) {
+	cpb := fin.CatPaymentBuilder{}
+	paycheck := fin.EntryTemplate{
+		Entry: fin.Entry{
+			Name: "Acme",
+			Desc: "Paycheck",
+			Tags: fin.ParseTags("work"),
+			CatPayment: cpb.AddCatRec(
+				fin.CatRec{Cat: fin.NewCat("1:2")}).AddCatRec(
+				fin.CatRec{Cat: fin.NewCat("0:7")}).AddCatRec(
+				fin.CatRec{Cat: fin.NewCat("0:8"), Amount: 1500}).SetPaymentId(
+				1).Build()},
+		Title: "Paycheck",
+		Percents: map[fin.Cat]int64{
+			fin.NewCat("1:2"): -10000,
+			fin.NewCat("0:7"): 2250}}
+	rent := fin.EntryTemplate{
+		Entry: fin.Entry{
+			Name:    "Landlord",
+			CheckNo: "1001",
+			CatPayment: cpb.AddCatRec(
+				fin.CatRec{Cat: fin.NewCat("0:9"), Amount: 150000}).SetPaymentId(
+				2).Build()},
+		Title: "Rent"}
+	if err := store.AddEntryTemplate(nil, &rent); err != nil {
+		t.Fatalf("Got error adding template: %v", err)
+	}
+	if err := store.AddEntryTemplate(nil, &paycheck); err != nil {
+		t.Fatalf("Got error adding template: %v", err)
+	}
+	verifyEntryTemplates(t, store, &paycheck, &rent)
+	paycheck.Percents[fin.NewCat("0:7")] = 2400
+	paycheck.Desc = "Semi-monthly paycheck"
+	if err := store.UpdateEntryTemplate(nil, &paycheck); err != nil {
+		t.Fatalf("Got error updating template: %v", err)
+	}
+	var actual fin.EntryTemplate
+	if err := store.EntryTemplateById(nil, paycheck.Id, &actual); err != nil {
+		t.Fatalf("Got error reading template: %v", err)
+	}
+	if !reflect.DeepEqual(paycheck, actual) {
+		t.Errorf("Expected %v, got %v", paycheck, actual)
+	}
+	if err := store.RemoveEntryTemplate(nil, paycheck.Id); err != nil {
+		t.Fatalf("Got error removing template: %v", err)
+	}
+	if err := store.EntryTemplateById(nil, paycheck.Id, &actual); err != findb.NoSuchId {
+		t.Errorf("Expected NoSuchId, got %v", err)
+	}
+	verifyEntryTemplates(t, store, &rent)
+}
+
+type EntryRevisionsStore interface {
+	MinimalStore
+	findb.EntryRevisionByIdRunner
+	findb.EntryRevisionsByEntryIdRunner
+}
+
+// EntryRevisions tests that changing entries records revisions.
+// userId is the id of the user store attributes changes to.
+func (f EntryAccountFixture) EntryRevisions(
+	t *testing.T, store EntryRevisionsStore, userId int64) {
+	f.createAccounts(t, store)
+	cpb := fin.CatPaymentBuilder{}
+	original := fin.Entry{
+		Date: date_util.YMD(2015, 9, 1),
+		Name: "Dentist",
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{Cat: fin.NewCat("0:7"), Amount: 12000}).SetPaymentId(
+			1).Build()}
+	changes := findb.EntryChanges{Adds: []*fin.Entry{&original}}
+	changeEntries(t, store, &changes)
+	updated := original
+	updated.Name = "Orthodontist"
+	updated.Tags = fin.TagSet{"medical": true}
+	changes = findb.EntryChanges{
+		Updates: map[int64]fin.EntryUpdater{
+			original.Id: func(p *fin.Entry) bool {
+				p.Name = "Orthodontist"
+				p.Tags = fin.TagSet{"medical": true}
+				return true
+			}}}
+	changeEntries(t, store, &changes)
+	changes = findb.EntryChanges{Deletes: []int64{original.Id}}
+	changeEntries(t, store, &changes)
+	var revisions []*fin.EntryRevision
+	err := store.EntryRevisionsByEntryId(
+		nil, original.Id, goconsume.AppendPtrsTo(&revisions))
+	if err != nil {
+		t.Fatalf("Got error reading revisions: %v", err)
+	}
+	expected := []struct {
+		before *fin.Entry
+		after  *fin.Entry
+	}{
+		{before: &updated},
+		{before: &original, after: &updated},
+		{after: &original},
+	}
+	if len(revisions) != len(expected) {
+		t.Fatalf("Expected %d revisions, got %d", len(expected), len(revisions))
+	}
+	for i := range expected {
+		revision := revisions[i]
+		if revision.EntryId != original.Id || revision.UserId != userId {
+			t.Errorf("Wrong entry or user in %v", revision)
+		}
+		if revision.Time.IsZero() {
+			t.Error("Expected revision time to be set.")
+		}
+		if !reflect.DeepEqual(expected[i].before, revision.Before) {
+			t.Errorf("Expected %v, got %v", expected[i].before, revision.Before)
+		}
+		if !reflect.DeepEqual(expected[i].after, revision.After) {
+			t.Errorf("Expected %v, got %v", expected[i].after, revision.After)
+		}
+	}
+	if !revisions[0].Deleted() || !revisions[2].Added() {
+		t.Error("Expected first revision to delete and last to add.")
+	}
+	var revision fin.EntryRevision
+	if err := store.EntryRevisionById(nil, revisions[1].Id, &revision); err != nil {
+		t.Fatalf("Got error reading revision: %v", err)
+	}
+	if !reflect.DeepEqual(revisions[1], &revision) {
+		t.Errorf("Expected %v, got %v", revisions[1], &revision)
+	}
+}
+
 func (f EntryAccountFixture) RemoveAccount(
 	t *testing.T, store RemoveAccountStore) {
 	f.createAccounts(t, store)
@@ -493,6 +1228,309 @@ func (f EntryAccountFixture) RemoveAccount(
 	}
 }
 
+type RecurringPeriodsStore interface {
+	findb.AddAccountRunner
+	findb.AddRecurringEntryRunner
+	findb.RecurringEntryApplier
+}
+
+// RecurringPeriods tests that the newer recurrence rules persist and
+// advance.
+func (f EntryAccountFixture) RecurringPeriods(
+	t *testing.T, store RecurringPeriodsStore) {
+	f.createAccounts(t, store)
+	periods := []fin.RecurringPeriod{
+		{Count: 1, Unit: fin.MonthEnds},
+		{Count: 1, Unit: fin.MonthWeekdays, Weekday: time.Tuesday, WeekOfMonth: 2},
+		{Count: 2, Unit: fin.HalfMonths, DayOfMonth: 5, SecondDayOfMonth: 20},
+		{Count: 1, Unit: fin.MonthBusinessEnds}}
+	nextDates := []time.Time{
+		date_util.YMD(2016, 2, 29),
+		date_util.YMD(2016, 2, 9),
+		date_util.YMD(2016, 2, 5),
+		date_util.YMD(2016, 2, 29)}
+	cp := fin.NewCatPayment(fin.Expense, 1200, false, 1)
+	for i := range periods {
+		entry := fin.RecurringEntry{
+			Entry:   fin.Entry{Date: date_util.YMD(2016, 1, 12), CatPayment: cp},
+			Period:  periods[i],
+			NumLeft: -1}
+		if err := store.AddRecurringEntry(nil, &entry); err != nil {
+			t.Fatalf("Error creating recurring entry: %v", err)
+		}
+		err := f.Doer.Do(func(t db.Transaction) error {
+			_, err := findb.ApplyRecurringEntry(t, store, entry.Id, nil)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Error applying recurring entry: %v", err)
+		}
+		var actual fin.RecurringEntry
+		if err := store.RecurringEntryById(nil, entry.Id, &actual); err != nil {
+			t.Fatalf("Error retrieving recurring entry: %v", err)
+		}
+		if actual.Period != periods[i] {
+			t.Errorf("Expected period %v, got %v", periods[i], actual.Period)
+		}
+		if actual.Date != nextDates[i] {
+			t.Errorf("Expected date %v, got %v", nextDates[i], actual.Date)
+		}
+	}
+}
+
+type RecurringOptionsStore interface {
+	findb.AddAccountRunner
+	findb.AddRecurringEntryRunner
+	findb.EntriesRunner
+	findb.RecurringEntriesApplier
+	findb.RecurringEntryByIdRunner
+}
+
+// RecurringEndDates tests that end dates and business day adjustments
+// persist and that applying recurring entries respects them.
+func (f EntryAccountFixture) RecurringEndDates(
+	t *testing.T, store RecurringOptionsStore) {
+	f.createAccounts(t, store)
+	holidays := fin.NewHolidays(date_util.YMD(2015, 7, 3))
+	cp := fin.NewCatPayment(fin.Expense, 1200, false, 1)
+	// July 4 2015 is a Saturday and July 3 is a holiday.
+	entry := fin.RecurringEntry{
+		Entry:      fin.Entry{Date: date_util.YMD(2015, 7, 4), CatPayment: cp},
+		Period:     fin.RecurringPeriod{Count: 1, DayOfMonth: 4},
+		NumLeft:    -1,
+		EndDate:    date_util.YMD(2015, 9, 30),
+		Adjustment: fin.PreviousBusinessDay}
+	if err := store.AddRecurringEntry(nil, &entry); err != nil {
+		t.Fatalf("Error creating recurring entry: %v", err)
+	}
+	var actual fin.RecurringEntry
+	if err := store.RecurringEntryById(nil, entry.Id, &actual); err != nil {
+		t.Fatalf("Error retrieving recurring entry: %v", err)
+	}
+	if actual.EndDate != entry.EndDate {
+		t.Errorf("Expected end date %v, got %v", entry.EndDate, actual.EndDate)
+	}
+	if actual.Adjustment != entry.Adjustment {
+		t.Errorf("Expected adjustment %v, got %v", entry.Adjustment, actual.Adjustment)
+	}
+
+	count, err := findb.ApplyRecurringEntriesDryRun(
+		nil, store, 0, date_util.YMD(2015, 7, 2), holidays)
+	if err != nil {
+		t.Fatalf("Got database error doing dry run: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected that 1 entry will be added, got %d", count)
+	}
+	err = f.Doer.Do(func(t db.Transaction) error {
+		var err error
+		count, err = findb.ApplyRecurringEntries(
+			t, store, 0, date_util.YMD(2015, 12, 31), holidays)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Error applying recurring entries: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 entries to be added, got %d", count)
+	}
+	var entries []fin.Entry
+	if err := store.Entries(nil, nil, goconsume.AppendTo(&entries)); err != nil {
+		t.Fatalf("Error reading entries: %v", err)
+	}
+	// October 4 would be past the end date
+	expectedDates := []time.Time{
+		date_util.YMD(2015, 9, 4),
+		date_util.YMD(2015, 8, 4),
+		date_util.YMD(2015, 7, 2)}
+	if len(entries) != len(expectedDates) {
+		t.Fatalf("Expected %d entries, got %d", len(expectedDates), len(entries))
+	}
+	for i := range entries {
+		if entries[i].Date != expectedDates[i] {
+			t.Errorf("Expected date %v, got %v", expectedDates[i], entries[i].Date)
+		}
+	}
+	verifyRecurringEntry(t, store, entry.Id, date_util.YMD(2015, 10, 4), -1)
+}
+
+// RecurringEscalations tests that estimated amounts and escalations
+// persist and that applying recurring entries respects them.
+func (f EntryAccountFixture) RecurringEscalations(
+	t *testing.T, store RecurringOptionsStore) {
+	f.createAccounts(t, store)
+	entry := fin.RecurringEntry{
+		Entry: fin.Entry{
+			Date:       date_util.YMD(2015, 1, 10),
+			CatPayment: fin.NewCatPayment(fin.Expense, 10000, false, 1),
+			Status:     fin.Reviewed},
+		Period:     fin.RecurringPeriod{Count: 1, DayOfMonth: 10},
+		NumLeft:    -1,
+		Estimated:  true,
+		Escalation: fin.Escalation{Amount: 500, Percent: 100, Every: 2}}
+	if err := store.AddRecurringEntry(nil, &entry); err != nil {
+		t.Fatalf("Error creating recurring entry: %v", err)
+	}
+	var actual fin.RecurringEntry
+	if err := store.RecurringEntryById(nil, entry.Id, &actual); err != nil {
+		t.Fatalf("Error retrieving recurring entry: %v", err)
+	}
+	if !actual.Estimated {
+		t.Error("Expected recurring entry to be estimated")
+	}
+	if actual.Escalation != entry.Escalation {
+		t.Errorf("Expected escalation %v, got %v", entry.Escalation, actual.Escalation)
+	}
+	err := f.Doer.Do(func(t db.Transaction) error {
+		_, err := findb.ApplyRecurringEntries(
+			t, store, 0, date_util.YMD(2015, 3, 10), nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Error applying recurring entries: %v", err)
+	}
+	var entries []fin.Entry
+	if err := store.Entries(nil, nil, goconsume.AppendTo(&entries)); err != nil {
+		t.Fatalf("Error reading entries: %v", err)
+	}
+	// Most recent first
+	expectedTotals := []int64{-10600, -10000, -10000}
+	if len(entries) != len(expectedTotals) {
+		t.Fatalf("Expected %d entries, got %d", len(expectedTotals), len(entries))
+	}
+	for i := range entries {
+		if total := entries[i].Total(); total != expectedTotals[i] {
+			t.Errorf("Expected total %d, got %d", expectedTotals[i], total)
+		}
+		if entries[i].Status != fin.NotReviewed {
+			t.Errorf("Expected entry not reviewed, got %v", entries[i].Status)
+		}
+	}
+	if err := store.RecurringEntryById(nil, entry.Id, &actual); err != nil {
+		t.Fatalf("Error retrieving recurring entry: %v", err)
+	}
+	if actual.Escalation.Count != 1 {
+		t.Errorf("Expected escalation count 1, got %d", actual.Escalation.Count)
+	}
+	if total := actual.Total(); total != -10600 {
+		t.Errorf("Expected total -10600, got %d", total)
+	}
+	if actual.Status != fin.Reviewed {
+		t.Errorf("Expected recurring entry to stay reviewed, got %v", actual.Status)
+	}
+}
+
+type LockDatesStore interface {
+	RecurringOptionsStore
+	findb.DoEntryChangesRunner
+	findb.LockDatesRunner
+	findb.UpdateLockDateRunner
+}
+
+// LockDates tests that entries dated on or before the lock date cannot
+// be added, changed, or deleted.
+func (f EntryAccountFixture) LockDates(t *testing.T, store LockDatesStore) {
+	f.createAccounts(t, store)
+	old := fin.Entry{
+		Date:       date_util.YMD(2015, 3, 10),
+		CatPayment: fin.NewCatPayment(fin.Expense, 1000, false, 1)}
+	recent := fin.Entry{
+		Date:       date_util.YMD(2015, 5, 10),
+		CatPayment: fin.NewCatPayment(fin.Expense, 2000, false, 1)}
+	changeEntries(t, store, &findb.EntryChanges{
+		Adds: []*fin.Entry{&old, &recent}})
+	updateLockDate(t, store, 0, date_util.YMD(2015, 3, 31))
+	updateLockDate(t, store, 2, date_util.YMD(2015, 4, 30))
+	locks, err := store.LockDates(nil)
+	if err != nil {
+		t.Fatalf("Error reading lock dates: %v", err)
+	}
+	expectedLocks := fin.LockDates{
+		0: date_util.YMD(2015, 3, 31), 2: date_util.YMD(2015, 4, 30)}
+	if !reflect.DeepEqual(expectedLocks, locks) {
+		t.Errorf("Expected %v, got %v", expectedLocks, locks)
+	}
+
+	tooOld := fin.Entry{
+		Date:       date_util.YMD(2015, 3, 31),
+		CatPayment: fin.NewCatPayment(fin.Expense, 500, false, 1)}
+	verifyLocked(t, store, &findb.EntryChanges{
+		Adds: []*fin.Entry{&tooOld}})
+	verifyLocked(t, store, &findb.EntryChanges{
+		Deletes: []int64{old.Id}})
+	verifyLocked(t, store, &findb.EntryChanges{
+		Updates: map[int64]fin.EntryUpdater{
+			old.Id: changeDate(date_util.YMD(2015, 6, 1))}})
+	// Moving a recent entry into the closed period is also locked.
+	verifyLocked(t, store, &findb.EntryChanges{
+		Updates: map[int64]fin.EntryUpdater{
+			recent.Id: changeDate(date_util.YMD(2015, 3, 1))}})
+	// Savings is locked through April.
+	toSavings := fin.Entry{
+		Date:       date_util.YMD(2015, 4, 15),
+		CatPayment: fin.NewCatPayment(fin.Expense, 500, false, 2)}
+	verifyLocked(t, store, &findb.EntryChanges{
+		Adds: []*fin.Entry{&toSavings}})
+	// A transfer from checking to savings touches savings too.
+	transfer := fin.Entry{
+		Date:       date_util.YMD(2015, 4, 15),
+		CatPayment: fin.NewCatPayment(fin.NewCat("2:2"), 500, false, 1)}
+	verifyLocked(t, store, &findb.EntryChanges{
+		Adds: []*fin.Entry{&transfer}})
+
+	// Checking is open in April.
+	fine := fin.Entry{
+		Date:       date_util.YMD(2015, 4, 15),
+		CatPayment: fin.NewCatPayment(fin.Expense, 500, false, 1)}
+	changeEntries(t, store, &findb.EntryChanges{
+		Adds: []*fin.Entry{&fine}})
+
+	// Applying a past due recurring entry would add an entry in the
+	// closed period.
+	addRecurringEntry(t, store, date_util.YMD(2015, 3, 20), 700, -1)
+	err = f.Doer.Do(func(t db.Transaction) error {
+		_, err := findb.ApplyRecurringEntries(
+			t, store, 0, date_util.YMD(2015, 5, 31), nil)
+		return err
+	})
+	if err != findb.Locked {
+		t.Errorf("Expected findb.Locked applying recurring entries, got %v", err)
+	}
+
+	updateLockDate(t, store, 0, time.Time{})
+	changeEntries(t, store, &findb.EntryChanges{
+		Deletes: []int64{old.Id}})
+	locks, err = store.LockDates(nil)
+	if err != nil {
+		t.Fatalf("Error reading lock dates: %v", err)
+	}
+	expectedLocks = fin.LockDates{2: date_util.YMD(2015, 4, 30)}
+	if !reflect.DeepEqual(expectedLocks, locks) {
+		t.Errorf("Expected %v, got %v", expectedLocks, locks)
+	}
+}
+
+type BankAccountsStore interface {
+	findb.BankAccountsRunner
+	findb.UpdateBankAccountRunner
+}
+
+// BankAccounts tests mapping bank accounts to accounts.
+func BankAccounts(t *testing.T, store BankAccountsStore) {
+	checking := fin.BankAccount{Institution: "Bank", BankAccountId: "1111"}
+	savings := fin.BankAccount{Institution: "Bank", BankAccountId: "2222"}
+	card := fin.BankAccount{Institution: "Card", BankAccountId: "1111"}
+	updateBankAccount(t, store, checking, 1)
+	updateBankAccount(t, store, savings, 2)
+	updateBankAccount(t, store, card, 3)
+	// Mapping a bank account again replaces its old mapping
+	updateBankAccount(t, store, savings, 4)
+	verifyBankAccounts(
+		t, store, fin.BankAccounts{checking: 1, savings: 4, card: 3})
+	updateBankAccount(t, store, checking, 0)
+	verifyBankAccounts(t, store, fin.BankAccounts{savings: 4, card: 3})
+}
+
 func (f EntryAccountFixture) ApplyRecurringEntries(
 	t *testing.T,
 	store RecurringEntriesApplier) {
@@ -522,7 +1560,7 @@ func (f EntryAccountFixture) ApplyRecurringEntries(
 	var applied bool
 	err := f.Doer.Do(func(t db.Transaction) error {
 		var err error
-		applied, err = findb.ApplyRecurringEntry(t, store, finiteId)
+		applied, err = findb.ApplyRecurringEntry(t, store, finiteId, nil)
 		return err
 	})
 	if err != nil {
@@ -549,7 +1587,7 @@ func (f EntryAccountFixture) ApplyRecurringEntries(
 	// Applying newYearId should return false
 	err = f.Doer.Do(func(t db.Transaction) error {
 		var err error
-		applied, err = findb.ApplyRecurringEntry(t, store, newYearId)
+		applied, err = findb.ApplyRecurringEntry(t, store, newYearId, nil)
 		return err
 	})
 	if err != nil {
@@ -581,7 +1619,7 @@ func (f EntryAccountFixture) ApplyRecurringEntries(
 	verifyRecurringEntriesSortedByDate(t, addedEntries)
 
 	// Do dry run
-	count, err := findb.ApplyRecurringEntriesDryRun(nil, store, 0, date_util.YMD(2015, 11, 10))
+	count, err := findb.ApplyRecurringEntriesDryRun(nil, store, 0, date_util.YMD(2015, 11, 10), nil)
 	if err != nil {
 		t.Errorf("Got database error doing dry run: %v", err)
 	}
@@ -590,7 +1628,7 @@ func (f EntryAccountFixture) ApplyRecurringEntries(
 	}
 
 	// Do dry run with only account 2
-	count, err = findb.ApplyRecurringEntriesDryRun(nil, store, 2, date_util.YMD(2015, 11, 10))
+	count, err = findb.ApplyRecurringEntriesDryRun(nil, store, 2, date_util.YMD(2015, 11, 10), nil)
 	if err != nil {
 		t.Errorf("Got database error doing dry run: %v", err)
 	}
@@ -602,7 +1640,7 @@ func (f EntryAccountFixture) ApplyRecurringEntries(
 	count = 0
 	err = f.Doer.Do(func(t db.Transaction) error {
 		var err error
-		count, err = findb.ApplyRecurringEntries(t, store, 2, date_util.YMD(2015, 11, 10))
+		count, err = findb.ApplyRecurringEntries(t, store, 2, date_util.YMD(2015, 11, 10), nil)
 		return err
 	})
 	if err != nil {
@@ -616,7 +1654,7 @@ func (f EntryAccountFixture) ApplyRecurringEntries(
 	count = 0
 	err = f.Doer.Do(func(t db.Transaction) error {
 		var err error
-		count, err = findb.ApplyRecurringEntries(t, store, 0, date_util.YMD(2015, 11, 10))
+		count, err = findb.ApplyRecurringEntries(t, store, 0, date_util.YMD(2015, 11, 10), nil)
 		return err
 	})
 	if err != nil {
@@ -629,7 +1667,7 @@ func (f EntryAccountFixture) ApplyRecurringEntries(
 	// Test idempotency
 
 	// Do dry run
-	count, err = findb.ApplyRecurringEntriesDryRun(nil, store, 0, date_util.YMD(2015, 11, 10))
+	count, err = findb.ApplyRecurringEntriesDryRun(nil, store, 0, date_util.YMD(2015, 11, 10), nil)
 	if err != nil {
 		t.Errorf("Got database error doing dry run: %v", err)
 	}
@@ -641,7 +1679,7 @@ func (f EntryAccountFixture) ApplyRecurringEntries(
 	count = 657
 	err = f.Doer.Do(func(t db.Transaction) error {
 		var err error
-		count, err = findb.ApplyRecurringEntries(t, store, 0, date_util.YMD(2015, 11, 10))
+		count, err = findb.ApplyRecurringEntries(t, store, 0, date_util.YMD(2015, 11, 10), nil)
 		return err
 	})
 	if err != nil {
@@ -735,6 +1773,27 @@ func createListEntries(t *testing.T, store findb.DoEntryChangesRunner) {
 	}
 }
 
+func verifySearch(
+	t *testing.T,
+	store findb.SearchEntriesRunner,
+	query string,
+	options *findb.EntryListOptions,
+	expectedIds ...int64) {
+	var entries []fin.Entry
+	err := store.SearchEntries(
+		nil, query, options, goconsume.AppendTo(&entries))
+	if err != nil {
+		t.Fatalf("Got error searching entries: %v", err)
+	}
+	var actualIds []int64
+	for _, entry := range entries {
+		actualIds = append(actualIds, entry.Id)
+	}
+	if !reflect.DeepEqual(expectedIds, actualIds) {
+		t.Errorf("Searching %q: expected %v, got %v", query, expectedIds, actualIds)
+	}
+}
+
 func fetchEntries(
 	t *testing.T,
 	store findb.EntriesRunner,
@@ -757,6 +1816,55 @@ func changeEntries(
 	}
 }
 
+func updateLockDate(
+	t *testing.T,
+	store findb.UpdateLockDateRunner,
+	acctId int64,
+	date time.Time) {
+	if err := store.UpdateLockDate(nil, acctId, date); err != nil {
+		t.Fatalf("Error updating lock date: %v", err)
+	}
+}
+
+func updateBankAccount(
+	t *testing.T,
+	store findb.UpdateBankAccountRunner,
+	bankAccount fin.BankAccount,
+	acctId int64) {
+	if err := store.UpdateBankAccount(nil, bankAccount, acctId); err != nil {
+		t.Fatalf("Error updating bank account: %v", err)
+	}
+}
+
+func verifyBankAccounts(
+	t *testing.T,
+	store findb.BankAccountsRunner,
+	expected fin.BankAccounts) {
+	bankAccounts, err := store.BankAccounts(nil)
+	if err != nil {
+		t.Fatalf("Error reading bank accounts: %v", err)
+	}
+	if !reflect.DeepEqual(expected, bankAccounts) {
+		t.Errorf("Expected %v, got %v", expected, bankAccounts)
+	}
+}
+
+func verifyLocked(
+	t *testing.T,
+	store findb.DoEntryChangesRunner,
+	ec *findb.EntryChanges) {
+	if err := store.DoEntryChanges(nil, ec); err != findb.Locked {
+		t.Errorf("Expected findb.Locked, got %v", err)
+	}
+}
+
+func changeDate(date time.Time) fin.EntryUpdater {
+	return func(p *fin.Entry) bool {
+		p.Date = date
+		return true
+	}
+}
+
 func initRecurringEntry(
 	date time.Time, count int, unit fin.RecurringUnit,
 	cp *fin.CatPayment, numLeft int, entry *fin.RecurringEntry) {
@@ -819,6 +1927,46 @@ func deleteRecurringEntry(
 	}
 }
 
+func verifyStatements(
+	t *testing.T,
+	store findb.StatementsByAccountIdRunner,
+	acctId int64,
+	expected ...*fin.Statement) {
+	var actual []*fin.Statement
+	err := store.StatementsByAccountId(
+		nil, acctId, goconsume.AppendPtrsTo(&actual))
+	if err != nil {
+		t.Fatalf("Got error reading statements: %v", err)
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d statements, got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(expected[i], actual[i]) {
+			t.Errorf("Expected %v, got %v", expected[i], actual[i])
+		}
+	}
+}
+
+func verifyEntryTemplates(
+	t *testing.T,
+	store findb.EntryTemplatesRunner,
+	expected ...*fin.EntryTemplate) {
+	var actual []*fin.EntryTemplate
+	err := store.EntryTemplates(nil, goconsume.AppendPtrsTo(&actual))
+	if err != nil {
+		t.Fatalf("Got error reading templates: %v", err)
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d templates, got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(expected[i], actual[i]) {
+			t.Errorf("Expected %v, got %v", expected[i], actual[i])
+		}
+	}
+}
+
 func verifyAccounts(
 	t *testing.T,
 	store findb.AccountByIdRunner,
@@ -1245,6 +2393,94 @@ func UpdateUser(t *testing.T, store UpdateUserStore) {
 	verifyUser(t, store, &user)
 }
 
+type ExchangeRatesStore interface {
+	findb.AddExchangeRateRunner
+	findb.ExchangeRatesRunner
+	findb.RemoveExchangeRateRunner
+}
+
+func ExchangeRates(t *testing.T, store ExchangeRatesStore) {
+	rates := []*fin.ExchangeRate{
+		{Date: date_util.YMD(2015, 1, 1), From: "EUR", To: "USD", Rate: 1.125},
+		{Date: date_util.YMD(2015, 2, 1), From: "USD", To: "CAD", Rate: 1.25},
+		{Date: date_util.YMD(2015, 1, 15), From: "EUR", To: "USD", Rate: 1.0625},
+	}
+	for _, rate := range rates {
+		if err := store.AddExchangeRate(nil, rate); err != nil {
+			t.Fatalf("Got error adding exchange rate: %v", err)
+		}
+	}
+	var actual []*fin.ExchangeRate
+	if err := store.ExchangeRates(nil, goconsume.AppendPtrsTo(&actual)); err != nil {
+		t.Fatalf("Got error reading exchange rates: %v", err)
+	}
+	expected := []*fin.ExchangeRate{rates[1], rates[2], rates[0]}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if err := store.RemoveExchangeRate(nil, rates[2].Id); err != nil {
+		t.Fatalf("Got error removing exchange rate: %v", err)
+	}
+	actual = nil
+	if err := store.ExchangeRates(nil, goconsume.AppendPtrsTo(&actual)); err != nil {
+		t.Fatalf("Got error reading exchange rates: %v", err)
+	}
+	expected = []*fin.ExchangeRate{rates[1], rates[0]}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+type BudgetItemsStore interface {
+	findb.AddBudgetItemRunner
+	findb.UpdateBudgetItemRunner
+	findb.BudgetItemsRunner
+	findb.RemoveBudgetItemRunner
+}
+
+func BudgetItems(t *testing.T, store BudgetItemsStore) {
+	items := []*fin.BudgetItem{
+		{
+			Cat:    fin.Cat{Type: fin.ExpenseCat, Id: 3},
+			Amount: 25000,
+			Start:  date_util.YMD(2015, 1, 1)},
+		{
+			Cat:      fin.Cat{Type: fin.IncomeCat, Id: 1},
+			Amount:   6000000,
+			Period:   fin.YearlyBudget,
+			Rollover: true,
+			Start:    date_util.YMD(2015, 3, 1)},
+	}
+	for _, item := range items {
+		if err := store.AddBudgetItem(nil, item); err != nil {
+			t.Fatalf("Got error adding budget item: %v", err)
+		}
+	}
+	var actual []*fin.BudgetItem
+	if err := store.BudgetItems(nil, goconsume.AppendPtrsTo(&actual)); err != nil {
+		t.Fatalf("Got error reading budget items: %v", err)
+	}
+	if !reflect.DeepEqual(items, actual) {
+		t.Errorf("Expected %v, got %v", items, actual)
+	}
+	items[0].Amount = 30000
+	items[0].Rollover = true
+	if err := store.UpdateBudgetItem(nil, items[0]); err != nil {
+		t.Fatalf("Got error updating budget item: %v", err)
+	}
+	if err := store.RemoveBudgetItem(nil, items[1].Id); err != nil {
+		t.Fatalf("Got error removing budget item: %v", err)
+	}
+	actual = nil
+	if err := store.BudgetItems(nil, goconsume.AppendPtrsTo(&actual)); err != nil {
+		t.Fatalf("Got error reading budget items: %v", err)
+	}
+	expected := []*fin.BudgetItem{items[0]}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
 func createUsersWithFunc(
 	t *testing.T,
 	store findb.AddUserRunner,
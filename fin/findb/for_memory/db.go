@@ -0,0 +1,1411 @@
+// Package for_memory stores types in fin package in an in-memory database.
+package for_memory
+
+import (
+	"fmt"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/consumers"
+	"github.com/keep94/finance/fin/findb"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"github.com/keep94/goconsume"
+	"github.com/keep94/toolbox/db"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+func New(db *memory_db.Db) Store {
+	return Store{db: db}
+}
+
+// DataNew returns a Store that works directly against data within the
+// current transaction.
+func DataNew(data *memory_db.Data) Store {
+	return Store{db: memory_db.NewDataDoer(data)}
+}
+
+func ReadOnlyWrapper(store Store) ReadOnlyStore {
+	return ReadOnlyStore{store: store}
+}
+
+func entries(
+	data *memory_db.Data,
+	options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	rows := entryRows(data, options)
+	sortEntries(rows)
+	return readEntries(data, rows, consumer)
+}
+
+// searchEntries works like the full text search of the sqlite store.
+// Entries having more of their words match the query come first.
+func searchEntries(
+	data *memory_db.Data,
+	query string,
+	options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	words := searchWords(query)
+	if len(words) == 0 {
+		return nil
+	}
+	scores := make(map[int64]float64)
+	var rows []fin.Entry
+	for _, row := range entryRows(data, options) {
+		if score := searchScore(&row, words); score > 0 {
+			scores[row.Id] = score
+			rows = append(rows, row)
+		}
+	}
+	sortEntries(rows)
+	sort.SliceStable(rows, func(i, j int) bool {
+		return scores[rows[i].Id] > scores[rows[j].Id]
+	})
+	return readEntries(data, rows, consumer)
+}
+
+// searchWords returns the lower case words in s.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchScore returns 0 if some word in words is not a whole word or the
+// beginning of a word in entry. Otherwise, searchScore returns the
+// number of words in entry that match divided by the number of words in
+// entry.
+func searchScore(entry *fin.Entry, words []string) float64 {
+	entryWords := searchWords(
+		strings.Join([]string{entry.Name, entry.Desc, entry.CheckNo}, " "))
+	var matches int
+	for _, word := range words {
+		var count int
+		for _, entryWord := range entryWords {
+			if strings.HasPrefix(entryWord, word) {
+				count++
+			}
+		}
+		if count == 0 {
+			return 0
+		}
+		matches += count
+	}
+	return float64(matches) / float64(len(entryWords))
+}
+
+// entryRows returns the entries not in the trash that match options
+// in no particular order.
+func entryRows(
+	data *memory_db.Data, options *findb.EntryListOptions) []fin.Entry {
+	var result []fin.Entry
+	for _, row := range data.Entries {
+		if !row.Trashed.IsZero() {
+			continue
+		}
+		if options != nil {
+			if options.Start != nil && row.Date.Before(toDate(*options.Start)) {
+				continue
+			}
+			if options.End != nil && !row.Date.Before(toDate(*options.End)) {
+				continue
+			}
+			if options.Unreviewed && row.Status == fin.Reviewed {
+				continue
+			}
+			if len(options.Cats) > 0 && !withLine(&row.CatPayment, options.Cats) {
+				continue
+			}
+		}
+		result = append(result, row.Entry)
+	}
+	return result
+}
+
+// withLine returns true if cp has a CatRec or payment in one of cats.
+func withLine(cp *fin.CatPayment, cats fin.CatSet) bool {
+	if cats[fin.Cat{Type: fin.AccountCat, Id: cp.PaymentId()}] {
+		return true
+	}
+	for _, cr := range cp.CatRecs() {
+		if cats[cr.Cat] {
+			return true
+		}
+	}
+	return false
+}
+
+// sortEntries sorts entries from most to least recent.
+func sortEntries(entries []fin.Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Date.Equal(entries[j].Date) {
+			return entries[i].Id > entries[j].Id
+		}
+		return entries[i].Date.After(entries[j].Date)
+	})
+}
+
+func readEntries(
+	data *memory_db.Data,
+	rows []fin.Entry,
+	consumer goconsume.Consumer) error {
+	counts := attachmentCounts(data)
+	for i := range rows {
+		if !consumer.CanConsume() {
+			break
+		}
+		entry := readEntry(&rows[i], counts[rows[i].Id])
+		consumer.Consume(&entry)
+	}
+	return nil
+}
+
+func entryById(data *memory_db.Data, id int64, entry *fin.Entry) error {
+	row, ok := data.Entries[id]
+	if !ok || !row.Trashed.IsZero() {
+		return findb.NoSuchId
+	}
+	*entry = readEntry(&row.Entry, attachmentCounts(data)[id])
+	return nil
+}
+
+func entriesByAccountId(
+	data *memory_db.Data,
+	acctId int64,
+	account *fin.Account,
+	consumer goconsume.Consumer) error {
+	if account == nil {
+		account = &fin.Account{}
+	}
+	if err := accountById(data, acctId, account); err != nil {
+		return err
+	}
+	consumer = &consumers.AddBalance{
+		Balance: account.Balance, EntryBalanceConsumer: consumer}
+	consumer = goconsume.Slice(consumer, 0, account.Count)
+	consumer = goconsume.Filter(
+		consumer,
+		func(ptr interface{}) bool {
+			p := ptr.(*fin.Entry)
+			return p.WithPaymentIn(
+				acctId, data.Accounts[p.PaymentId()].Currency)
+		})
+	return entries(data, accountOptions(acctId), consumer)
+}
+
+func unreconciledEntries(
+	data *memory_db.Data,
+	acctId int64,
+	account *fin.Account,
+	consumer goconsume.Consumer) error {
+	if account == nil {
+		account = &fin.Account{}
+	}
+	if err := accountById(data, acctId, account); err != nil {
+		return err
+	}
+	consumer = goconsume.Slice(consumer, 0, account.Count-account.RCount)
+	consumer = goconsume.Filter(
+		consumer,
+		func(ptr interface{}) bool {
+			p := ptr.(*fin.Entry)
+			return p.WithPaymentIn(
+				acctId, data.Accounts[p.PaymentId()].Currency) && !p.Reconciled()
+		})
+	return entries(data, accountOptions(acctId), consumer)
+}
+
+func accountOptions(acctId int64) *findb.EntryListOptions {
+	return &findb.EntryListOptions{
+		Cats: fin.CatSet{fin.Cat{Type: fin.AccountCat, Id: acctId}: true}}
+}
+
+func doEntryChanges(
+	data *memory_db.Data, userId int64, changes *findb.EntryChanges) error {
+	history := &entryHistory{
+		data: data, userId: userId, ts: time.Now().Unix()}
+	var deltas fin.AccountDeltas = make(map[int64]*fin.AccountDelta)
+	locks := data.LockDates
+	for _, id := range changes.Deletes {
+		row, ok := data.Entries[id]
+		if !ok {
+			continue
+		}
+		// Entries in the trash no longer count toward balances, and
+		// their history already shows them as deleted. They also passed
+		// the lock check when they were trashed.
+		trashed := !row.Trashed.IsZero()
+		if !trashed && locks.Locked(&row.Entry) {
+			return findb.Locked
+		}
+		if !trashed {
+			deltas.Exclude(&row.CatPayment)
+		}
+		delete(data.Entries, id)
+		for attachmentId, attachment := range data.Attachments {
+			if attachment.EntryId == id {
+				delete(data.Attachments, attachmentId)
+			}
+		}
+		if !trashed {
+			history.record(id, &row.Entry, nil)
+		}
+	}
+	for _, id := range changes.Trash {
+		row, ok := data.Entries[id]
+		if !ok || !row.Trashed.IsZero() {
+			continue
+		}
+		if locks.Locked(&row.Entry) {
+			return findb.Locked
+		}
+		deltas.Exclude(&row.CatPayment)
+		row.Trashed = time.Unix(history.ts, 0).UTC()
+		data.Entries[id] = row
+		history.record(id, &row.Entry, nil)
+	}
+	for _, id := range changes.Restore {
+		row, ok := data.Entries[id]
+		if !ok || row.Trashed.IsZero() {
+			continue
+		}
+		if locks.Locked(&row.Entry) {
+			return findb.Locked
+		}
+		deltas.Include(&row.CatPayment)
+		row.Trashed = time.Time{}
+		data.Entries[id] = row
+		history.record(id, nil, &row.Entry)
+	}
+	counts := attachmentCounts(data)
+	for id, update := range changes.Updates {
+		row, ok := data.Entries[id]
+		if !ok {
+			continue
+		}
+		// Entries in the trash cannot be updated.
+		if !row.Trashed.IsZero() {
+			return findb.Trashed
+		}
+		entry := readEntry(&row.Entry, counts[id])
+		concurrent_update_detected := false
+		if changes.Etags != nil {
+			expected_etag, ok := changes.Etags[id]
+			if !ok {
+				panic("Etags field present, but does not contain etag for all updated entries.")
+			}
+			if expected_etag != entry.Etag {
+				concurrent_update_detected = true
+			}
+		}
+		locked := locks.Locked(&entry)
+		if !update(&entry) {
+			continue
+		}
+		if concurrent_update_detected {
+			return findb.ConcurrentUpdate
+		}
+		if locked || locks.Locked(&entry) {
+			return findb.Locked
+		}
+		deltas.Exclude(&row.CatPayment)
+		deltas.Include(&entry.CatPayment)
+		entry.Id = id
+		newRow := fin.TrashedEntry{Entry: entryRow(&entry)}
+		data.Entries[id] = newRow
+		history.record(id, &row.Entry, &newRow.Entry)
+	}
+	for _, entry := range changes.Adds {
+		if locks.Locked(entry) {
+			return findb.Locked
+		}
+		deltas.Include(&entry.CatPayment)
+		entry.Id = data.NewId(memory_db.EntriesTable)
+		row := fin.TrashedEntry{Entry: entryRow(entry)}
+		data.Entries[entry.Id] = row
+		history.record(entry.Id, nil, &row.Entry)
+	}
+	recordAccountDeltas(data, deltas)
+	return nil
+}
+
+func recordAccountDeltas(data *memory_db.Data, deltas fin.AccountDeltas) {
+	for id, delta := range deltas {
+		account, ok := data.Accounts[id]
+		if !ok {
+			continue
+		}
+		account.Balance += delta.Balance
+		account.RBalance += delta.RBalance
+		account.Count += delta.Count
+		account.RCount += delta.RCount
+		data.Accounts[id] = account
+	}
+}
+
+// entryHistory records revisions on behalf of one user for one call to
+// DoEntryChanges.
+type entryHistory struct {
+	data   *memory_db.Data
+	userId int64
+	ts     int64
+}
+
+// record records a revision of the entry with given id. before is nil
+// if the change added the entry; after is nil if the change deleted the
+// entry.
+func (h *entryHistory) record(entryId int64, before, after *fin.Entry) {
+	id := h.data.NewId(memory_db.EntryRevisionsTable)
+	h.data.EntryRevisions[id] = fin.EntryRevision{
+		Id:      id,
+		EntryId: entryId,
+		UserId:  h.userId,
+		Time:    time.Unix(h.ts, 0).UTC(),
+		Before:  entrySnapshot(before),
+		After:   entrySnapshot(after)}
+}
+
+// entrySnapshot returns a copy of entry as it would be stored or nil if
+// entry is nil.
+func entrySnapshot(entry *fin.Entry) *fin.Entry {
+	if entry == nil {
+		return nil
+	}
+	result := entryRow(entry)
+	result.Tags = copyTags(entry.Tags)
+	return &result
+}
+
+// entryRow returns entry the way the Entries table stores it.
+func entryRow(entry *fin.Entry) fin.Entry {
+	result := *entry
+	result.Date = toDate(entry.Date)
+	result.Tags = copyTags(entry.Tags)
+	result.AttachmentCount = 0
+	result.Etag = 0
+	return result
+}
+
+// readEntry returns a copy of row with its etag and attachment count.
+func readEntry(row *fin.Entry, attachmentCount int) fin.Entry {
+	result := *row
+	result.Tags = copyTags(row.Tags)
+	result.AttachmentCount = attachmentCount
+	result.Etag = etag(*row)
+	return result
+}
+
+// attachmentCounts returns the number of attachments by entry id.
+func attachmentCounts(data *memory_db.Data) map[int64]int {
+	result := make(map[int64]int)
+	for _, attachment := range data.Attachments {
+		result[attachment.EntryId]++
+	}
+	return result
+}
+
+func trashedEntries(
+	data *memory_db.Data, consumer goconsume.Consumer) error {
+	var rows []fin.TrashedEntry
+	for _, row := range data.Entries {
+		if !row.Trashed.IsZero() {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Trashed.Equal(rows[j].Trashed) {
+			return rows[i].Id > rows[j].Id
+		}
+		return rows[i].Trashed.After(rows[j].Trashed)
+	})
+	counts := attachmentCounts(data)
+	for i := range rows {
+		if !consumer.CanConsume() {
+			break
+		}
+		trashed := fin.TrashedEntry{
+			Entry:   readEntry(&rows[i].Entry, counts[rows[i].Id]),
+			Trashed: rows[i].Trashed}
+		consumer.Consume(&trashed)
+	}
+	return nil
+}
+
+func purgeTrash(
+	data *memory_db.Data, userId int64, before time.Time) error {
+	var ids []int64
+	for id, row := range data.Entries {
+		if !row.Trashed.IsZero() && row.Trashed.Unix() < before.Unix() {
+			ids = append(ids, id)
+		}
+	}
+	return doEntryChanges(data, userId, &findb.EntryChanges{Deletes: ids})
+}
+
+func accountById(
+	data *memory_db.Data, acctId int64, account *fin.Account) error {
+	row, ok := data.Accounts[acctId]
+	if !ok {
+		return findb.NoSuchId
+	}
+	*account = row
+	return nil
+}
+
+func accounts(data *memory_db.Data) []fin.Account {
+	result := make([]fin.Account, 0, len(data.Accounts))
+	for _, row := range data.Accounts {
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+func activeAccounts(data *memory_db.Data) (result []*fin.Account) {
+	for _, row := range accounts(data) {
+		if row.Active {
+			account := row
+			result = append(result, &account)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return
+}
+
+func addAccount(data *memory_db.Data, account *fin.Account) {
+	account.Id = data.NewId(memory_db.AccountsTable)
+	data.Accounts[account.Id] = accountRow(account)
+}
+
+// updateAccount changes the account with given id. updateAccount does
+// nothing if there is no such account.
+func updateAccount(
+	data *memory_db.Data, acctId int64, update func(a *fin.Account)) {
+	row, ok := data.Accounts[acctId]
+	if !ok {
+		return
+	}
+	update(&row)
+	data.Accounts[acctId] = accountRow(&row)
+}
+
+func accountRow(account *fin.Account) fin.Account {
+	result := *account
+	result.ImportSD = toDate(account.ImportSD)
+	return result
+}
+
+func userRow(user *fin.User) fin.User {
+	result := *user
+	// Defaults to fin.NonePermission if the permission is not recognized
+	result.Permission, _ = fin.ToPermission(user.Permission.ToInt())
+	result.LastLogin = toSeconds(user.LastLogin)
+	return result
+}
+
+func users(data *memory_db.Data) []fin.User {
+	result := make([]fin.User, 0, len(data.Users))
+	for _, row := range data.Users {
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name == result[j].Name {
+			return result[i].Id < result[j].Id
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func userByName(data *memory_db.Data, name string, user *fin.User) error {
+	for _, row := range users(data) {
+		if row.Name == name {
+			*user = row
+			return nil
+		}
+	}
+	return findb.NoSuchId
+}
+
+func recurringEntryRow(entry *fin.RecurringEntry) fin.RecurringEntry {
+	result := *entry
+	result.Entry = entryRow(&entry.Entry)
+	// Recurring entries don't store tags.
+	result.Tags = nil
+	result.EndDate = toDate(entry.EndDate)
+	return result
+}
+
+func readRecurringEntry(row *fin.RecurringEntry) fin.RecurringEntry {
+	result := *row
+	result.Etag = etag(*row)
+	return result
+}
+
+func exchangeRateRow(rate *fin.ExchangeRate) fin.ExchangeRate {
+	result := *rate
+	result.Date = toDate(rate.Date)
+	result.From = rate.From.Normalize()
+	result.To = rate.To.Normalize()
+	return result
+}
+
+func attachmentRow(attachment *fin.Attachment) fin.Attachment {
+	result := *attachment
+	result.Data = copyBytes(attachment.Data)
+	result.Size = int64(len(attachment.Data))
+	return result
+}
+
+func budgetItemRow(item *fin.BudgetItem) fin.BudgetItem {
+	result := *item
+	result.Start = toDate(item.Start)
+	return result
+}
+
+func readEntryRevision(row *fin.EntryRevision) fin.EntryRevision {
+	result := *row
+	result.Before = entrySnapshot(row.Before)
+	result.After = entrySnapshot(row.After)
+	return result
+}
+
+func statementRow(statement *fin.Statement) fin.Statement {
+	result := *statement
+	result.Date = toDate(statement.Date)
+	result.EntryIds = copyIds(statement.EntryIds)
+	result.Finished = toSeconds(statement.Finished)
+	return result
+}
+
+func readStatement(row *fin.Statement) fin.Statement {
+	result := *row
+	result.EntryIds = copyIds(row.EntryIds)
+	return result
+}
+
+func entryTemplateRow(template *fin.EntryTemplate) fin.EntryTemplate {
+	result := *template
+	// Entry templates don't store a date or review status.
+	result.Entry = entryRow(&template.Entry)
+	result.Date = time.Time{}
+	result.Status = fin.NotReviewed
+	result.Percents = copyPercents(template.Percents)
+	return result
+}
+
+func readEntryTemplate(row *fin.EntryTemplate) fin.EntryTemplate {
+	result := *row
+	result.Tags = copyTags(row.Tags)
+	result.Percents = copyPercents(row.Percents)
+	return result
+}
+
+func updateLockDate(data *memory_db.Data, acctId int64, date time.Time) {
+	if date.IsZero() {
+		delete(data.LockDates, acctId)
+		return
+	}
+	data.LockDates[acctId] = toDate(date)
+}
+
+// etag returns the etag of value. value must not include its own etag.
+func etag(value interface{}) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v", value)
+	return h.Sum64()
+}
+
+// toDate returns the date of t at midnight UTC the way the sqlite store
+// stores dates.
+func toDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// toSeconds truncates t to the second in UTC the way the sqlite store
+// stores times.
+func toSeconds(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+	return time.Unix(t.Unix(), 0).UTC()
+}
+
+// copyTags returns a copy of tags or nil if tags is empty.
+func copyTags(tags fin.TagSet) fin.TagSet {
+	return fin.ParseTags(tags.String())
+}
+
+func copyBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+func copyIds(ids []int64) []int64 {
+	if len(ids) == 0 {
+		return nil
+	}
+	return append([]int64(nil), ids...)
+}
+
+func copyPercents(percents map[fin.Cat]int64) map[fin.Cat]int64 {
+	if len(percents) == 0 {
+		return nil
+	}
+	result := make(map[fin.Cat]int64, len(percents))
+	for cat, percent := range percents {
+		result[cat] = percent
+	}
+	return result
+}
+
+type Store struct {
+	db     memory_db.Doer
+	userId int64
+}
+
+// WithUserId returns a Store like this one that attributes the entry
+// changes it makes to the user with given id.
+func (s Store) WithUserId(userId int64) Store {
+	s.userId = userId
+	return s
+}
+
+func (s Store) AccountById(
+	t db.Transaction, acctId int64, account *fin.Account) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return accountById(data, acctId, account)
+	})
+}
+
+func (s Store) Accounts(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		rows := accounts(data)
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			consumer.Consume(&rows[i])
+		}
+		return nil
+	})
+}
+
+func (s Store) ActiveAccounts(t db.Transaction) (
+	accounts []*fin.Account, err error) {
+	err = memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		accounts = activeAccounts(data)
+		return nil
+	})
+	return
+}
+
+func (s Store) AddAccount(t db.Transaction, account *fin.Account) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		addAccount(data, account)
+		return nil
+	})
+}
+
+func (s Store) DoEntryChanges(
+	t db.Transaction, changes *findb.EntryChanges) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return doEntryChanges(data, s.userId, changes)
+	})
+}
+
+func (s Store) Entries(
+	t db.Transaction, options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return entries(data, options, consumer)
+	})
+}
+
+func (s Store) SearchEntries(
+	t db.Transaction, query string, options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return searchEntries(data, query, options, consumer)
+	})
+}
+
+func (s Store) EntriesByAccountId(
+	t db.Transaction, acctId int64, account *fin.Account,
+	consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return entriesByAccountId(data, acctId, account, consumer)
+	})
+}
+
+func (s Store) EntryById(
+	t db.Transaction, id int64, entry *fin.Entry) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return entryById(data, id, entry)
+	})
+}
+
+func (s Store) UnreconciledEntries(
+	t db.Transaction, acctId int64,
+	account *fin.Account, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return unreconciledEntries(data, acctId, account, consumer)
+	})
+}
+
+func (s Store) UpdateAccountImportSD(
+	t db.Transaction, acctId int64, date time.Time) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		updateAccount(data, acctId, func(a *fin.Account) {
+			a.ImportSD = date
+		})
+		return nil
+	})
+}
+
+func (s Store) UpdateAccountCurrency(
+	t db.Transaction, acctId int64, currency fin.Currency) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		updateAccount(data, acctId, func(a *fin.Account) {
+			a.Currency = currency
+		})
+		return nil
+	})
+}
+
+func (s Store) UpdateAccountType(
+	t db.Transaction, acctId int64, accountType fin.AccountType) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		updateAccount(data, acctId, func(a *fin.Account) {
+			a.Type = accountType
+		})
+		return nil
+	})
+}
+
+func (s Store) UpdateAccount(
+	t db.Transaction, account *fin.Account) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		updateAccount(data, account.Id, func(a *fin.Account) {
+			*a = *account
+		})
+		return nil
+	})
+}
+
+func (s Store) RemoveAccount(
+	t db.Transaction, id int64) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		delete(data.Accounts, id)
+		return nil
+	})
+}
+
+func (s Store) AddUser(t db.Transaction, user *fin.User) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		user.Id = data.NewId(memory_db.UsersTable)
+		data.Users[user.Id] = userRow(user)
+		return nil
+	})
+}
+
+func (s Store) RemoveUserByName(t db.Transaction, name string) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		for id, row := range data.Users {
+			if row.Name == name {
+				delete(data.Users, id)
+			}
+		}
+		return nil
+	})
+}
+
+func (s Store) UpdateUser(t db.Transaction, user *fin.User) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		if _, ok := data.Users[user.Id]; ok {
+			data.Users[user.Id] = userRow(user)
+		}
+		return nil
+	})
+}
+
+func (s Store) UserById(
+	t db.Transaction, id int64, user *fin.User) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		row, ok := data.Users[id]
+		if !ok {
+			return findb.NoSuchId
+		}
+		*user = row
+		return nil
+	})
+}
+
+func (s Store) UserByName(
+	t db.Transaction, name string, user *fin.User) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return userByName(data, name, user)
+	})
+}
+
+func (s Store) Users(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		rows := users(data)
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			consumer.Consume(&rows[i])
+		}
+		return nil
+	})
+}
+
+func (s Store) AddRecurringEntry(
+	t db.Transaction, entry *fin.RecurringEntry) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		entry.Id = data.NewId(memory_db.RecurringEntriesTable)
+		data.RecurringEntries[entry.Id] = recurringEntryRow(entry)
+		return nil
+	})
+}
+
+func (s Store) UpdateRecurringEntry(
+	t db.Transaction, entry *fin.RecurringEntry) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		if _, ok := data.RecurringEntries[entry.Id]; ok {
+			data.RecurringEntries[entry.Id] = recurringEntryRow(entry)
+		}
+		return nil
+	})
+}
+
+func (s Store) RecurringEntryById(
+	t db.Transaction, id int64, entry *fin.RecurringEntry) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		row, ok := data.RecurringEntries[id]
+		if !ok {
+			return findb.NoSuchId
+		}
+		*entry = readRecurringEntry(&row)
+		return nil
+	})
+}
+
+func (s Store) RecurringEntries(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		rows := make([]fin.RecurringEntry, 0, len(data.RecurringEntries))
+		for _, row := range data.RecurringEntries {
+			rows = append(rows, row)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Date.Equal(rows[j].Date) {
+				return rows[i].Id < rows[j].Id
+			}
+			return rows[i].Date.Before(rows[j].Date)
+		})
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			entry := readRecurringEntry(&rows[i])
+			consumer.Consume(&entry)
+		}
+		return nil
+	})
+}
+
+func (s Store) RemoveRecurringEntryById(t db.Transaction, id int64) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		delete(data.RecurringEntries, id)
+		return nil
+	})
+}
+
+func (s Store) AddExchangeRate(
+	t db.Transaction, rate *fin.ExchangeRate) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		rate.Id = data.NewId(memory_db.ExchangeRatesTable)
+		data.ExchangeRates[rate.Id] = exchangeRateRow(rate)
+		return nil
+	})
+}
+
+func (s Store) ExchangeRates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		rows := make([]fin.ExchangeRate, 0, len(data.ExchangeRates))
+		for _, row := range data.ExchangeRates {
+			rows = append(rows, row)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Date.Equal(rows[j].Date) {
+				return rows[i].Id > rows[j].Id
+			}
+			return rows[i].Date.After(rows[j].Date)
+		})
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			consumer.Consume(&rows[i])
+		}
+		return nil
+	})
+}
+
+func (s Store) RemoveExchangeRate(t db.Transaction, id int64) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		delete(data.ExchangeRates, id)
+		return nil
+	})
+}
+
+func (s Store) AddAttachment(
+	t db.Transaction, attachment *fin.Attachment) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		attachment.Id = data.NewId(memory_db.AttachmentsTable)
+		attachment.Size = int64(len(attachment.Data))
+		data.Attachments[attachment.Id] = attachmentRow(attachment)
+		return nil
+	})
+}
+
+func (s Store) AttachmentById(
+	t db.Transaction, id int64, attachment *fin.Attachment) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		row, ok := data.Attachments[id]
+		if !ok {
+			return findb.NoSuchId
+		}
+		*attachment = row
+		attachment.Data = copyBytes(row.Data)
+		return nil
+	})
+}
+
+func (s Store) AttachmentsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		var rows []fin.Attachment
+		for _, row := range data.Attachments {
+			if row.EntryId == entryId {
+				row.Data = nil
+				rows = append(rows, row)
+			}
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i].Id < rows[j].Id
+		})
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			consumer.Consume(&rows[i])
+		}
+		return nil
+	})
+}
+
+func (s Store) RemoveAttachment(t db.Transaction, id int64) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		delete(data.Attachments, id)
+		return nil
+	})
+}
+
+func (s Store) AddBudgetItem(
+	t db.Transaction, item *fin.BudgetItem) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		item.Id = data.NewId(memory_db.BudgetItemsTable)
+		data.BudgetItems[item.Id] = budgetItemRow(item)
+		return nil
+	})
+}
+
+func (s Store) UpdateBudgetItem(
+	t db.Transaction, item *fin.BudgetItem) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		if _, ok := data.BudgetItems[item.Id]; ok {
+			data.BudgetItems[item.Id] = budgetItemRow(item)
+		}
+		return nil
+	})
+}
+
+func (s Store) BudgetItems(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		rows := make([]fin.BudgetItem, 0, len(data.BudgetItems))
+		for _, row := range data.BudgetItems {
+			rows = append(rows, row)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i].Id < rows[j].Id
+		})
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			consumer.Consume(&rows[i])
+		}
+		return nil
+	})
+}
+
+func (s Store) RemoveBudgetItem(t db.Transaction, id int64) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		delete(data.BudgetItems, id)
+		return nil
+	})
+}
+
+func (s Store) TrashedEntries(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return trashedEntries(data, consumer)
+	})
+}
+
+func (s Store) PurgeTrash(t db.Transaction, before time.Time) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		return purgeTrash(data, s.userId, before)
+	})
+}
+
+func (s Store) EntryRevisionById(
+	t db.Transaction, id int64, revision *fin.EntryRevision) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		row, ok := data.EntryRevisions[id]
+		if !ok {
+			return findb.NoSuchId
+		}
+		*revision = readEntryRevision(&row)
+		return nil
+	})
+}
+
+func (s Store) EntryRevisionsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		var rows []fin.EntryRevision
+		for _, row := range data.EntryRevisions {
+			if row.EntryId == entryId {
+				rows = append(rows, row)
+			}
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i].Id > rows[j].Id
+		})
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			revision := readEntryRevision(&rows[i])
+			consumer.Consume(&revision)
+		}
+		return nil
+	})
+}
+
+func (s Store) AddStatement(
+	t db.Transaction, statement *fin.Statement) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		statement.Id = data.NewId(memory_db.StatementsTable)
+		data.Statements[statement.Id] = statementRow(statement)
+		return nil
+	})
+}
+
+func (s Store) UpdateStatement(
+	t db.Transaction, statement *fin.Statement) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		if _, ok := data.Statements[statement.Id]; ok {
+			data.Statements[statement.Id] = statementRow(statement)
+		}
+		return nil
+	})
+}
+
+func (s Store) StatementsByAccountId(
+	t db.Transaction, acctId int64, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		var rows []fin.Statement
+		for _, row := range data.Statements {
+			if row.AcctId == acctId {
+				rows = append(rows, row)
+			}
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Date.Equal(rows[j].Date) {
+				return rows[i].Id > rows[j].Id
+			}
+			return rows[i].Date.After(rows[j].Date)
+		})
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			statement := readStatement(&rows[i])
+			consumer.Consume(&statement)
+		}
+		return nil
+	})
+}
+
+func (s Store) RemoveStatement(t db.Transaction, id int64) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		delete(data.Statements, id)
+		return nil
+	})
+}
+
+func (s Store) AddEntryTemplate(
+	t db.Transaction, template *fin.EntryTemplate) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		template.Id = data.NewId(memory_db.EntryTemplatesTable)
+		data.EntryTemplates[template.Id] = entryTemplateRow(template)
+		return nil
+	})
+}
+
+func (s Store) UpdateEntryTemplate(
+	t db.Transaction, template *fin.EntryTemplate) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		if _, ok := data.EntryTemplates[template.Id]; ok {
+			data.EntryTemplates[template.Id] = entryTemplateRow(template)
+		}
+		return nil
+	})
+}
+
+func (s Store) EntryTemplateById(
+	t db.Transaction, id int64, template *fin.EntryTemplate) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		row, ok := data.EntryTemplates[id]
+		if !ok {
+			return findb.NoSuchId
+		}
+		*template = readEntryTemplate(&row)
+		return nil
+	})
+}
+
+func (s Store) EntryTemplates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		rows := make([]fin.EntryTemplate, 0, len(data.EntryTemplates))
+		for _, row := range data.EntryTemplates {
+			rows = append(rows, row)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Title == rows[j].Title {
+				return rows[i].Id < rows[j].Id
+			}
+			return rows[i].Title < rows[j].Title
+		})
+		for i := range rows {
+			if !consumer.CanConsume() {
+				break
+			}
+			template := readEntryTemplate(&rows[i])
+			consumer.Consume(&template)
+		}
+		return nil
+	})
+}
+
+func (s Store) RemoveEntryTemplate(t db.Transaction, id int64) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		delete(data.EntryTemplates, id)
+		return nil
+	})
+}
+
+func (s Store) LockDates(t db.Transaction) (
+	result fin.LockDates, err error) {
+	err = memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		result = make(fin.LockDates, len(data.LockDates))
+		for acctId, date := range data.LockDates {
+			result[acctId] = date
+		}
+		return nil
+	})
+	return
+}
+
+func (s Store) UpdateLockDate(
+	t db.Transaction, acctId int64, date time.Time) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		updateLockDate(data, acctId, date)
+		return nil
+	})
+}
+
+func (s Store) BankAccounts(t db.Transaction) (
+	result fin.BankAccounts, err error) {
+	err = memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		result = make(fin.BankAccounts, len(data.BankAccounts))
+		for bankAccount, acctId := range data.BankAccounts {
+			result[bankAccount] = acctId
+		}
+		return nil
+	})
+	return
+}
+
+func (s Store) UpdateBankAccount(
+	t db.Transaction, bankAccount fin.BankAccount, acctId int64) error {
+	return memory_db.ToDoer(s.db, t).Do(func(data *memory_db.Data) error {
+		if acctId == 0 {
+			delete(data.BankAccounts, bankAccount)
+		} else {
+			data.BankAccounts[bankAccount] = acctId
+		}
+		return nil
+	})
+}
+
+type ReadOnlyStore struct {
+	findb.NoPermissionStore
+	store Store
+}
+
+func (s ReadOnlyStore) AccountById(
+	t db.Transaction, acctId int64, account *fin.Account) error {
+	return s.store.AccountById(t, acctId, account)
+}
+
+func (s ReadOnlyStore) Accounts(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.Accounts(t, consumer)
+}
+
+func (s ReadOnlyStore) ActiveAccounts(t db.Transaction) (
+	accounts []*fin.Account, err error) {
+	return s.store.ActiveAccounts(t)
+}
+
+func (s ReadOnlyStore) Entries(
+	t db.Transaction, options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	return s.store.Entries(t, options, consumer)
+}
+
+func (s ReadOnlyStore) SearchEntries(
+	t db.Transaction, query string, options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	return s.store.SearchEntries(t, query, options, consumer)
+}
+
+func (s ReadOnlyStore) EntriesByAccountId(
+	t db.Transaction, acctId int64, account *fin.Account,
+	consumer goconsume.Consumer) error {
+	return s.store.EntriesByAccountId(t, acctId, account, consumer)
+}
+
+func (s ReadOnlyStore) EntryById(
+	t db.Transaction, id int64, entry *fin.Entry) error {
+	return s.store.EntryById(t, id, entry)
+}
+
+func (s ReadOnlyStore) UnreconciledEntries(
+	t db.Transaction, acctId int64,
+	account *fin.Account, consumer goconsume.Consumer) error {
+	return s.store.UnreconciledEntries(t, acctId, account, consumer)
+}
+
+func (s ReadOnlyStore) UserById(
+	t db.Transaction, id int64, user *fin.User) error {
+	return s.store.UserById(t, id, user)
+}
+
+func (s ReadOnlyStore) UserByName(
+	t db.Transaction, name string, user *fin.User) error {
+	return s.store.UserByName(t, name, user)
+}
+
+func (s ReadOnlyStore) Users(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.Users(t, consumer)
+}
+
+func (s ReadOnlyStore) RecurringEntryById(
+	t db.Transaction, id int64, entry *fin.RecurringEntry) error {
+	return s.store.RecurringEntryById(t, id, entry)
+}
+
+func (s ReadOnlyStore) RecurringEntries(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.RecurringEntries(t, consumer)
+}
+
+func (s ReadOnlyStore) ExchangeRates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.ExchangeRates(t, consumer)
+}
+
+func (s ReadOnlyStore) BudgetItems(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.BudgetItems(t, consumer)
+}
+
+func (s ReadOnlyStore) AttachmentById(
+	t db.Transaction, id int64, attachment *fin.Attachment) error {
+	return s.store.AttachmentById(t, id, attachment)
+}
+
+func (s ReadOnlyStore) AttachmentsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return s.store.AttachmentsByEntryId(t, entryId, consumer)
+}
+
+func (s ReadOnlyStore) EntryRevisionById(
+	t db.Transaction, id int64, revision *fin.EntryRevision) error {
+	return s.store.EntryRevisionById(t, id, revision)
+}
+
+func (s ReadOnlyStore) EntryRevisionsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return s.store.EntryRevisionsByEntryId(t, entryId, consumer)
+}
+
+func (s ReadOnlyStore) TrashedEntries(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.TrashedEntries(t, consumer)
+}
+
+func (s ReadOnlyStore) StatementsByAccountId(
+	t db.Transaction, acctId int64, consumer goconsume.Consumer) error {
+	return s.store.StatementsByAccountId(t, acctId, consumer)
+}
+
+func (s ReadOnlyStore) EntryTemplateById(
+	t db.Transaction, id int64, template *fin.EntryTemplate) error {
+	return s.store.EntryTemplateById(t, id, template)
+}
+
+func (s ReadOnlyStore) EntryTemplates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.EntryTemplates(t, consumer)
+}
+
+func (s ReadOnlyStore) LockDates(t db.Transaction) (fin.LockDates, error) {
+	return s.store.LockDates(t)
+}
+
+func (s ReadOnlyStore) BankAccounts(t db.Transaction) (
+	fin.BankAccounts, error) {
+	return s.store.BankAccounts(t)
+}
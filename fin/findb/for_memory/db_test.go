@@ -0,0 +1,260 @@
+package for_memory
+
+import (
+	"github.com/keep94/finance/fin/findb/fixture"
+	"github.com/keep94/finance/fin/findb/memory_db"
+	"testing"
+)
+
+func TestAccountUpdates(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).AccountUpdates(t, New(db))
+}
+
+func TestSaveAndLoadEntry(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).SaveAndLoadEntry(t, New(db))
+}
+
+func TestUpdateEntry(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).UpdateEntry(t, New(db))
+}
+
+func TestUpdateEntrySkipped(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).UpdateEntrySkipped(t, New(db))
+}
+
+func TestListEntries(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ListEntries(t, New(db))
+}
+
+func TestDeleteEntries(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).DeleteEntries(t, New(db))
+}
+
+func TestSearchEntries(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).SearchEntries(t, New(db))
+}
+
+func TestListEntriesEmptyOptions(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ListEntriesEmptyOptions(t, New(db))
+}
+
+func TestListEntriesDateRange(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ListEntriesDateRange(t, New(db))
+}
+
+func TestListEntriesDateRangeAndUnreviewed(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ListEntriesDateRangeAndUnreviewed(t, New(db))
+}
+
+func TestListEntriesCats(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ListEntriesCats(t, New(db))
+}
+
+func TestListEntriesJustStartDate(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ListEntriesJustStartDate(t, New(db))
+}
+
+func TestListEntriesJustEndDate(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ListEntriesJustEndDate(t, New(db))
+}
+
+func TestListEntriesUnreviewed(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ListEntriesUnreviewed(t, New(db))
+}
+
+func TestEntriesByAccountId(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).EntriesByAccountId(t, New(db))
+}
+
+func TestEntriesByAccountIdNilPtr(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).EntriesByAccountIdNilPtr(t, New(db))
+}
+
+func TestUnreconciledEntries(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).UnreconciledEntries(t, New(db))
+}
+
+func TestUnreconciledEntriesNoAccount(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).UnreconciledEntriesNoAccount(t, New(db))
+}
+
+func TestConcurrentUpdateDetection(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ConcurrentUpdateDetection(t, New(db))
+}
+
+func TestConcurrentUpdateSkipped(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ConcurrentUpdateSkipped(t, New(db))
+}
+
+func TestRecurringPeriods(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).RecurringPeriods(t, New(db))
+}
+
+func TestRecurringEndDates(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).RecurringEndDates(t, New(db))
+}
+
+func TestRecurringEscalations(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).RecurringEscalations(t, New(db))
+}
+
+func TestLockDates(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).LockDates(t, New(db))
+}
+
+func TestBankAccounts(t *testing.T) {
+	db := openDb(t)
+	fixture.BankAccounts(t, New(db))
+}
+
+func TestApplyRecurringEntries(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ApplyRecurringEntries(t, New(db))
+}
+
+func TestActiveAccounts(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).ActiveAccounts(t, New(db))
+}
+
+func TestUpdateAccountImportSD(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).UpdateAccountImportSD(t, New(db))
+}
+
+func TestUpdateAccount(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).UpdateAccount(t, New(db))
+}
+
+func TestRemoveAccount(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).RemoveAccount(t, New(db))
+}
+
+func TestUserById(t *testing.T) {
+	db := openDb(t)
+	fixture.UserById(t, New(db))
+}
+
+func TestUserByName(t *testing.T) {
+	db := openDb(t)
+	fixture.UserByName(t, New(db))
+}
+
+func TestUsers(t *testing.T) {
+	db := openDb(t)
+	fixture.Users(t, New(db))
+}
+
+func TestLoginUser(t *testing.T) {
+	db := openDb(t)
+	fixture.LoginUser(t, memory_db.NewDoer(db), New(db))
+}
+
+func TestRemoveUserByName(t *testing.T) {
+	db := openDb(t)
+	fixture.RemoveUserByName(t, New(db))
+}
+
+func TestNoUserByName(t *testing.T) {
+	db := openDb(t)
+	fixture.NoUserByName(t, New(db))
+}
+
+func TestUpdateUser(t *testing.T) {
+	db := openDb(t)
+	fixture.UpdateUser(t, New(db))
+}
+
+func TestUpdateAccountType(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).UpdateAccountType(t, New(db))
+}
+
+func TestAccountBalancesAsOf(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).AccountBalancesAsOf(t, New(db))
+}
+
+func TestCrossCurrencyEntries(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).CrossCurrencyEntries(t, New(db))
+}
+
+func TestTags(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).Tags(t, New(db))
+}
+
+func TestAttachments(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).Attachments(t, New(db))
+}
+
+func TestTrash(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).Trash(t, New(db))
+}
+
+func TestPurgeLockedTrash(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).PurgeLockedTrash(t, New(db))
+}
+
+func TestStatements(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).Statements(t, New(db))
+}
+
+func TestEntryTemplates(t *testing.T) {
+	db := openDb(t)
+	fixture.EntryTemplates(t, New(db))
+}
+
+func TestEntryRevisions(t *testing.T) {
+	db := openDb(t)
+	newEntryAccountFixture(db).EntryRevisions(t, New(db).WithUserId(7), 7)
+}
+
+func TestExchangeRates(t *testing.T) {
+	db := openDb(t)
+	fixture.ExchangeRates(t, New(db))
+}
+
+func TestBudgetItems(t *testing.T) {
+	db := openDb(t)
+	fixture.BudgetItems(t, New(db))
+}
+
+func newEntryAccountFixture(db *memory_db.Db) fixture.EntryAccountFixture {
+	return fixture.EntryAccountFixture{Doer: memory_db.NewDoer(db)}
+}
+
+func openDb(t *testing.T) *memory_db.Db {
+	return memory_db.New()
+}
@@ -2,6 +2,7 @@
 package for_sqlite
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/keep94/finance/fin"
@@ -13,30 +14,51 @@ import (
 	"github.com/keep94/toolbox/db/sqlite_db"
 	"github.com/keep94/toolbox/db/sqlite_rw"
 	"github.com/keep94/toolbox/passwords"
+	"hash/fnv"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 const (
-	kSQLEntryById                = "select id, date, name, desc, check_no, cats, payment, reviewed from entries where id = ?"
-	kSQLEntriesPrefix            = "select id, date, name, desc, check_no, cats, payment, reviewed from entries"
-	kSQLEntries                  = "select id, date, name, desc, check_no, cats, payment, reviewed from entries order by date desc, id desc"
+	kSQLEntryById                = "select id, date, name, desc, check_no, cats, payment, reviewed, " + kSQLEntryTagsColumn + ", " + kSQLEntryAttachmentCount + ", trashed from entries where id = ? and trashed = 0"
+	kSQLEntryByIdWithTrash       = "select id, date, name, desc, check_no, cats, payment, reviewed, " + kSQLEntryTagsColumn + ", " + kSQLEntryAttachmentCount + ", trashed from entries where id = ?"
+	kSQLEntriesPrefix            = "select id, date, name, desc, check_no, cats, payment, reviewed, " + kSQLEntryTagsColumn + ", " + kSQLEntryAttachmentCount + ", trashed from entries where trashed = 0"
+	kSQLTrashedEntries           = "select id, date, name, desc, check_no, cats, payment, reviewed, " + kSQLEntryTagsColumn + ", " + kSQLEntryAttachmentCount + ", trashed from entries where trashed != 0 order by trashed desc, id desc"
+	kSQLTrashedEntryIdsBefore    = "select id from entries where trashed != 0 and trashed < ?"
+	kSQLUpdateEntryTrashed       = "update entries set trashed = ? where id = ?"
 	kSQLEntryOrderBy             = " order by date desc, id desc"
+	kSQLEntriesByAccountId       = kSQLEntriesPrefix + " and id in (select entry_id from entry_lines where cat_type = 2 and cat_id = ?)" + kSQLEntryOrderBy
+	kSQLUnreconciledEntries      = kSQLEntriesPrefix + " and id in (select entry_id from entry_lines where cat_type = 2 and cat_id = ? and reconciled = 0)" + kSQLEntryOrderBy
+	kSQLDeleteEntryLines         = "delete from entry_lines where entry_id = ?"
+	kSQLInsertEntryLine          = "insert into entry_lines (entry_id, cat_type, cat_id, amount, reconciled) values (?, ?, ?, ?, ?)"
+	kSQLSearchEntriesPrefix      = "select id, date, name, desc, check_no, cats, payment, reviewed, " + kSQLEntryTagsColumn + ", " + kSQLEntryAttachmentCount + ", trashed from entries, (select rowid as s_id, rank as s_rank from entry_search where entry_search match ?) where id = s_id and trashed = 0"
+	kSQLSearchEntriesOrderBy     = " order by s_rank, date desc, id desc"
+	kSQLIndexEntry               = "insert into entry_search (rowid, name, desc, check_no) values (?, ?, ?, ?)"
+	kSQLUnindexEntry             = "delete from entry_search where rowid = ?"
 	kSQLInsertEntry              = "insert into entries (date, name, desc, check_no, cats, payment, reviewed) values (?, ?, ?, ?, ?, ?, ?)"
 	kSQLUpdateEntry              = "update entries set date = ?, name = ?, desc = ?, check_no = ?, cats = ?, payment = ?, reviewed = ? where id = ?"
 	kSQLDeleteEntryById          = "delete from entries where id = ?"
-	kSQLRecurringEntryById       = "select id, date, name, desc, check_no, cats, payment, reviewed, count, unit, num_left, day_of_month from recurring_entries where id = ?"
-	kSQLRecurringEntries         = "select id, date, name, desc, check_no, cats, payment, reviewed, count, unit, num_left, day_of_month from recurring_entries order by date, id"
-	kSQLInsertRecurringEntry     = "insert into recurring_entries (date, name, desc, check_no, cats, payment, reviewed, count, unit, num_left, day_of_month) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
-	kSQLUpdateRecurringEntry     = "update recurring_entries set date = ?, name = ?, desc = ?, check_no = ?, cats = ?, payment = ?, reviewed = ?, count = ?, unit = ?, num_left = ?, day_of_month = ? where id = ?"
+	kSQLEntryTagsColumn          = "(select group_concat(name, ',') from (select t.name from entry_tags et, tags t where et.entry_id = entries.id and et.tag_id = t.id order by t.name))"
+	kSQLEntryAttachmentCount     = "(select count(*) from attachments a where a.entry_id = entries.id)"
+	kSQLInsertTag                = "insert or ignore into tags (name) values (?)"
+	kSQLInsertEntryTag           = "insert into entry_tags (entry_id, tag_id) select ?, id from tags where name = ?"
+	kSQLDeleteEntryTags          = "delete from entry_tags where entry_id = ?"
+	kSQLRecurringEntryById       = "select id, date, name, desc, check_no, cats, payment, reviewed, count, unit, num_left, day_of_month, day_of_month2, weekday, week_of_month, end_date, adjustment, estimated, esc_amount, esc_percent, esc_every, esc_count from recurring_entries where id = ?"
+	kSQLRecurringEntries         = "select id, date, name, desc, check_no, cats, payment, reviewed, count, unit, num_left, day_of_month, day_of_month2, weekday, week_of_month, end_date, adjustment, estimated, esc_amount, esc_percent, esc_every, esc_count from recurring_entries order by date, id"
+	kSQLInsertRecurringEntry     = "insert into recurring_entries (date, name, desc, check_no, cats, payment, reviewed, count, unit, num_left, day_of_month, day_of_month2, weekday, week_of_month, end_date, adjustment, estimated, esc_amount, esc_percent, esc_every, esc_count) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	kSQLUpdateRecurringEntry     = "update recurring_entries set date = ?, name = ?, desc = ?, check_no = ?, cats = ?, payment = ?, reviewed = ?, count = ?, unit = ?, num_left = ?, day_of_month = ?, day_of_month2 = ?, weekday = ?, week_of_month = ?, end_date = ?, adjustment = ?, estimated = ?, esc_amount = ?, esc_percent = ?, esc_every = ?, esc_count = ? where id = ?"
 	kSQLDeleteRecurringEntryById = "delete from recurring_entries where id = ?"
-	kSQLAccountById              = "select id, name, is_active, balance, reconciled, b_count, r_count, import_sd from accounts where id = ?"
-	kSQLAccounts                 = "select id, name, is_active, balance, reconciled, b_count, r_count, import_sd from accounts"
-	kSQLActiveAccounts           = "select id, name, is_active, balance, reconciled, b_count, r_count, import_sd from accounts where is_active = 1 order by name"
-	kSQLInsertAccount            = "insert into accounts (name, is_active, balance, reconciled, b_count, r_count, import_sd) values (?, ?, ?, ?, ?, ?, ?)"
+	kSQLAccountById              = "select id, name, is_active, balance, reconciled, b_count, r_count, import_sd, currency, acct_type from accounts where id = ?"
+	kSQLAccounts                 = "select id, name, is_active, balance, reconciled, b_count, r_count, import_sd, currency, acct_type from accounts"
+	kSQLActiveAccounts           = "select id, name, is_active, balance, reconciled, b_count, r_count, import_sd, currency, acct_type from accounts where is_active = 1 order by name"
+	kSQLInsertAccount            = "insert into accounts (name, is_active, balance, reconciled, b_count, r_count, import_sd, currency, acct_type) values (?, ?, ?, ?, ?, ?, ?, ?, ?)"
 	kSQLUpdateAccountImportSD    = "update accounts set import_sd = ? where id = ?"
-	kSQLUpdateAccount            = "update accounts set name = ?, is_active = ?, balance = ?, reconciled = ?, b_count = ?, r_count = ?, import_sd = ? where id = ?"
+	kSQLUpdateAccountCurrency    = "update accounts set currency = ? where id = ?"
+	kSQLUpdateAccountType        = "update accounts set acct_type = ? where id = ?"
+	kSQLUpdateAccount            = "update accounts set name = ?, is_active = ?, balance = ?, reconciled = ?, b_count = ?, r_count = ?, import_sd = ?, currency = ?, acct_type = ? where id = ?"
 	kSQLRemoveAccount            = "delete from accounts where id = ?"
 	kSQLUserById                 = "select id, name, go_password, permission, last_login from users where id = ?"
 	kSQLUsers                    = "select id, name, go_password, permission, last_login from users order by name"
@@ -44,14 +66,44 @@ const (
 	kSQLUserByName               = "select id, name, go_password, permission, last_login from users where name = ?"
 	kSQLInsertUser               = "insert into users (name, go_password, permission, last_login) values (?, ?, ?, ?)"
 	kSQLUpdateUser               = "update users set name = ?, go_password = ?, permission = ?, last_login = ? where id = ?"
+	kSQLExchangeRates            = "select id, date, from_currency, to_currency, rate from exchange_rates order by date desc, id desc"
+	kSQLInsertExchangeRate       = "insert into exchange_rates (date, from_currency, to_currency, rate) values (?, ?, ?, ?)"
+	kSQLRemoveExchangeRate       = "delete from exchange_rates where id = ?"
+	kSQLAttachmentById           = "select id, entry_id, name, content_type, size, data from attachments where id = ?"
+	kSQLAttachmentsByEntryId     = "select id, entry_id, name, content_type, size, NULL from attachments where entry_id = ? order by id"
+	kSQLInsertAttachment         = "insert into attachments (entry_id, name, content_type, size, data) values (?, ?, ?, ?, ?)"
+	kSQLRemoveAttachment         = "delete from attachments where id = ?"
+	kSQLRemoveEntryAttachments   = "delete from attachments where entry_id = ?"
+	kSQLBudgetItems              = "select id, cat, amount, period, rollover, start from budget_items order by id"
+	kSQLInsertBudgetItem         = "insert into budget_items (cat, amount, period, rollover, start) values (?, ?, ?, ?, ?)"
+	kSQLUpdateBudgetItem         = "update budget_items set cat = ?, amount = ?, period = ?, rollover = ?, start = ? where id = ?"
+	kSQLRemoveBudgetItem         = "delete from budget_items where id = ?"
+	kSQLEntryRevisionById        = "select id, entry_id, user_id, ts, before, after from entry_history where id = ?"
+	kSQLEntryRevisionsByEntryId  = "select id, entry_id, user_id, ts, before, after from entry_history where entry_id = ? order by id desc"
+	kSQLInsertEntryRevision      = "insert into entry_history (entry_id, user_id, ts, before, after) values (?, ?, ?, ?, ?)"
+	kSQLStatementsByAccountId    = "select id, acct_id, date, balance, entry_ids, finished from statements where acct_id = ? order by date desc, id desc"
+	kSQLInsertStatement          = "insert into statements (acct_id, date, balance, entry_ids, finished) values (?, ?, ?, ?, ?)"
+	kSQLUpdateStatement          = "update statements set acct_id = ?, date = ?, balance = ?, entry_ids = ?, finished = ? where id = ?"
+	kSQLRemoveStatement          = "delete from statements where id = ?"
+	kSQLEntryTemplateById        = "select id, title, name, desc, check_no, cats, payment, tags, percents from entry_templates where id = ?"
+	kSQLEntryTemplates           = "select id, title, name, desc, check_no, cats, payment, tags, percents from entry_templates order by title, id"
+	kSQLInsertEntryTemplate      = "insert into entry_templates (title, name, desc, check_no, cats, payment, tags, percents) values (?, ?, ?, ?, ?, ?, ?, ?)"
+	kSQLUpdateEntryTemplate      = "update entry_templates set title = ?, name = ?, desc = ?, check_no = ?, cats = ?, payment = ?, tags = ?, percents = ? where id = ?"
+	kSQLRemoveEntryTemplate      = "delete from entry_templates where id = ?"
+	kSQLLockDates                = "select acct_id, date from lock_dates"
+	kSQLUpdateLockDate           = "insert or replace into lock_dates (acct_id, date) values (?, ?)"
+	kSQLRemoveLockDate           = "delete from lock_dates where acct_id = ?"
+	kSQLBankAccounts             = "select institution, bank_account_id, acct_id from bank_accounts"
+	kSQLUpdateBankAccount        = "insert or replace into bank_accounts (institution, bank_account_id, acct_id) values (?, ?, ?)"
+	kSQLRemoveBankAccount        = "delete from bank_accounts where institution = ? and bank_account_id = ?"
 )
 
 func New(db *sqlite_db.Db) Store {
-	return Store{db}
+	return Store{db: db}
 }
 
 func ConnNew(conn *sqlite.Conn) Store {
-	return Store{sqlite_db.NewSqliteDoer(conn)}
+	return Store{db: sqlite_db.NewSqliteDoer(conn)}
 }
 
 func ReadOnlyWrapper(store Store) ReadOnlyStore {
@@ -59,54 +111,125 @@ func ReadOnlyWrapper(store Store) ReadOnlyStore {
 }
 
 func entries(conn *sqlite.Conn, options *findb.EntryListOptions, consumer goconsume.Consumer) error {
-	var sql string
+	return listEntries(
+		conn, kSQLEntriesPrefix, kSQLEntryOrderBy, nil, options, consumer)
+}
+
+func searchEntries(
+	conn *sqlite.Conn,
+	query string,
+	options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	match := searchMatch(query)
+	if match == "" {
+		return nil
+	}
+	return listEntries(
+		conn,
+		kSQLSearchEntriesPrefix,
+		kSQLSearchEntriesOrderBy,
+		[]interface{}{match},
+		options,
+		consumer)
+}
+
+// listEntries lists entries selected by sql prefix which must end with a
+// where clause. listEntries appends the conditions in options to prefix
+// followed by orderBy. params are the parameters for prefix.
+func listEntries(
+	conn *sqlite.Conn,
+	prefix, orderBy string,
+	params []interface{},
+	options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	var where_clauses []string
 	if options != nil {
-		where_clauses := make([]string, 3)
-		where_clause_count := 0
 		if options.Start != nil {
-			where_clauses[where_clause_count] = "date >= ?"
-			where_clause_count++
+			where_clauses = append(where_clauses, "date >= ?")
+			params = append(params, sqlite_db.DateToString(*options.Start))
 		}
 		if options.End != nil {
-			where_clauses[where_clause_count] = "date < ?"
-			where_clause_count++
+			where_clauses = append(where_clauses, "date < ?")
+			params = append(params, sqlite_db.DateToString(*options.End))
 		}
 		if options.Unreviewed {
-			where_clauses[where_clause_count] = "reviewed != 1"
-			where_clause_count++
+			where_clauses = append(where_clauses, "reviewed != 1")
 		}
-		if where_clause_count > 0 {
-			sql = kSQLEntriesPrefix + " where " + strings.Join(where_clauses[:where_clause_count], " and ") + kSQLEntryOrderBy
-		} else {
-			sql = kSQLEntriesPrefix + kSQLEntryOrderBy
+		if len(options.Cats) > 0 {
+			where_clauses = append(where_clauses, catsClause(options.Cats))
 		}
-	} else {
-		sql = kSQLEntriesPrefix + kSQLEntryOrderBy
 	}
-	stmt, err := conn.Prepare(sql)
+	sql := prefix
+	if len(where_clauses) > 0 {
+		sql += " and " + strings.Join(where_clauses, " and ")
+	}
+	stmt, err := conn.Prepare(sql + orderBy)
 	if err != nil {
 		return err
 	}
 	defer stmt.Finalize()
-	if options != nil && (options.Start != nil || options.End != nil) {
-		sql_params := make([]interface{}, 2)
-		sql_param_count := 0
-		if options.Start != nil {
-			sql_params[sql_param_count] = sqlite_db.DateToString(*options.Start)
-			sql_param_count++
-		}
-		if options.End != nil {
-			sql_params[sql_param_count] = sqlite_db.DateToString(*options.End)
-			sql_param_count++
-		}
-		err = stmt.Exec(sql_params[:sql_param_count]...)
-		if err != nil {
+	if len(params) > 0 {
+		if err = stmt.Exec(params...); err != nil {
 			return err
 		}
 	}
 	return sqlite_rw.ReadRows((&rawEntry{}).init(&fin.Entry{}), stmt, consumer)
 }
 
+// catsClause returns the where clause that selects the entries with a line
+// in one of cats. The category ids are integers, so catsClause includes
+// them directly instead of as parameters to avoid limits on the number of
+// parameters.
+func catsClause(cats fin.CatSet) string {
+	idsByType := make(map[fin.CatType][]string)
+	for cat, ok := range cats {
+		if ok {
+			idsByType[cat.Type] = append(
+				idsByType[cat.Type], strconv.FormatInt(cat.Id, 10))
+		}
+	}
+	var conditions []string
+	for catType, ids := range idsByType {
+		sort.Strings(ids)
+		conditions = append(
+			conditions,
+			fmt.Sprintf(
+				"(cat_type = %d and cat_id in (%s))",
+				catType,
+				strings.Join(ids, ", ")))
+	}
+	if len(conditions) == 0 {
+		return "0"
+	}
+	sort.Strings(conditions)
+	return fmt.Sprintf(
+		"id in (select entry_id from entry_lines where %s)",
+		strings.Join(conditions, " or "))
+}
+
+// searchMatch converts query to a full text match expression that
+// matches each word in query as a whole word or word prefix. searchMatch
+// returns "" if query has no words.
+func searchMatch(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i := range words {
+		words[i] = fmt.Sprintf("\"%s\"*", words[i])
+	}
+	return strings.Join(words, " ")
+}
+
+// indexEntry adds entry to the full text index replacing what is
+// already there.
+func indexEntry(conn *sqlite.Conn, entry *fin.Entry) error {
+	if err := conn.Exec(kSQLUnindexEntry, entry.Id); err != nil {
+		return err
+	}
+	return conn.Exec(
+		kSQLIndexEntry, entry.Id, entry.Name, entry.Desc, entry.CheckNo)
+}
+
 func entryById(conn *sqlite.Conn, id int64, entry *fin.Entry) error {
 	stmt, err := conn.Prepare(kSQLEntryById)
 	if err != nil {
@@ -130,11 +253,18 @@ func entriesByAccountId(conn *sqlite.Conn, acctId int64, account *fin.Account, c
 	if err := accountById(conn, acctId, account); err != nil {
 		return err
 	}
-	stmt, err := conn.Prepare(kSQLEntries)
+	currencies, err := accountCurrencies(conn)
+	if err != nil {
+		return err
+	}
+	stmt, err := conn.Prepare(kSQLEntriesByAccountId)
 	if err != nil {
 		return err
 	}
 	defer stmt.Finalize()
+	if err = stmt.Exec(acctId); err != nil {
+		return err
+	}
 	consumer = &consumers.AddBalance{
 		Balance: account.Balance, EntryBalanceConsumer: consumer}
 	consumer = goconsume.Slice(consumer, 0, account.Count)
@@ -142,7 +272,7 @@ func entriesByAccountId(conn *sqlite.Conn, acctId int64, account *fin.Account, c
 		consumer,
 		func(ptr interface{}) bool {
 			p := ptr.(*fin.Entry)
-			return p.WithPayment(acctId)
+			return p.WithPaymentIn(acctId, currencies[p.PaymentId()])
 		})
 	return sqlite_rw.ReadRows((&rawEntry{}).init(&fin.Entry{}), stmt, consumer)
 }
@@ -154,28 +284,43 @@ func unreconciledEntries(conn *sqlite.Conn, acctId int64, account *fin.Account,
 	if err := accountById(conn, acctId, account); err != nil {
 		return err
 	}
-	stmt, err := conn.Prepare(kSQLEntries)
+	currencies, err := accountCurrencies(conn)
+	if err != nil {
+		return err
+	}
+	stmt, err := conn.Prepare(kSQLUnreconciledEntries)
 	if err != nil {
 		return err
 	}
 	defer stmt.Finalize()
+	if err = stmt.Exec(acctId); err != nil {
+		return err
+	}
 	consumer = goconsume.Slice(consumer, 0, account.Count-account.RCount)
 	consumer = goconsume.Filter(
 		consumer,
 		func(ptr interface{}) bool {
 			p := ptr.(*fin.Entry)
-			return p.WithPayment(acctId) && !p.Reconciled()
+			return p.WithPaymentIn(acctId, currencies[p.PaymentId()]) && !p.Reconciled()
 		})
 	return sqlite_rw.ReadRows((&rawEntry{}).init(&fin.Entry{}), stmt, consumer)
 }
 
-func doEntryChanges(conn *sqlite.Conn, changes *findb.EntryChanges) error {
+func doEntryChanges(
+	conn *sqlite.Conn, userId int64, changes *findb.EntryChanges) error {
 	row := (&rawEntry{}).init(&fin.Entry{})
+	history := &entryHistory{
+		conn: conn, userId: userId, ts: time.Now().Unix()}
+	var before string
 	var err error
 	var deltas fin.AccountDeltas = make(map[int64]*fin.AccountDelta)
 	var lastRowIdStmt, getStmt, addStmt, deleteStmt, updateStmt *sqlite.Stmt
-	if len(changes.Updates) > 0 || len(changes.Deletes) > 0 {
-		getStmt, err = conn.Prepare(kSQLEntryById)
+	locks, err := lockDates(conn)
+	if err != nil {
+		return err
+	}
+	if len(changes.Updates) > 0 || len(changes.Deletes) > 0 || len(changes.Trash) > 0 || len(changes.Restore) > 0 {
+		getStmt, err = conn.Prepare(kSQLEntryByIdWithTrash)
 		if err != nil {
 			return err
 		}
@@ -215,12 +360,88 @@ func doEntryChanges(conn *sqlite.Conn, changes *findb.EntryChanges) error {
 		if err != nil {
 			return err
 		}
-		deltas.Exclude(&row.CatPayment)
+		// Entries in the trash no longer count toward balances, and
+		// their history already shows them as deleted. They also passed
+		// the lock check when they were trashed.
+		trashed := row.trashed != 0
+		if !trashed && locks.Locked(row.Entry) {
+			return findb.Locked
+		}
+		if !trashed {
+			if before, err = encodeEntry(row.Entry); err != nil {
+				return err
+			}
+			deltas.Exclude(&row.CatPayment)
+		}
 		err = deleteStmt.Exec(id)
 		if err != nil {
 			return err
 		}
 		deleteStmt.Next()
+		if err = setEntryTags(conn, id, nil); err != nil {
+			return err
+		}
+		if err = conn.Exec(kSQLUnindexEntry, id); err != nil {
+			return err
+		}
+		if err = conn.Exec(kSQLDeleteEntryLines, id); err != nil {
+			return err
+		}
+		if err = conn.Exec(kSQLRemoveEntryAttachments, id); err != nil {
+			return err
+		}
+		if !trashed {
+			if err = history.record(id, before, nil); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range changes.Trash {
+		err = _entryById(getStmt, row, id)
+		if err == findb.NoSuchId {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if row.trashed != 0 {
+			continue
+		}
+		if locks.Locked(row.Entry) {
+			return findb.Locked
+		}
+		if before, err = encodeEntry(row.Entry); err != nil {
+			return err
+		}
+		deltas.Exclude(&row.CatPayment)
+		if err = conn.Exec(kSQLUpdateEntryTrashed, history.ts, id); err != nil {
+			return err
+		}
+		if err = history.record(id, before, nil); err != nil {
+			return err
+		}
+	}
+	for _, id := range changes.Restore {
+		err = _entryById(getStmt, row, id)
+		if err == findb.NoSuchId {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if row.trashed == 0 {
+			continue
+		}
+		if locks.Locked(row.Entry) {
+			return findb.Locked
+		}
+		deltas.Include(&row.CatPayment)
+		if err = conn.Exec(kSQLUpdateEntryTrashed, 0, id); err != nil {
+			return err
+		}
+		if err = history.record(id, "", row.Entry); err != nil {
+			return err
+		}
 	}
 	for id, update := range changes.Updates {
 		err = _entryById(getStmt, row, id)
@@ -230,6 +451,10 @@ func doEntryChanges(conn *sqlite.Conn, changes *findb.EntryChanges) error {
 		if err != nil {
 			return err
 		}
+		// Entries in the trash cannot be updated.
+		if row.trashed != 0 {
+			return findb.Trashed
+		}
 		concurrent_update_detected := false
 		if changes.Etags != nil {
 			expected_etag, ok := changes.Etags[id]
@@ -241,12 +466,19 @@ func doEntryChanges(conn *sqlite.Conn, changes *findb.EntryChanges) error {
 			}
 		}
 		old_cat_payment := row.CatPayment
+		if before, err = encodeEntry(row.Entry); err != nil {
+			return err
+		}
+		locked := locks.Locked(row.Entry)
 		if !update(row.Entry) {
 			continue
 		}
 		if concurrent_update_detected {
 			return findb.ConcurrentUpdate
 		}
+		if locked || locks.Locked(row.Entry) {
+			return findb.Locked
+		}
 		deltas.Exclude(&old_cat_payment)
 		deltas.Include(&row.CatPayment)
 		row.Entry.Id = id
@@ -259,18 +491,116 @@ func doEntryChanges(conn *sqlite.Conn, changes *findb.EntryChanges) error {
 			return err
 		}
 		updateStmt.Next()
+		if err = setEntryTags(conn, id, row.Tags); err != nil {
+			return err
+		}
+		if err = indexEntry(conn, row.Entry); err != nil {
+			return err
+		}
+		if err = setEntryLines(conn, id, &row.CatPayment); err != nil {
+			return err
+		}
+		if err = history.record(id, before, row.Entry); err != nil {
+			return err
+		}
 	}
 	for _, entry := range changes.Adds {
+		if locks.Locked(entry) {
+			return findb.Locked
+		}
 		row.init(entry)
 		deltas.Include(&entry.CatPayment)
 		err = addEntry(addStmt, lastRowIdStmt, row)
 		if err != nil {
 			return err
 		}
+		if err = setEntryTags(conn, entry.Id, entry.Tags); err != nil {
+			return err
+		}
+		if err = indexEntry(conn, entry); err != nil {
+			return err
+		}
+		if err = setEntryLines(conn, entry.Id, &entry.CatPayment); err != nil {
+			return err
+		}
+		if err = history.record(entry.Id, "", entry); err != nil {
+			return err
+		}
 	}
 	return recordAccountDeltas(conn, deltas)
 }
 
+func lockDates(conn *sqlite.Conn) (fin.LockDates, error) {
+	stmt, err := conn.Prepare(kSQLLockDates)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Finalize()
+	if err = stmt.Exec(); err != nil {
+		return nil, err
+	}
+	result := make(fin.LockDates)
+	for stmt.Next() {
+		var acctId int64
+		var dateStr string
+		if err = stmt.Scan(&acctId, &dateStr); err != nil {
+			return nil, err
+		}
+		date, err := sqlite_db.StringToDate(dateStr)
+		if err != nil {
+			return nil, err
+		}
+		result[acctId] = date
+	}
+	return result, stmt.Error()
+}
+
+func updateLockDate(conn *sqlite.Conn, acctId int64, date time.Time) error {
+	if date.IsZero() {
+		return conn.Exec(kSQLRemoveLockDate, acctId)
+	}
+	return conn.Exec(
+		kSQLUpdateLockDate, acctId, sqlite_db.DateToString(date))
+}
+
+func bankAccounts(conn *sqlite.Conn) (fin.BankAccounts, error) {
+	stmt, err := conn.Prepare(kSQLBankAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Finalize()
+	if err = stmt.Exec(); err != nil {
+		return nil, err
+	}
+	result := make(fin.BankAccounts)
+	for stmt.Next() {
+		var bankAccount fin.BankAccount
+		var acctId int64
+		err = stmt.Scan(
+			&bankAccount.Institution, &bankAccount.BankAccountId, &acctId)
+		if err != nil {
+			return nil, err
+		}
+		result[bankAccount] = acctId
+	}
+	return result, stmt.Error()
+}
+
+func updateBankAccount(
+	conn *sqlite.Conn, bankAccount fin.BankAccount, acctId int64) error {
+	if acctId == 0 {
+		return conn.Exec(
+			kSQLRemoveBankAccount,
+			bankAccount.Institution,
+			bankAccount.BankAccountId)
+	}
+	return conn.Exec(
+		kSQLUpdateBankAccount,
+		bankAccount.Institution,
+		bankAccount.BankAccountId,
+		acctId)
+}
+
 func accountById(conn *sqlite.Conn, acctId int64, account *fin.Account) error {
 	return sqlite_rw.ReadSingle(
 		conn,
@@ -280,6 +610,24 @@ func accountById(conn *sqlite.Conn, acctId int64, account *fin.Account) error {
 		acctId)
 }
 
+// accountCurrencies returns the currency of each account by account id.
+func accountCurrencies(conn *sqlite.Conn) (map[int64]fin.Currency, error) {
+	var accounts []*fin.Account
+	err := sqlite_rw.ReadMultiple(
+		conn,
+		(&rawAccount{}).init(&fin.Account{}),
+		goconsume.AppendPtrsTo(&accounts),
+		kSQLAccounts)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int64]fin.Currency, len(accounts))
+	for _, account := range accounts {
+		result[account.Id] = account.Currency
+	}
+	return result, nil
+}
+
 func activeAccounts(conn *sqlite.Conn) (accounts []*fin.Account, err error) {
 	err = sqlite_rw.ReadMultiple(
 		conn,
@@ -293,6 +641,14 @@ func updateAccountImportSD(conn *sqlite.Conn, acctId int64, date time.Time) erro
 	return conn.Exec(kSQLUpdateAccountImportSD, sqlite_db.DateToString(date), acctId)
 }
 
+func updateAccountCurrency(conn *sqlite.Conn, acctId int64, currency fin.Currency) error {
+	return conn.Exec(kSQLUpdateAccountCurrency, string(currency), acctId)
+}
+
+func updateAccountType(conn *sqlite.Conn, acctId int64, accountType fin.AccountType) error {
+	return conn.Exec(kSQLUpdateAccountType, accountType.ToInt(), acctId)
+}
+
 func addEntry(stmt, lastRowIdStmt *sqlite.Stmt, r *rawEntry) error {
 	values, err := sqlite_rw.InsertValues(r)
 	if err != nil {
@@ -307,6 +663,164 @@ func addEntry(stmt, lastRowIdStmt *sqlite.Stmt, r *rawEntry) error {
 	return err
 }
 
+// setEntryTags replaces the tags of the entry with given id.
+func setEntryTags(conn *sqlite.Conn, entryId int64, tags fin.TagSet) error {
+	if err := conn.Exec(kSQLDeleteEntryTags, entryId); err != nil {
+		return err
+	}
+	for _, tag := range tags.Sorted() {
+		if err := conn.Exec(kSQLInsertTag, tag); err != nil {
+			return err
+		}
+		if err := conn.Exec(kSQLInsertEntryTag, entryId, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setEntryLines replaces the lines of an entry in the entry_lines table.
+// Each CatRec gets a line, and the payment gets a line with the entry
+// total. Lines let queries find the entries of an account or category
+// without reading every entry.
+func setEntryLines(
+	conn *sqlite.Conn, entryId int64, cp *fin.CatPayment) error {
+	if err := conn.Exec(kSQLDeleteEntryLines, entryId); err != nil {
+		return err
+	}
+	for _, cr := range cp.CatRecs() {
+		// Lines store amounts in the currency of their account.
+		amount := cr.Amount
+		if cr.Currency != "" {
+			amount = cr.CurrencyAmount
+		}
+		err := conn.Exec(
+			kSQLInsertEntryLine,
+			entryId,
+			int(cr.Cat.Type),
+			cr.Cat.Id,
+			amount,
+			cr.Reconciled)
+		if err != nil {
+			return err
+		}
+	}
+	return conn.Exec(
+		kSQLInsertEntryLine,
+		entryId,
+		int(fin.AccountCat),
+		cp.PaymentId(),
+		cp.Total(),
+		cp.Reconciled())
+}
+
+func trashedEntryIdsBefore(
+	conn *sqlite.Conn, before time.Time) ([]int64, error) {
+	stmt, err := conn.Prepare(kSQLTrashedEntryIdsBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Finalize()
+	if err = stmt.Exec(before.Unix()); err != nil {
+		return nil, err
+	}
+	var result []int64
+	for stmt.Next() {
+		var id int64
+		if err = stmt.Scan(&id); err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+	return result, stmt.Error()
+}
+
+// entryHistory records revisions to the entry_history table on behalf
+// of one user for one call to DoEntryChanges.
+type entryHistory struct {
+	conn   *sqlite.Conn
+	userId int64
+	ts     int64
+}
+
+// record records a revision of the entry with given id. before is the
+// encoded entry before the change or "" if the change added the entry;
+// after is nil if the change deleted the entry.
+func (h *entryHistory) record(
+	entryId int64, before string, after *fin.Entry) error {
+	var afterStr string
+	if after != nil {
+		var err error
+		if afterStr, err = encodeEntry(after); err != nil {
+			return err
+		}
+	}
+	return h.conn.Exec(
+		kSQLInsertEntryRevision, entryId, h.userId, h.ts, before, afterStr)
+}
+
+// entrySnapshot is how the entry_history table stores a version of an
+// entry. The fields hold the same values as the columns of the entries
+// table.
+type entrySnapshot struct {
+	Date    string `json:"date"`
+	Name    string `json:"name"`
+	Desc    string `json:"desc"`
+	CheckNo string `json:"check_no"`
+	Cat     string `json:"cats"`
+	Payment string `json:"payment"`
+	Status  int    `json:"status"`
+	Tags    string `json:"tags"`
+}
+
+func encodeEntry(entry *fin.Entry) (string, error) {
+	row := (&rawEntry{}).init(entry)
+	if err := row.Marshall(); err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(&entrySnapshot{
+		Date:    row.dateStr,
+		Name:    entry.Name,
+		Desc:    entry.Desc,
+		CheckNo: entry.CheckNo,
+		Cat:     row.cat,
+		Payment: row.payment,
+		Status:  row.status,
+		Tags:    entry.Tags.String()})
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// decodeEntry decodes what encodeEntry returned. decodeEntry returns nil
+// if encoded is "".
+func decodeEntry(encoded string, id int64) (*fin.Entry, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	var snapshot entrySnapshot
+	if err := json.Unmarshal([]byte(encoded), &snapshot); err != nil {
+		return nil, err
+	}
+	result := &fin.Entry{
+		Id:      id,
+		Name:    snapshot.Name,
+		Desc:    snapshot.Desc,
+		CheckNo: snapshot.CheckNo}
+	row := &rawEntry{
+		Entry:   result,
+		dateStr: snapshot.Date,
+		cat:     snapshot.Cat,
+		payment: snapshot.Payment,
+		status:  snapshot.Status,
+		tags:    snapshot.Tags}
+	if err := row.Unmarshall(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func recordAccountDeltas(conn *sqlite.Conn, deltas fin.AccountDeltas) error {
 	for id, delta := range deltas {
 		err := conn.Exec("update accounts set balance = balance + ?, reconciled = reconciled + ?, b_count = b_count + ?, r_count = r_count + ? where id = ?", delta.Balance, delta.RBalance, delta.Count, delta.RCount, id)
@@ -323,6 +837,8 @@ type rawEntry struct {
 	cat     string
 	payment string
 	status  int
+	tags    string
+	trashed int64
 }
 
 func (r *rawEntry) init(bo *fin.Entry) *rawEntry {
@@ -331,15 +847,22 @@ func (r *rawEntry) init(bo *fin.Entry) *rawEntry {
 }
 
 func (r *rawEntry) Ptrs() []interface{} {
-	return []interface{}{&r.Id, &r.dateStr, &r.Name, &r.Desc, &r.CheckNo, &r.cat, &r.payment, &r.status}
+	return []interface{}{&r.Id, &r.dateStr, &r.Name, &r.Desc, &r.CheckNo, &r.cat, &r.payment, &r.status, &r.tags, &r.AttachmentCount, &r.trashed}
 }
 
 func (r *rawEntry) Values() []interface{} {
 	return []interface{}{r.dateStr, r.Name, r.Desc, r.CheckNo, r.cat, r.payment, r.status, r.Id}
 }
 
+// SetEtag folds the tags into the etag because the tags live in the
+// entry_tags table rather than in the entry row.
 func (r *rawEntry) SetEtag(etag uint64) {
 	r.Etag = etag
+	if r.tags != "" {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%d %s", etag, r.tags)
+		r.Etag = h.Sum64()
+	}
 }
 
 func (r *rawEntry) ValuePtr() interface{} {
@@ -352,6 +875,7 @@ func (r *rawEntry) Unmarshall() error {
 		return err
 	}
 	r.Status = fin.ReviewStatus(r.status)
+	r.Tags = fin.ParseTags(r.tags)
 	return r.Entry.Unmarshall(r, unmarshall)
 }
 
@@ -362,10 +886,45 @@ func (r *rawEntry) Marshall() error {
 	return nil
 }
 
+type rawTrashedEntry struct {
+	*fin.TrashedEntry
+	re rawEntry
+}
+
+func (r *rawTrashedEntry) init(bo *fin.TrashedEntry) *rawTrashedEntry {
+	r.TrashedEntry = bo
+	r.re.init(&bo.Entry)
+	return r
+}
+
+func (r *rawTrashedEntry) Ptrs() []interface{} {
+	return r.re.Ptrs()
+}
+
+func (r *rawTrashedEntry) Values() []interface{} {
+	return r.re.Values()
+}
+
+func (r *rawTrashedEntry) SetEtag(etag uint64) {
+	r.re.SetEtag(etag)
+}
+
+func (r *rawTrashedEntry) ValuePtr() interface{} {
+	return r.TrashedEntry
+}
+
+func (r *rawTrashedEntry) Unmarshall() error {
+	r.Trashed = time.Unix(r.re.trashed, 0).UTC()
+	return r.re.Unmarshall()
+}
+
 type rawRecurringEntry struct {
 	*fin.RecurringEntry
-	re   rawEntry
-	unit int
+	re         rawEntry
+	unit       int
+	weekday    int
+	endDateStr string
+	adjustment int
 }
 
 func (r *rawRecurringEntry) init(bo *fin.RecurringEntry) *rawRecurringEntry {
@@ -375,11 +934,11 @@ func (r *rawRecurringEntry) init(bo *fin.RecurringEntry) *rawRecurringEntry {
 }
 
 func (r *rawRecurringEntry) Ptrs() []interface{} {
-	return []interface{}{&r.Id, &r.re.dateStr, &r.Name, &r.Desc, &r.CheckNo, &r.re.cat, &r.re.payment, &r.re.status, &r.Period.Count, &r.unit, &r.NumLeft, &r.Period.DayOfMonth}
+	return []interface{}{&r.Id, &r.re.dateStr, &r.Name, &r.Desc, &r.CheckNo, &r.re.cat, &r.re.payment, &r.re.status, &r.Period.Count, &r.unit, &r.NumLeft, &r.Period.DayOfMonth, &r.Period.SecondDayOfMonth, &r.weekday, &r.Period.WeekOfMonth, &r.endDateStr, &r.adjustment, &r.Estimated, &r.Escalation.Amount, &r.Escalation.Percent, &r.Escalation.Every, &r.Escalation.Count}
 }
 
 func (r *rawRecurringEntry) Values() []interface{} {
-	return []interface{}{r.re.dateStr, r.Name, r.Desc, r.CheckNo, r.re.cat, r.re.payment, r.re.status, r.Period.Count, r.unit, r.NumLeft, r.Period.DayOfMonth, r.Id}
+	return []interface{}{r.re.dateStr, r.Name, r.Desc, r.CheckNo, r.re.cat, r.re.payment, r.re.status, r.Period.Count, r.unit, r.NumLeft, r.Period.DayOfMonth, r.Period.SecondDayOfMonth, r.weekday, r.Period.WeekOfMonth, r.endDateStr, r.adjustment, r.Estimated, r.Escalation.Amount, r.Escalation.Percent, r.Escalation.Every, r.Escalation.Count, r.Id}
 }
 
 func (r *rawRecurringEntry) SetEtag(etag uint64) {
@@ -397,47 +956,357 @@ func (r *rawRecurringEntry) Unmarshall() (err error) {
 	var valid bool
 	if r.Period.Unit, valid = fin.ToRecurringUnit(r.unit); !valid {
 		err = errors.New("Invalid recurring unit found in database.")
+		return
+	}
+	if r.weekday < 0 || r.weekday > int(time.Saturday) {
+		err = errors.New("Invalid weekday found in database.")
+		return
+	}
+	r.Period.Weekday = time.Weekday(r.weekday)
+	if r.Adjustment, valid = fin.ToBusinessDayAdjustment(r.adjustment); !valid {
+		err = errors.New("Invalid business day adjustment found in database.")
+		return
+	}
+	r.EndDate = time.Time{}
+	if r.endDateStr != "" {
+		if r.EndDate, err = sqlite_db.StringToDate(r.endDateStr); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *rawRecurringEntry) Marshall() (err error) {
+	if err = r.re.Marshall(); err != nil {
+		return
+	}
+	r.unit = r.Period.Unit.ToInt()
+	r.weekday = int(r.Period.Weekday)
+	r.adjustment = r.Adjustment.ToInt()
+	r.endDateStr = ""
+	if !r.EndDate.IsZero() {
+		r.endDateStr = sqlite_db.DateToString(r.EndDate)
+	}
+	return
+}
+
+type rawEntryTemplate struct {
+	*fin.EntryTemplate
+	re       rawEntry
+	percents string
+}
+
+func (r *rawEntryTemplate) init(bo *fin.EntryTemplate) *rawEntryTemplate {
+	r.EntryTemplate = bo
+	r.re.init(&bo.Entry)
+	return r
+}
+
+func (r *rawEntryTemplate) Ptrs() []interface{} {
+	return []interface{}{&r.Id, &r.Title, &r.Name, &r.Desc, &r.CheckNo, &r.re.cat, &r.re.payment, &r.re.tags, &r.percents}
+}
+
+func (r *rawEntryTemplate) Values() []interface{} {
+	return []interface{}{r.Title, r.Name, r.Desc, r.CheckNo, r.re.cat, r.re.payment, r.re.tags, r.percents, r.Id}
+}
+
+func (r *rawEntryTemplate) ValuePtr() interface{} {
+	return r.EntryTemplate
+}
+
+// Unmarshall decodes percents which are stored as cat|percent pairs
+// e.g 0:7|2250|1:2|-10000.
+func (r *rawEntryTemplate) Unmarshall() error {
+	r.Tags = fin.ParseTags(r.re.tags)
+	if err := r.Entry.Unmarshall(&r.re, unmarshall); err != nil {
+		return err
+	}
+	r.Percents = nil
+	if r.percents == "" {
+		return nil
+	}
+	parts := strings.Split(r.percents, "|")
+	if len(parts)%2 != 0 {
+		return errors.New(fmt.Sprintf("for_sqlite: Percents string invalid: %s", r.percents))
+	}
+	r.Percents = make(map[fin.Cat]int64, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		cat, err := fin.CatFromString(parts[i])
+		if err != nil {
+			return err
+		}
+		percent, err := strconv.ParseInt(parts[i+1], 10, 64)
+		if err != nil {
+			return err
+		}
+		r.Percents[cat] = percent
+	}
+	return nil
+}
+
+func (r *rawEntryTemplate) Marshall() error {
+	r.re.tags = r.Tags.String()
+	r.Entry.Marshall(marshall, &r.re)
+	cats := make([]fin.Cat, 0, len(r.Percents))
+	for cat := range r.Percents {
+		cats = append(cats, cat)
+	}
+	sort.Slice(cats, func(i, j int) bool {
+		return cats[i].ToString() < cats[j].ToString()
+	})
+	parts := make([]string, 0, 2*len(cats))
+	for _, cat := range cats {
+		parts = append(
+			parts,
+			cat.ToString(),
+			strconv.FormatInt(r.Percents[cat], 10))
+	}
+	r.percents = strings.Join(parts, "|")
+	return nil
+}
+
+type rawAccount struct {
+	*fin.Account
+	importSDStr string
+	currency    string
+	acctType    int
+}
+
+func (r *rawAccount) init(bo *fin.Account) *rawAccount {
+	r.Account = bo
+	return r
+}
+
+func (r *rawAccount) Ptrs() []interface{} {
+	return []interface{}{&r.Id, &r.Name, &r.Active, &r.Balance, &r.RBalance, &r.Count, &r.RCount, &r.importSDStr, &r.currency, &r.acctType}
+}
+
+func (r *rawAccount) Values() []interface{} {
+	return []interface{}{r.Name, r.Active, r.Balance, r.RBalance, r.Count, r.RCount, r.importSDStr, r.currency, r.acctType, r.Id}
+}
+
+func (r *rawAccount) ValuePtr() interface{} {
+	return r.Account
+}
+
+func (r *rawAccount) Unmarshall() error {
+	r.Account.ImportSD, _ = sqlite_db.StringToDate(r.importSDStr)
+	r.Account.Currency = fin.Currency(r.currency)
+	var ok bool
+	if r.Account.Type, ok = fin.ToAccountType(r.acctType); !ok {
+		return errors.New("Invalid account type found in database.")
+	}
+	return nil
+}
+
+func (r *rawAccount) Marshall() error {
+	r.importSDStr = sqlite_db.DateToString(r.ImportSD)
+	r.currency = string(r.Currency)
+	r.acctType = r.Type.ToInt()
+	return nil
+}
+
+type rawExchangeRate struct {
+	*fin.ExchangeRate
+	dateStr string
+	from    string
+	to      string
+}
+
+func (r *rawExchangeRate) init(bo *fin.ExchangeRate) *rawExchangeRate {
+	r.ExchangeRate = bo
+	return r
+}
+
+func (r *rawExchangeRate) Ptrs() []interface{} {
+	return []interface{}{&r.Id, &r.dateStr, &r.from, &r.to, &r.Rate}
+}
+
+func (r *rawExchangeRate) Values() []interface{} {
+	return []interface{}{r.dateStr, r.from, r.to, r.Rate, r.Id}
+}
+
+func (r *rawExchangeRate) ValuePtr() interface{} {
+	return r.ExchangeRate
+}
+
+func (r *rawExchangeRate) Unmarshall() (err error) {
+	r.Date, err = sqlite_db.StringToDate(r.dateStr)
+	r.From = fin.Currency(r.from)
+	r.To = fin.Currency(r.to)
+	return
+}
+
+func (r *rawExchangeRate) Marshall() error {
+	r.dateStr = sqlite_db.DateToString(r.Date)
+	r.from = string(r.From.Normalize())
+	r.to = string(r.To.Normalize())
+	return nil
+}
+
+type rawAttachment struct {
+	*fin.Attachment
+}
+
+func (r *rawAttachment) init(bo *fin.Attachment) *rawAttachment {
+	r.Attachment = bo
+	return r
+}
+
+func (r *rawAttachment) Ptrs() []interface{} {
+	return []interface{}{&r.Id, &r.EntryId, &r.Name, &r.ContentType, &r.Size, &r.Data}
+}
+
+func (r *rawAttachment) Values() []interface{} {
+	return []interface{}{r.EntryId, r.Name, r.ContentType, r.Size, r.Data, r.Id}
+}
+
+func (r *rawAttachment) ValuePtr() interface{} {
+	return r.Attachment
+}
+
+func (r *rawAttachment) Unmarshall() error {
+	// Scan reuses the buffer sqlite owns, so make a copy.
+	if r.Data != nil {
+		r.Data = append([]byte(nil), r.Data...)
+	}
+	return nil
+}
+
+func (r *rawAttachment) Marshall() error {
+	r.Size = int64(len(r.Data))
+	return nil
+}
+
+type rawEntryRevision struct {
+	*fin.EntryRevision
+	ts     int64
+	before string
+	after  string
+}
+
+func (r *rawEntryRevision) init(
+	bo *fin.EntryRevision) *rawEntryRevision {
+	r.EntryRevision = bo
+	return r
+}
+
+func (r *rawEntryRevision) Ptrs() []interface{} {
+	return []interface{}{&r.Id, &r.EntryId, &r.UserId, &r.ts, &r.before, &r.after}
+}
+
+func (r *rawEntryRevision) ValuePtr() interface{} {
+	return r.EntryRevision
+}
+
+func (r *rawEntryRevision) Unmarshall() (err error) {
+	r.Time = time.Unix(r.ts, 0).UTC()
+	if r.Before, err = decodeEntry(r.before, r.EntryId); err != nil {
+		return
+	}
+	r.After, err = decodeEntry(r.after, r.EntryId)
+	return
+}
+
+type rawStatement struct {
+	*fin.Statement
+	dateStr     string
+	entryIdsStr string
+	finished    int64
+}
+
+func (r *rawStatement) init(bo *fin.Statement) *rawStatement {
+	r.Statement = bo
+	return r
+}
+
+func (r *rawStatement) Ptrs() []interface{} {
+	return []interface{}{&r.Id, &r.AcctId, &r.dateStr, &r.Balance, &r.entryIdsStr, &r.finished}
+}
+
+func (r *rawStatement) Values() []interface{} {
+	return []interface{}{r.AcctId, r.dateStr, r.Balance, r.entryIdsStr, r.finished, r.Id}
+}
+
+func (r *rawStatement) ValuePtr() interface{} {
+	return r.Statement
+}
+
+func (r *rawStatement) Unmarshall() (err error) {
+	if r.Date, err = sqlite_db.StringToDate(r.dateStr); err != nil {
+		return
+	}
+	r.EntryIds = nil
+	if r.entryIdsStr != "" {
+		for _, idStr := range strings.Split(r.entryIdsStr, ",") {
+			var id int64
+			if id, err = strconv.ParseInt(idStr, 10, 64); err != nil {
+				return
+			}
+			r.EntryIds = append(r.EntryIds, id)
+		}
+	}
+	r.Finished = time.Time{}
+	if r.finished != 0 {
+		r.Finished = time.Unix(r.finished, 0).UTC()
 	}
 	return
 }
 
-func (r *rawRecurringEntry) Marshall() (err error) {
-	if err = r.re.Marshall(); err != nil {
-		return
+func (r *rawStatement) Marshall() error {
+	r.dateStr = sqlite_db.DateToString(r.Date)
+	idStrs := make([]string, len(r.EntryIds))
+	for i, id := range r.EntryIds {
+		idStrs[i] = strconv.FormatInt(id, 10)
 	}
-	r.unit = r.Period.Unit.ToInt()
-	return
+	r.entryIdsStr = strings.Join(idStrs, ",")
+	r.finished = 0
+	if r.Done() {
+		r.finished = r.Finished.Unix()
+	}
+	return nil
 }
 
-type rawAccount struct {
-	*fin.Account
-	importSDStr string
+type rawBudgetItem struct {
+	*fin.BudgetItem
+	cat      string
+	period   int
+	startStr string
 }
 
-func (r *rawAccount) init(bo *fin.Account) *rawAccount {
-	r.Account = bo
+func (r *rawBudgetItem) init(bo *fin.BudgetItem) *rawBudgetItem {
+	r.BudgetItem = bo
 	return r
 }
 
-func (r *rawAccount) Ptrs() []interface{} {
-	return []interface{}{&r.Id, &r.Name, &r.Active, &r.Balance, &r.RBalance, &r.Count, &r.RCount, &r.importSDStr}
+func (r *rawBudgetItem) Ptrs() []interface{} {
+	return []interface{}{&r.Id, &r.cat, &r.Amount, &r.period, &r.Rollover, &r.startStr}
 }
 
-func (r *rawAccount) Values() []interface{} {
-	return []interface{}{r.Name, r.Active, r.Balance, r.RBalance, r.Count, r.RCount, r.importSDStr, r.Id}
+func (r *rawBudgetItem) Values() []interface{} {
+	return []interface{}{r.cat, r.Amount, r.period, r.Rollover, r.startStr, r.Id}
 }
 
-func (r *rawAccount) ValuePtr() interface{} {
-	return r.Account
+func (r *rawBudgetItem) ValuePtr() interface{} {
+	return r.BudgetItem
 }
 
-func (r *rawAccount) Unmarshall() error {
-	r.Account.ImportSD, _ = sqlite_db.StringToDate(r.importSDStr)
-	return nil
+func (r *rawBudgetItem) Unmarshall() (err error) {
+	if r.Cat, err = fin.CatFromString(r.cat); err != nil {
+		return
+	}
+	var valid bool
+	if r.Period, valid = fin.ToBudgetPeriod(r.period); !valid {
+		return errors.New("Invalid budget period found in database.")
+	}
+	r.Start, _ = sqlite_db.StringToDate(r.startStr)
+	return
 }
 
-func (r *rawAccount) Marshall() error {
-	r.importSDStr = sqlite_db.DateToString(r.ImportSD)
+func (r *rawBudgetItem) Marshall() error {
+	r.cat = r.Cat.String()
+	r.period = r.Period.ToInt()
+	r.startStr = sqlite_db.DateToString(r.Start)
 	return nil
 }
 
@@ -504,7 +1373,7 @@ func unmarshall(ptr interface{}, cr *[]fin.CatRec, id *int64, reconciled *bool)
 		(*cr) = nil
 	}
 	for i := range *cr {
-		a, err := strconv.ParseInt(parts[3*i+1], 10, 64)
+		a, currency, currencyAmount, err := parseAmount(parts[3*i+1])
 		if err != nil {
 			return err
 		}
@@ -517,6 +1386,8 @@ func unmarshall(ptr interface{}, cr *[]fin.CatRec, id *int64, reconciled *bool)
 		} else {
 			(*cr)[i] = fin.CatRec{Amount: a, Reconciled: false}
 		}
+		(*cr)[i].Currency = currency
+		(*cr)[i].CurrencyAmount = currencyAmount
 		(*cr)[i].Cat, err = fin.CatFromString(parts[3*i])
 		if err != nil {
 			return err
@@ -549,7 +1420,7 @@ func marshall(cr []fin.CatRec, id int64, reconciled bool, ptr interface{}) {
 	catStrs := make([]string, 3*len(cr))
 	for i := range cr {
 		catStrs[3*i] = cr[i].Cat.ToString()
-		catStrs[3*i+1] = strconv.FormatInt(cr[i].Amount, 10)
+		catStrs[3*i+1] = formatAmount(&cr[i])
 		if cr[i].Reconciled {
 			catStrs[3*i+2] = "1"
 		} else {
@@ -568,8 +1439,45 @@ func marshall(cr []fin.CatRec, id int64, reconciled bool, ptr interface{}) {
 	p.payment = strings.Join(paymentStrs, "|")
 }
 
+// formatAmount encodes the amount of a CatRec. A CatRec with a currency
+// is encoded as amount;currency;currencyAmount e.g 1234;EUR;1100.
+func formatAmount(cr *fin.CatRec) string {
+	if cr.Currency == "" {
+		return strconv.FormatInt(cr.Amount, 10)
+	}
+	return fmt.Sprintf("%d;%s;%d", cr.Amount, cr.Currency, cr.CurrencyAmount)
+}
+
+// parseAmount is the inverse of formatAmount.
+func parseAmount(s string) (
+	amount int64, currency fin.Currency, currencyAmount int64, err error) {
+	parts := strings.Split(s, ";")
+	if len(parts) != 1 && len(parts) != 3 {
+		err = errors.New(fmt.Sprintf("for_sqlite: Amount string invalid: %s", s))
+		return
+	}
+	if amount, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return
+	}
+	if len(parts) == 3 {
+		if currency, err = fin.ParseCurrency(parts[1]); err != nil {
+			return
+		}
+		currencyAmount, err = strconv.ParseInt(parts[2], 10, 64)
+	}
+	return
+}
+
 type Store struct {
-	db sqlite_db.Doer
+	db     sqlite_db.Doer
+	userId int64
+}
+
+// WithUserId returns a Store like this one that attributes the entry
+// changes it makes to the user with given id.
+func (s Store) WithUserId(userId int64) Store {
+	s.userId = userId
+	return s
 }
 
 func (s Store) AccountById(
@@ -606,7 +1514,7 @@ func (s Store) AddAccount(t db.Transaction, account *fin.Account) error {
 func (s Store) DoEntryChanges(
 	t db.Transaction, changes *findb.EntryChanges) error {
 	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
-		return doEntryChanges(conn, changes)
+		return doEntryChanges(conn, s.userId, changes)
 	})
 }
 
@@ -618,6 +1526,14 @@ func (s Store) Entries(
 	})
 }
 
+func (s Store) SearchEntries(
+	t db.Transaction, query string, options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return searchEntries(conn, query, options, consumer)
+	})
+}
+
 func (s Store) EntriesByAccountId(
 	t db.Transaction, acctId int64, account *fin.Account,
 	consumer goconsume.Consumer) error {
@@ -648,6 +1564,20 @@ func (s Store) UpdateAccountImportSD(
 	})
 }
 
+func (s Store) UpdateAccountCurrency(
+	t db.Transaction, acctId int64, currency fin.Currency) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return updateAccountCurrency(conn, acctId, currency)
+	})
+}
+
+func (s Store) UpdateAccountType(
+	t db.Transaction, acctId int64, accountType fin.AccountType) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return updateAccountType(conn, acctId, accountType)
+	})
+}
+
 func (s Store) UpdateAccount(
 	t db.Transaction, account *fin.Account) error {
 	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
@@ -763,6 +1693,276 @@ func (s Store) RemoveRecurringEntryById(t db.Transaction, id int64) error {
 	})
 }
 
+func (s Store) AddExchangeRate(
+	t db.Transaction, rate *fin.ExchangeRate) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.AddRow(
+			conn,
+			(&rawExchangeRate{}).init(rate),
+			&rate.Id,
+			kSQLInsertExchangeRate)
+	})
+}
+
+func (s Store) ExchangeRates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadMultiple(
+			conn,
+			(&rawExchangeRate{}).init(&fin.ExchangeRate{}),
+			consumer,
+			kSQLExchangeRates)
+	})
+}
+
+func (s Store) RemoveExchangeRate(t db.Transaction, id int64) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return conn.Exec(kSQLRemoveExchangeRate, id)
+	})
+}
+
+func (s Store) AddAttachment(
+	t db.Transaction, attachment *fin.Attachment) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.AddRow(
+			conn,
+			(&rawAttachment{}).init(attachment),
+			&attachment.Id,
+			kSQLInsertAttachment)
+	})
+}
+
+func (s Store) AttachmentById(
+	t db.Transaction, id int64, attachment *fin.Attachment) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadSingle(
+			conn,
+			(&rawAttachment{}).init(attachment),
+			findb.NoSuchId,
+			kSQLAttachmentById,
+			id)
+	})
+}
+
+func (s Store) AttachmentsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadMultiple(
+			conn,
+			(&rawAttachment{}).init(&fin.Attachment{}),
+			consumer,
+			kSQLAttachmentsByEntryId,
+			entryId)
+	})
+}
+
+func (s Store) RemoveAttachment(t db.Transaction, id int64) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return conn.Exec(kSQLRemoveAttachment, id)
+	})
+}
+
+func (s Store) AddBudgetItem(
+	t db.Transaction, item *fin.BudgetItem) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.AddRow(
+			conn,
+			(&rawBudgetItem{}).init(item),
+			&item.Id,
+			kSQLInsertBudgetItem)
+	})
+}
+
+func (s Store) UpdateBudgetItem(
+	t db.Transaction, item *fin.BudgetItem) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.UpdateRow(
+			conn, (&rawBudgetItem{}).init(item), kSQLUpdateBudgetItem)
+	})
+}
+
+func (s Store) BudgetItems(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadMultiple(
+			conn,
+			(&rawBudgetItem{}).init(&fin.BudgetItem{}),
+			consumer,
+			kSQLBudgetItems)
+	})
+}
+
+func (s Store) RemoveBudgetItem(t db.Transaction, id int64) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return conn.Exec(kSQLRemoveBudgetItem, id)
+	})
+}
+
+func (s Store) TrashedEntries(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadMultiple(
+			conn,
+			(&rawTrashedEntry{}).init(&fin.TrashedEntry{}),
+			consumer,
+			kSQLTrashedEntries)
+	})
+}
+
+func (s Store) PurgeTrash(t db.Transaction, before time.Time) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		ids, err := trashedEntryIdsBefore(conn, before)
+		if err != nil {
+			return err
+		}
+		return doEntryChanges(
+			conn, s.userId, &findb.EntryChanges{Deletes: ids})
+	})
+}
+
+func (s Store) EntryRevisionById(
+	t db.Transaction, id int64, revision *fin.EntryRevision) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadSingle(
+			conn,
+			(&rawEntryRevision{}).init(revision),
+			findb.NoSuchId,
+			kSQLEntryRevisionById,
+			id)
+	})
+}
+
+func (s Store) EntryRevisionsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadMultiple(
+			conn,
+			(&rawEntryRevision{}).init(&fin.EntryRevision{}),
+			consumer,
+			kSQLEntryRevisionsByEntryId,
+			entryId)
+	})
+}
+
+func (s Store) AddStatement(
+	t db.Transaction, statement *fin.Statement) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.AddRow(
+			conn,
+			(&rawStatement{}).init(statement),
+			&statement.Id,
+			kSQLInsertStatement)
+	})
+}
+
+func (s Store) UpdateStatement(
+	t db.Transaction, statement *fin.Statement) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.UpdateRow(
+			conn, (&rawStatement{}).init(statement), kSQLUpdateStatement)
+	})
+}
+
+func (s Store) StatementsByAccountId(
+	t db.Transaction, acctId int64, consumer goconsume.Consumer) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadMultiple(
+			conn,
+			(&rawStatement{}).init(&fin.Statement{}),
+			consumer,
+			kSQLStatementsByAccountId,
+			acctId)
+	})
+}
+
+func (s Store) RemoveStatement(t db.Transaction, id int64) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return conn.Exec(kSQLRemoveStatement, id)
+	})
+}
+
+func (s Store) AddEntryTemplate(
+	t db.Transaction, template *fin.EntryTemplate) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.AddRow(
+			conn,
+			(&rawEntryTemplate{}).init(template),
+			&template.Id,
+			kSQLInsertEntryTemplate)
+	})
+}
+
+func (s Store) UpdateEntryTemplate(
+	t db.Transaction, template *fin.EntryTemplate) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.UpdateRow(
+			conn,
+			(&rawEntryTemplate{}).init(template),
+			kSQLUpdateEntryTemplate)
+	})
+}
+
+func (s Store) EntryTemplateById(
+	t db.Transaction, id int64, template *fin.EntryTemplate) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadSingle(
+			conn,
+			(&rawEntryTemplate{}).init(template),
+			findb.NoSuchId,
+			kSQLEntryTemplateById,
+			id)
+	})
+}
+
+func (s Store) EntryTemplates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return sqlite_rw.ReadMultiple(
+			conn,
+			(&rawEntryTemplate{}).init(&fin.EntryTemplate{}),
+			consumer,
+			kSQLEntryTemplates)
+	})
+}
+
+func (s Store) RemoveEntryTemplate(t db.Transaction, id int64) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return conn.Exec(kSQLRemoveEntryTemplate, id)
+	})
+}
+
+func (s Store) LockDates(t db.Transaction) (
+	result fin.LockDates, err error) {
+	err = sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) (err error) {
+		result, err = lockDates(conn)
+		return
+	})
+	return
+}
+
+func (s Store) UpdateLockDate(
+	t db.Transaction, acctId int64, date time.Time) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return updateLockDate(conn, acctId, date)
+	})
+}
+
+func (s Store) BankAccounts(t db.Transaction) (
+	result fin.BankAccounts, err error) {
+	err = sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) (err error) {
+		result, err = bankAccounts(conn)
+		return
+	})
+	return
+}
+
+func (s Store) UpdateBankAccount(
+	t db.Transaction, bankAccount fin.BankAccount, acctId int64) error {
+	return sqlite_db.ToDoer(s.db, t).Do(func(conn *sqlite.Conn) error {
+		return updateBankAccount(conn, bankAccount, acctId)
+	})
+}
+
 type ReadOnlyStore struct {
 	findb.NoPermissionStore
 	store Store
@@ -789,6 +1989,12 @@ func (s ReadOnlyStore) Entries(
 	return s.store.Entries(t, options, consumer)
 }
 
+func (s ReadOnlyStore) SearchEntries(
+	t db.Transaction, query string, options *findb.EntryListOptions,
+	consumer goconsume.Consumer) error {
+	return s.store.SearchEntries(t, query, options, consumer)
+}
+
 func (s ReadOnlyStore) EntriesByAccountId(
 	t db.Transaction, acctId int64, account *fin.Account,
 	consumer goconsume.Consumer) error {
@@ -830,3 +2036,62 @@ func (s ReadOnlyStore) RecurringEntries(
 	t db.Transaction, consumer goconsume.Consumer) error {
 	return s.store.RecurringEntries(t, consumer)
 }
+
+func (s ReadOnlyStore) ExchangeRates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.ExchangeRates(t, consumer)
+}
+
+func (s ReadOnlyStore) BudgetItems(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.BudgetItems(t, consumer)
+}
+
+func (s ReadOnlyStore) AttachmentById(
+	t db.Transaction, id int64, attachment *fin.Attachment) error {
+	return s.store.AttachmentById(t, id, attachment)
+}
+
+func (s ReadOnlyStore) AttachmentsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return s.store.AttachmentsByEntryId(t, entryId, consumer)
+}
+
+func (s ReadOnlyStore) EntryRevisionById(
+	t db.Transaction, id int64, revision *fin.EntryRevision) error {
+	return s.store.EntryRevisionById(t, id, revision)
+}
+
+func (s ReadOnlyStore) EntryRevisionsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return s.store.EntryRevisionsByEntryId(t, entryId, consumer)
+}
+
+func (s ReadOnlyStore) TrashedEntries(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.TrashedEntries(t, consumer)
+}
+
+func (s ReadOnlyStore) StatementsByAccountId(
+	t db.Transaction, acctId int64, consumer goconsume.Consumer) error {
+	return s.store.StatementsByAccountId(t, acctId, consumer)
+}
+
+func (s ReadOnlyStore) EntryTemplateById(
+	t db.Transaction, id int64, template *fin.EntryTemplate) error {
+	return s.store.EntryTemplateById(t, id, template)
+}
+
+func (s ReadOnlyStore) EntryTemplates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return s.store.EntryTemplates(t, consumer)
+}
+
+func (s ReadOnlyStore) LockDates(t db.Transaction) (fin.LockDates, error) {
+	return s.store.LockDates(t)
+}
+
+func (s ReadOnlyStore) BankAccounts(t db.Transaction) (
+	fin.BankAccounts, error) {
+	return s.store.BankAccounts(t)
+}
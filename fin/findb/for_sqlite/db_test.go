@@ -2,8 +2,11 @@ package for_sqlite
 
 import (
 	"errors"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb"
 	"github.com/keep94/finance/fin/findb/fixture"
 	"github.com/keep94/finance/fin/findb/sqlite_setup"
+	"github.com/keep94/goconsume"
 	"github.com/keep94/gosqlite/sqlite"
 	"github.com/keep94/toolbox/db/sqlite_db"
 	"testing"
@@ -49,6 +52,12 @@ func TestDeleteEntries(t *testing.T) {
 	newEntryAccountFixture(db).DeleteEntries(t, New(db))
 }
 
+func TestSearchEntries(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).SearchEntries(t, New(db))
+}
+
 func TestListEntriesEmptyOptions(t *testing.T) {
 	db := openDb(t)
 	defer closeDb(t, db)
@@ -67,6 +76,112 @@ func TestListEntriesDateRangeAndUnreviewed(t *testing.T) {
 	newEntryAccountFixture(db).ListEntriesDateRangeAndUnreviewed(t, New(db))
 }
 
+func TestListEntriesCats(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).ListEntriesCats(t, New(db))
+}
+
+func TestEntryLinesAddedToExistingDatabase(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	store := New(db)
+	for _, name := range []string{"checking", "savings"} {
+		err := store.AddAccount(nil, &fin.Account{Name: name, Active: true})
+		if err != nil {
+			t.Fatalf("Error adding account: %v", err)
+		}
+	}
+	groceries := fin.Entry{
+		CatPayment: fin.NewCatPayment(fin.NewCat("0:7"), 100, false, 1)}
+	transfer := fin.Entry{
+		CatPayment: fin.NewCatPayment(fin.NewCat("2:1"), 200, true, 2)}
+	err := store.DoEntryChanges(nil, &findb.EntryChanges{
+		Adds: []*fin.Entry{&groceries, &transfer}})
+	if err != nil {
+		t.Fatalf("Error adding entries: %v", err)
+	}
+	// Set up tables again after removing entry_lines to simulate a
+	// database created before entry_lines existed.
+	err = db.Do(func(conn *sqlite.Conn) error {
+		if err := conn.Exec("drop table entry_lines"); err != nil {
+			return err
+		}
+		if err := conn.Exec("update schema_version set version = 2"); err != nil {
+			return err
+		}
+		return sqlite_setup.SetUpTables(conn)
+	})
+	if err != nil {
+		t.Fatalf("Error setting up tables: %v", err)
+	}
+	verifyEntryCount(t, 2, func(consumer goconsume.Consumer) error {
+		return store.EntriesByAccountId(nil, 1, nil, consumer)
+	})
+	verifyEntryCount(t, 1, func(consumer goconsume.Consumer) error {
+		return store.EntriesByAccountId(nil, 2, nil, consumer)
+	})
+	verifyEntryCount(t, 2, func(consumer goconsume.Consumer) error {
+		return store.UnreconciledEntries(nil, 1, nil, consumer)
+	})
+	verifyEntryCount(t, 0, func(consumer goconsume.Consumer) error {
+		return store.UnreconciledEntries(nil, 2, nil, consumer)
+	})
+	verifyEntryCount(t, 1, func(consumer goconsume.Consumer) error {
+		return store.Entries(
+			nil,
+			&findb.EntryListOptions{
+				Cats: fin.CatSet{fin.NewCat("0:7"): true}},
+			consumer)
+	})
+}
+
+func TestCrossCurrencyEntryLines(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	store := New(db)
+	for _, name := range []string{"checking", "savings"} {
+		err := store.AddAccount(nil, &fin.Account{Name: name, Active: true})
+		if err != nil {
+			t.Fatalf("Error adding account: %v", err)
+		}
+	}
+	if err := store.UpdateAccountCurrency(nil, 2, "EUR"); err != nil {
+		t.Fatalf("Error updating currency: %v", err)
+	}
+	cpb := fin.CatPaymentBuilder{}
+	transfer := fin.Entry{
+		CatPayment: cpb.AddCatRec(
+			fin.CatRec{
+				Cat:            fin.NewCat("2:2"),
+				Amount:         10000,
+				Currency:       "EUR",
+				CurrencyAmount: 9150}).SetPaymentId(1).Build()}
+	err := store.DoEntryChanges(nil, &findb.EntryChanges{
+		Adds: []*fin.Entry{&transfer}})
+	if err != nil {
+		t.Fatalf("Error adding entries: %v", err)
+	}
+	verifyEntryLineAmount(t, db, 1, -10000)
+	verifyEntryLineAmount(t, db, 2, 9150)
+
+	// Migrating rewrites lines stored in the payment currency.
+	err = db.Do(func(conn *sqlite.Conn) error {
+		if err := conn.Exec("update entry_lines set amount = 10000 where cat_type = 2 and cat_id = 2"); err != nil {
+			return err
+		}
+		if err := conn.Exec("update schema_version set version = 4"); err != nil {
+			return err
+		}
+		return sqlite_setup.SetUpTables(conn)
+	})
+	if err != nil {
+		t.Fatalf("Error setting up tables: %v", err)
+	}
+	verifyEntryLineAmount(t, db, 1, -10000)
+	verifyEntryLineAmount(t, db, 2, 9150)
+}
+
 func TestListEntriesJustStartDate(t *testing.T) {
 	db := openDb(t)
 	defer closeDb(t, db)
@@ -121,6 +236,36 @@ func TestConcurrentUpdateSkipped(t *testing.T) {
 	newEntryAccountFixture(db).ConcurrentUpdateSkipped(t, New(db))
 }
 
+func TestRecurringPeriods(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).RecurringPeriods(t, New(db))
+}
+
+func TestRecurringEndDates(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).RecurringEndDates(t, New(db))
+}
+
+func TestRecurringEscalations(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).RecurringEscalations(t, New(db))
+}
+
+func TestLockDates(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).LockDates(t, New(db))
+}
+
+func TestBankAccounts(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	fixture.BankAccounts(t, New(db))
+}
+
 func TestApplyRecurringEntries(t *testing.T) {
 	db := openDb(t)
 	defer closeDb(t, db)
@@ -193,6 +338,120 @@ func TestUpdateUser(t *testing.T) {
 	fixture.UpdateUser(t, New(db))
 }
 
+func TestUpdateAccountType(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).UpdateAccountType(t, New(db))
+}
+
+func TestAccountBalancesAsOf(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).AccountBalancesAsOf(t, New(db))
+}
+
+func TestCrossCurrencyEntries(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).CrossCurrencyEntries(t, New(db))
+}
+
+func TestTags(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).Tags(t, New(db))
+}
+
+func TestAttachments(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).Attachments(t, New(db))
+}
+
+func TestTrash(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).Trash(t, New(db))
+}
+
+func TestPurgeLockedTrash(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).PurgeLockedTrash(t, New(db))
+}
+
+func TestStatements(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).Statements(t, New(db))
+}
+
+func TestEntryTemplates(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	fixture.EntryTemplates(t, New(db))
+}
+
+func TestEntryRevisions(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	newEntryAccountFixture(db).EntryRevisions(t, New(db).WithUserId(7), 7)
+}
+
+func TestExchangeRates(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	fixture.ExchangeRates(t, New(db))
+}
+
+func TestBudgetItems(t *testing.T) {
+	db := openDb(t)
+	defer closeDb(t, db)
+	fixture.BudgetItems(t, New(db))
+}
+
+func verifyEntryCount(
+	t *testing.T,
+	expected int,
+	entries func(consumer goconsume.Consumer) error) {
+	var count int
+	counter := goconsume.ConsumerFunc(func(ptr interface{}) { count++ })
+	if err := entries(counter); err != nil {
+		t.Fatalf("Error fetching entries: %v", err)
+	}
+	if count != expected {
+		t.Errorf("Expected %d entries, got %d", expected, count)
+	}
+}
+
+// verifyEntryLineAmount verifies the amount in entry_lines for the
+// account with given id.
+func verifyEntryLineAmount(
+	t *testing.T, db *sqlite_db.Db, acctId int64, expected int64) {
+	t.Helper()
+	var amount int64
+	err := db.Do(func(conn *sqlite.Conn) error {
+		stmt, err := conn.Prepare("select amount from entry_lines where cat_type = 2 and cat_id = ?")
+		if err != nil {
+			return err
+		}
+		defer stmt.Finalize()
+		if err := stmt.Exec(acctId); err != nil {
+			return err
+		}
+		if !stmt.Next() {
+			return findb.NoSuchId
+		}
+		return stmt.Scan(&amount)
+	})
+	if err != nil {
+		t.Fatalf("Error reading entry lines: %v", err)
+	}
+	if amount != expected {
+		t.Errorf("Expected %d, got %d", expected, amount)
+	}
+}
+
 func newEntryAccountFixture(db *sqlite_db.Db) fixture.EntryAccountFixture {
 	return fixture.EntryAccountFixture{Doer: sqlite_db.NewDoer(db)}
 }
@@ -0,0 +1,207 @@
+// Package memory_db contains an in-memory database for the types in the
+// fin package. It needs no cgo which makes it good for fast unit tests
+// and for demos. Nothing in it survives the process.
+package memory_db
+
+import (
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/categories"
+	"github.com/keep94/toolbox/db"
+	"sync"
+)
+
+// Table names for Data.NewId
+const (
+	AccountsTable          = "accounts"
+	EntriesTable           = "entries"
+	RecurringEntriesTable  = "recurring_entries"
+	UsersTable             = "users"
+	ExchangeRatesTable     = "exchange_rates"
+	AttachmentsTable       = "attachments"
+	BudgetItemsTable       = "budget_items"
+	EntryRevisionsTable    = "entry_history"
+	StatementsTable        = "statements"
+	EntryTemplatesTable    = "entry_templates"
+	ExpenseCategoriesTable = "expense_categories"
+	IncomeCategoriesTable  = "income_categories"
+)
+
+// FitId is the key of the FitIds table.
+type FitId struct {
+	AcctId int64
+	FitId  string
+}
+
+// Data holds the tables of the database. Each table maps id to row.
+// The rows are values that share slices and maps with the rows of
+// earlier transactions, so code must replace rows rather than change
+// what is inside them.
+type Data struct {
+	Accounts map[int64]fin.Account
+	// A zero Trashed field means the entry is not in the trash.
+	Entries           map[int64]fin.TrashedEntry
+	RecurringEntries  map[int64]fin.RecurringEntry
+	Users             map[int64]fin.User
+	ExchangeRates     map[int64]fin.ExchangeRate
+	Attachments       map[int64]fin.Attachment
+	BudgetItems       map[int64]fin.BudgetItem
+	EntryRevisions    map[int64]fin.EntryRevision
+	Statements        map[int64]fin.Statement
+	EntryTemplates    map[int64]fin.EntryTemplate
+	LockDates         fin.LockDates
+	BankAccounts      fin.BankAccounts
+	ExpenseCategories map[int64]categories.CatDbRow
+	IncomeCategories  map[int64]categories.CatDbRow
+	FitIds            map[FitId]bool
+	lastIds           map[string]int64
+}
+
+// NewId returns a new id for a row in table. Like an autoincrement
+// column, NewId never returns the same id twice for the same table.
+func (d *Data) NewId(table string) int64 {
+	d.lastIds[table]++
+	return d.lastIds[table]
+}
+
+func newData() *Data {
+	return &Data{
+		Accounts:          make(map[int64]fin.Account),
+		Entries:           make(map[int64]fin.TrashedEntry),
+		RecurringEntries:  make(map[int64]fin.RecurringEntry),
+		Users:             make(map[int64]fin.User),
+		ExchangeRates:     make(map[int64]fin.ExchangeRate),
+		Attachments:       make(map[int64]fin.Attachment),
+		BudgetItems:       make(map[int64]fin.BudgetItem),
+		EntryRevisions:    make(map[int64]fin.EntryRevision),
+		Statements:        make(map[int64]fin.Statement),
+		EntryTemplates:    make(map[int64]fin.EntryTemplate),
+		LockDates:         make(fin.LockDates),
+		BankAccounts:      make(fin.BankAccounts),
+		ExpenseCategories: make(map[int64]categories.CatDbRow),
+		IncomeCategories:  make(map[int64]categories.CatDbRow),
+		FitIds:            make(map[FitId]bool),
+		lastIds:           make(map[string]int64)}
+}
+
+func (d *Data) clone() *Data {
+	result := newData()
+	for k, v := range d.Accounts {
+		result.Accounts[k] = v
+	}
+	for k, v := range d.Entries {
+		result.Entries[k] = v
+	}
+	for k, v := range d.RecurringEntries {
+		result.RecurringEntries[k] = v
+	}
+	for k, v := range d.Users {
+		result.Users[k] = v
+	}
+	for k, v := range d.ExchangeRates {
+		result.ExchangeRates[k] = v
+	}
+	for k, v := range d.Attachments {
+		result.Attachments[k] = v
+	}
+	for k, v := range d.BudgetItems {
+		result.BudgetItems[k] = v
+	}
+	for k, v := range d.EntryRevisions {
+		result.EntryRevisions[k] = v
+	}
+	for k, v := range d.Statements {
+		result.Statements[k] = v
+	}
+	for k, v := range d.EntryTemplates {
+		result.EntryTemplates[k] = v
+	}
+	for k, v := range d.LockDates {
+		result.LockDates[k] = v
+	}
+	for k, v := range d.BankAccounts {
+		result.BankAccounts[k] = v
+	}
+	for k, v := range d.ExpenseCategories {
+		result.ExpenseCategories[k] = v
+	}
+	for k, v := range d.IncomeCategories {
+		result.IncomeCategories[k] = v
+	}
+	for k, v := range d.FitIds {
+		result.FitIds[k] = v
+	}
+	for k, v := range d.lastIds {
+		result.lastIds[k] = v
+	}
+	return result
+}
+
+// Action represents some action against an in-memory database.
+type Action func(data *Data) error
+
+// Db is an in-memory database. Multiple goroutines can safely share
+// the same Db. Db also provides transactional behavior.
+type Db struct {
+	mutex sync.Mutex
+	data  *Data
+}
+
+// New creates a new, empty Db.
+func New() *Db {
+	return &Db{data: newData()}
+}
+
+// Do performs action within a transaction. If action returns an error,
+// Do discards the changes action made.
+func (d *Db) Do(action Action) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	data := d.data.clone()
+	if err := action(data); err != nil {
+		return err
+	}
+	d.data = data
+	return nil
+}
+
+func NewDoer(db *Db) db.Doer {
+	return genericDoer{db}
+}
+
+// NewDataDoer returns a Doer that does actions directly against data
+// within the current transaction.
+func NewDataDoer(data *Data) Doer {
+	return simpleDoer{data}
+}
+
+// Doer does an Action against an in-memory database
+type Doer interface {
+	Do(Action) error
+}
+
+// If t is not nil, converts t to a Doer. Otherwise
+// returns db as the Doer.
+func ToDoer(db Doer, t db.Transaction) Doer {
+	if t == nil {
+		return db
+	}
+	return t.(Doer)
+}
+
+type genericDoer struct {
+	db *Db
+}
+
+func (g genericDoer) Do(action db.Action) error {
+	return g.db.Do(func(data *Data) error {
+		return action(simpleDoer{data})
+	})
+}
+
+type simpleDoer struct {
+	data *Data
+}
+
+func (s simpleDoer) Do(a Action) error {
+	return a(s.data)
+}
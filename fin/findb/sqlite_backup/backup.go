@@ -0,0 +1,526 @@
+// Package sqlite_backup takes and restores snapshots of a ledger sqlite
+// database while it is in use. Snapshots are optionally compressed and
+// encrypted.
+package sqlite_backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/keep94/finance/fin/findb/sqlite_setup"
+	"github.com/keep94/gosqlite/sqlite"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Snapshot file names are kPrefix followed by the time in kTimeFormat
+	// followed by one of kExtensions.
+	kPrefix     = "ledger-"
+	kTimeFormat = "20060102-150405"
+
+	kSqliteExt  = ".db"
+	kGzipExt    = ".gz"
+	kEncryptExt = ".enc"
+
+	// Encrypted snapshots start with kEncryptedMagic followed by the salt
+	// and the nonce.
+	kEncryptedMagic = "LEDGERENC1"
+	kSaltSize       = 16
+	kKeySize        = 32
+
+	// scrypt parameters recommended for interactive logins
+	kScryptN = 32768
+	kScryptR = 8
+	kScryptP = 1
+
+	// How long to wait before trying again when the database is locked.
+	kBusyWait = 100 * time.Millisecond
+)
+
+var (
+	// How long to keep trying when the database is locked. A variable so
+	// that tests can shorten it.
+	kMaxBusyWait = 30 * time.Second
+)
+
+var (
+	kExtensions = []string{
+		kSqliteExt,
+		kSqliteExt + kGzipExt,
+		kSqliteExt + kEncryptExt,
+		kSqliteExt + kGzipExt + kEncryptExt,
+	}
+	kGzipMagic   = []byte{0x1f, 0x8b}
+	kSqliteMagic = []byte("SQLite format 3\x00")
+)
+
+var (
+	NeedPassphrase  = errors.New("sqlite_backup: Snapshot is encrypted. Passphrase needed.")
+	WrongPassphrase = errors.New("sqlite_backup: Wrong passphrase or snapshot corrupted.")
+	NotADatabase    = errors.New("sqlite_backup: Snapshot is not a sqlite database.")
+	NotALedger      = errors.New("sqlite_backup: Snapshot is not a ledger database.")
+	NewerSchema     = errors.New("sqlite_backup: Snapshot has a newer schema than this program supports.")
+	DatabaseLocked  = errors.New("sqlite_backup: Database stayed locked. Try again later.")
+)
+
+// Options controls how snapshots are written.
+type Options struct {
+	// If true, snapshots are gzip compressed.
+	Compress bool
+
+	// If non-empty, snapshots are encrypted with this passphrase.
+	Passphrase string
+}
+
+func (o *Options) extension() string {
+	result := kSqliteExt
+	if o.Compress {
+		result += kGzipExt
+	}
+	if o.Passphrase != "" {
+		result += kEncryptExt
+	}
+	return result
+}
+
+// Copy is a copy of a database in a temporary file. Taking a copy is
+// quick while compressing and encrypting it is not, so callers that hold
+// the only connection to a database should take a copy, give up the
+// connection, and then save the copy.
+type Copy struct {
+	path string
+}
+
+// NewCopy copies the main database of conn to a temporary file. conn may
+// be in a transaction and other connections may use the database while
+// NewCopy runs. NewCopy returns DatabaseLocked if the database stays
+// locked too long. Caller must call Close on the returned Copy.
+func NewCopy(conn *sqlite.Conn) (*Copy, error) {
+	file, err := ioutil.TempFile("", "ledgerbackup")
+	if err != nil {
+		return nil, err
+	}
+	result := &Copy{path: file.Name()}
+	if err = file.Close(); err == nil {
+		err = result.copyFrom(conn)
+	}
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *Copy) copyFrom(conn *sqlite.Conn) error {
+	dst, err := sqlite.Open(c.path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	return copyDb(dst, conn)
+}
+
+// Snapshot writes this copy to w as a snapshot. options may be nil.
+func (c *Copy) Snapshot(w io.Writer, options *Options) error {
+	if options == nil {
+		options = &Options{}
+	}
+	raw, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	if options.Compress {
+		if raw, err = compress(raw); err != nil {
+			return err
+		}
+	}
+	if options.Passphrase != "" {
+		if raw, err = encrypt(raw, options.Passphrase); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(raw)
+	return err
+}
+
+// Save writes this copy as a snapshot to a new file in dir and then
+// removes all but the newest keep snapshots in dir. keep of 0 or less
+// means remove nothing. options may be nil. Save returns the path of the
+// new snapshot.
+func (c *Copy) Save(dir string, keep int, options *Options) (
+	path string, err error) {
+	if options == nil {
+		options = &Options{}
+	}
+	path = filepath.Join(
+		dir,
+		kPrefix+time.Now().UTC().Format(kTimeFormat)+options.extension())
+	if _, err = os.Stat(path); err == nil {
+		return "", fmt.Errorf("sqlite_backup: %s already exists.", path)
+	}
+	if err = writeFile(path, func(w io.Writer) error {
+		return c.Snapshot(w, options)
+	}); err != nil {
+		return "", err
+	}
+	if keep > 0 {
+		err = rotate(dir, keep)
+	}
+	return
+}
+
+// Close removes the temporary file holding this copy.
+func (c *Copy) Close() error {
+	return os.Remove(c.path)
+}
+
+// Snapshot writes a consistent snapshot of the main database of conn to w.
+// options may be nil. conn may be in a transaction and other connections
+// may use the database while Snapshot runs. Snapshot uses conn until it
+// returns; to give up conn sooner, use NewCopy.
+func Snapshot(conn *sqlite.Conn, w io.Writer, options *Options) error {
+	c, err := NewCopy(conn)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.Snapshot(w, options)
+}
+
+// Save writes a snapshot of the main database of conn to a new file in
+// dir and then removes all but the newest keep snapshots in dir. keep of
+// 0 or less means remove nothing. Save returns the path of the new
+// snapshot. Save uses conn until it returns; to give up conn sooner, use
+// NewCopy.
+func Save(conn *sqlite.Conn, dir string, keep int, options *Options) (
+	path string, err error) {
+	c, err := NewCopy(conn)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	return c.Save(dir, keep, options)
+}
+
+// List returns the paths of the snapshots in dir, newest first.
+func List(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, info := range infos {
+		if !info.IsDir() && isSnapshotName(info.Name()) {
+			result = append(result, filepath.Join(dir, info.Name()))
+		}
+	}
+	// Snapshot names sort by time.
+	sort.Sort(sort.Reverse(sort.StringSlice(result)))
+	return result, nil
+}
+
+// Check reads a snapshot from r and verifies that it is an intact ledger
+// database that this program can use. passphrase is needed only if the
+// snapshot is encrypted. On success, Check returns the schema version of
+// the snapshot.
+func Check(r io.Reader, passphrase string) (version int, err error) {
+	err = withSnapshot(r, passphrase, func(snapshot *sqlite.Conn) (err error) {
+		version, err = check(snapshot)
+		return
+	})
+	return
+}
+
+// Restore reads a snapshot from r and copies it over the main database of
+// conn. Restore returns an error and leaves the database alone if the
+// snapshot fails the same checks that Check does. conn must not be in a
+// transaction. Other connections to the database should be closed since
+// they may have cached what Restore replaces. On success, Restore returns
+// the schema version of the snapshot which may be older than the latest
+// version.
+func Restore(conn *sqlite.Conn, r io.Reader, passphrase string) (
+	version int, err error) {
+	err = withSnapshot(r, passphrase, func(snapshot *sqlite.Conn) (err error) {
+		if version, err = check(snapshot); err != nil {
+			return
+		}
+		return copyDb(conn, snapshot)
+	})
+	return
+}
+
+// withSnapshot decodes the snapshot in r into a temporary file and calls f
+// with a connection to it.
+func withSnapshot(
+	r io.Reader, passphrase string, f func(snapshot *sqlite.Conn) error) error {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if raw, err = decode(raw, passphrase); err != nil {
+		return err
+	}
+	return withTempDb(raw, func(path string, snapshot *sqlite.Conn) error {
+		return f(snapshot)
+	})
+}
+
+// check returns the schema version of snapshot or an error if snapshot
+// is damaged or is not a ledger database this program can use.
+func check(snapshot *sqlite.Conn) (int, error) {
+	if err := integrityCheck(snapshot); err != nil {
+		return 0, err
+	}
+	version, err := sqlite_setup.Version(snapshot)
+	if err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, NotALedger
+	}
+	if version > sqlite_setup.LatestVersion() {
+		return 0, NewerSchema
+	}
+	return version, nil
+}
+
+func integrityCheck(conn *sqlite.Conn) error {
+	stmt, err := conn.Prepare("pragma integrity_check")
+	if err != nil {
+		return err
+	}
+	defer stmt.Finalize()
+	if err = stmt.Exec(); err != nil {
+		return err
+	}
+	var problems []string
+	for stmt.Next() {
+		var message string
+		if err = stmt.Scan(&message); err != nil {
+			return err
+		}
+		if message != "ok" {
+			problems = append(problems, message)
+		}
+	}
+	if err = stmt.Error(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf(
+			"sqlite_backup: Integrity check failed: %s",
+			strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// copyDb copies the main database of src over the main database of dst
+// using the sqlite backup API. The copy happens in one step so that it is
+// consistent even if other connections change src. copyDb returns
+// DatabaseLocked if src or dst stays locked longer than kMaxBusyWait.
+func copyDb(dst, src *sqlite.Conn) error {
+	backup, err := sqlite.NewBackup(dst, "main", src, "main")
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+	deadline := time.Now().Add(kMaxBusyWait)
+	for {
+		// Step returns nil both on success and when the database is locked.
+		err := backup.Step(-1)
+		if err == sqlite.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return DatabaseLocked
+		}
+		time.Sleep(kBusyWait)
+	}
+}
+
+// withTempDb calls f with the path of a new temporary database file
+// holding contents and a connection to it.
+func withTempDb(
+	contents []byte, f func(path string, conn *sqlite.Conn) error) error {
+	file, err := ioutil.TempFile("", "ledgerbackup")
+	if err != nil {
+		return err
+	}
+	path := file.Name()
+	defer os.Remove(path)
+	_, err = file.Write(contents)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	conn, err := sqlite.Open(path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return f(path, conn)
+}
+
+// writeFile writes path through a temporary file in the same directory so
+// that path never holds a partial snapshot.
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), "ledgerbackup")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// rotate removes all but the newest keep snapshots in dir.
+func rotate(dir string, keep int) error {
+	paths, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for len(paths) > keep {
+		if err := os.Remove(paths[len(paths)-1]); err != nil {
+			return err
+		}
+		paths = paths[:len(paths)-1]
+	}
+	return nil
+}
+
+func isSnapshotName(name string) bool {
+	if !strings.HasPrefix(name, kPrefix) {
+		return false
+	}
+	name = name[len(kPrefix):]
+	if len(name) < len(kTimeFormat) {
+		return false
+	}
+	if _, err := time.Parse(kTimeFormat, name[:len(kTimeFormat)]); err != nil {
+		return false
+	}
+	ext := name[len(kTimeFormat):]
+	for _, e := range kExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// decode undoes the encryption and compression of a snapshot.
+func decode(raw []byte, passphrase string) (result []byte, err error) {
+	if bytes.HasPrefix(raw, []byte(kEncryptedMagic)) {
+		if passphrase == "" {
+			return nil, NeedPassphrase
+		}
+		if raw, err = decrypt(raw, passphrase); err != nil {
+			return
+		}
+	}
+	if bytes.HasPrefix(raw, kGzipMagic) {
+		if raw, err = decompress(raw); err != nil {
+			return
+		}
+	}
+	if !bytes.HasPrefix(raw, kSqliteMagic) {
+		return nil, NotADatabase
+	}
+	return raw, nil
+}
+
+func compress(raw []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w := gzip.NewWriter(&buffer)
+	if _, err := w.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func decompress(raw []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func encrypt(raw []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, kSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	result := append([]byte(kEncryptedMagic), salt...)
+	result = append(result, nonce...)
+	return aead.Seal(result, nonce, raw, nil), nil
+}
+
+func decrypt(raw []byte, passphrase string) ([]byte, error) {
+	raw = raw[len(kEncryptedMagic):]
+	if len(raw) < kSaltSize {
+		return nil, WrongPassphrase
+	}
+	aead, err := newAEAD(passphrase, raw[:kSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	raw = raw[kSaltSize:]
+	if len(raw) < aead.NonceSize() {
+		return nil, WrongPassphrase
+	}
+	result, err := aead.Open(
+		nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, WrongPassphrase
+	}
+	return result, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(
+		[]byte(passphrase), salt, kScryptN, kScryptR, kScryptP, kKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,254 @@
+package sqlite_backup
+
+import (
+	"bytes"
+	"github.com/keep94/finance/fin"
+	"github.com/keep94/finance/fin/findb/for_sqlite"
+	"github.com/keep94/finance/fin/findb/sqlite_setup"
+	"github.com/keep94/gosqlite/sqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	for _, options := range []*Options{
+		nil,
+		{Compress: true},
+		{Passphrase: "secret"},
+		{Compress: true, Passphrase: "secret"},
+	} {
+		src := openLedger(t)
+		defer src.Close()
+		addAccount(t, src, "checking")
+		var snapshot bytes.Buffer
+		if err := Snapshot(src, &snapshot, options); err != nil {
+			t.Fatalf("Error taking snapshot: %v", err)
+		}
+		passphrase := ""
+		if options != nil {
+			passphrase = options.Passphrase
+		}
+		dst := openLedger(t)
+		defer dst.Close()
+		addAccount(t, dst, "savings")
+		version, err := Restore(dst, &snapshot, passphrase)
+		if err != nil {
+			t.Fatalf("Error restoring with %+v: %v", options, err)
+		}
+		if version != sqlite_setup.LatestVersion() {
+			t.Errorf("Expected version %d, got %d", sqlite_setup.LatestVersion(), version)
+		}
+		verifyAccountName(t, dst, "checking")
+	}
+}
+
+func TestRestorePassphrase(t *testing.T) {
+	src := openLedger(t)
+	defer src.Close()
+	addAccount(t, src, "checking")
+	var snapshot bytes.Buffer
+	if err := Snapshot(src, &snapshot, &Options{Passphrase: "secret"}); err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	dst := openLedger(t)
+	defer dst.Close()
+	addAccount(t, dst, "savings")
+	if _, err := Restore(dst, bytes.NewReader(snapshot.Bytes()), ""); err != NeedPassphrase {
+		t.Errorf("Expected NeedPassphrase, got %v", err)
+	}
+	if _, err := Restore(dst, bytes.NewReader(snapshot.Bytes()), "wrong"); err != WrongPassphrase {
+		t.Errorf("Expected WrongPassphrase, got %v", err)
+	}
+	verifyAccountName(t, dst, "savings")
+}
+
+func TestCheck(t *testing.T) {
+	if _, err := Check(bytes.NewReader([]byte("not a database")), ""); err != NotADatabase {
+		t.Errorf("Expected NotADatabase, got %v", err)
+	}
+	empty := openConn(t)
+	defer empty.Close()
+	if err := empty.Exec("create table a (id INTEGER)"); err != nil {
+		t.Fatalf("Error creating table: %v", err)
+	}
+	if _, err := Check(snapshotOf(t, empty), ""); err != NotALedger {
+		t.Errorf("Expected NotALedger, got %v", err)
+	}
+	newer := openLedger(t)
+	defer newer.Close()
+	err := newer.Exec(
+		"update schema_version set version = ?",
+		sqlite_setup.LatestVersion()+1)
+	if err != nil {
+		t.Fatalf("Error changing schema version: %v", err)
+	}
+	if _, err := Check(snapshotOf(t, newer), ""); err != NewerSchema {
+		t.Errorf("Expected NewerSchema, got %v", err)
+	}
+	dst := openLedger(t)
+	defer dst.Close()
+	addAccount(t, dst, "savings")
+	if _, err := Restore(dst, snapshotOf(t, newer), ""); err != NewerSchema {
+		t.Errorf("Expected NewerSchema, got %v", err)
+	}
+	verifyAccountName(t, dst, "savings")
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite_backup_test")
+	if err != nil {
+		t.Fatalf("Error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	oldNames := []string{
+		"ledger-20200101-000000.db",
+		"ledger-20200102-000000.db.gz",
+		"ledger-20200103-000000.db.gz.enc",
+	}
+	for _, name := range append(oldNames, "notes.txt") {
+		writeDummyFile(t, filepath.Join(dir, name))
+	}
+	conn := openLedger(t)
+	defer conn.Close()
+	addAccount(t, conn, "checking")
+	options := &Options{Compress: true}
+	path, err := Save(conn, dir, 2, options)
+	if err != nil {
+		t.Fatalf("Error saving: %v", err)
+	}
+	paths, err := List(dir)
+	if err != nil {
+		t.Fatalf("Error listing: %v", err)
+	}
+	expected := []string{path, filepath.Join(dir, oldNames[2])}
+	if !reflect.DeepEqual(expected, paths) {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Expected notes.txt to remain: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening snapshot: %v", err)
+	}
+	defer f.Close()
+	if _, err := Check(f, ""); err != nil {
+		t.Errorf("Expected saved snapshot to pass check: %v", err)
+	}
+}
+
+func TestCopyAndSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite_backup_test")
+	if err != nil {
+		t.Fatalf("Error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	conn := openLedger(t)
+	addAccount(t, conn, "checking")
+	c, err := NewCopy(conn)
+	if err != nil {
+		t.Fatalf("Error copying: %v", err)
+	}
+	defer c.Close()
+	// The copy no longer needs the database.
+	conn.Close()
+	path, err := c.Save(dir, 0, &Options{Compress: true, Passphrase: "secret"})
+	if err != nil {
+		t.Fatalf("Error saving: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening snapshot: %v", err)
+	}
+	defer f.Close()
+	dst := openLedger(t)
+	defer dst.Close()
+	if _, err := Restore(dst, f, "secret"); err != nil {
+		t.Fatalf("Error restoring: %v", err)
+	}
+	verifyAccountName(t, dst, "checking")
+}
+
+func TestCopyLocked(t *testing.T) {
+	oldMaxBusyWait := kMaxBusyWait
+	kMaxBusyWait = 300 * time.Millisecond
+	defer func() { kMaxBusyWait = oldMaxBusyWait }()
+	dir, err := ioutil.TempDir("", "sqlite_backup_test")
+	if err != nil {
+		t.Fatalf("Error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ledger.db")
+	conn, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer conn.Close()
+	if err := sqlite_setup.SetUpTables(conn); err != nil {
+		t.Fatalf("Error creating tables: %v", err)
+	}
+	locker, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer locker.Close()
+	if err := locker.Exec("begin exclusive"); err != nil {
+		t.Fatalf("Error locking database: %v", err)
+	}
+	defer locker.Exec("rollback")
+	if _, err := NewCopy(conn); err != DatabaseLocked {
+		t.Errorf("Expected DatabaseLocked, got %v", err)
+	}
+}
+
+func addAccount(t *testing.T, conn *sqlite.Conn, name string) {
+	err := for_sqlite.ConnNew(conn).AddAccount(
+		nil, &fin.Account{Name: name, Active: true})
+	if err != nil {
+		t.Fatalf("Error adding account: %v", err)
+	}
+}
+
+func verifyAccountName(t *testing.T, conn *sqlite.Conn, name string) {
+	var account fin.Account
+	if err := for_sqlite.ConnNew(conn).AccountById(nil, 1, &account); err != nil {
+		t.Fatalf("Error reading account: %v", err)
+	}
+	if account.Name != name {
+		t.Errorf("Expected account %s, got %s", name, account.Name)
+	}
+}
+
+func snapshotOf(t *testing.T, conn *sqlite.Conn) *bytes.Buffer {
+	var result bytes.Buffer
+	if err := Snapshot(conn, &result, nil); err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	return &result
+}
+
+func writeDummyFile(t *testing.T, path string) {
+	if err := ioutil.WriteFile(path, []byte(path), 0600); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+}
+
+func openLedger(t *testing.T) *sqlite.Conn {
+	conn := openConn(t)
+	if err := sqlite_setup.SetUpTables(conn); err != nil {
+		t.Fatalf("Error creating tables: %v", err)
+	}
+	return conn
+}
+
+func openConn(t *testing.T) *sqlite.Conn {
+	conn, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	return conn
+}
@@ -0,0 +1,152 @@
+package sqlite_setup
+
+import (
+	"fmt"
+	"github.com/keep94/gosqlite/sqlite"
+)
+
+const (
+	kSQLSchemaVersion       = "select version from schema_version"
+	kSQLClearSchemaVersion  = "delete from schema_version"
+	kSQLInsertSchemaVersion = "insert into schema_version (version) values (?)"
+)
+
+// Migration is one step in evolving the schema of a database.
+type Migration struct {
+	// The schema version of the database after this migration
+	Version int
+
+	// What this migration does
+	Description string
+
+	run func(conn *sqlite.Conn) error
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%d: %s", m.Version, m.Description)
+}
+
+// kMigrations are in order of version. Never change or remove a migration
+// once released; add a new one instead. Migrations that predate schema
+// versions check what already exists because older databases may be
+// partly set up.
+var kMigrations = []*Migration{
+	{
+		Version:     1,
+		Description: "Create tables",
+		run:         createTables,
+	},
+	{
+		Version:     2,
+		Description: "Add full text index of entry names, descriptions and check numbers",
+		run:         createSearchIndex,
+	},
+	{
+		Version:     3,
+		Description: "Add entry_lines table for finding entries by account and category",
+		run:         createEntryLines,
+	},
+	{
+		Version:     4,
+		Description: "Add bank_accounts table mapping bank accounts to accounts",
+		run:         createBankAccounts,
+	},
+	{
+		Version:     5,
+		Description: "Store amounts in entry_lines in the currency of their account",
+		run:         rebuildEntryLines,
+	},
+}
+
+// LatestVersion returns the schema version that Migrate brings databases
+// to.
+func LatestVersion() int {
+	return kMigrations[len(kMigrations)-1].Version
+}
+
+// Version returns the schema version of a database. Version returns 0 for
+// a new database or one that predates schema versions.
+func Version(conn *sqlite.Conn) (int, error) {
+	count, err := queryCount(conn, kSQLTableCount, "schema_version")
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	stmt, err := conn.Prepare(kSQLSchemaVersion)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Finalize()
+	if err = stmt.Exec(); err != nil {
+		return 0, err
+	}
+	if !stmt.Next() {
+		return 0, stmt.Error()
+	}
+	var version int
+	if err = stmt.Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// PendingMigrations returns the migrations that Migrate would apply to a
+// database in the order it would apply them.
+func PendingMigrations(conn *sqlite.Conn) ([]*Migration, error) {
+	version, err := Version(conn)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Migration
+	for _, migration := range kMigrations {
+		if migration.Version > version {
+			result = append(result, migration)
+		}
+	}
+	return result, nil
+}
+
+// Migrate applies the pending migrations of a database in order. Each
+// migration runs in its own savepoint along with the change to the
+// schema version. If a migration fails, Migrate rolls back just that
+// migration, so when Migrate is not called within a transaction, the
+// database stays at the version of the last migration that succeeded.
+func Migrate(conn *sqlite.Conn) error {
+	migrations, err := PendingMigrations(conn)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if err := migrate(conn, migration); err != nil {
+			return fmt.Errorf(
+				"sqlite_setup: Migration %v failed: %v", migration, err)
+		}
+	}
+	return nil
+}
+
+func migrate(conn *sqlite.Conn, migration *Migration) error {
+	if err := conn.Exec("savepoint migration"); err != nil {
+		return err
+	}
+	err := migration.run(conn)
+	if err == nil {
+		err = setVersion(conn, migration.Version)
+	}
+	if err != nil {
+		conn.Exec("rollback to migration")
+		conn.Exec("release migration")
+		return err
+	}
+	return conn.Exec("release migration")
+}
+
+func setVersion(conn *sqlite.Conn, version int) error {
+	err := conn.Exec("create table if not exists schema_version (version INTEGER)")
+	if err != nil {
+		return err
+	}
+	if err = conn.Exec(kSQLClearSchemaVersion); err != nil {
+		return err
+	}
+	return conn.Exec(kSQLInsertSchemaVersion, version)
+}
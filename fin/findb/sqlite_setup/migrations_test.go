@@ -0,0 +1,90 @@
+package sqlite_setup
+
+import (
+	"errors"
+	"github.com/keep94/gosqlite/sqlite"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	conn := openConn(t)
+	defer conn.Close()
+	verifyVersion(t, conn, 0)
+	pending, err := PendingMigrations(conn)
+	if err != nil {
+		t.Fatalf("Error getting pending migrations: %v", err)
+	}
+	if len(pending) != len(kMigrations) {
+		t.Errorf("Expected %d pending migrations, got %d", len(kMigrations), len(pending))
+	}
+	if err := Migrate(conn); err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+	verifyVersion(t, conn, LatestVersion())
+	pending, err = PendingMigrations(conn)
+	if err != nil {
+		t.Fatalf("Error getting pending migrations: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending migrations, got %v", pending)
+	}
+	// Migrating again does nothing
+	if err := Migrate(conn); err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+	verifyVersion(t, conn, LatestVersion())
+}
+
+func TestMigrateFailure(t *testing.T) {
+	conn := openConn(t)
+	defer conn.Close()
+	oldMigrations := kMigrations
+	defer func() { kMigrations = oldMigrations }()
+	kMigrations = []*Migration{
+		{
+			Version:     1,
+			Description: "Create a table",
+			run: func(conn *sqlite.Conn) error {
+				return conn.Exec("create table a (id INTEGER)")
+			},
+		},
+		{
+			Version:     2,
+			Description: "Fail after creating a table",
+			run: func(conn *sqlite.Conn) error {
+				if err := conn.Exec("create table b (id INTEGER)"); err != nil {
+					return err
+				}
+				return errors.New("Failed")
+			},
+		},
+	}
+	if err := Migrate(conn); err == nil {
+		t.Error("Expected migration to fail")
+	}
+	verifyVersion(t, conn, 1)
+	if count, _ := queryCount(conn, kSQLTableCount, "a"); count != 1 {
+		t.Error("Expected table a to exist")
+	}
+	if count, _ := queryCount(conn, kSQLTableCount, "b"); count != 0 {
+		t.Error("Expected table b to be rolled back")
+	}
+}
+
+func openConn(t *testing.T) *sqlite.Conn {
+	conn, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	return conn
+}
+
+func verifyVersion(t *testing.T, conn *sqlite.Conn, expected int) {
+	version, err := Version(conn)
+	if err != nil {
+		t.Fatalf("Error getting version: %v", err)
+	}
+	if version != expected {
+		t.Errorf("Expected version %d, got %d", expected, version)
+	}
+}
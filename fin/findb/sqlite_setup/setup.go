@@ -2,16 +2,44 @@
 package sqlite_setup
 
 import (
+	"fmt"
+	"github.com/keep94/finance/fin"
 	"github.com/keep94/gosqlite/sqlite"
+	"strconv"
+	"strings"
 )
 
-// SetUpTables creates all needed tables in database.
+const (
+	kSQLColumnCount = "select count(*) from pragma_table_info(?) where name = ?"
+	kSQLTableCount  = "select count(*) from sqlite_master where name = ?"
+)
+
+// SetUpTables brings a database up to the latest schema version by
+// applying all pending migrations. See Migrate.
 func SetUpTables(conn *sqlite.Conn) error {
-	err := conn.Exec("create table if not exists accounts (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, is_active INTEGER, balance INTEGER, reconciled INTEGER, b_count INTEGER, r_count INTEGER, import_sd TEXT)")
+	return Migrate(conn)
+}
+
+// createTables creates the tables that existed before schema versions.
+// It adds any columns that older databases are missing.
+func createTables(conn *sqlite.Conn) error {
+	err := conn.Exec("create table if not exists accounts (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, is_active INTEGER, balance INTEGER, reconciled INTEGER, b_count INTEGER, r_count INTEGER, import_sd TEXT, currency TEXT NOT NULL DEFAULT '', acct_type INTEGER NOT NULL DEFAULT 0)")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "accounts", "currency", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "accounts", "acct_type", "INTEGER NOT NULL DEFAULT 0")
 	if err != nil {
 		return err
 	}
-	err = conn.Exec("create table if not exists entries (id INTEGER PRIMARY KEY AUTOINCREMENT, date TEXT, name TEXT, cats TEXT, payment TEXT, desc TEXT, check_no TEXT, reviewed INTEGER)")
+	err = conn.Exec("create table if not exists entries (id INTEGER PRIMARY KEY AUTOINCREMENT, date TEXT, name TEXT, cats TEXT, payment TEXT, desc TEXT, check_no TEXT, reviewed INTEGER, trashed INTEGER NOT NULL DEFAULT 0)")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "entries", "trashed", "INTEGER NOT NULL DEFAULT 0")
 	if err != nil {
 		return err
 	}
@@ -19,7 +47,47 @@ func SetUpTables(conn *sqlite.Conn) error {
 	if err != nil {
 		return err
 	}
-	err = conn.Exec("create table if not exists recurring_entries (id INTEGER PRIMARY KEY AUTOINCREMENT, date TEXT, name TEXT, cats TEXT, payment TEXT, desc TEXT, check_no TEXT, reviewed INTEGER, count INTEGER, unit INTEGER, num_left INTEGER, day_of_month INTEGER)")
+	err = conn.Exec("create table if not exists recurring_entries (id INTEGER PRIMARY KEY AUTOINCREMENT, date TEXT, name TEXT, cats TEXT, payment TEXT, desc TEXT, check_no TEXT, reviewed INTEGER, count INTEGER, unit INTEGER, num_left INTEGER, day_of_month INTEGER, day_of_month2 INTEGER NOT NULL DEFAULT 0, weekday INTEGER NOT NULL DEFAULT 0, week_of_month INTEGER NOT NULL DEFAULT 0, end_date TEXT NOT NULL DEFAULT '', adjustment INTEGER NOT NULL DEFAULT 0, estimated INTEGER NOT NULL DEFAULT 0, esc_amount INTEGER NOT NULL DEFAULT 0, esc_percent INTEGER NOT NULL DEFAULT 0, esc_every INTEGER NOT NULL DEFAULT 0, esc_count INTEGER NOT NULL DEFAULT 0)")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "day_of_month2", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "weekday", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "week_of_month", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "end_date", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "adjustment", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "estimated", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "esc_amount", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "esc_percent", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "esc_every", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing(conn, "recurring_entries", "esc_count", "INTEGER NOT NULL DEFAULT 0")
 	if err != nil {
 		return err
 	}
@@ -47,5 +115,259 @@ func SetUpTables(conn *sqlite.Conn) error {
 	if err != nil {
 		return err
 	}
+	err = conn.Exec("create table if not exists exchange_rates (id INTEGER PRIMARY KEY AUTOINCREMENT, date TEXT, from_currency TEXT, to_currency TEXT, rate REAL)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create index if not exists exchange_rates_date_idx on exchange_rates (date, id)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create table if not exists budget_items (id INTEGER PRIMARY KEY AUTOINCREMENT, cat TEXT, amount INTEGER, period INTEGER, rollover INTEGER, start TEXT)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create unique index if not exists budget_items_cat_idx on budget_items (cat)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create table if not exists tags (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create unique index if not exists tags_name_idx on tags (name)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create table if not exists entry_tags (entry_id INTEGER, tag_id INTEGER)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create unique index if not exists entry_tags_entry_id_tag_id_idx on entry_tags (entry_id, tag_id)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create index if not exists entry_tags_tag_id_idx on entry_tags (tag_id)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create table if not exists attachments (id INTEGER PRIMARY KEY AUTOINCREMENT, entry_id INTEGER, name TEXT, content_type TEXT, size INTEGER, data BLOB)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create index if not exists attachments_entry_id_idx on attachments (entry_id)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create table if not exists entry_history (id INTEGER PRIMARY KEY AUTOINCREMENT, entry_id INTEGER, user_id INTEGER, ts INTEGER, before TEXT, after TEXT)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create index if not exists entry_history_entry_id_idx on entry_history (entry_id)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create table if not exists statements (id INTEGER PRIMARY KEY AUTOINCREMENT, acct_id INTEGER, date TEXT, balance INTEGER, entry_ids TEXT, finished INTEGER)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create index if not exists statements_acct_id_date_idx on statements (acct_id, date)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create table if not exists entry_templates (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT, name TEXT, desc TEXT, check_no TEXT, cats TEXT, payment TEXT, tags TEXT, percents TEXT)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create table if not exists lock_dates (acct_id INTEGER PRIMARY KEY, date TEXT)")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// createSearchIndex creates the full text index of entries and indexes
+// the entries already in the database.
+func createSearchIndex(conn *sqlite.Conn) error {
+	count, err := queryCount(conn, kSQLTableCount, "entry_search")
+	if err != nil || count > 0 {
+		return err
+	}
+	err = conn.Exec("create virtual table entry_search using fts5(name, desc, check_no, prefix='2 3')")
+	if err != nil {
+		return err
+	}
+	return conn.Exec("insert into entry_search (rowid, name, desc, check_no) select id, name, desc, check_no from entries")
+}
+
+// createEntryLines creates the entry_lines table which has one row for
+// each line item and payment of each entry and adds the lines of the
+// entries already in the database.
+func createEntryLines(conn *sqlite.Conn) error {
+	count, err := queryCount(conn, kSQLTableCount, "entry_lines")
+	if err != nil || count > 0 {
+		return err
+	}
+	err = conn.Exec("create table entry_lines (entry_id INTEGER, cat_type INTEGER, cat_id INTEGER, amount INTEGER, reconciled INTEGER)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create index entry_lines_cat_idx on entry_lines (cat_type, cat_id, reconciled, entry_id)")
+	if err != nil {
+		return err
+	}
+	err = conn.Exec("create index entry_lines_entry_id_idx on entry_lines (entry_id)")
+	if err != nil {
+		return err
+	}
+	return insertEntryLines(conn)
+}
+
+// rebuildEntryLines replaces the rows of the entry_lines table with the
+// lines of the entries in the database. Earlier versions stored the
+// amounts of cross-currency lines in the currency of the payment account.
+func rebuildEntryLines(conn *sqlite.Conn) error {
+	if err := conn.Exec("delete from entry_lines"); err != nil {
+		return err
+	}
+	return insertEntryLines(conn)
+}
+
+// insertEntryLines adds the lines of the entries in the database to the
+// entry_lines table.
+func insertEntryLines(conn *sqlite.Conn) error {
+	lines, err := existingEntryLines(conn)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		err = conn.Exec("insert into entry_lines (entry_id, cat_type, cat_id, amount, reconciled) values (?, ?, ?, ?, ?)", line.entryId, int(line.cat.Type), line.cat.Id, line.amount, line.reconciled)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// createBankAccounts creates the bank_accounts table which maps accounts
+// at financial institutions to accounts.
+func createBankAccounts(conn *sqlite.Conn) error {
+	err := conn.Exec("create table if not exists bank_accounts (institution TEXT, bank_account_id TEXT, acct_id INTEGER)")
+	if err != nil {
+		return err
+	}
+	return conn.Exec("create unique index if not exists bank_accounts_institution_bank_account_id_idx on bank_accounts (institution, bank_account_id)")
+}
+
+type entryLine struct {
+	entryId    int64
+	cat        fin.Cat
+	amount     int64
+	reconciled bool
+}
+
+// existingEntryLines returns the lines of the entries already in the
+// database by parsing the cats and payment columns. The cats column has
+// cat|amount|reconciled for each line item where amount may be
+// amount;currency;currencyAmount. The payment column is cat|reconciled.
+// The amount of each line is in the currency of its account.
+func existingEntryLines(conn *sqlite.Conn) ([]entryLine, error) {
+	stmt, err := conn.Prepare("select id, cats, payment from entries")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Finalize()
+	if err = stmt.Exec(); err != nil {
+		return nil, err
+	}
+	var result []entryLine
+	for stmt.Next() {
+		var id int64
+		var cats, payment string
+		if err = stmt.Scan(&id, &cats, &payment); err != nil {
+			return nil, err
+		}
+		var parts []string
+		if cats != "" {
+			parts = strings.Split(cats, "|")
+		}
+		paymentParts := strings.Split(payment, "|")
+		if len(parts)%3 != 0 || len(paymentParts) != 2 {
+			return nil, fmt.Errorf(
+				"sqlite_setup: Invalid entry %d: %s %s", id, cats, payment)
+		}
+		var total int64
+		for i := 0; i < len(parts); i += 3 {
+			amountParts := strings.Split(parts[i+1], ";")
+			line, err := parseEntryLine(
+				id, parts[i], amountParts[0], parts[i+2])
+			if err != nil {
+				return nil, err
+			}
+			total -= line.amount
+			if len(amountParts) == 3 {
+				line.amount, err = strconv.ParseInt(amountParts[2], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, line)
+		}
+		line, err := parseEntryLine(id, paymentParts[0], "0", paymentParts[1])
+		if err != nil {
+			return nil, err
+		}
+		line.amount = total
+		result = append(result, line)
+	}
+	return result, stmt.Error()
+}
+
+func parseEntryLine(
+	entryId int64, catStr, amountStr, reconciledStr string) (
+	line entryLine, err error) {
+	line.entryId = entryId
+	if line.cat, err = fin.CatFromString(catStr); err != nil {
+		return
+	}
+	if line.amount, err = strconv.ParseInt(amountStr, 10, 64); err != nil {
+		return
+	}
+	reconciled, err := strconv.Atoi(reconciledStr)
+	line.reconciled = reconciled > 0
+	return
+}
+
+// addColumnIfMissing adds a column to an existing table. Tables created
+// before the column was introduced won't have it because create table
+// statements never alter existing tables.
+func addColumnIfMissing(
+	conn *sqlite.Conn, table, column, definition string) error {
+	count, err := queryCount(conn, kSQLColumnCount, table, column)
+	if err != nil || count > 0 {
+		return err
+	}
+	return conn.Exec(
+		fmt.Sprintf("alter table %s add column %s %s", table, column, definition))
+}
+
+// queryCount runs sql which selects a single count.
+func queryCount(
+	conn *sqlite.Conn, sql string, args ...interface{}) (int, error) {
+	stmt, err := conn.Prepare(sql)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Finalize()
+	if err = stmt.Exec(args...); err != nil {
+		return 0, err
+	}
+	if !stmt.Next() {
+		return 0, stmt.Error()
+	}
+	var count int
+	if err = stmt.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/keep94/finance/fin"
 	"github.com/keep94/goconsume"
 	"github.com/keep94/toolbox/db"
+	"sort"
 	"time"
 )
 
@@ -14,6 +15,10 @@ var (
 	NoSuchId         = errors.New("findb: No Such Id.")
 	WrongPassword    = errors.New("findb: Wrong password.")
 	NoPermission     = errors.New("findb: Insufficient permission.")
+	NotBalanced      = errors.New("findb: Statement does not balance.")
+	AlreadyFinished  = errors.New("findb: Statement already finished.")
+	Locked           = errors.New("findb: Entry is on or before the lock date.")
+	Trashed          = errors.New("findb: Entry is in the trash.")
 )
 
 type AccountByIdRunner interface {
@@ -42,6 +47,22 @@ type UpdateAccountImportSDRunner interface {
 		t db.Transaction, accountId int64, date time.Time) error
 }
 
+type UpdateAccountCurrencyRunner interface {
+	// UpdateAccountCurrency updates the currency of an account.
+	// UpdateAccountCurrency changes only the currency code. It does not
+	// convert the balances of the account or the amounts of its entries,
+	// so callers should change the currency only of accounts without
+	// entries.
+	UpdateAccountCurrency(
+		t db.Transaction, accountId int64, currency fin.Currency) error
+}
+
+type UpdateAccountTypeRunner interface {
+	// UpdateAccountType updates the type of an account.
+	UpdateAccountType(
+		t db.Transaction, accountId int64, accountType fin.AccountType) error
+}
+
 type UpdateAccountRunner interface {
 	// UpdateAccount updates an account.
 	UpdateAccount(
@@ -55,6 +76,10 @@ type RemoveAccountRunner interface {
 
 type DoEntryChangesRunner interface {
 	// DoEntryChanges adds, updates, and deletes entries in bulk.
+	// DoEntryChanges records a fin.EntryRevision for each entry it
+	// changes. DoEntryChanges returns Locked and changes nothing if any
+	// entry it would change is dated on or before the lock date either
+	// before or after the change.
 	DoEntryChanges(t db.Transaction, changes *EntryChanges) error
 }
 
@@ -66,6 +91,16 @@ type EntriesRunner interface {
 		consumer goconsume.Consumer) error
 }
 
+type SearchEntriesRunner interface {
+	// SearchEntries gets entries whose name, description, or check number
+	// has each word in query as a whole word or as the beginning of a
+	// word. Search ignores case. Best matches come first. options is
+	// additional options for getting entries, may be nil;
+	// consumer consumes the fin.Entry values.
+	SearchEntries(t db.Transaction, query string, options *EntryListOptions,
+		consumer goconsume.Consumer) error
+}
+
 type EntriesByAccountIdRunner interface {
 	// EntryByAccountId gets entries by account from most to least recent.
 	// acctId is the account ID; account is where
@@ -76,10 +111,22 @@ type EntriesByAccountIdRunner interface {
 }
 
 type EntryByIdRunner interface {
-	// EntryById fetches an Entry by id.
+	// EntryById fetches an Entry by id. Entries in the trash are not found.
 	EntryById(t db.Transaction, id int64, entry *fin.Entry) error
 }
 
+type TrashedEntriesRunner interface {
+	// TrashedEntries gets the entries in the trash from most to least
+	// recently trashed. consumer consumes the fin.TrashedEntry values.
+	TrashedEntries(t db.Transaction, consumer goconsume.Consumer) error
+}
+
+type PurgeTrashRunner interface {
+	// PurgeTrash permanently deletes the entries moved to the trash
+	// before the given time.
+	PurgeTrash(t db.Transaction, before time.Time) error
+}
+
 type UnreconciledEntriesRunner interface {
 	// UnreconciledEntries gets unreconciled entries by account from most to least
 	// recent.
@@ -146,14 +193,168 @@ type RemoveUserByNameRunner interface {
 	RemoveUserByName(t db.Transaction, name string) error
 }
 
+type AddExchangeRateRunner interface {
+	// AddExchangeRate adds a new exchange rate.
+	AddExchangeRate(t db.Transaction, rate *fin.ExchangeRate) error
+}
+
+type ExchangeRatesRunner interface {
+	// ExchangeRates gets all the exchange rates from most to least recent.
+	ExchangeRates(t db.Transaction, consumer goconsume.Consumer) error
+}
+
+type RemoveExchangeRateRunner interface {
+	// RemoveExchangeRate removes an exchange rate by id.
+	RemoveExchangeRate(t db.Transaction, id int64) error
+}
+
+type AddBudgetItemRunner interface {
+	// AddBudgetItem adds a new budget item.
+	AddBudgetItem(t db.Transaction, item *fin.BudgetItem) error
+}
+
+type UpdateBudgetItemRunner interface {
+	// UpdateBudgetItem updates a budget item.
+	UpdateBudgetItem(t db.Transaction, item *fin.BudgetItem) error
+}
+
+type BudgetItemsRunner interface {
+	// BudgetItems gets all the budget items.
+	BudgetItems(t db.Transaction, consumer goconsume.Consumer) error
+}
+
+type RemoveBudgetItemRunner interface {
+	// RemoveBudgetItem removes a budget item by id.
+	RemoveBudgetItem(t db.Transaction, id int64) error
+}
+
+type AddAttachmentRunner interface {
+	// AddAttachment adds a new attachment. AddAttachment sets the Size
+	// field from the length of the Data field.
+	AddAttachment(t db.Transaction, attachment *fin.Attachment) error
+}
+
+type AttachmentByIdRunner interface {
+	// AttachmentById gets an attachment including its contents by id.
+	AttachmentById(
+		t db.Transaction, id int64, attachment *fin.Attachment) error
+}
+
+type AttachmentsByEntryIdRunner interface {
+	// AttachmentsByEntryId gets the attachments of an entry without
+	// their contents. The Data field of each attachment is nil.
+	AttachmentsByEntryId(
+		t db.Transaction, entryId int64, consumer goconsume.Consumer) error
+}
+
+type EntryRevisionByIdRunner interface {
+	// EntryRevisionById gets an entry revision by id.
+	EntryRevisionById(
+		t db.Transaction, id int64, revision *fin.EntryRevision) error
+}
+
+type EntryRevisionsByEntryIdRunner interface {
+	// EntryRevisionsByEntryId gets the revisions of an entry from most to
+	// least recent. consumer consumes the fin.EntryRevision values.
+	EntryRevisionsByEntryId(
+		t db.Transaction, entryId int64, consumer goconsume.Consumer) error
+}
+
+type AddStatementRunner interface {
+	// AddStatement adds a new reconciliation session.
+	AddStatement(t db.Transaction, statement *fin.Statement) error
+}
+
+type UpdateStatementRunner interface {
+	// UpdateStatement updates a reconciliation session.
+	UpdateStatement(t db.Transaction, statement *fin.Statement) error
+}
+
+type StatementsByAccountIdRunner interface {
+	// StatementsByAccountId gets the reconciliation sessions of an account
+	// from most to least recent statement date. consumer consumes the
+	// fin.Statement values.
+	StatementsByAccountId(
+		t db.Transaction, acctId int64, consumer goconsume.Consumer) error
+}
+
+type RemoveStatementRunner interface {
+	// RemoveStatement removes a reconciliation session by id.
+	RemoveStatement(t db.Transaction, id int64) error
+}
+
+type AddEntryTemplateRunner interface {
+	// AddEntryTemplate adds a new entry template.
+	AddEntryTemplate(t db.Transaction, template *fin.EntryTemplate) error
+}
+
+type UpdateEntryTemplateRunner interface {
+	// UpdateEntryTemplate updates an entry template.
+	UpdateEntryTemplate(t db.Transaction, template *fin.EntryTemplate) error
+}
+
+type EntryTemplateByIdRunner interface {
+	// EntryTemplateById gets an entry template by id.
+	EntryTemplateById(
+		t db.Transaction, id int64, template *fin.EntryTemplate) error
+}
+
+type EntryTemplatesRunner interface {
+	// EntryTemplates gets all entry templates sorted by title.
+	// consumer consumes the fin.EntryTemplate values.
+	EntryTemplates(t db.Transaction, consumer goconsume.Consumer) error
+}
+
+type RemoveEntryTemplateRunner interface {
+	// RemoveEntryTemplate removes an entry template by id.
+	RemoveEntryTemplate(t db.Transaction, id int64) error
+}
+
+type LockDatesRunner interface {
+	// LockDates gets the lock dates.
+	LockDates(t db.Transaction) (fin.LockDates, error)
+}
+
+type UpdateLockDateRunner interface {
+	// UpdateLockDate sets the lock date of the account with given id.
+	// acctId of 0 means all accounts; a zero date removes the lock date.
+	UpdateLockDate(t db.Transaction, acctId int64, date time.Time) error
+}
+
+type BankAccountsRunner interface {
+	// BankAccounts gets the mapping from bank accounts to accounts.
+	BankAccounts(t db.Transaction) (fin.BankAccounts, error)
+}
+
+type UpdateBankAccountRunner interface {
+	// UpdateBankAccount maps bankAccount to the account with given id.
+	// An acctId of 0 removes the mapping.
+	UpdateBankAccount(
+		t db.Transaction, bankAccount fin.BankAccount, acctId int64) error
+}
+
+type RemoveAttachmentRunner interface {
+	// RemoveAttachment removes an attachment by id.
+	RemoveAttachment(t db.Transaction, id int64) error
+}
+
 // EntryChanges represents changes to entries.
 type EntryChanges struct {
 	// Adds is entries to add
 	Adds []*fin.Entry
 	// The key is the entry id; the value does the update in-place.
 	Updates map[int64]fin.EntryUpdater
-	// Deletes is the ids of the entries to delete.
+	// Deletes is the ids of the entries to delete permanently. Deleting
+	// an entry also deletes its tags and attachments.
 	Deletes []int64
+	// Trash is the ids of the entries to move to the trash. Entries in the
+	// trash do not count toward account balances and are hidden from
+	// everything except TrashedEntries.
+	Trash []int64
+	// Restore is the ids of the entries to restore from the trash.
+	// Entries are restored before updates are applied. Updating an entry
+	// that is still in the trash fails with Trashed.
+	Restore []int64
 	// Etags contains the etags of the entries being updated.
 	// It is used to detect concurrent updates.
 	// The key is the entry id; the value is the etag of the original entry.
@@ -170,6 +371,10 @@ type EntryListOptions struct {
 	End *time.Time
 	// If true, show only unreviewed entries
 	Unreviewed bool
+	// If non-empty, show only entries with a line item or payment in one
+	// of these categories. Entries listed may have other line items too,
+	// so callers still filter with fin.CatPayment.WithCat.
+	Cats fin.CatSet
 }
 
 // NoPermissionStore always returns NoPermissionError
@@ -201,6 +406,16 @@ func (n NoPermissionStore) UpdateAccountImportSD(
 	return NoPermission
 }
 
+func (n NoPermissionStore) UpdateAccountCurrency(
+	t db.Transaction, accountId int64, currency fin.Currency) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) UpdateAccountType(
+	t db.Transaction, accountId int64, accountType fin.AccountType) error {
+	return NoPermission
+}
+
 func (n NoPermissionStore) UpdateAccount(
 	t db.Transaction, account *fin.Account) error {
 	return NoPermission
@@ -221,6 +436,11 @@ func (n NoPermissionStore) Entries(t db.Transaction, options *EntryListOptions,
 	return NoPermission
 }
 
+func (n NoPermissionStore) SearchEntries(t db.Transaction, query string,
+	options *EntryListOptions, consumer goconsume.Consumer) error {
+	return NoPermission
+}
+
 func (n NoPermissionStore) EntriesByAccountId(t db.Transaction, acctId int64,
 	account *fin.Account, consumer goconsume.Consumer) error {
 	return NoPermission
@@ -286,6 +506,146 @@ func (n NoPermissionStore) RemoveUserByName(t db.Transaction, name string) error
 	return NoPermission
 }
 
+func (n NoPermissionStore) AddExchangeRate(
+	t db.Transaction, rate *fin.ExchangeRate) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) ExchangeRates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) RemoveExchangeRate(
+	t db.Transaction, id int64) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) AddBudgetItem(
+	t db.Transaction, item *fin.BudgetItem) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) UpdateBudgetItem(
+	t db.Transaction, item *fin.BudgetItem) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) BudgetItems(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) RemoveBudgetItem(
+	t db.Transaction, id int64) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) AddAttachment(
+	t db.Transaction, attachment *fin.Attachment) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) AttachmentById(
+	t db.Transaction, id int64, attachment *fin.Attachment) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) AttachmentsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) RemoveAttachment(
+	t db.Transaction, id int64) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) TrashedEntries(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) PurgeTrash(
+	t db.Transaction, before time.Time) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) EntryRevisionById(
+	t db.Transaction, id int64, revision *fin.EntryRevision) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) EntryRevisionsByEntryId(
+	t db.Transaction, entryId int64, consumer goconsume.Consumer) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) AddStatement(
+	t db.Transaction, statement *fin.Statement) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) UpdateStatement(
+	t db.Transaction, statement *fin.Statement) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) StatementsByAccountId(
+	t db.Transaction, acctId int64, consumer goconsume.Consumer) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) RemoveStatement(
+	t db.Transaction, id int64) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) AddEntryTemplate(
+	t db.Transaction, template *fin.EntryTemplate) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) UpdateEntryTemplate(
+	t db.Transaction, template *fin.EntryTemplate) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) EntryTemplateById(
+	t db.Transaction, id int64, template *fin.EntryTemplate) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) EntryTemplates(
+	t db.Transaction, consumer goconsume.Consumer) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) RemoveEntryTemplate(
+	t db.Transaction, id int64) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) LockDates(t db.Transaction) (
+	fin.LockDates, error) {
+	return nil, NoPermission
+}
+
+func (n NoPermissionStore) UpdateLockDate(
+	t db.Transaction, acctId int64, date time.Time) error {
+	return NoPermission
+}
+
+func (n NoPermissionStore) BankAccounts(t db.Transaction) (
+	fin.BankAccounts, error) {
+	return nil, NoPermission
+}
+
+func (n NoPermissionStore) UpdateBankAccount(
+	t db.Transaction, bankAccount fin.BankAccount, acctId int64) error {
+	return NoPermission
+}
+
 type RecurringEntriesApplier interface {
 	DoEntryChangesRunner
 	UpdateRecurringEntryRunner
@@ -306,8 +666,8 @@ type RecurringEntrySkipper interface {
 // SkipRecurringEntry advances the recurring entry with given id without
 // creating a new entry for it.
 // t is the database transaction and must be non-nil.
-// Returns true if the entry was skipped or false if the NumLeft field
-// has already reached 0.
+// Returns true if the entry was skipped or false if the recurring entry
+// is already done.
 func SkipRecurringEntry(
 	t db.Transaction,
 	store RecurringEntrySkipper,
@@ -320,7 +680,7 @@ func SkipRecurringEntry(
 		return false, err
 	}
 	// If we didn't advance we are done
-	if !entry.AdvanceOnce(nil) {
+	if !entry.AdvanceOnce(nil, nil) {
 		return false, nil
 	}
 	if err := store.UpdateRecurringEntry(t, &entry); err != nil {
@@ -332,12 +692,15 @@ func SkipRecurringEntry(
 // ApplyRecurringEntry advances the recurring entry with given id
 // creating one new entry for it.
 // t is the database transaction and must be non-nil.
-// Returns true if the entry was applied or false if the NumLeft field
-// has already reached 0.
+// holidays are the holidays for adjusting the date of the new entry and
+// may be nil.
+// Returns true if the entry was applied or false if the recurring entry
+// is already done.
 func ApplyRecurringEntry(
 	t db.Transaction,
 	store RecurringEntryApplier,
-	id int64) (bool, error) {
+	id int64,
+	holidays fin.HolidayCalendar) (bool, error) {
 	if t == nil {
 		panic("non nil transaction required.")
 	}
@@ -347,7 +710,7 @@ func ApplyRecurringEntry(
 	}
 	var newEntry fin.Entry
 	// If we didn't advance we are done
-	if !entry.AdvanceOnce(&newEntry) {
+	if !entry.AdvanceOnce(&newEntry, holidays) {
 		return false, nil
 	}
 	if err := store.UpdateRecurringEntry(t, &entry); err != nil {
@@ -367,13 +730,15 @@ func ApplyRecurringEntry(
 // If acctId is non-zero, ApplyRecurringEntriesDryRun considers only
 // the outstanding recurring entries pertaining to that account.
 // currentDate is the current date.
+// holidays are the holidays for business day adjustment and may be nil.
 func ApplyRecurringEntriesDryRun(
 	t db.Transaction,
 	store RecurringEntriesRunner,
 	acctId int64,
-	currentDate time.Time) (int, error) {
+	currentDate time.Time,
+	holidays fin.HolidayCalendar) (int, error) {
 	_, entriesToAdd, err := applyRecurringEntriesDryRun(
-		t, store, acctId, currentDate)
+		t, store, acctId, currentDate, holidays)
 	return len(entriesToAdd), err
 }
 
@@ -386,16 +751,18 @@ func ApplyRecurringEntriesDryRun(
 // If acctId is non-zero, ApplyRecurringEntries applies only the outstanding
 // recurring entries pertaining to that account.
 // currentDate is the current date.
+// holidays are the holidays for business day adjustment and may be nil.
 func ApplyRecurringEntries(
 	t db.Transaction,
 	store RecurringEntriesApplier,
 	acctId int64,
-	currentDate time.Time) (int, error) {
+	currentDate time.Time,
+	holidays fin.HolidayCalendar) (int, error) {
 	if t == nil {
 		panic("non nil transaction required.")
 	}
 	recurringEntries, entries, err := applyRecurringEntriesDryRun(
-		t, store, acctId, currentDate)
+		t, store, acctId, currentDate, holidays)
 	if err != nil {
 		return 0, err
 	}
@@ -447,11 +814,175 @@ func LoginUser(
 	return nil
 }
 
+type StatementFinisher interface {
+	AccountByIdRunner
+	DoEntryChangesRunner
+	EntryByIdRunner
+	UpdateStatementRunner
+}
+
+// FinishStatement reconciles the entries with given ids and finishes
+// statement recording those ids in it.
+// t is the database transaction and must be non-nil.
+// Ids of entries that are already reconciled or that do not belong to
+// the statement's account are ignored. If the reconciled balance of the
+// account plus the total of the entries does not match the statement
+// balance, FinishStatement returns NotBalanced and changes nothing.
+func FinishStatement(
+	t db.Transaction,
+	store StatementFinisher,
+	statement *fin.Statement,
+	entryIds []int64,
+	now time.Time) error {
+	if t == nil {
+		panic("non nil transaction required.")
+	}
+	if statement.Done() {
+		return AlreadyFinished
+	}
+	var account fin.Account
+	if err := store.AccountById(t, statement.AcctId, &account); err != nil {
+		return err
+	}
+	var cleared int64
+	var ids []int64
+	seen := make(map[int64]bool, len(entryIds))
+	for _, id := range entryIds {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		var entry fin.Entry
+		err := store.EntryById(t, id, &entry)
+		if err == NoSuchId {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !entry.WithPayment(statement.AcctId) || entry.Reconciled() {
+			continue
+		}
+		cleared += entry.Total()
+		ids = append(ids, id)
+	}
+	if statement.Difference(account.RBalance, cleared) != 0 {
+		return NotBalanced
+	}
+	reconciler := func(p *fin.Entry) bool {
+		return p.Reconcile(statement.AcctId)
+	}
+	updates := make(map[int64]fin.EntryUpdater, len(ids))
+	for _, id := range ids {
+		updates[id] = reconciler
+	}
+	if err := store.DoEntryChanges(t, &EntryChanges{Updates: updates}); err != nil {
+		return err
+	}
+	statement.EntryIds = ids
+	statement.Finished = now
+	return store.UpdateStatement(t, statement)
+}
+
+type CurrencyConverterRunner interface {
+	AccountsRunner
+	ExchangeRatesRunner
+}
+
+// NewCurrencyConverter returns a converter to the reporting currency
+// using the currencies of all accounts and all exchange rates in store.
+func NewCurrencyConverter(
+	t db.Transaction,
+	store CurrencyConverterRunner,
+	reporting fin.Currency) (*fin.CurrencyConverter, error) {
+	var accounts []*fin.Account
+	if err := store.Accounts(t, goconsume.AppendPtrsTo(&accounts)); err != nil {
+		return nil, err
+	}
+	var rates []fin.ExchangeRate
+	if err := store.ExchangeRates(t, goconsume.AppendTo(&rates)); err != nil {
+		return nil, err
+	}
+	return fin.NewCurrencyConverter(reporting, accounts, rates), nil
+}
+
+// AccountBalancesAsOf returns the balance of the account with given id
+// at the end of each of the given dates. The balances are in the same
+// order as dates and in the currency of the account.
+// AccountBalancesAsOf derives the balances from the fin.EntryBalance
+// values that store.EntriesByAccountId emits.
+func AccountBalancesAsOf(
+	t db.Transaction,
+	store EntriesByAccountIdRunner,
+	acctId int64,
+	dates []time.Time) ([]int64, error) {
+	consumer := newBalancesAsOfConsumer(dates)
+	var account fin.Account
+	if err := store.EntriesByAccountId(t, acctId, &account, consumer); err != nil {
+		return nil, err
+	}
+	consumer.finish(account.Balance)
+	return consumer.balances, nil
+}
+
+// balancesAsOfConsumer consumes fin.EntryBalance values from newest to
+// oldest recording the balance at the end of each of its dates.
+type balancesAsOfConsumer struct {
+	dates []time.Time
+	// indexes into dates from latest to earliest date
+	order    []int
+	next     int
+	consumed bool
+	// The balance before the last consumed entry
+	before   int64
+	balances []int64
+}
+
+func newBalancesAsOfConsumer(dates []time.Time) *balancesAsOfConsumer {
+	order := make([]int, len(dates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return dates[order[i]].After(dates[order[j]])
+	})
+	return &balancesAsOfConsumer{
+		dates:    dates,
+		order:    order,
+		balances: make([]int64, len(dates))}
+}
+
+func (c *balancesAsOfConsumer) CanConsume() bool {
+	return c.next < len(c.order)
+}
+
+func (c *balancesAsOfConsumer) Consume(ptr interface{}) {
+	p := ptr.(*fin.EntryBalance)
+	for c.next < len(c.order) && !c.dates[c.order[c.next]].Before(p.Date) {
+		c.balances[c.order[c.next]] = p.Balance
+		c.next++
+	}
+	c.before = p.Balance - p.Total()
+	c.consumed = true
+}
+
+// finish records the balance for the dates before the oldest entry.
+// balance is the ending balance of the account.
+func (c *balancesAsOfConsumer) finish(balance int64) {
+	if c.consumed {
+		balance = c.before
+	}
+	for ; c.next < len(c.order); c.next++ {
+		c.balances[c.order[c.next]] = balance
+	}
+}
+
 func applyRecurringEntriesDryRun(
 	t db.Transaction,
 	store RecurringEntriesRunner,
 	acctId int64,
-	currentDate time.Time) (
+	currentDate time.Time,
+	holidays fin.HolidayCalendar) (
 	recurringEntriesToUpdate []*fin.RecurringEntry,
 	entriesToAdd []*fin.Entry,
 	err error) {
@@ -465,7 +996,8 @@ func applyRecurringEntriesDryRun(
 	}
 	idx := 0
 	for i := range recurringEntriesToUpdate {
-		if recurringEntriesToUpdate[i].Advance(currentDate, &entriesToAdd) {
+		if recurringEntriesToUpdate[i].Advance(
+			currentDate, holidays, &entriesToAdd) {
 			recurringEntriesToUpdate[idx] = recurringEntriesToUpdate[i]
 			idx++
 		}
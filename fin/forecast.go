@@ -0,0 +1,110 @@
+package fin
+
+import (
+	"time"
+)
+
+// AccountForecast is the projected balance of one account for each day
+// of a Forecast.
+type AccountForecast struct {
+	// The account with its current balance.
+	Account Account
+
+	// Balances[i] is the projected balance at the end of the ith day of
+	// the forecast in the currency of the account.
+	Balances []int64
+
+	// The first day that the projected balance falls below the low
+	// balance of the forecast. Zero if it never does. Always zero for
+	// liability accounts such as credit cards.
+	LowBalanceDate time.Time
+}
+
+// Low returns true if the projected balance of this account falls below
+// the low balance of the forecast.
+func (a *AccountForecast) Low() bool {
+	return !a.LowBalanceDate.IsZero()
+}
+
+// Forecast projects daily account balances from recurring entries.
+type Forecast struct {
+	// The first day of the forecast.
+	Start time.Time
+
+	// The forecasts of each account in the same order as the accounts
+	// passed to NewForecast.
+	Accounts []*AccountForecast
+
+	days int
+}
+
+// NewForecast projects the balances of accounts for each day from start
+// through end by advancing copies of recurringEntries with their Advance
+// method. Generated entries dated before start are past due, so
+// NewForecast counts them on start. NewForecast does not change
+// recurringEntries. holidays are for business day adjustment and may be
+// nil. lowBalance is the balance in cents below which an asset account
+// gets a LowBalanceDate.
+func NewForecast(
+	accounts []*Account,
+	recurringEntries []*RecurringEntry,
+	start, end time.Time,
+	holidays HolidayCalendar,
+	lowBalance int64) *Forecast {
+	days := daysBetween(start, end) + 1
+	if days < 1 {
+		days = 1
+	}
+	deltas := make([]AccountDeltas, days)
+	for _, re := range recurringEntries {
+		recurringEntry := *re
+		var entries []*Entry
+		recurringEntry.Advance(end, holidays, &entries)
+		for _, entry := range entries {
+			day := daysBetween(start, entry.Date)
+			if day < 0 {
+				day = 0
+			}
+			if deltas[day] == nil {
+				deltas[day] = make(AccountDeltas)
+			}
+			deltas[day].Include(&entry.CatPayment)
+		}
+	}
+	result := &Forecast{
+		Start:    start,
+		Accounts: make([]*AccountForecast, len(accounts)),
+		days:     days}
+	for i, account := range accounts {
+		af := &AccountForecast{
+			Account:  *account,
+			Balances: make([]int64, days)}
+		balance := account.Balance
+		for day := range af.Balances {
+			if delta := deltas[day][account.Id]; delta != nil {
+				balance += delta.Balance
+			}
+			af.Balances[day] = balance
+			if balance < lowBalance && !af.Low() && !account.Type.IsLiability() {
+				af.LowBalanceDate = result.Date(day)
+			}
+		}
+		result.Accounts[i] = af
+	}
+	return result
+}
+
+// Days returns the number of days in this forecast.
+func (f *Forecast) Days() int {
+	return f.days
+}
+
+// Date returns the date of the ith day of this forecast.
+func (f *Forecast) Date(i int) time.Time {
+	return f.Start.AddDate(0, 0, i)
+}
+
+// daysBetween returns the number of whole days from start to end.
+func daysBetween(start, end time.Time) int {
+	return int((end.Sub(start) + 12*time.Hour) / (24 * time.Hour))
+}
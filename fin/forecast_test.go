@@ -0,0 +1,54 @@
+package fin
+
+import (
+	"github.com/keep94/toolbox/date_util"
+	"testing"
+)
+
+func TestForecast(t *testing.T) {
+	accounts := []*Account{
+		{Id: 1, Name: "checking", Balance: 50000},
+		{Id: 2, Name: "savings", Balance: 100000},
+		{Id: 3, Name: "visa", Balance: -2000, Type: CreditCardAccount}}
+	rent := &RecurringEntry{
+		Entry: Entry{
+			Date:       date_util.YMD(2015, 8, 1),
+			CatPayment: NewCatPayment(NewCat("0:7"), 40000, false, 1)},
+		Period:  RecurringPeriod{Count: 1, DayOfMonth: 1},
+		NumLeft: -1}
+	// Past due so it counts on the first day
+	transfer := &RecurringEntry{
+		Entry: Entry{
+			Date:       date_util.YMD(2015, 7, 10),
+			CatPayment: NewCatPayment(NewCat("2:2"), 5000, false, 1)},
+		Period:  RecurringPeriod{Count: 1, DayOfMonth: 10},
+		NumLeft: 1}
+	forecast := NewForecast(
+		accounts,
+		[]*RecurringEntry{rent, transfer},
+		date_util.YMD(2015, 7, 20),
+		date_util.YMD(2015, 9, 5),
+		nil,
+		0)
+	if output := forecast.Days(); output != 48 {
+		t.Errorf("Expected 48 days, got %d", output)
+	}
+	verifyDate(t, date_util.YMD(2015, 9, 5), forecast.Date(47))
+	checking := forecast.Accounts[0]
+	expected := map[int]int64{0: 45000, 11: 45000, 12: 5000, 42: 5000, 43: -35000, 47: -35000}
+	for day, balance := range expected {
+		if output := checking.Balances[day]; output != balance {
+			t.Errorf("Day %d: expected %d, got %d", day, balance, output)
+		}
+	}
+	verifyDate(t, date_util.YMD(2015, 9, 1), checking.LowBalanceDate)
+	if output := forecast.Accounts[1].Balances[47]; output != 105000 {
+		t.Errorf("Expected 105000, got %d", output)
+	}
+	if forecast.Accounts[1].Low() || forecast.Accounts[2].Low() {
+		t.Error("Expected only checking to have a low balance")
+	}
+	if rent.Date != date_util.YMD(2015, 8, 1) || transfer.NumLeft != 1 {
+		t.Error("Expected recurring entries to be unchanged")
+	}
+}
@@ -0,0 +1,43 @@
+package fin
+
+import (
+	"time"
+)
+
+// LockDates are the dates on or before which entries may not change such
+// as the end of a year for which taxes were filed. The key is the account
+// id; the key 0 is the lock date for all accounts.
+type LockDates map[int64]time.Time
+
+// LockDate returns the lock date for the account with given id which is
+// the later of the lock date for all accounts and the lock date for that
+// account. Zero means no lock date.
+func (l LockDates) LockDate(acctId int64) time.Time {
+	result := l[0]
+	if date := l[acctId]; date.After(result) {
+		result = date
+	}
+	return result
+}
+
+// Locked returns true if entry is dated on or before the lock date of any
+// of the accounts it touches.
+func (l LockDates) Locked(entry *Entry) bool {
+	if len(l) == 0 {
+		return false
+	}
+	if l.lockedFor(entry.PaymentId(), entry.Date) {
+		return true
+	}
+	for _, catrec := range entry.CatRecs() {
+		if catrec.Cat.Type == AccountCat && l.lockedFor(catrec.Cat.Id, entry.Date) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l LockDates) lockedFor(acctId int64, date time.Time) bool {
+	lockDate := l.LockDate(acctId)
+	return !lockDate.IsZero() && !date.After(lockDate)
+}
@@ -0,0 +1,32 @@
+package fin
+
+import (
+	"github.com/keep94/toolbox/date_util"
+	"testing"
+)
+
+func TestLockDates(t *testing.T) {
+	lockDates := LockDates{
+		0: date_util.YMD(2014, 12, 31),
+		2: date_util.YMD(2015, 3, 31)}
+	verifyDate(t, date_util.YMD(2014, 12, 31), lockDates.LockDate(1))
+	verifyDate(t, date_util.YMD(2015, 3, 31), lockDates.LockDate(2))
+	entry := Entry{
+		Date:       date_util.YMD(2014, 12, 31),
+		CatPayment: NewCatPayment(NewCat("0:7"), 100, false, 1)}
+	if !lockDates.Locked(&entry) {
+		t.Error("Expected entry on lock date to be locked")
+	}
+	entry.Date = date_util.YMD(2015, 1, 1)
+	if lockDates.Locked(&entry) {
+		t.Error("Expected entry after lock date not to be locked")
+	}
+	// Transfer to account 2
+	entry.CatPayment = NewCatPayment(NewCat("2:2"), 100, false, 1)
+	if !lockDates.Locked(&entry) {
+		t.Error("Expected transfer to locked account to be locked")
+	}
+	if LockDates(nil).Locked(&entry) {
+		t.Error("Expected nothing locked without lock dates")
+	}
+}
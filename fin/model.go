@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/keep94/toolbox/passwords"
-	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -113,6 +112,23 @@ type CatRec struct {
 	// Reconciled is reconcile flag, only applicable for categories of
 	// AccountCat type.
 	Reconciled bool
+	// Currency is set only for categories of AccountCat type whose account
+	// is in a different currency than the payment account as happens with
+	// cross-currency transfers. Amount is always in the currency of the
+	// payment account.
+	Currency Currency
+	// CurrencyAmount is the amount in Currency in one cent increments.
+	// Ignored if Currency is empty.
+	CurrencyAmount int64
+}
+
+// accountAmount returns the amount of this CatRec in the currency of its
+// account.
+func (c *CatRec) accountAmount() int64 {
+	if c.Currency != "" {
+		return c.CurrencyAmount
+	}
+	return c.Amount
 }
 
 // Unmarshaller builds components of CatPayment from database columns.
@@ -226,8 +242,19 @@ func (c *CatPayment) Total() int64 {
 // WithPayment changes this CatPayment so that the payment id matches id.
 // If id is not the payment Id and it does not correspond to any payment ids
 // in the CatRecs, then WithPayment returns false and leaves this value
-// unchanged.
+// unchanged. If id is in a different currency than the original payment,
+// the amounts of the changed CatPayment are in the currency of id.
+// WithPayment assumes the original payment account is in DefaultCurrency;
+// use WithPaymentIn when it may not be.
 func (c *CatPayment) WithPayment(id int64) bool {
+	return c.WithPaymentIn(id, DefaultCurrency)
+}
+
+// WithPaymentIn works like WithPayment where paymentCurrency is the
+// currency of the original payment account. If id is in a different
+// currency, the CatRec for the original payment account keeps its amount
+// in paymentCurrency.
+func (c *CatPayment) WithPaymentIn(id int64, paymentCurrency Currency) bool {
 	if c.id == id {
 		return true
 	}
@@ -236,7 +263,12 @@ func (c *CatPayment) WithPayment(id int64) bool {
 		if c.cr[i].Cat == pc {
 			ncr := make([]CatRec, 1)
 			ncr[0].Cat = Cat{Id: c.id, Type: AccountCat}
-			ncr[0].Amount = -c.cr[i].Amount
+			// Amounts must be in the currency of the new payment account.
+			ncr[0].Amount = -c.cr[i].accountAmount()
+			if c.cr[i].Currency != "" {
+				ncr[0].Currency = paymentCurrency.Normalize()
+				ncr[0].CurrencyAmount = -c.cr[i].Amount
+			}
 			ncr[0].Reconciled = c.r
 			c.id = c.cr[i].Cat.Id
 			c.r = c.cr[i].Reconciled
@@ -334,6 +366,10 @@ func (c *CatPaymentBuilder) AddCatRec(cr CatRec) *CatPaymentBuilder {
 	if cr.Reconciled {
 		ocr.Reconciled = true
 	}
+	if cr.Currency != "" {
+		ocr.Currency = cr.Currency
+		ocr.CurrencyAmount += cr.CurrencyAmount
+	}
 	c.m[cr.Cat] = ocr
 	return c
 }
@@ -397,7 +433,13 @@ type Entry struct {
 	CheckNo string
 	CatPayment
 	Status ReviewStatus
-	Etag   uint64
+	// Tags are the labels on this entry. nil if entry has no tags.
+	Tags TagSet
+	// AttachmentCount is the number of attachments on this entry. It is
+	// read-only; stores set it when reading entries and ignore it when
+	// writing them.
+	AttachmentCount int
+	Etag            uint64
 }
 
 func (e *Entry) String() string {
@@ -413,6 +455,13 @@ type EntryBalance struct {
 	Balance int64
 }
 
+// TrashedEntry is an Entry in the trash.
+type TrashedEntry struct {
+	Entry
+	// When the entry was moved to the trash.
+	Trashed time.Time
+}
+
 // Account represents an account for payment.
 type Account struct {
 	// Unique Id
@@ -430,13 +479,18 @@ type Account struct {
 	RCount int
 	// Auto import should ignore transactions before this date.
 	ImportSD time.Time
+	// The currency of this account. Balances are in this currency.
+	Currency Currency
+	// The type of this account e.g checking or credit card.
+	Type AccountType
 }
 
 func (a *Account) String() string {
 	return fmt.Sprintf("%v", *a)
 }
 
-// AccountDelta represents changes in a single account.
+// AccountDelta represents changes in a single account. Balances are in
+// the currency of the account.
 type AccountDelta struct {
 	// Balance is change in overall balance in cents.
 	Balance int64
@@ -484,7 +538,7 @@ func (a AccountDeltas) add(catPayment *CatPayment, multiplier int) {
 	for i := range catPayment.cr {
 		catrec := &catPayment.cr[i]
 		if catrec.Cat.Type == AccountCat {
-			a._add(catrec.Cat.Id, catrec.Amount, catrec.Reconciled, multiplier)
+			a._add(catrec.Cat.Id, catrec.accountAmount(), catrec.Reconciled, multiplier)
 		}
 		total -= catrec.Amount
 	}
@@ -503,7 +557,10 @@ func (a AccountDeltas) _add(id int64, amount int64, reconciled bool, multiplier
 	}
 }
 
-// CatTotals represents category totals
+// CatTotals represents category totals. CatTotals assumes that all the
+// CatPayment values it includes are in the same currency. Use
+// CurrencyConverter.ConvertEntry to convert entries to a common currency
+// first.
 type CatTotals map[Cat]int64
 
 func (c CatTotals) Include(catPayment *CatPayment) {
@@ -579,18 +636,15 @@ type User struct {
 // FormatUSD returns amount as dollars and cents.
 // 347 -> "3.47"
 func FormatUSD(x int64) string {
-	return fmt.Sprintf("%.2f", float64(x)/100.0)
+	return Money(x).String()
 }
 
-// ParseUSD is the inverse of FormatUSD.
+// ParseUSD is the inverse of FormatUSD. It accepts everything that
+// ParseMoney accepts.
 // "3.47" -> 347
 func ParseUSD(s string) (v int64, e error) {
-	f, e := strconv.ParseFloat(s, 64)
-	if e != nil {
-		return
-	}
-	v = int64(math.Floor(f*100.0 + 0.5))
-	return
+	m, e := ParseMoney(s)
+	return int64(m), e
 }
 
 type catSlice []Cat
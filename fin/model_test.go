@@ -10,18 +10,18 @@ func TestBuildCatPayment(t *testing.T) {
 	cpb.SetPaymentId(5).SetReconciled(true)
 
 	// 0:9 should not disappear even though its total amount is 0
-	cpb.AddCatRec(CatRec{NewCat("0:9"), 4009, false})
-	cpb.AddCatRec(CatRec{NewCat("0:9"), -4009, false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:9"), Amount: 4009, Reconciled: false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:9"), Amount: -4009, Reconciled: false})
 
-	cpb.AddCatRec(CatRec{NewCat("0:5"), 2324, false})
-	cpb.AddCatRec(CatRec{NewCat("0:6"), 9002, false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:5"), Amount: 2324, Reconciled: false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:6"), Amount: 9002, Reconciled: false})
 
 	// 2:5 should be ignored since it is the payment type
-	cpb.AddCatRec(CatRec{NewCat("2:5"), 3535, false})
-	cpb.AddCatRec(CatRec{NewCat("2:6"), 5003, false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("2:5"), Amount: 3535, Reconciled: false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("2:6"), Amount: 5003, Reconciled: false})
 
 	// This 0:5 should be merged with first one
-	cpb.AddCatRec(CatRec{NewCat("0:5"), 1076, false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:5"), Amount: 1076, Reconciled: false})
 
 	cp := cpb.Build()
 	if cp.WithPayment(7) {
@@ -89,8 +89,8 @@ func TestBuildCatPayment(t *testing.T) {
 
 func TestBuildCatPaymentSetPaymentLast(t *testing.T) {
 	cpb := CatPaymentBuilder{}
-	cpb.AddCatRec(CatRec{NewCat("2:5"), 3456, false})
-	cpb.AddCatRec(CatRec{NewCat("0:1"), 1234, false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("2:5"), Amount: 3456, Reconciled: false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:1"), Amount: 1234, Reconciled: false})
 	cpb.SetPaymentId(5)
 	cp := cpb.Build()
 	if verifyCatPayment(t, &cp, -1234, 1, 5, false) {
@@ -118,13 +118,13 @@ func TestZeroCatPaymentAndBuilder(t *testing.T) {
 
 func TestMergeReconcileInCatRec(t *testing.T) {
 	cpb := CatPaymentBuilder{}
-	cpb.AddCatRec(CatRec{NewCat("0:5"), 10000, false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:5"), Amount: 10000, Reconciled: false})
 	cpb.SetPaymentId(9).SetReconciled(true)
 	cp := cpb.Build()
-	cpb.AddCatRec(CatRec{NewCat("0:7"), 3000, true})
-	cpb.AddCatRec(CatRec{NewCat("0:7"), 1000, false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:7"), Amount: 3000, Reconciled: true})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:7"), Amount: 1000, Reconciled: false})
 	cp2 := cpb.Build()
-	cpb.AddCatRec(CatRec{NewCat("0:7"), 2000, false})
+	cpb.AddCatRec(CatRec{Cat: NewCat("0:7"), Amount: 2000, Reconciled: false})
 	cp3 := cpb.Build()
 	verifyCatRec(t, &cp, 0, "0:5", 10000, false)
 	verifyCatPayment(t, &cp, -10000, 1, 9, true)
@@ -159,9 +159,9 @@ func TestSetSingleCat(t *testing.T) {
 func TestChangeCat(t *testing.T) {
 	cpb := CatPaymentBuilder{}
 	cp := cpb.AddCatRec(
-		CatRec{NewCat("0:5"), 1000, false}).AddCatRec(
-		CatRec{NewCat("0:7"), 2000, false}).AddCatRec(
-		CatRec{NewCat("0:10"), 4000, false}).SetPaymentId(
+		CatRec{Cat: NewCat("0:5"), Amount: 1000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("0:7"), Amount: 2000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("0:10"), Amount: 4000, Reconciled: false}).SetPaymentId(
 		9).SetReconciled(true).Build()
 	// Change 0:5 to 0:7
 	modifyCat(NewCat("0:5"), NewCat("0:7"), &cp)
@@ -173,12 +173,12 @@ func TestChangeCat(t *testing.T) {
 func TestCatPaymentBuilderSet(t *testing.T) {
 	cpb := CatPaymentBuilder{}
 	cp := cpb.AddCatRec(
-		CatRec{NewCat("0:5"), 1000, false}).AddCatRec(
-		CatRec{NewCat("0:7"), 2000, false}).AddCatRec(
-		CatRec{NewCat("0:10"), 4000, false}).SetPaymentId(
+		CatRec{Cat: NewCat("0:5"), Amount: 1000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("0:7"), Amount: 2000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("0:10"), Amount: 4000, Reconciled: false}).SetPaymentId(
 		9).SetReconciled(true).Build()
 	newCpb := CatPaymentBuilder{}
-	newCpb.AddCatRec(CatRec{NewCat("0:11"), 5500, true})
+	newCpb.AddCatRec(CatRec{Cat: NewCat("0:11"), Amount: 5500, Reconciled: true})
 	newCpb.Set(&cp)
 	newCp := newCpb.Build()
 	verifyCatPayment(t, &newCp, -7000, 3, 9, true)
@@ -191,10 +191,10 @@ func TestCatTotals(t *testing.T) {
 	var ct CatTotals = make(map[Cat]int64)
 	cpb := CatPaymentBuilder{}
 	cp := cpb.AddCatRec(
-		CatRec{NewCat("0:7"), 6000, false}).AddCatRec(
-		CatRec{NewCat("1:5"), -3000, false}).AddCatRec(
-		CatRec{NewCat("0:3"), 2000, true}).AddCatRec(
-		CatRec{NewCat("2:2"), 1000, false}).SetPaymentId(
+		CatRec{Cat: NewCat("0:7"), Amount: 6000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("1:5"), Amount: -3000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("0:3"), Amount: 2000, Reconciled: true}).AddCatRec(
+		CatRec{Cat: NewCat("2:2"), Amount: 1000, Reconciled: false}).SetPaymentId(
 		1).SetReconciled(false).Build()
 	ct.Include(&cp)
 	var expected CatTotals = map[Cat]int64{
@@ -205,11 +205,11 @@ func TestCatTotals(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, ct)
 	}
 	cp = cpb.AddCatRec(
-		CatRec{NewCat("0:7"), 1000, false}).AddCatRec(
-		CatRec{NewCat("1:5"), 3000, false}).AddCatRec(
-		CatRec{NewCat("1:7"), 0, false}).AddCatRec(
-		CatRec{NewCat("0:4"), 1500, true}).AddCatRec(
-		CatRec{NewCat("2:2"), 1000, false}).SetPaymentId(
+		CatRec{Cat: NewCat("0:7"), Amount: 1000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("1:5"), Amount: 3000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("1:7"), Amount: 0, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("0:4"), Amount: 1500, Reconciled: true}).AddCatRec(
+		CatRec{Cat: NewCat("2:2"), Amount: 1000, Reconciled: false}).SetPaymentId(
 		1).SetReconciled(false).Build()
 	ct.Include(&cp)
 	expected = map[Cat]int64{
@@ -227,10 +227,10 @@ func TestAccountSet(t *testing.T) {
 	as := make(AccountSet)
 	cpb := CatPaymentBuilder{}
 	cp := cpb.AddCatRec(
-		CatRec{NewCat("0:7"), 6000, false}).AddCatRec(
-		CatRec{NewCat("1:5"), -3000, false}).AddCatRec(
-		CatRec{NewCat("2:4"), 2000, true}).AddCatRec(
-		CatRec{NewCat("2:2"), 1000, false}).SetPaymentId(
+		CatRec{Cat: NewCat("0:7"), Amount: 6000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("1:5"), Amount: -3000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("2:4"), Amount: 2000, Reconciled: true}).AddCatRec(
+		CatRec{Cat: NewCat("2:2"), Amount: 1000, Reconciled: false}).SetPaymentId(
 		1).SetReconciled(false).Build()
 	as.Include(&cp)
 	var expected AccountSet = AccountSet{1: true, 2: true, 4: true}
@@ -243,12 +243,12 @@ func TestAccountDeltas(t *testing.T) {
 	var d AccountDeltas = make(map[int64]*AccountDelta)
 	cpb := CatPaymentBuilder{}
 	cp := cpb.AddCatRec(
-		CatRec{NewCat("0:7"), 6000, false}).AddCatRec(
-		CatRec{NewCat("2:3"), 1100, true}).AddCatRec(
-		CatRec{NewCat("2:3"), 900, false}).AddCatRec(
-		CatRec{NewCat("2:2"), 700, false}).AddCatRec(
-		CatRec{NewCat("2:2"), 300, false}).AddCatRec(
-		CatRec{NewCat("2:1"), 5800, true}).SetPaymentId(
+		CatRec{Cat: NewCat("0:7"), Amount: 6000, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("2:3"), Amount: 1100, Reconciled: true}).AddCatRec(
+		CatRec{Cat: NewCat("2:3"), Amount: 900, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("2:2"), Amount: 700, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("2:2"), Amount: 300, Reconciled: false}).AddCatRec(
+		CatRec{Cat: NewCat("2:1"), Amount: 5800, Reconciled: true}).SetPaymentId(
 		1).SetReconciled(false).Build()
 	d.Include(&cp)
 	var expected AccountDeltas = map[int64]*AccountDelta{
@@ -257,7 +257,7 @@ func TestAccountDeltas(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, d)
 	}
 	cp2 := cpb.AddCatRec(
-		CatRec{NewCat("0:7"), 2000, false}).SetPaymentId(
+		CatRec{Cat: NewCat("0:7"), Amount: 2000, Reconciled: false}).SetPaymentId(
 		3).SetReconciled(true).Build()
 	d.Include(&cp2)
 	expected = map[int64]*AccountDelta{
@@ -273,6 +273,48 @@ func TestAccountDeltas(t *testing.T) {
 	}
 }
 
+func TestCrossCurrencyAccountDeltas(t *testing.T) {
+	var d AccountDeltas = make(map[int64]*AccountDelta)
+	cpb := CatPaymentBuilder{}
+	cp := cpb.AddCatRec(
+		CatRec{Cat: NewCat("2:2"), Amount: 1000, Currency: "EUR", CurrencyAmount: 900}).AddCatRec(
+		CatRec{Cat: NewCat("2:2"), Amount: 500, Currency: "EUR", CurrencyAmount: 450}).SetPaymentId(
+		1).Build()
+	verifyCatPayment(t, &cp, -1500, 1, 1, false)
+	d.Include(&cp)
+	expected := AccountDeltas{
+		1: {Balance: -1500, Count: 1}, 2: {Balance: 1350, Count: 1}}
+	if !reflect.DeepEqual(expected, d) {
+		t.Errorf("Expected %v, got %v", expected, d)
+	}
+	twoPayment := cp
+	if !twoPayment.WithPayment(2) {
+		t.Error("Expected WithPayment(2) to succeed.")
+	}
+	if verifyCatPayment(t, &twoPayment, 1350, 1, 2, false) {
+		verifyCatRec(t, &twoPayment, 0, "2:1", -1350, false)
+	}
+	expectedCatRec := CatRec{
+		Cat: NewCat("2:1"), Amount: -1350, Currency: USD, CurrencyAmount: -1500}
+	if output := twoPayment.CatRecByIndex(0); output != expectedCatRec {
+		t.Errorf("Expected %v, got %v", expectedCatRec, output)
+	}
+	// The old payment account keeps its own balance.
+	d = make(map[int64]*AccountDelta)
+	d.Include(&twoPayment)
+	if !reflect.DeepEqual(expected, d) {
+		t.Errorf("Expected %v, got %v", expected, d)
+	}
+	gbpPayment := cp
+	if !gbpPayment.WithPaymentIn(2, "GBP") {
+		t.Error("Expected WithPaymentIn(2) to succeed.")
+	}
+	expectedCatRec.Currency = "GBP"
+	if output := gbpPayment.CatRecByIndex(0); output != expectedCatRec {
+		t.Errorf("Expected %v, got %v", expectedCatRec, output)
+	}
+}
+
 func TestZeroCatPaymentsEqual(t *testing.T) {
 	zero := CatPayment{}
 	cpb := CatPaymentBuilder{}
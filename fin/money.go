@@ -0,0 +1,155 @@
+package fin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The largest number of whole units that ParseMoney accepts.
+	kMaxMoneyUnits = 1e15
+)
+
+// Money is an amount in one cent increments. Like CatRec.Amount, the sign
+// depends on context.
+type Money int64
+
+// ParseMoney parses s exactly without going through floating point. s may
+// have a leading currency symbol such as $ and thousands separators such as
+// "1,234.56". Negative amounts may have a leading or trailing minus sign or
+// be in parentheses so that "-$3", "$-3", "3-" and "(3.00)" all mean -300.
+// Digits past the cents are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	str := strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(str, "(") && strings.HasSuffix(str, ")") {
+		negative = true
+		str = strings.TrimSpace(str[1 : len(str)-1])
+	}
+	signs := 0
+	if strings.HasSuffix(str, "-") {
+		signs++
+		str = strings.TrimSpace(str[:len(str)-1])
+	}
+	str, signs = trimSign(str, signs)
+	str = trimCurrencySymbol(str)
+	str, signs = trimSign(str, signs)
+	if signs > 1 || (signs == 1 && negative) {
+		return 0, moneyError(s)
+	}
+	if signs == 1 {
+		negative = true
+	}
+	whole, fraction := str, ""
+	if idx := strings.IndexByte(str, '.'); idx != -1 {
+		whole, fraction = str[:idx], str[idx+1:]
+	}
+	whole, ok := removeGrouping(whole)
+	if !ok || !allDigits(whole) || !allDigits(fraction) || whole+fraction == "" {
+		return 0, moneyError(s)
+	}
+	var units int64
+	if whole != "" {
+		var err error
+		units, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil || units >= kMaxMoneyUnits {
+			return 0, moneyError(s)
+		}
+	}
+	fraction += "000"
+	cents, _ := strconv.ParseInt(fraction[:2], 10, 64)
+	result := units*100 + cents
+	if fraction[2] >= '5' {
+		result++
+	}
+	if negative {
+		result = -result
+	}
+	return Money(result), nil
+}
+
+// String returns this amount as plain dollars and cents suitable for
+// form values and exports. 123456 -> "1234.56"; -347 -> "-3.47"
+func (m Money) String() string {
+	sign, units, cents := m.parts()
+	return fmt.Sprintf("%s%d.%02d", sign, units, cents)
+}
+
+// Grouped returns this amount as dollars and cents with thousands
+// separators. 123456 -> "1,234.56"; -347 -> "-3.47"
+func (m Money) Grouped() string {
+	sign, units, cents := m.parts()
+	digits := strconv.FormatInt(units, 10)
+	var sb strings.Builder
+	sb.WriteString(sign)
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte(digits[i])
+	}
+	fmt.Fprintf(&sb, ".%02d", cents)
+	return sb.String()
+}
+
+func (m Money) parts() (sign string, units, cents int64) {
+	x := int64(m)
+	if x < 0 {
+		sign = "-"
+		x = -x
+	}
+	return sign, x / 100, x % 100
+}
+
+func moneyError(s string) error {
+	return fmt.Errorf("fin: Invalid amount: %s", s)
+}
+
+// trimSign removes a leading + or - from str. If it removes a -, it
+// returns signs + 1.
+func trimSign(str string, signs int) (string, int) {
+	if strings.HasPrefix(str, "-") {
+		return strings.TrimSpace(str[1:]), signs + 1
+	}
+	if strings.HasPrefix(str, "+") {
+		return strings.TrimSpace(str[1:]), signs
+	}
+	return str, signs
+}
+
+func trimCurrencySymbol(str string) string {
+	for _, symbol := range []string{"$", "€", "£", "¥"} {
+		if strings.HasPrefix(str, symbol) {
+			return strings.TrimSpace(str[len(symbol):])
+		}
+	}
+	return str
+}
+
+// removeGrouping removes the thousands separators from whole. It returns
+// false if the separators are misplaced as in "12,34".
+func removeGrouping(whole string) (string, bool) {
+	if !strings.Contains(whole, ",") {
+		return whole, true
+	}
+	groups := strings.Split(whole, ",")
+	if len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return "", false
+	}
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return "", false
+		}
+	}
+	return strings.Join(groups, ""), true
+}
+
+func allDigits(str string) bool {
+	for i := range str {
+		if str[i] < '0' || str[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
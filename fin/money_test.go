@@ -0,0 +1,68 @@
+package fin
+
+import (
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	verifyParseMoney(t, "3.47", 347)
+	verifyParseMoney(t, "$1,234.56", 123456)
+	verifyParseMoney(t, "1,234,567", 123456700)
+	verifyParseMoney(t, "(45.00)", -4500)
+	verifyParseMoney(t, "($45)", -4500)
+	verifyParseMoney(t, "-$3", -300)
+	verifyParseMoney(t, "$-3", -300)
+	verifyParseMoney(t, "12.50-", -1250)
+	verifyParseMoney(t, " +7.1 ", 710)
+	verifyParseMoney(t, ".05", 5)
+	verifyParseMoney(t, "5.", 500)
+	verifyParseMoney(t, "0.005", 1)
+	verifyParseMoney(t, "-0.005", -1)
+	verifyParseMoney(t, "0.0049999", 0)
+	verifyParseMoney(t, "€9.99", 999)
+	verifyParseMoneyError(t, "")
+	verifyParseMoneyError(t, ".")
+	verifyParseMoneyError(t, "abc")
+	verifyParseMoneyError(t, "1,23.00")
+	verifyParseMoneyError(t, "1234,567")
+	verifyParseMoneyError(t, "(-3)")
+	verifyParseMoneyError(t, "--3")
+	verifyParseMoneyError(t, "3.4.5")
+	verifyParseMoneyError(t, "1e3")
+	verifyParseMoneyError(t, "99999999999999999999")
+}
+
+func TestFormatMoney(t *testing.T) {
+	verifyString(t, "1234.56", Money(123456).String())
+	verifyString(t, "-0.07", Money(-7).String())
+	verifyString(t, "1,234.56", Money(123456).Grouped())
+	verifyString(t, "-1,234,567.89", Money(-123456789).Grouped())
+	verifyString(t, "123.00", Money(12300).Grouped())
+	verifyString(t, "0.00", Money(0).Grouped())
+}
+
+func verifyParseMoney(t *testing.T, s string, expected Money) {
+	t.Helper()
+	actual, err := ParseMoney(s)
+	if err != nil {
+		t.Errorf("Got error parsing %q: %v", s, err)
+		return
+	}
+	if actual != expected {
+		t.Errorf("Parsing %q: expected %d, got %d", s, expected, actual)
+	}
+}
+
+func verifyParseMoneyError(t *testing.T, s string) {
+	t.Helper()
+	if _, err := ParseMoney(s); err == nil {
+		t.Errorf("Expected error parsing %q", s)
+	}
+}
+
+func verifyString(t *testing.T, expected, actual string) {
+	t.Helper()
+	if actual != expected {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+}
@@ -0,0 +1,90 @@
+package fin
+
+// AccountType is the type of an account. The zero value is
+// CheckingAccount so that accounts created before account types existed
+// count as assets.
+type AccountType int
+
+const (
+	CheckingAccount AccountType = iota
+	SavingsAccount
+	CashAccount
+	InvestmentAccount
+	OtherAssetAccount
+	CreditCardAccount
+	LoanAccount
+	OtherLiabilityAccount
+	// Placeholder for type count. Does not represent an actual type.
+	// New types must be inserted right before this one.
+	AccountTypeCount
+)
+
+// ToAccountType takes an int that ToInt returned and converts it back to
+// an AccountType. On success, returns the AccountType and true. If x is
+// out of range, returns AccountTypeCount and false.
+func ToAccountType(x int) (AccountType, bool) {
+	if x < 0 || x >= int(AccountTypeCount) {
+		return AccountTypeCount, false
+	}
+	return AccountType(x), true
+}
+
+func (a AccountType) String() string {
+	switch a {
+	case CheckingAccount:
+		return "checking"
+	case SavingsAccount:
+		return "savings"
+	case CashAccount:
+		return "cash"
+	case InvestmentAccount:
+		return "investment"
+	case OtherAssetAccount:
+		return "other asset"
+	case CreditCardAccount:
+		return "credit card"
+	case LoanAccount:
+		return "loan"
+	case OtherLiabilityAccount:
+		return "other liability"
+	default:
+		return "unknown"
+	}
+}
+
+// ToInt maps an AccountType to an int in a way that is suitable for
+// persistent storage.
+func (a AccountType) ToInt() int {
+	return int(a)
+}
+
+// IsLiability returns true if accounts of this type are liabilities
+// rather than assets.
+func (a AccountType) IsLiability() bool {
+	return a >= CreditCardAccount && a < AccountTypeCount
+}
+
+// NetWorth totals account balances into assets and liabilities.
+type NetWorth struct {
+	// The total balance of asset accounts.
+	Assets int64
+	// The total amount owed on liability accounts. Positive means money
+	// is owed.
+	Liabilities int64
+}
+
+// Include includes the balance of an account of the given type.
+// balance follows the sign convention of Account.Balance so that the
+// balance of a credit card with charges on it is negative.
+func (n *NetWorth) Include(accountType AccountType, balance int64) {
+	if accountType.IsLiability() {
+		n.Liabilities -= balance
+	} else {
+		n.Assets += balance
+	}
+}
+
+// Total returns Assets minus Liabilities.
+func (n *NetWorth) Total() int64 {
+	return n.Assets - n.Liabilities
+}
@@ -0,0 +1,38 @@
+package fin
+
+import (
+	"testing"
+)
+
+func TestAccountType(t *testing.T) {
+	for i := 0; i < int(AccountTypeCount); i++ {
+		accountType, ok := ToAccountType(i)
+		if !ok || accountType.ToInt() != i {
+			t.Errorf("Expected %d to round trip", i)
+		}
+	}
+	if _, ok := ToAccountType(int(AccountTypeCount)); ok {
+		t.Error("Expected AccountTypeCount to be out of range.")
+	}
+	if CheckingAccount.IsLiability() || OtherAssetAccount.IsLiability() {
+		t.Error("Expected asset account types.")
+	}
+	if !CreditCardAccount.IsLiability() || !OtherLiabilityAccount.IsLiability() {
+		t.Error("Expected liability account types.")
+	}
+}
+
+func TestNetWorth(t *testing.T) {
+	var netWorth NetWorth
+	netWorth.Include(CheckingAccount, 150000)
+	netWorth.Include(InvestmentAccount, 1000000)
+	netWorth.Include(CreditCardAccount, -45000)
+	netWorth.Include(LoanAccount, -800000)
+	expected := NetWorth{Assets: 1150000, Liabilities: 845000}
+	if netWorth != expected {
+		t.Errorf("Expected %v, got %v", expected, netWorth)
+	}
+	if netWorth.Total() != 305000 {
+		t.Errorf("Expected 305000, got %d", netWorth.Total())
+	}
+}
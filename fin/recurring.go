@@ -2,6 +2,7 @@
 package fin
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -14,6 +15,14 @@ const (
 	Years
 	Days
 	Weeks
+	// Months on the last day of the month
+	MonthEnds
+	// Months on the nth weekday of the month e.g second Tuesday
+	MonthWeekdays
+	// Twice a month on two days of the month e.g 1st and 15th
+	HalfMonths
+	// Months on the last business day of the month
+	MonthBusinessEnds
 	// Placeholder for unit count. Does not represent an actual unit.
 	// New units must be inserted right before this one.
 	RecurringUnitCount
@@ -39,6 +48,14 @@ func (r RecurringUnit) String() string {
 		return "months"
 	case Years:
 		return "years"
+	case MonthEnds:
+		return "months on last day"
+	case MonthWeekdays:
+		return "months on weekday"
+	case HalfMonths:
+		return "half months"
+	case MonthBusinessEnds:
+		return "months on last business day"
 	default:
 		return "unknown"
 	}
@@ -56,10 +73,38 @@ type RecurringPeriod struct {
 	Count int
 	// The time unit.
 	Unit RecurringUnit
-	// Specifies the desired day of the month. Relevant only when Unit is Months.
+	// Specifies the desired day of the month. Relevant only when Unit is
+	// Months or HalfMonths.
 	// If <= 0 when Unit is Months, then RecurringPeriod works just like
-	// time.AddDate(0, Count, 0)
+	// time.AddDate(0, Count, 0). If <= 0 when Unit is HalfMonths, it means
+	// the 1st.
 	DayOfMonth int
+	// The second day of the month. Relevant only when Unit is HalfMonths.
+	// If <= 0, it means the 15th.
+	SecondDayOfMonth int
+	// The day of the week. Relevant only when Unit is MonthWeekdays.
+	Weekday time.Weekday
+	// Which Weekday in the month. 1 through 4 mean the first through the
+	// fourth; any other value means the last. Relevant only when Unit is
+	// MonthWeekdays.
+	WeekOfMonth int
+}
+
+func (r RecurringPeriod) String() string {
+	result := fmt.Sprintf("Every %d %v", r.Count, r.Unit)
+	switch r.Unit {
+	case HalfMonths:
+		first, second := r.halfMonthDays()
+		return fmt.Sprintf("%s (%s and %s)", result, ordinal(first), ordinal(second))
+	case MonthWeekdays:
+		week := "last"
+		if r.WeekOfMonth >= 1 && r.WeekOfMonth <= 4 {
+			week = ordinal(r.WeekOfMonth)
+		}
+		return fmt.Sprintf("%s (%s %v)", result, week, r.Weekday)
+	default:
+		return result
+	}
 }
 
 // AddTo returns date + (this instance). If the Count field of this instance
@@ -82,15 +127,278 @@ func (r RecurringPeriod) AddTo(date time.Time) time.Time {
 		return addMonths(date, count, r.DayOfMonth)
 	case Years:
 		return date.AddDate(count, 0, 0)
+	case MonthEnds:
+		return lastDayOfMonth(addMonths(date, count, 1))
+	case MonthWeekdays:
+		return weekdayOfMonth(
+			addMonths(date, count, 1), r.WeekOfMonth, r.Weekday)
+	case HalfMonths:
+		for i := 0; i < count; i++ {
+			date = r.nextHalfMonth(date)
+		}
+		return date
+	case MonthBusinessEnds:
+		return lastBusinessDayOfMonth(addMonths(date, count, 1))
 	default:
 		panic("Unit field not a valid RecurringUnit.")
 	}
 }
 
+// halfMonthDays returns the two days of the month for HalfMonths in
+// ascending order.
+func (r RecurringPeriod) halfMonthDays() (first, second int) {
+	first, second = r.DayOfMonth, r.SecondDayOfMonth
+	if first <= 0 {
+		first = 1
+	}
+	if second <= 0 {
+		second = 15
+	}
+	if first > second {
+		first, second = second, first
+	}
+	return
+}
+
+// nextHalfMonth returns the first day after date that falls on one of the
+// days for HalfMonths.
+func (r RecurringPeriod) nextHalfMonth(date time.Time) time.Time {
+	first, second := r.halfMonthDays()
+	if result := addMonths(date, 0, first); result.After(date) {
+		return result
+	}
+	if result := addMonths(date, 0, second); result.After(date) {
+		return result
+	}
+	return addMonths(date, 1, first)
+}
+
+// Escalation changes the amount of a recurring entry after every so many
+// occurrences such as a yearly rent increase. The zero value means no
+// escalation.
+type Escalation struct {
+	// The amount in one cent increments to increase the total by. The
+	// amount is spread across the lines in proportion to their amounts.
+	// Positive means the total gets bigger whether it is an expense or
+	// income; negative means it gets smaller.
+	Amount int64
+
+	// The percentage to increase every line by in hundredths of a percent
+	// so that 250 means 2.5%. Negative means decrease.
+	Percent int64
+
+	// The number of occurrences between escalations. Values < 1 mean no
+	// escalation.
+	Every int
+
+	// The number of occurrences since the last escalation.
+	Count int
+}
+
+// Active returns true if this instance changes the amount.
+func (e Escalation) Active() bool {
+	return e.Every > 0 && (e.Amount != 0 || e.Percent != 0)
+}
+
+func (e Escalation) String() string {
+	if !e.Active() {
+		return "none"
+	}
+	var change string
+	if e.Percent != 0 {
+		change = FormatUSD(e.Percent) + "%"
+	}
+	if e.Amount != 0 {
+		if change != "" {
+			change += " and "
+		}
+		change += FormatUSD(e.Amount)
+	}
+	return fmt.Sprintf("%s every %d", change, e.Every)
+}
+
+// Escalate changes the amounts of cp by this escalation once. Percentage
+// changes and each line's share of Amount are rounded to the nearest cent;
+// the last line absorbs any rounding so that the total changes by exactly
+// Amount.
+func (e Escalation) Escalate(cp *CatPayment) {
+	catRecs := cp.CatRecs()
+	if len(catRecs) == 0 {
+		return
+	}
+	var total int64
+	for i := range catRecs {
+		catRecs[i].Amount += percentOf(catRecs[i].Amount, e.Percent)
+		catRecs[i].CurrencyAmount += percentOf(
+			catRecs[i].CurrencyAmount, e.Percent)
+		total += catRecs[i].Amount
+	}
+	if total == 0 {
+		// No proportions to go by, so the first line gets it all.
+		if catRecs[0].Amount < 0 {
+			addToCatRec(&catRecs[0], -e.Amount)
+		} else {
+			addToCatRec(&catRecs[0], e.Amount)
+		}
+	} else {
+		delta := e.Amount
+		if total < 0 {
+			delta = -delta
+		}
+		remaining := delta
+		last := len(catRecs) - 1
+		for i := range catRecs {
+			share := remaining
+			if i != last {
+				share = convert(
+					delta, float64(catRecs[i].Amount)/float64(total))
+			}
+			addToCatRec(&catRecs[i], share)
+			remaining -= share
+		}
+	}
+	cpb := CatPaymentBuilder{}
+	cpb.SetPaymentId(cp.PaymentId()).SetReconciled(cp.Reconciled())
+	for _, cr := range catRecs {
+		cpb.AddCatRec(cr)
+	}
+	*cp = cpb.Build()
+}
+
+// addToCatRec adds amount to cr keeping the exchange rate between Amount
+// and CurrencyAmount the same.
+func addToCatRec(cr *CatRec, amount int64) {
+	oldAmount := cr.Amount
+	cr.Amount += amount
+	if cr.Currency != "" && oldAmount != 0 {
+		cr.CurrencyAmount = convert(
+			cr.CurrencyAmount, float64(cr.Amount)/float64(oldAmount))
+	}
+}
+
+// advance counts one occurrence and returns true if it is time to escalate.
+func (e *Escalation) advance() bool {
+	if !e.Active() {
+		return false
+	}
+	e.Count++
+	if e.Count < e.Every {
+		return false
+	}
+	e.Count = 0
+	return true
+}
+
+// kMaxBusinessDayAdjustment is the most days Adjust moves a date so that
+// a calendar with no business days can't make it loop forever.
+const kMaxBusinessDayAdjustment = 31
+
+// BusinessDayAdjustment says how to move the date of a generated entry that
+// falls on a weekend or holiday. The zero value is NoAdjustment.
+type BusinessDayAdjustment int
+
+const (
+	NoAdjustment BusinessDayAdjustment = iota
+	PreviousBusinessDay
+	NextBusinessDay
+	// Placeholder for adjustment count. Does not represent an actual
+	// adjustment. New adjustments must be inserted right before this one.
+	BusinessDayAdjustmentCount
+)
+
+// ToBusinessDayAdjustment takes an int that ToInt returned and converts it
+// back to a BusinessDayAdjustment. On success, returns the
+// BusinessDayAdjustment and true. If x is out of range, returns
+// BusinessDayAdjustmentCount and false.
+func ToBusinessDayAdjustment(x int) (BusinessDayAdjustment, bool) {
+	if x < 0 || x >= int(BusinessDayAdjustmentCount) {
+		return BusinessDayAdjustmentCount, false
+	}
+	return BusinessDayAdjustment(x), true
+}
+
+func (a BusinessDayAdjustment) String() string {
+	switch a {
+	case NoAdjustment:
+		return "none"
+	case PreviousBusinessDay:
+		return "previous business day"
+	case NextBusinessDay:
+		return "next business day"
+	default:
+		return "unknown"
+	}
+}
+
+// ToInt maps a BusinessDayAdjustment to an int in a way that is suitable
+// for persistent storage.
+func (a BusinessDayAdjustment) ToInt() int {
+	return int(a)
+}
+
+// Adjust returns date moved to the previous or next business day if it
+// falls on a weekend or on one of the holidays. holidays may be nil
+// meaning there are no holidays. If there is no business day within
+// kMaxBusinessDayAdjustment days, Adjust returns date unchanged.
+func (a BusinessDayAdjustment) Adjust(
+	date time.Time, holidays HolidayCalendar) time.Time {
+	var step int
+	switch a {
+	case PreviousBusinessDay:
+		step = -1
+	case NextBusinessDay:
+		step = 1
+	default:
+		return date
+	}
+	adjusted := date
+	for i := 0; i <= kMaxBusinessDayAdjustment; i++ {
+		if IsBusinessDay(adjusted, holidays) {
+			return adjusted
+		}
+		adjusted = adjusted.AddDate(0, 0, step)
+	}
+	return date
+}
+
+// HolidayCalendar tells which dates are holidays.
+type HolidayCalendar interface {
+	IsHoliday(date time.Time) bool
+}
+
+// Holidays is a HolidayCalendar made from a set of dates. The zero value
+// has no holidays.
+type Holidays map[time.Time]bool
+
+// NewHolidays returns a Holidays containing dates.
+func NewHolidays(dates ...time.Time) Holidays {
+	result := make(Holidays, len(dates))
+	for _, date := range dates {
+		result[toYMD(date)] = true
+	}
+	return result
+}
+
+// IsHoliday returns true if the day of date is in this instance.
+func (h Holidays) IsHoliday(date time.Time) bool {
+	return h[toYMD(date)]
+}
+
+// IsBusinessDay returns true if date falls on neither a weekend nor one of
+// the holidays. holidays may be nil meaning there are no holidays.
+func IsBusinessDay(date time.Time, holidays HolidayCalendar) bool {
+	switch date.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return holidays == nil || !holidays.IsHoliday(date)
+}
+
 // RecurringEntry represents a recurring entry.
 type RecurringEntry struct {
 	// The entry, the date field in this entry corresponds to the date of the
-	// next entry that the Advance method generates.
+	// next entry that the Advance method generates before any business day
+	// adjustment.
 	Entry
 
 	// The period of time between each generated entries
@@ -99,39 +407,79 @@ type RecurringEntry struct {
 	// The number of entries left to generate, a negative number means
 	// unlimited.
 	NumLeft int
+
+	// If non-zero, no entries are generated for dates after EndDate.
+	// Like NumLeft, EndDate applies to the dates before any business day
+	// adjustment.
+	EndDate time.Time
+
+	// How to adjust the dates of generated entries that fall on a weekend
+	// or holiday.
+	Adjustment BusinessDayAdjustment
+
+	// If true, the amount is only an estimate so generated entries are
+	// not reviewed until the user confirms the real amount.
+	Estimated bool
+
+	// How the amount changes as this recurring entry advances.
+	Escalation Escalation
+}
+
+// Done returns true if this recurring entry won't generate any more entries
+// either because NumLeft reached 0 or because its date is past EndDate.
+func (r *RecurringEntry) Done() bool {
+	return r.NumLeft == 0 || (!r.EndDate.IsZero() && r.Date.After(r.EndDate))
+}
+
+// NextDate returns the date of the next entry that this instance generates
+// after business day adjustment. holidays may be nil meaning there are
+// no holidays.
+func (r *RecurringEntry) NextDate(holidays HolidayCalendar) time.Time {
+	return r.Adjustment.Adjust(r.Date, holidays)
 }
 
 // AdvanceOnce advances this recurring entry exactly once storing the
-// generated entry at newEntry.
-// newEntry may be nil.
+// generated entry at newEntry. The date of the generated entry is adjusted
+// to a business day according to the Adjustment field using holidays.
+// newEntry and holidays may be nil.
 // Returns true if this instance advanced or false if it did not.
-// This instance won't advance if NumLeft is already 0.
-func (r *RecurringEntry) AdvanceOnce(newEntry *Entry) (advanced bool) {
+// This instance won't advance if Done returns true.
+func (r *RecurringEntry) AdvanceOnce(
+	newEntry *Entry, holidays HolidayCalendar) (advanced bool) {
 	// Are we out?
-	if r.NumLeft == 0 {
+	if r.Done() {
 		return false
 	}
 	if newEntry != nil {
 		*newEntry = r.Entry
 		newEntry.Id = 0
+		newEntry.Date = r.NextDate(holidays)
+		if r.Estimated {
+			newEntry.Status = NotReviewed
+		}
 	}
 	r.Date = r.Period.AddTo(r.Date)
 	if r.NumLeft > 0 {
 		r.NumLeft--
 	}
+	if r.Escalation.advance() {
+		r.Escalation.Escalate(&r.CatPayment)
+	}
 	return true
 }
 
 // Advance advances this recurring entry through the current date adding the
-// generated entries to appendedNewEntries. After this returns, the date
-// of this instance is after currentDate unless the NumLeft field reached
-// zero before this instance could be advanced passed currentDate. Returns
-// true if Advance generated new entries or false otherwise.
+// generated entries to appendedNewEntries. After this returns, the adjusted
+// date of this instance is after currentDate unless this instance became
+// done before it could be advanced passed currentDate. holidays may be
+// nil. Returns true if Advance generated new entries or false otherwise.
 func (r *RecurringEntry) Advance(
-	currentDate time.Time, appendedNewEntries *[]*Entry) (advanced bool) {
-	for !r.Date.After(currentDate) {
+	currentDate time.Time,
+	holidays HolidayCalendar,
+	appendedNewEntries *[]*Entry) (advanced bool) {
+	for !r.NextDate(holidays).After(currentDate) {
 		var newEntry Entry
-		if !r.AdvanceOnce(&newEntry) {
+		if !r.AdvanceOnce(&newEntry, holidays) {
 			break
 		}
 		*appendedNewEntries = append(*appendedNewEntries, &newEntry)
@@ -150,6 +498,59 @@ func withDayOfMonth(date time.Time, dayOfMonth int) time.Time {
 		date.Second(), date.Nanosecond(), date.Location())
 }
 
+// toYMD returns the day of date at midnight UTC.
+func toYMD(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// lastDayOfMonth returns the last day of the month containing date.
+func lastDayOfMonth(date time.Time) time.Time {
+	return withDayOfMonth(date, 1).AddDate(0, 1, -1)
+}
+
+// lastBusinessDayOfMonth returns the last weekday of the month containing
+// date. It does not account for holidays.
+func lastBusinessDayOfMonth(date time.Time) time.Time {
+	result := lastDayOfMonth(date)
+	switch result.Weekday() {
+	case time.Saturday:
+		return result.AddDate(0, 0, -1)
+	case time.Sunday:
+		return result.AddDate(0, 0, -2)
+	default:
+		return result
+	}
+}
+
+// weekdayOfMonth returns the nth weekday of the month containing date.
+// n works like the WeekOfMonth field of RecurringPeriod.
+func weekdayOfMonth(date time.Time, n int, weekday time.Weekday) time.Time {
+	if n >= 1 && n <= 4 {
+		first := withDayOfMonth(date, 1)
+		offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, offset+7*(n-1))
+	}
+	last := lastDayOfMonth(date)
+	offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+	return last.AddDate(0, 0, -offset)
+}
+
+// ordinal returns 1st for 1, 2nd for 2 etc.
+func ordinal(x int) string {
+	suffix := "th"
+	if x%100 < 11 || x%100 > 13 {
+		switch x % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", x, suffix)
+}
+
 func addMonths(date time.Time, months, dayOfMonth int) time.Time {
 	// dayOfMonth cannot exceed 31
 	if dayOfMonth > 31 {
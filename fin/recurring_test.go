@@ -2,6 +2,7 @@ package fin
 
 import (
 	"github.com/keep94/toolbox/date_util"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -81,6 +82,93 @@ func TestPeriod(t *testing.T) {
 		period.AddTo(date_util.YMD(2015, 8, 20)))
 }
 
+func TestMonthEnds(t *testing.T) {
+	period := RecurringPeriod{Count: 1, Unit: MonthEnds}
+	verifyDate(
+		t,
+		date_util.YMD(2016, 2, 29),
+		period.AddTo(date_util.YMD(2016, 1, 31)))
+	verifyDate(
+		t,
+		date_util.YMD(2016, 3, 31),
+		period.AddTo(date_util.YMD(2016, 2, 29)))
+	period.Count = 3
+	verifyDate(
+		t,
+		date_util.YMD(2016, 4, 30),
+		period.AddTo(date_util.YMD(2016, 1, 15)))
+}
+
+func TestMonthWeekdays(t *testing.T) {
+	period := RecurringPeriod{
+		Count: 1, Unit: MonthWeekdays, Weekday: time.Tuesday, WeekOfMonth: 2}
+	verifyDate(
+		t,
+		date_util.YMD(2016, 2, 9),
+		period.AddTo(date_util.YMD(2016, 1, 12)))
+	period.Count = 3
+	verifyDate(
+		t,
+		date_util.YMD(2016, 5, 10),
+		period.AddTo(date_util.YMD(2016, 2, 9)))
+	period = RecurringPeriod{
+		Count: 1, Unit: MonthWeekdays, Weekday: time.Friday, WeekOfMonth: -1}
+	verifyDate(
+		t,
+		date_util.YMD(2016, 3, 25),
+		period.AddTo(date_util.YMD(2016, 2, 26)))
+	if out := period.String(); out != "Every 1 months on weekday (last Friday)" {
+		t.Errorf("Got %s", out)
+	}
+}
+
+func TestHalfMonths(t *testing.T) {
+	period := RecurringPeriod{Count: 1, Unit: HalfMonths}
+	verifyDate(
+		t,
+		date_util.YMD(2016, 1, 15),
+		period.AddTo(date_util.YMD(2016, 1, 1)))
+	verifyDate(
+		t,
+		date_util.YMD(2016, 2, 1),
+		period.AddTo(date_util.YMD(2016, 1, 15)))
+	period.Count = 3
+	verifyDate(
+		t,
+		date_util.YMD(2016, 2, 15),
+		period.AddTo(date_util.YMD(2016, 1, 1)))
+	period = RecurringPeriod{
+		Count: 1, Unit: HalfMonths, DayOfMonth: 31, SecondDayOfMonth: 15}
+	verifyDate(
+		t,
+		date_util.YMD(2016, 2, 29),
+		period.AddTo(date_util.YMD(2016, 2, 15)))
+	verifyDate(
+		t,
+		date_util.YMD(2016, 3, 15),
+		period.AddTo(date_util.YMD(2016, 2, 29)))
+	if out := period.String(); out != "Every 1 half months (15th and 31st)" {
+		t.Errorf("Got %s", out)
+	}
+}
+
+func TestMonthBusinessEnds(t *testing.T) {
+	period := RecurringPeriod{Count: 1, Unit: MonthBusinessEnds}
+	verifyDate(
+		t,
+		date_util.YMD(2016, 2, 29),
+		period.AddTo(date_util.YMD(2016, 1, 29)))
+	verifyDate(
+		t,
+		date_util.YMD(2016, 4, 29),
+		period.AddTo(date_util.YMD(2016, 3, 31)))
+	period.Count = 5
+	verifyDate(
+		t,
+		date_util.YMD(2016, 7, 29),
+		period.AddTo(date_util.YMD(2016, 2, 29)))
+}
+
 func TestDayOfMonth(t *testing.T) {
 	var period RecurringPeriod
 	period.Unit = Months
@@ -135,25 +223,25 @@ func TestInfiniteRecurring(t *testing.T) {
 	var entries []*Entry
 
 	// Shouldn't advance too early
-	if output := r.Advance(date_util.YMD(2015, 7, 19), &entries); output {
+	if output := r.Advance(date_util.YMD(2015, 7, 19), nil, &entries); output {
 		t.Error("Did not expect advancement")
 	}
 	verifyEntries(t, entries)
 
 	// advance 1
-	if output := r.Advance(date_util.YMD(2015, 7, 20), &entries); !output {
+	if output := r.Advance(date_util.YMD(2015, 7, 20), nil, &entries); !output {
 		t.Error("expected advancement")
 	}
 	verifyEntries(t, entries, date_util.YMD(2015, 7, 20))
 
 	// We already advanced for this date, shouldn't advance again
-	if output := r.Advance(date_util.YMD(2015, 7, 20), &entries); output {
+	if output := r.Advance(date_util.YMD(2015, 7, 20), nil, &entries); output {
 		t.Error("Did not expect advancement")
 	}
 	verifyEntries(t, entries, date_util.YMD(2015, 7, 20))
 
 	// Advance 2
-	if output := r.Advance(date_util.YMD(2015, 9, 20), &entries); !output {
+	if output := r.Advance(date_util.YMD(2015, 9, 20), nil, &entries); !output {
 		t.Error("expected advancement")
 	}
 	verifyEntries(t, entries,
@@ -178,13 +266,13 @@ func TestFiniteRecurringEverySixMonths(t *testing.T) {
 	var entries []*Entry
 
 	// Shouldn't advance too early
-	if output := r.Advance(date_util.YMD(2015, 7, 31), &entries); output {
+	if output := r.Advance(date_util.YMD(2015, 7, 31), nil, &entries); output {
 		t.Error("Did not expect advancement")
 	}
 	verifyEntries(t, entries)
 
 	// advance 2
-	if output := r.Advance(date_util.YMD(2016, 2, 1), &entries); !output {
+	if output := r.Advance(date_util.YMD(2016, 2, 1), nil, &entries); !output {
 		t.Error("expected advancement")
 	}
 	verifyEntries(t, entries,
@@ -192,7 +280,7 @@ func TestFiniteRecurringEverySixMonths(t *testing.T) {
 		date_util.YMD(2016, 2, 1))
 
 	// Advance 2 more but we only advance 1 because we ran out
-	if output := r.Advance(date_util.YMD(2017, 2, 1), &entries); !output {
+	if output := r.Advance(date_util.YMD(2017, 2, 1), nil, &entries); !output {
 		t.Error("expected advancement")
 	}
 	verifyEntries(t, entries,
@@ -201,7 +289,7 @@ func TestFiniteRecurringEverySixMonths(t *testing.T) {
 		date_util.YMD(2016, 8, 1))
 
 	// Try to advance again but we can't because we are out
-	if output := r.Advance(date_util.YMD(2017, 8, 1), &entries); output {
+	if output := r.Advance(date_util.YMD(2017, 8, 1), nil, &entries); output {
 		t.Error("Did not expect advancement")
 	}
 	verifyEntries(t, entries,
@@ -225,7 +313,7 @@ func TestRecurringLastDayOfMonth(t *testing.T) {
 	var entries []*Entry
 
 	// advance 1
-	if output := r.Advance(date_util.YMD(2015, 7, 1), &entries); !output {
+	if output := r.Advance(date_util.YMD(2015, 7, 1), nil, &entries); !output {
 		t.Error("expected advancement")
 	}
 	verifyEntries(t, entries,
@@ -233,6 +321,169 @@ func TestRecurringLastDayOfMonth(t *testing.T) {
 		date_util.YMD(2015, 7, 1))
 }
 
+func TestRecurringEndDate(t *testing.T) {
+	var r RecurringEntry
+	r.Date = date_util.YMD(2015, 5, 15)
+	r.NumLeft = -1
+	r.EndDate = date_util.YMD(2015, 7, 15)
+	var entries []*Entry
+
+	if output := r.Advance(date_util.YMD(2015, 12, 31), nil, &entries); !output {
+		t.Error("expected advancement")
+	}
+	verifyEntries(t, entries,
+		date_util.YMD(2015, 5, 15),
+		date_util.YMD(2015, 6, 15),
+		date_util.YMD(2015, 7, 15))
+	if !r.Done() {
+		t.Error("Expected recurring entry to be done")
+	}
+	if r.AdvanceOnce(nil, nil) {
+		t.Error("Did not expect advancement past end date")
+	}
+	verifyDate(t, date_util.YMD(2015, 8, 15), r.Date)
+}
+
+func TestRecurringBusinessDayAdjustment(t *testing.T) {
+	holidays := NewHolidays(date_util.YMD(2015, 7, 3))
+	var r RecurringEntry
+	r.Date = date_util.YMD(2015, 7, 4)
+	r.NumLeft = -1
+	r.Period.DayOfMonth = 4
+	r.Adjustment = PreviousBusinessDay
+	var entries []*Entry
+
+	// July 4 is a Saturday and July 3 a holiday so move to July 2.
+	if output := r.Advance(date_util.YMD(2015, 7, 2), holidays, &entries); !output {
+		t.Error("expected advancement")
+	}
+	verifyEntries(t, entries, date_util.YMD(2015, 7, 2))
+	verifyDate(t, date_util.YMD(2015, 8, 4), r.Date)
+
+	r.Date = date_util.YMD(2015, 10, 4)
+	r.Adjustment = NextBusinessDay
+	entries = nil
+
+	// October 4 is a Sunday so entry isn't due until Monday October 5.
+	if output := r.Advance(date_util.YMD(2015, 10, 4), holidays, &entries); output {
+		t.Error("Did not expect advancement")
+	}
+	if output := r.Advance(date_util.YMD(2015, 10, 5), holidays, &entries); !output {
+		t.Error("expected advancement")
+	}
+	verifyEntries(t, entries, date_util.YMD(2015, 10, 5))
+
+	verifyDate(
+		t,
+		date_util.YMD(2015, 7, 3),
+		NoAdjustment.Adjust(date_util.YMD(2015, 7, 3), holidays))
+	if !IsBusinessDay(date_util.YMD(2015, 7, 3), nil) {
+		t.Error("Expected July 3 to be a business day without holidays")
+	}
+
+	// With no business days at all, dates stay put.
+	verifyDate(
+		t,
+		date_util.YMD(2015, 7, 4),
+		NextBusinessDay.Adjust(date_util.YMD(2015, 7, 4), allHolidays{}))
+	verifyDate(
+		t,
+		date_util.YMD(2015, 7, 4),
+		PreviousBusinessDay.Adjust(date_util.YMD(2015, 7, 4), allHolidays{}))
+}
+
+func TestRecurringEscalation(t *testing.T) {
+	var r RecurringEntry
+	r.Date = date_util.YMD(2015, 1, 1)
+	r.NumLeft = -1
+	r.Period.Count = 1
+	r.Period.Unit = Years
+	r.CatPayment = NewCatPayment(NewCat("0:7"), 100000, false, 1)
+	r.Escalation = Escalation{Amount: 1000, Percent: 300, Every: 2}
+	var entries []*Entry
+
+	r.Advance(date_util.YMD(2019, 1, 1), nil, &entries)
+	expectedTotals := []int64{-100000, -100000, -104000, -104000, -108120}
+	if len(entries) != len(expectedTotals) {
+		t.Fatalf("Expected %d entries, got %d", len(expectedTotals), len(entries))
+	}
+	for i := range entries {
+		if total := entries[i].Total(); total != expectedTotals[i] {
+			t.Errorf("Expected total %d, got %d", expectedTotals[i], total)
+		}
+	}
+	if r.Escalation.Count != 1 {
+		t.Errorf("Expected count 1, got %d", r.Escalation.Count)
+	}
+
+	// Income gets bigger too
+	cp := NewCatPayment(NewCat("1:2"), -50000, false, 1)
+	Escalation{Amount: 500, Percent: -1000, Every: 1}.Escalate(&cp)
+	if total := cp.Total(); total != 45500 {
+		t.Errorf("Expected total 45500, got %d", total)
+	}
+
+	// Amounts in another currency escalate along with the amount.
+	cpb := CatPaymentBuilder{}
+	cp = cpb.AddCatRec(CatRec{
+		Cat:            NewCat("2:2"),
+		Amount:         10000,
+		Currency:       "EUR",
+		CurrencyAmount: 9000}).SetPaymentId(1).Build()
+	Escalation{Amount: 1000, Percent: 1000, Every: 1}.Escalate(&cp)
+	catRecs := cp.CatRecs()
+	if len(catRecs) != 1 {
+		t.Fatalf("Expected 1 CatRec, got %v", catRecs)
+	}
+	if catRecs[0].Amount != 12000 {
+		t.Errorf("Expected 12000, got %d", catRecs[0].Amount)
+	}
+	if catRecs[0].Currency != "EUR" || catRecs[0].CurrencyAmount != 10800 {
+		t.Errorf("Expected EUR 10800, got %s %d", catRecs[0].Currency, catRecs[0].CurrencyAmount)
+	}
+
+	// The fixed amount is spread across lines by their amounts.
+	cp = cpb.AddCatRec(
+		CatRec{Cat: NewCat("0:7"), Amount: 2000}).AddCatRec(
+		CatRec{Cat: NewCat("0:8"), Amount: 3000}).AddCatRec(
+		CatRec{Cat: NewCat("0:9"), Amount: 5000}).SetPaymentId(1).Build()
+	Escalation{Amount: 1001, Every: 1}.Escalate(&cp)
+	if total := cp.Total(); total != -11001 {
+		t.Errorf("Expected total -11001, got %d", total)
+	}
+	expected := map[Cat]int64{
+		NewCat("0:7"): 2200, NewCat("0:8"): 3300, NewCat("0:9"): 5501}
+	if output := catRecAmounts(&cp); !reflect.DeepEqual(expected, output) {
+		t.Errorf("Expected %v, got %v", expected, output)
+	}
+}
+
+// catRecAmounts returns the amount of each category in cp.
+func catRecAmounts(cp *CatPayment) map[Cat]int64 {
+	result := make(map[Cat]int64)
+	for _, cr := range cp.CatRecs() {
+		result[cr.Cat] = cr.Amount
+	}
+	return result
+}
+
+func TestRecurringEstimated(t *testing.T) {
+	var r RecurringEntry
+	r.Date = date_util.YMD(2015, 7, 20)
+	r.NumLeft = -1
+	r.Status = Reviewed
+	var entry Entry
+	r.AdvanceOnce(&entry, nil)
+	if entry.Status != Reviewed {
+		t.Errorf("Expected reviewed, got %v", entry.Status)
+	}
+	r.Estimated = true
+	r.AdvanceOnce(&entry, nil)
+	if entry.Status != NotReviewed {
+		t.Errorf("Expected not reviewed, got %v", entry.Status)
+	}
+}
+
 func verifyEntries(
 	t *testing.T, entries []*Entry, times ...time.Time) {
 	if len(entries) != len(times) {
@@ -254,3 +505,11 @@ func verifyDate(t *testing.T, expected, actual time.Time) {
 		t.Errorf("Expected %v, got %v", expected, actual)
 	}
 }
+
+// allHolidays is a HolidayCalendar where every day is a holiday.
+type allHolidays struct {
+}
+
+func (a allHolidays) IsHoliday(date time.Time) bool {
+	return true
+}
@@ -0,0 +1,35 @@
+package fin
+
+import (
+	"fmt"
+	"time"
+)
+
+// EntryRevision records a single add, update, or delete of an entry.
+type EntryRevision struct {
+	Id int64
+	// The id of the entry changed.
+	EntryId int64
+	// The id of the user who made the change. 0 means unknown.
+	UserId int64
+	// When the change was made.
+	Time time.Time
+	// The entry before the change. nil if the change added the entry.
+	Before *Entry
+	// The entry after the change. nil if the change deleted the entry.
+	After *Entry
+}
+
+func (r *EntryRevision) String() string {
+	return fmt.Sprintf("%v", *r)
+}
+
+// Added returns true if this revision added the entry.
+func (r *EntryRevision) Added() bool {
+	return r.Before == nil
+}
+
+// Deleted returns true if this revision deleted the entry.
+func (r *EntryRevision) Deleted() bool {
+	return r.After == nil
+}
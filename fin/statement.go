@@ -0,0 +1,39 @@
+package fin
+
+import (
+	"fmt"
+	"time"
+)
+
+// Statement is a session reconciling an account against a bank statement.
+type Statement struct {
+	Id int64
+	// The account being reconciled.
+	AcctId int64
+	// The statement date.
+	Date time.Time
+	// The statement ending balance in the currency of the account.
+	Balance int64
+	// The ids of the entries cleared in this session. While the session
+	// is open, these are the entries selected so far.
+	EntryIds []int64
+	// When the session was finished. Zero while the session is open.
+	Finished time.Time
+}
+
+func (s *Statement) String() string {
+	return fmt.Sprintf("%v", *s)
+}
+
+// Done returns true if this session is finished.
+func (s *Statement) Done() bool {
+	return !s.Finished.IsZero()
+}
+
+// Difference returns how far the account is from the statement ending
+// balance. rBalance is the reconciled balance of the account; cleared is
+// the total of the entries selected in this session. The session can be
+// finished only when Difference returns 0.
+func (s *Statement) Difference(rBalance, cleared int64) int64 {
+	return rBalance + cleared - s.Balance
+}
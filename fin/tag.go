@@ -0,0 +1,72 @@
+package fin
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TagSet is a set of tags. Tags are labels such as "vacation-2026" or
+// "tax-deductible" that cut across categories. Tags are always lower case
+// and never contain commas or whitespace.
+type TagSet map[string]bool
+
+// NormalizeTag converts s to a tag by trimming whitespace and converting
+// to lower case. Returns the empty string if s is not a valid tag. A
+// valid tag has at least one letter or digit.
+func NormalizeTag(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.IndexFunc(s, isTagSeparator) != -1 {
+		return ""
+	}
+	if strings.IndexFunc(s, isLetterOrDigit) == -1 {
+		return ""
+	}
+	return s
+}
+
+// ParseTags parses tags separated by commas or whitespace. ParseTags
+// returns nil if s contains no tags.
+func ParseTags(s string) TagSet {
+	var result TagSet
+	for _, field := range strings.FieldsFunc(s, isTagSeparator) {
+		if tag := NormalizeTag(field); tag != "" {
+			if result == nil {
+				result = make(TagSet)
+			}
+			result[tag] = true
+		}
+	}
+	return result
+}
+
+// Contains returns true if this instance contains tag.
+func (t TagSet) Contains(tag string) bool {
+	return t[NormalizeTag(tag)]
+}
+
+// Sorted returns the tags in this instance in alphabetical order.
+func (t TagSet) Sorted() []string {
+	result := make([]string, 0, len(t))
+	for tag, ok := range t {
+		if ok {
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// String returns the tags in this instance in alphabetical order separated
+// by commas. ParseTags reverses String.
+func (t TagSet) String() string {
+	return strings.Join(t.Sorted(), ",")
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isTagSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,47 @@
+package fin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tags := ParseTags(" Vacation-2026, tax-deductible\treimbursable,,vacation-2026 ")
+	expected := TagSet{
+		"vacation-2026":  true,
+		"tax-deductible": true,
+		"reimbursable":   true}
+	if !reflect.DeepEqual(expected, tags) {
+		t.Errorf("Expected %v, got %v", expected, tags)
+	}
+	if s := tags.String(); s != "reimbursable,tax-deductible,vacation-2026" {
+		t.Errorf("Got %s", s)
+	}
+	if !reflect.DeepEqual(tags, ParseTags(tags.String())) {
+		t.Error("Expected ParseTags to reverse String")
+	}
+	if !tags.Contains("Reimbursable") {
+		t.Error("Expected tags to contain reimbursable")
+	}
+	if tags.Contains("business") {
+		t.Error("Expected tags not to contain business")
+	}
+	if ParseTags(" , ") != nil {
+		t.Error("Expected nil for no tags")
+	}
+	if s := TagSet(nil).String(); s != "" {
+		t.Errorf("Expected empty string, got %s", s)
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	if tag := NormalizeTag(" Tax-Deductible "); tag != "tax-deductible" {
+		t.Errorf("Got %s", tag)
+	}
+	if tag := NormalizeTag("two words"); tag != "" {
+		t.Errorf("Expected empty string, got %s", tag)
+	}
+	if tag := NormalizeTag("-!-"); tag != "" {
+		t.Errorf("Expected empty string, got %s", tag)
+	}
+}
@@ -12,5 +12,6 @@ require (
 	github.com/keep94/toolbox v0.1.0
 	github.com/keep94/weblogs v1.0.0
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
 	gopkg.in/yaml.v2 v2.3.0
 )